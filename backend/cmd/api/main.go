@@ -6,10 +6,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -24,11 +27,13 @@ import (
 	"github.com/SirsiMaster/assiduous/backend/pkg/config"
 	"github.com/SirsiMaster/assiduous/backend/pkg/entitlements"
 	"github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/geo"
 	"github.com/SirsiMaster/assiduous/backend/pkg/httpapi"
 	"github.com/SirsiMaster/assiduous/backend/pkg/kms"
 	"github.com/SirsiMaster/assiduous/backend/pkg/listings"
 	"github.com/SirsiMaster/assiduous/backend/pkg/lob"
 	"github.com/SirsiMaster/assiduous/backend/pkg/microflip"
+	"github.com/SirsiMaster/assiduous/backend/pkg/objectstore"
 	"github.com/SirsiMaster/assiduous/backend/pkg/opensign"
 	"github.com/SirsiMaster/assiduous/backend/pkg/plaid"
 	"github.com/SirsiMaster/assiduous/backend/pkg/sqlclient"
@@ -74,7 +79,35 @@ func main() {
 	}
 
 	microflipEngine := microflip.NewEngine()
-	listingsRegistry := listings.NewRegistry()
+	// Providers come from the file named by LISTINGS_PROVIDERS_CONFIG when
+	// set, otherwise the built-in providers are configured from env.
+	listingsRegistry, err := listings.RegistryFromEnv()
+	if err != nil {
+		log.Fatalf("[api] invalid listings provider config: %v", err)
+	}
+	// Asynchronous ingest runs started over HTTP, streamed via
+	// /api/listings/runs/{id}/events.
+	ingestTracker := listings.NewRunTracker(cfg.ProjectID, listingsRegistry)
+
+	// The in-process ingest scheduler is opt-in so local runs and one-off
+	// instances do not start pulling provider feeds on their own.
+	if os.Getenv("INGEST_SCHEDULER_ENABLED") == "true" {
+		go listings.NewScheduler(cfg.ProjectID, listingsRegistry).Run(ctx)
+	}
+
+	// Listing photos are stored in object storage (OBJECT_STORE_BUCKET, or
+	// OBJECT_STORE_DIR locally). The background media worker is opt-in too.
+	var mediaWorker *listings.MediaWorker
+	mediaStore, err := objectstore.Default(ctx)
+	if err != nil {
+		log.Printf("[api] warning: object store not configured: %v", err)
+	}
+	if mediaStore != nil {
+		mediaWorker = listings.NewMediaWorker(cfg.ProjectID, mediaStore)
+		if os.Getenv("MEDIA_WORKER_ENABLED") == "true" {
+			go mediaWorker.Run(ctx)
+		}
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
@@ -92,11 +125,62 @@ func main() {
 		})
 	})
 
+	// GET /api/media/{key}
+	// Serves stored listing photos and thumbnails. Keys are content hashes,
+	// so responses are cacheable forever.
+	r.Get("/api/media/*", func(w http.ResponseWriter, r *http.Request) {
+		if mediaStore == nil {
+			httpapi.Error(w, http.StatusNotFound, "not_found", "media not found")
+			return
+		}
+		key := "media/" + chi.URLParam(r, "*")
+		data, err := mediaStore.Get(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, objectstore.ErrNotFound) {
+				httpapi.Error(w, http.StatusNotFound, "not_found", "media not found")
+				return
+			}
+			log.Printf("[media] failed to read %s: %v", key, err)
+			httpapi.Error(w, http.StatusInternalServerError, "media_error", "failed to read media")
+			return
+		}
+		if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Write(data)
+	})
+
 	// MLS agent configuration endpoints
 	r.Route("/api/mls", func(r chi.Router) {
+		// Agents manage their own MLS settings; admins may pass ?agentId=UID.
+		mlsAgent := func(w http.ResponseWriter, r *http.Request) (string, bool) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return "", false
+			}
+			if uc.Role != "agent" && uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "insufficient role to manage MLS settings")
+				return "", false
+			}
+			agentUID := uc.UID
+			if uc.Role == "admin" {
+				if q := r.URL.Query().Get("agentId"); q != "" {
+					agentUID = q
+				}
+			}
+			if agentUID == "" {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "agentId is required")
+				return "", false
+			}
+			return agentUID, true
+		}
+
 		// GET /api/mls/connection
-		// Returns the current agent's MLS connection metadata. Admins may pass
-		// ?agentId=UID to inspect a specific agent.
+		// Returns the current agent's primary MLS connection metadata; see
+		// /api/mls/connections for every board. Admins may pass ?agentId=UID
+		// to inspect a specific agent.
 		r.Get("/connection", func(w http.ResponseWriter, r *http.Request) {
 			uc := auth.FromContext(r.Context())
 			if uc == nil {
@@ -131,7 +215,7 @@ func main() {
 		})
 
 		// PUT /api/mls/connection
-		// Upserts MLS connection metadata for the current agent. Admins can set
+		// Upserts the primary MLS connection metadata for the current agent. Admins can set
 		// configuration on behalf of an agent by including agentUid in the body.
 		r.Put("/connection", func(w http.ResponseWriter, r *http.Request) {
 			uc := auth.FromContext(r.Context())
@@ -178,6 +262,18 @@ func main() {
 				DefaultState: strings.TrimSpace(body.DefaultState),
 				Enabled:      body.Enabled,
 			}
+			// Agents with several boards edit their primary one here. Its
+			// board is fixed once set, as for /api/mls/connections/{id}.
+			if primary, err := listings.GetAgentMLSConnection(r.Context(), cfg.ProjectID, agentUID); err == nil && primary != nil {
+				conn.ID = primary.ID
+				if primary.Board != "" {
+					if conn.Board != "" && !primary.SameBoard(conn.Board) {
+						httpapi.Error(w, http.StatusBadRequest, "invalid_request", "board cannot be changed; add a new connection instead")
+						return
+					}
+					conn.Board = primary.Board
+				}
+			}
 
 			if err := listings.UpsertAgentMLSConnection(r.Context(), cfg.ProjectID, conn); err != nil {
 				log.Printf("[mls] UpsertAgentMLSConnection error for %s: %v", agentUID, err)
@@ -187,6 +283,250 @@ func main() {
 
 			httpapi.JSON(w, http.StatusOK, map[string]any{"connection": conn})
 		})
+
+		// MLS credentials are write-only: they can be stored, rotated and
+		// revoked, but only their non-secret status is ever returned. Each
+		// board connection has its own under
+		// /api/mls/connections/{id}/credentials; /api/mls/credentials holds
+		// the ones used when the agent runs ingest without a board.
+		credentialRoutes := func(r chi.Router, target func(w http.ResponseWriter, r *http.Request) (string, string, bool)) {
+			// GET .../credentials
+			// Returns whether credentials are stored, with a client id hint,
+			// version and rotation/revocation timestamps. Never the secrets.
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, connID, ok := target(w, r)
+				if !ok {
+					return
+				}
+				status, err := listings.GetAgentMLSCredentialStatus(r.Context(), cfg.ProjectID, agentUID, connID)
+				if err != nil {
+					log.Printf("[mls] GetAgentMLSCredentialStatus error for %s: %v", agentUID, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to load MLS credentials")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"credentials": status})
+			})
+
+			// PUT .../credentials
+			// Body: { "clientId": "...", "clientSecret": "...", "baseUrl": "https://..." }
+			// Stores the credentials envelope-encrypted with Cloud KMS,
+			// replacing any existing ones.
+			r.Put("/", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, connID, ok := target(w, r)
+				if !ok {
+					return
+				}
+				var body listings.MLSCredentials
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+					return
+				}
+				body.ClientID = strings.TrimSpace(body.ClientID)
+				body.BaseURL = strings.TrimSpace(body.BaseURL)
+				if err := body.Validate(); err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", err.Error())
+					return
+				}
+				status, err := listings.StoreAgentMLSCredentials(r.Context(), cfg.ProjectID, agentUID, connID, body)
+				if errors.Is(err, listings.ErrConnectionNotFound) {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "MLS connection not found")
+					return
+				}
+				if err != nil {
+					log.Printf("[mls] StoreAgentMLSCredentials error for %s: %v", agentUID, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to store MLS credentials")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"credentials": status})
+			})
+
+			// POST .../credentials/rotate-key
+			// Re-encrypts the stored credentials under a fresh data key and the
+			// current KMS key version.
+			r.Post("/rotate-key", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, connID, ok := target(w, r)
+				if !ok {
+					return
+				}
+				status, err := listings.RotateAgentMLSCredentialKey(r.Context(), cfg.ProjectID, agentUID, connID)
+				if errors.Is(err, listings.ErrCredentialsRevoked) {
+					httpapi.Error(w, http.StatusConflict, "credentials_revoked", "MLS credentials were revoked")
+					return
+				}
+				if err != nil {
+					log.Printf("[mls] RotateAgentMLSCredentialKey error for %s: %v", agentUID, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to rotate MLS credential key")
+					return
+				}
+				if status == nil {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "no MLS credentials stored")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"credentials": status})
+			})
+
+			// DELETE .../credentials
+			// Revokes the credentials: the ciphertext and wrapped key are
+			// destroyed and ingest falls back to the global MLS account.
+			r.Delete("/", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, connID, ok := target(w, r)
+				if !ok {
+					return
+				}
+				revoked, err := listings.RevokeAgentMLSCredentials(r.Context(), cfg.ProjectID, agentUID, connID)
+				if err != nil {
+					log.Printf("[mls] RevokeAgentMLSCredentials error for %s: %v", agentUID, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to revoke MLS credentials")
+					return
+				}
+				if !revoked {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "no MLS credentials stored")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"success": true})
+			})
+		}
+
+		// GET /api/mls/connections
+		// Lists every MLS board the agent belongs to.
+		r.Route("/connections", func(r chi.Router) {
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, ok := mlsAgent(w, r)
+				if !ok {
+					return
+				}
+				conns, err := listings.ListAgentMLSConnections(r.Context(), cfg.ProjectID, agentUID)
+				if err != nil {
+					log.Printf("[mls] ListAgentMLSConnections error for %s: %v", agentUID, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to load MLS connections")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"connections": conns})
+			})
+
+			// POST /api/mls/connections
+			// Body: { "board": "...", "mlsAgentId": "...", "mlsOfficeId": "...",
+			//         "defaultCity": "...", "defaultState": "...", "enabled": true }
+			// Adds a board connection. An agent can connect to each board once.
+			r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, ok := mlsAgent(w, r)
+				if !ok {
+					return
+				}
+				conn, ok := decodeMLSConnection(w, r, agentUID)
+				if !ok {
+					return
+				}
+				if conn.Board == "" {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "board is required")
+					return
+				}
+				if err := listings.CreateAgentMLSConnection(r.Context(), cfg.ProjectID, conn); err != nil {
+					if errors.Is(err, listings.ErrConnectionExists) {
+						httpapi.Error(w, http.StatusConflict, "connection_exists", err.Error())
+						return
+					}
+					log.Printf("[mls] CreateAgentMLSConnection error for %s: %v", agentUID, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to save MLS connection")
+					return
+				}
+				httpapi.JSON(w, http.StatusCreated, map[string]any{"connection": conn})
+			})
+
+			// PUT /api/mls/connections/{id}
+			// Replaces a board connection's identifiers, default region and
+			// enabled flag. The board itself cannot change.
+			r.Put("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, ok := mlsAgent(w, r)
+				if !ok {
+					return
+				}
+				id := chi.URLParam(r, "id")
+				existing, err := listings.GetAgentMLSConnectionByID(r.Context(), cfg.ProjectID, agentUID, id)
+				if err != nil {
+					log.Printf("[mls] GetAgentMLSConnectionByID error for %s: %v", id, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to load MLS connection")
+					return
+				}
+				if existing == nil {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "MLS connection not found")
+					return
+				}
+				conn, ok := decodeMLSConnection(w, r, agentUID)
+				if !ok {
+					return
+				}
+				conn.ID = id
+				// The board is part of the connection id and duplicate
+				// detection, so it is fixed once set.
+				if existing.Board != "" {
+					if conn.Board != "" && !existing.SameBoard(conn.Board) {
+						httpapi.Error(w, http.StatusBadRequest, "invalid_request", "board cannot be changed; add a new connection instead")
+						return
+					}
+					conn.Board = existing.Board
+				}
+				if err := listings.UpsertAgentMLSConnection(r.Context(), cfg.ProjectID, conn); err != nil {
+					log.Printf("[mls] UpsertAgentMLSConnection error for %s: %v", id, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to save MLS connection")
+					return
+				}
+				conn.LastSyncedAt = existing.LastSyncedAt
+				httpapi.JSON(w, http.StatusOK, map[string]any{"connection": conn})
+			})
+
+			// /api/mls/connections/{id}/credentials
+			// The board's own credentials; see credentialRoutes.
+			r.Route("/{id}/credentials", func(r chi.Router) {
+				credentialRoutes(r, func(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+					agentUID, ok := mlsAgent(w, r)
+					if !ok {
+						return "", "", false
+					}
+					id := chi.URLParam(r, "id")
+					conn, err := listings.GetAgentMLSConnectionByID(r.Context(), cfg.ProjectID, agentUID, id)
+					if err != nil {
+						log.Printf("[mls] GetAgentMLSConnectionByID error for %s: %v", id, err)
+						httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to load MLS connection")
+						return "", "", false
+					}
+					if conn == nil {
+						httpapi.Error(w, http.StatusNotFound, "not_found", "MLS connection not found")
+						return "", "", false
+					}
+					return agentUID, conn.ID, true
+				})
+			})
+
+			// DELETE /api/mls/connections/{id}
+			// Removes a board connection. Its automatic schedule is disabled on
+			// the scheduler's next agent sync.
+			r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				agentUID, ok := mlsAgent(w, r)
+				if !ok {
+					return
+				}
+				id := chi.URLParam(r, "id")
+				deleted, err := listings.DeleteAgentMLSConnection(r.Context(), cfg.ProjectID, agentUID, id)
+				if err != nil {
+					log.Printf("[mls] DeleteAgentMLSConnection error for %s: %v", id, err)
+					httpapi.Error(w, http.StatusInternalServerError, "mls_error", "failed to delete MLS connection")
+					return
+				}
+				if !deleted {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "MLS connection not found")
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			})
+		})
+
+		r.Route("/credentials", func(r chi.Router) {
+			credentialRoutes(r, func(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+				agentUID, ok := mlsAgent(w, r)
+				return agentUID, "", ok
+			})
+		})
 	})
 
 	// Crypto endpoints
@@ -241,131 +581,1318 @@ func main() {
 				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
 				return
 			}
-			if uc.Role != "admin" {
-				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+			if uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+
+			providers := listingsRegistry.Providers()
+			out := make([]map[string]any, 0, len(providers))
+			for _, p := range providers {
+				item := map[string]any{
+					"key":     string(p.Key()),
+					"name":    p.DisplayName(),
+					"enabled": p.Enabled(),
+				}
+				if typ := listingsRegistry.Type(p.Key()); typ != "" {
+					item["type"] = typ
+				}
+				if hr, ok := p.(listings.HealthReporter); ok {
+					item["health"] = hr.Health()
+				}
+				item["reconcile"] = listingsRegistry.ReconcilePolicy(p.Key())
+				item["push"] = len(listingsRegistry.PushSecrets(p.Key())) > 0
+				out = append(out, item)
+			}
+
+			httpapi.JSON(w, http.StatusOK, map[string]any{"providers": out})
+		})
+
+		// POST /api/listings/providers/{key}/test
+		// Makes a minimal live call (one listing, nothing persisted) and
+		// reports latency, HTTP status, auth validity and sample count, plus
+		// the provider's recent run history. Admin only.
+		r.Post("/providers/{key}/test", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+
+			prov, ok := listingsRegistry.Get(listings.ProviderKey(strings.ToLower(chi.URLParam(r, "key"))))
+			if !ok {
+				httpapi.Error(w, http.StatusNotFound, "unknown_provider", "unknown listings provider")
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+			defer cancel()
+			resp := map[string]any{"test": listings.TestProviderConnection(ctx, prov)}
+			if hr, ok := prov.(listings.HealthReporter); ok {
+				resp["health"] = hr.Health()
+			}
+			stats, err := listings.ProviderStats(r.Context(), cfg.ProjectID, prov.Key(), time.Now().AddDate(0, 0, -7))
+			if err != nil {
+				log.Printf("[listings] ProviderStats error for provider %s: %v", prov.Key(), err)
+			} else {
+				resp["recent"] = stats
+			}
+			httpapi.JSON(w, http.StatusOK, resp)
+		})
+
+		// GET /api/listings/providers/{key}/health?days=7
+		// Returns circuit breaker state and success/error rates from the
+		// ingest run ledger over the window. Admin only.
+		r.Get("/providers/{key}/health", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+
+			prov, ok := listingsRegistry.Get(listings.ProviderKey(strings.ToLower(chi.URLParam(r, "key"))))
+			if !ok {
+				httpapi.Error(w, http.StatusNotFound, "unknown_provider", "unknown listings provider")
+				return
+			}
+
+			days := 7
+			if d := strings.TrimSpace(r.URL.Query().Get("days")); d != "" {
+				if n, err := strconv.Atoi(d); err == nil && n > 0 && n <= 90 {
+					days = n
+				}
+			}
+			stats, err := listings.ProviderStats(r.Context(), cfg.ProjectID, prov.Key(), time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				log.Printf("[listings] ProviderStats error for provider %s: %v", prov.Key(), err)
+				httpapi.Error(w, http.StatusInternalServerError, "stats_error", "failed to load provider run history")
+				return
+			}
+
+			resp := map[string]any{
+				"provider": string(prov.Key()),
+				"enabled":  prov.Enabled(),
+				"recent":   stats,
+			}
+			if hr, ok := prov.(listings.HealthReporter); ok {
+				resp["health"] = hr.Health()
+			}
+			httpapi.JSON(w, http.StatusOK, resp)
+		})
+
+		// POST /api/listings/ingest/{provider}
+		// Triggers a one-off ingest run from a configured provider. This is
+		// restricted to admin/dev roles and is typically invoked by a
+		// Cloud Scheduler job or an internal operator. With ?dryRun=true the
+		// listings are fetched and mapped but nothing is written; the
+		// response previews each listing's create/update/skip/quarantine
+		// outcome with per-field diffs against the current documents. With
+		// ?async=true the run starts in the background and 202 returns its
+		// runId; progress streams from GET /api/listings/runs/{id}/events.
+		r.Post("/ingest/{provider}", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" && uc.Role != "agent" {
+				// For now, restrict to staff-like roles; can be tightened to dev/admin only.
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "insufficient role to trigger ingest")
+				return
+			}
+
+			providerKeyStr := chi.URLParam(r, "provider")
+			pk := listings.ProviderKey(strings.ToLower(providerKeyStr))
+			prov, ok := listingsRegistry.Get(pk)
+			if !ok {
+				httpapi.Error(w, http.StatusNotFound, "unknown_provider", "unknown listings provider")
+				return
+			}
+
+			if !prov.Enabled() {
+				httpapi.Error(w, http.StatusServiceUnavailable, "provider_not_configured", "provider is not configured; set API credentials and base URL")
+				return
+			}
+
+			var body struct {
+				Since       *time.Time             `json:"since,omitempty"`
+				Region      *listings.RegionFilter `json:"region,omitempty"`
+				Limit       int                    `json:"limit,omitempty"`
+				MaxPages    int                    `json:"maxPages,omitempty"`
+				IncludeSold bool                   `json:"includeSold,omitempty"`
+				AgentUID    string                 `json:"agentUid,omitempty"`
+				// ConnectionID limits an agent run to one of their MLS boards.
+				ConnectionID string `json:"connectionId,omitempty"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
+			}
+			// agentUid selects whose stored MLS credentials and boards are
+			// used, so agents may only name themselves.
+			if uc.Role != "admin" && body.AgentUID != "" && body.AgentUID != uc.UID {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "only admins can run ingest as another agent")
+				return
+			}
+
+			req := listings.IngestRequest{
+				Provider:     pk,
+				Limit:        body.Limit,
+				MaxPages:     body.MaxPages,
+				IncludeSold:  body.IncludeSold,
+				AgentUID:     body.AgentUID,
+				ConnectionID: body.ConnectionID,
+				Trigger:      listings.TriggerAPI,
+				TriggeredBy:  uc.UID,
+			}
+			if body.Since != nil {
+				req.Since = *body.Since
+			}
+			if body.Region != nil {
+				req.Region = *body.Region
+			}
+			if v := r.URL.Query().Get("dryRun"); v != "" {
+				dryRun, err := strconv.ParseBool(v)
+				if err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "dryRun must be true or false")
+					return
+				}
+				req.DryRun = dryRun
+			}
+			if v := r.URL.Query().Get("async"); v != "" {
+				async, err := strconv.ParseBool(v)
+				if err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "async must be true or false")
+					return
+				}
+				if async {
+					id, err := ingestTracker.Start(req)
+					if err != nil {
+						log.Printf("[listings] failed to start async ingest for provider %s: %v", prov.Key(), err)
+						httpapi.Error(w, http.StatusInternalServerError, "ingest_error", "failed to start ingest run")
+						return
+					}
+					httpapi.JSON(w, http.StatusAccepted, map[string]any{
+						"provider": string(pk),
+						"runId":    id,
+						"dryRun":   req.DryRun,
+						"events":   "/api/listings/runs/" + id + "/events",
+					})
+					return
+				}
+			}
+
+			out, err := listings.RunIngest(r.Context(), cfg.ProjectID, listingsRegistry, req)
+			if err != nil {
+				if errors.Is(err, listings.ErrNotConfigured) {
+					httpapi.Error(w, http.StatusServiceUnavailable, "provider_not_configured", "provider is not yet implemented or configured")
+					return
+				}
+				if errors.Is(err, listings.ErrCircuitOpen) {
+					httpapi.Error(w, http.StatusServiceUnavailable, "provider_unavailable", "provider is temporarily unavailable after repeated failures; retry later")
+					return
+				}
+				if errors.Is(err, listings.ErrConnectionNotFound) {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "MLS connection not found for agent")
+					return
+				}
+				if errors.Is(err, listings.ErrConnectionRequired) {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", err.Error())
+					return
+				}
+				if errors.Is(err, listings.ErrIngestPersist) {
+					log.Printf("[listings] Firestore upsert error for provider %s: %v", prov.Key(), err)
+					httpapi.Error(w, http.StatusInternalServerError, "ingest_persist_error", "failed to persist listings into Firestore")
+					return
+				}
+				log.Printf("[listings] ingest error for provider %s: %v", prov.Key(), err)
+				httpapi.Error(w, http.StatusInternalServerError, "ingest_error", "failed to fetch listings from provider")
+				return
+			}
+
+			resp := map[string]any{
+				"provider": string(out.Provider),
+				"fetched":  out.Fetched,
+				"pages":    out.Pages,
+				"nextPage": out.NextPage,
+				"runId":    out.RunID,
+			}
+			if len(out.Errors) > 0 {
+				resp["errors"] = out.Errors
+			}
+			if out.ConnectionID != "" {
+				resp["connectionId"] = out.ConnectionID
+			}
+			if len(out.Connections) > 0 {
+				resp["connections"] = out.Connections
+			}
+			if summary := out.Summary; summary != nil {
+				resp["attempted"] = summary.Attempted
+				resp["created"] = summary.Created
+				resp["updated"] = summary.Updated
+				resp["skipped"] = summary.Skipped
+				if out.DryRun {
+					resp["quarantined"] = summary.Quarantined
+					resp["historyEvents"] = summary.HistoryEvents
+					resp["previews"] = summary.Previews
+					resp["previewsTruncated"] = summary.PreviewsTruncated
+				}
+			}
+			if out.DryRun {
+				resp["dryRun"] = true
+			}
+			httpapi.JSON(w, http.StatusOK, resp)
+		})
+
+		// POST /api/listings/push/{provider}
+		// Provider-initiated updates. Requests are authenticated by an HMAC
+		// signature rather than a user token:
+		//   X-Assiduous-Timestamp: <unix seconds>
+		//   X-Assiduous-Signature: sha256=<hex HMAC-SHA256 of "{timestamp}.{body}">
+		// keyed with {PREFIX}_PUSH_SECRET. Body:
+		//   { "format": "normalized|native|reso", "listings": [ ... ] }
+		// Each record is acknowledged by index as created, updated, quarantined
+		// or rejected.
+		r.Post("/push/{provider}", func(w http.ResponseWriter, r *http.Request) {
+			pk := listings.ProviderKey(strings.ToLower(chi.URLParam(r, "provider")))
+			if _, ok := listingsRegistry.Get(pk); !ok {
+				httpapi.Error(w, http.StatusNotFound, "unknown_provider", "unknown listings provider")
+				return
+			}
+			secrets := listingsRegistry.PushSecrets(pk)
+			if len(secrets) == 0 {
+				httpapi.Error(w, http.StatusNotFound, "push_not_configured", "push is not enabled for this provider")
+				return
+			}
+
+			const maxPushBytes = 16 << 20
+			payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushBytes))
+			if err != nil {
+				httpapi.Error(w, http.StatusRequestEntityTooLarge, "invalid_request", "push body too large or unreadable")
+				return
+			}
+			ts := r.Header.Get(listings.PushTimestampHeader)
+			sig := r.Header.Get(listings.PushSignatureHeader)
+			if err := listings.VerifyPushSignature(secrets, ts, sig, payload, time.Now()); err != nil {
+				log.Printf("[listings] rejected push for provider %s: %v", pk, err)
+				httpapi.Error(w, http.StatusUnauthorized, "invalid_signature", "push signature verification failed")
+				return
+			}
+
+			var batch listings.PushBatch
+			if err := json.Unmarshal(payload, &batch); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
+			}
+			res, err := listings.IngestPush(r.Context(), cfg.ProjectID, pk, batch)
+			if err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, res)
+		})
+
+		// POST /api/listings/import?format=csv|reso_json|idx&provider=&preview=true
+		// Bulk import from a file drop. The file is sent either as the "file"
+		// part of a multipart form (with an optional "profile" JSON part for
+		// CSV/IDX column mapping) or as the raw request body. Only admins may
+		// set provider; agent imports are always written as the file provider,
+		// to property ids scoped to the agent.
+		r.Post("/import", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" && uc.Role != "agent" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "insufficient role to import listings")
+				return
+			}
+
+			params := r.URL.Query()
+			opts := listings.ImportOptions{
+				Format:   strings.ToLower(strings.TrimSpace(params.Get("format"))),
+				Provider: listings.ProviderKey(strings.ToLower(strings.TrimSpace(params.Get("provider")))),
+			}
+			preview := params.Get("preview") == "true"
+			// Importing under a feed's provider key would take over its
+			// documents and precedence rank, so only admins may do it.
+			if uc.Role != "admin" && opts.Provider != "" && opts.Provider != listings.ProviderFile {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "only admins can import as another provider")
+				return
+			}
+			if uc.Role != "admin" {
+				opts.Owner = uc.UID
+			}
+
+			const maxImportBytes = 64 << 20
+			r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+			var file io.Reader = r.Body
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+				if err := r.ParseMultipartForm(32 << 20); err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid multipart upload")
+					return
+				}
+				f, _, err := r.FormFile("file")
+				if err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "file part is required")
+					return
+				}
+				defer f.Close()
+				file = f
+				if p := r.FormValue("profile"); p != "" {
+					var profile listings.ImportProfile
+					if err := json.Unmarshal([]byte(p), &profile); err != nil {
+						httpapi.Error(w, http.StatusBadRequest, "invalid_request", "profile must be valid JSON")
+						return
+					}
+					opts.Profile = &profile
+				}
+			}
+
+			rep, err := listings.ImportListingsFile(r.Context(), cfg.ProjectID, file, opts, preview, uc.UID)
+			if err != nil {
+				if errors.Is(err, listings.ErrIngestPersist) {
+					log.Printf("[listings] file import persist error: %v", err)
+					httpapi.Error(w, http.StatusInternalServerError, "ingest_persist_error", "failed to persist listings into Firestore")
+					return
+				}
+				httpapi.Error(w, http.StatusBadRequest, "invalid_file", err.Error())
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, rep)
+		})
+
+		// POST /api/listings/remap
+		// Re-derives property fields from archived raw provider payloads after
+		// the mapping improves. Admin only.
+		// Body: { "provider": "mls", "force": false, "limit": 200 }
+		r.Post("/remap", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+
+			var body listings.RemapRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
+			}
+			body.Provider = listings.ProviderKey(strings.ToLower(string(body.Provider)))
+
+			summary, err := listings.RemapProperties(r.Context(), cfg.ProjectID, body)
+			if err != nil {
+				log.Printf("[listings] RemapProperties error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "remap_error", "failed to remap properties")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, summary)
+		})
+
+		// POST /api/listings/underwrite
+		// Body: { "propertyIds": ["..."] } or { "provider": "mls", "limit": 100 }
+		// Runs the automatic underwriting pass on demand (admin only). New and
+		// changed listings are underwritten during ingest when
+		// AUTO_UNDERWRITE_ENABLED=true.
+		r.Post("/underwrite", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+
+			var body listings.UnderwriteRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
+			}
+			body.Provider = listings.ProviderKey(strings.ToLower(string(body.Provider)))
+			if len(body.PropertyIDs) == 0 && body.Provider == "" {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "propertyIds or provider is required")
+				return
+			}
+			if len(body.PropertyIDs) > 500 {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "at most 500 propertyIds per request")
+				return
+			}
+
+			summary, err := listings.RunUnderwriting(r.Context(), cfg.ProjectID, microflipEngine, body)
+			if err != nil {
+				log.Printf("[listings] RunUnderwriting error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "underwrite_error", "failed to underwrite properties")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, summary)
+		})
+
+		// POST /api/listings/alerts/digest
+		// Sends today's daily saved search digests now. The in-process
+		// scheduler does this automatically; this hook is for Cloud Scheduler.
+		r.Post("/alerts/digest", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+
+			sent, err := listings.SendSavedSearchDigests(r.Context(), cfg.ProjectID, time.Now())
+			if err != nil {
+				log.Printf("[listings] SendSavedSearchDigests error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "digest_error", "failed to send saved search digests")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, map[string]any{"sent": sent})
+		})
+
+		// POST /api/listings/media/process?propertyId=
+		// Runs one media worker pass now, or processes a single property's
+		// photos. Requires object storage to be configured.
+		r.Post("/media/process", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+			if mediaWorker == nil {
+				httpapi.Error(w, http.StatusServiceUnavailable, "media_not_configured", "object storage is not configured")
+				return
+			}
+
+			var summary *listings.MediaSummary
+			var err error
+			if id := strings.TrimSpace(r.URL.Query().Get("propertyId")); id != "" {
+				summary, err = mediaWorker.ProcessProperty(r.Context(), id)
+			} else {
+				summary, err = mediaWorker.ProcessPending(r.Context())
+			}
+			if err != nil {
+				log.Printf("[listings] media processing error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "media_error", "failed to process listing media")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, summary)
+		})
+
+		// Listings that failed data-quality validation (admin only). They are
+		// held in listing_quarantine until fixed and released, or discarded.
+		r.Route("/quarantine", func(r chi.Router) {
+			requireAdmin := func(w http.ResponseWriter, r *http.Request) (*auth.UserContext, bool) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return nil, false
+				}
+				if uc.Role != "admin" {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+					return nil, false
+				}
+				return uc, true
+			}
+			quarantineError := func(w http.ResponseWriter, id string, err error) {
+				if errors.Is(err, listings.ErrQuarantineResolved) {
+					httpapi.Error(w, http.StatusConflict, "already_resolved", err.Error())
+					return
+				}
+				log.Printf("[listings] quarantine error for %s: %v", id, err)
+				httpapi.Error(w, http.StatusInternalServerError, "quarantine_error", "failed to update quarantined listing")
+			}
+
+			// GET /api/listings/quarantine?status=pending|released|discarded&provider=&limit=
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := requireAdmin(w, r); !ok {
+					return
+				}
+				params := r.URL.Query()
+				f := listings.QuarantineFilter{
+					Status:   strings.TrimSpace(params.Get("status")),
+					Provider: listings.ProviderKey(strings.ToLower(strings.TrimSpace(params.Get("provider")))),
+				}
+				if v := params.Get("limit"); v != "" {
+					n, err := strconv.Atoi(v)
+					if err != nil || n <= 0 || n > 500 {
+						httpapi.Error(w, http.StatusBadRequest, "invalid_request", "limit must be between 1 and 500")
+						return
+					}
+					f.Limit = n
+				}
+				items, err := listings.ListQuarantinedListings(r.Context(), cfg.ProjectID, f)
+				if err != nil {
+					log.Printf("[listings] ListQuarantinedListings error: %v", err)
+					httpapi.Error(w, http.StatusInternalServerError, "quarantine_error", "failed to list quarantined listings")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"listings": items})
+			})
+
+			// GET /api/listings/quarantine/{id}
+			r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := requireAdmin(w, r); !ok {
+					return
+				}
+				id := chi.URLParam(r, "id")
+				ql, err := listings.GetQuarantinedListing(r.Context(), cfg.ProjectID, id)
+				if err != nil {
+					quarantineError(w, id, err)
+					return
+				}
+				if ql == nil {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "quarantined listing not found")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, ql)
+			})
+
+			// PATCH /api/listings/quarantine/{id}
+			// Body: partial ExternalListing JSON, e.g. { "address": { "city": "Austin" } }.
+			// Applies the fix and re-validates; the listing stays quarantined
+			// until released.
+			r.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := requireAdmin(w, r); !ok {
+					return
+				}
+				id := chi.URLParam(r, "id")
+				patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+				if err != nil || !json.Valid(patch) {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+					return
+				}
+				ql, err := listings.FixQuarantinedListing(r.Context(), cfg.ProjectID, id, patch)
+				if err != nil {
+					quarantineError(w, id, err)
+					return
+				}
+				if ql == nil {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "quarantined listing not found")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, ql)
+			})
+
+			// POST /api/listings/quarantine/{id}/release
+			// Writes the listing into properties as it stands, even if it still
+			// fails validation.
+			r.Post("/{id}/release", func(w http.ResponseWriter, r *http.Request) {
+				uc, ok := requireAdmin(w, r)
+				if !ok {
+					return
+				}
+				id := chi.URLParam(r, "id")
+				summary, err := listings.ReleaseQuarantinedListing(r.Context(), cfg.ProjectID, id, uc.UID)
+				if err != nil {
+					quarantineError(w, id, err)
+					return
+				}
+				if summary == nil {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "quarantined listing not found")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"status": listings.QuarantineReleased, "summary": summary})
+			})
+
+			// POST /api/listings/quarantine/{id}/discard
+			r.Post("/{id}/discard", func(w http.ResponseWriter, r *http.Request) {
+				uc, ok := requireAdmin(w, r)
+				if !ok {
+					return
+				}
+				id := chi.URLParam(r, "id")
+				found, err := listings.DiscardQuarantinedListing(r.Context(), cfg.ProjectID, id, uc.UID)
+				if err != nil {
+					quarantineError(w, id, err)
+					return
+				}
+				if !found {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "quarantined listing not found")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"status": listings.QuarantineDiscarded})
+			})
+		})
+
+		// Ingest run ledger (admin only). Every ingest, whether triggered over
+		// HTTP or by the scheduler, is recorded in ingest_runs. The events and
+		// cancel endpoints are also open to the user who started the run.
+		r.Route("/runs", func(r chi.Router) {
+			// GET /api/listings/runs?provider=&trigger=&status=&agentUid=&since=&until=&limit=
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return
+				}
+				if uc.Role != "admin" {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+					return
+				}
+
+				params := r.URL.Query()
+				filter := listings.RunFilter{
+					Provider: listings.ProviderKey(strings.ToLower(strings.TrimSpace(params.Get("provider")))),
+					Trigger:  strings.TrimSpace(params.Get("trigger")),
+					Status:   strings.TrimSpace(params.Get("status")),
+					AgentUID: strings.TrimSpace(params.Get("agentUid")),
+				}
+				for key, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+					if v := strings.TrimSpace(params.Get(key)); v != "" {
+						t, err := time.Parse(time.RFC3339, v)
+						if err != nil {
+							httpapi.Error(w, http.StatusBadRequest, "invalid_request", key+" must be an RFC3339 timestamp")
+							return
+						}
+						*dst = t
+					}
+				}
+				if l := strings.TrimSpace(params.Get("limit")); l != "" {
+					if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 500 {
+						filter.Limit = n
+					}
+				}
+
+				items, err := listings.ListIngestRuns(r.Context(), cfg.ProjectID, filter)
+				if err != nil {
+					log.Printf("[listings] ListIngestRuns error: %v", err)
+					httpapi.Error(w, http.StatusInternalServerError, "run_error", "failed to list ingest runs")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"runs": items})
+			})
+
+			// GET /api/listings/runs/{id}
+			r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return
+				}
+				if uc.Role != "admin" {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+					return
+				}
+
+				id := chi.URLParam(r, "id")
+				run, err := listings.GetIngestRun(r.Context(), cfg.ProjectID, id)
+				if err != nil {
+					log.Printf("[listings] GetIngestRun %s error: %v", id, err)
+					httpapi.Error(w, http.StatusInternalServerError, "run_error", "failed to load ingest run")
+					return
+				}
+				if run == nil {
+					httpapi.Error(w, http.StatusNotFound, "not_found", "ingest run not found")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, run)
+			})
+
+			// GET /api/listings/runs/{id}/events
+			// Streams a run's progress as Server-Sent Events: started, page,
+			// upserted and error events while it runs, then a final done,
+			// failed or cancelled event carrying the outcome. Reconnects
+			// resume after Last-Event-ID. Runs not tracked by this instance
+			// get their ledger entry as a single "run" event. Admins and the
+			// user who started the run may stream it.
+			r.Get("/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return
+				}
+
+				id := chi.URLParam(r, "id")
+				var ledger *listings.IngestRun
+				if by, ok := ingestTracker.TriggeredBy(id); ok {
+					if uc.Role != "admin" && by != uc.UID {
+						httpapi.Error(w, http.StatusForbidden, "forbidden", "not allowed to view this ingest run")
+						return
+					}
+				} else {
+					run, err := listings.GetIngestRun(r.Context(), cfg.ProjectID, id)
+					if err != nil {
+						log.Printf("[listings] GetIngestRun %s error: %v", id, err)
+						httpapi.Error(w, http.StatusInternalServerError, "run_error", "failed to load ingest run")
+						return
+					}
+					if run == nil {
+						httpapi.Error(w, http.StatusNotFound, "not_found", "ingest run not found")
+						return
+					}
+					if uc.Role != "admin" && run.TriggeredBy != uc.UID {
+						httpapi.Error(w, http.StatusForbidden, "forbidden", "not allowed to view this ingest run")
+						return
+					}
+					ledger = run
+				}
+
+				flusher, ok := w.(http.Flusher)
+				if !ok {
+					httpapi.Error(w, http.StatusInternalServerError, "streaming_unsupported", "streaming is not supported")
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+				w.Header().Set("Connection", "keep-alive")
+				w.Header().Set("X-Accel-Buffering", "no")
+				w.WriteHeader(http.StatusOK)
+				send := func(seq int, event string, data any) {
+					b, err := json.Marshal(data)
+					if err != nil {
+						log.Printf("[listings] failed to encode run %s event: %v", id, err)
+						return
+					}
+					fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", seq, event, b)
+					flusher.Flush()
+				}
+
+				if ledger != nil {
+					send(1, "run", ledger)
+					return
+				}
+
+				after := 0
+				if v := r.Header.Get("Last-Event-ID"); v != "" {
+					if n, err := strconv.Atoi(v); err == nil {
+						after = n
+					}
+				}
+				heartbeat := time.NewTicker(15 * time.Second)
+				defer heartbeat.Stop()
+				for {
+					events, finished, changed, ok := ingestTracker.Events(id, after)
+					if !ok {
+						return
+					}
+					for _, ev := range events {
+						send(ev.Seq, ev.Type, ev)
+						after = ev.Seq
+					}
+					if finished {
+						return
+					}
+					select {
+					case <-r.Context().Done():
+						return
+					case <-changed:
+					case <-heartbeat.C:
+						fmt.Fprint(w, ": ping\n\n")
+						flusher.Flush()
+					}
+				}
+			})
+
+			// POST /api/listings/runs/{id}/cancel
+			// Stops a running asynchronous ingest. Pages already persisted are
+			// kept and the ledger entry is marked cancelled. Admins and the
+			// user who started the run may cancel it.
+			r.Post("/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return
+				}
+
+				id := chi.URLParam(r, "id")
+				by, ok := ingestTracker.TriggeredBy(id)
+				if !ok {
+					run, err := listings.GetIngestRun(r.Context(), cfg.ProjectID, id)
+					if err != nil {
+						log.Printf("[listings] GetIngestRun %s error: %v", id, err)
+						httpapi.Error(w, http.StatusInternalServerError, "run_error", "failed to load ingest run")
+						return
+					}
+					if run != nil && run.Status != listings.RunStatusRunning {
+						httpapi.Error(w, http.StatusConflict, "run_not_active", "ingest run has already finished")
+						return
+					}
+					httpapi.Error(w, http.StatusNotFound, "not_found", "ingest run is not running on this instance")
+					return
+				}
+				if uc.Role != "admin" && by != uc.UID {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "not allowed to cancel this ingest run")
+					return
+				}
+				if _, err := ingestTracker.Cancel(id); err != nil {
+					httpapi.Error(w, http.StatusConflict, "run_not_active", "ingest run has already finished")
+					return
+				}
+				httpapi.JSON(w, http.StatusAccepted, map[string]any{"id": id, "status": "cancelling"})
+			})
+		})
+
+		// Ingest schedule endpoints (admin only). Schedules are executed by the
+		// in-process scheduler when INGEST_SCHEDULER_ENABLED=true.
+		r.Route("/schedules", func(r chi.Router) {
+			// GET /api/listings/schedules
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return
+				}
+				if uc.Role != "admin" {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+					return
+				}
+
+				items, err := listings.ListIngestSchedules(r.Context(), cfg.ProjectID)
+				if err != nil {
+					log.Printf("[listings] ListIngestSchedules error: %v", err)
+					httpapi.Error(w, http.StatusInternalServerError, "schedule_error", "failed to list schedules")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"schedules": items})
+			})
+
+			// POST /api/listings/schedules
+			// PUT /api/listings/schedules/{id}
+			// Body: { "cron": "0 */6 * * *", "provider": "mls", "region": {...},
+			//         "agentUid": "...", "limit": 100, "maxPages": 5, "enabled": true }
+			saveSchedule := func(w http.ResponseWriter, r *http.Request) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return
+				}
+				if uc.Role != "admin" {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+					return
+				}
+
+				var body listings.IngestSchedule
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+					return
+				}
+				sched := &listings.IngestSchedule{
+					ID:           chi.URLParam(r, "id"),
+					Cron:         strings.TrimSpace(body.Cron),
+					TimeZone:     strings.TrimSpace(body.TimeZone),
+					Provider:     listings.ProviderKey(strings.ToLower(string(body.Provider))),
+					Region:       body.Region,
+					AgentUID:     strings.TrimSpace(body.AgentUID),
+					ConnectionID: strings.TrimSpace(body.ConnectionID),
+					Limit:        body.Limit,
+					MaxPages:     body.MaxPages,
+					IncludeSold:  body.IncludeSold,
+					Enabled:      body.Enabled,
+				}
+				if sched.ID != "" {
+					existing, err := listings.GetIngestSchedule(r.Context(), cfg.ProjectID, sched.ID)
+					if err != nil {
+						log.Printf("[listings] GetIngestSchedule error for %s: %v", sched.ID, err)
+						httpapi.Error(w, http.StatusInternalServerError, "schedule_error", "failed to load schedule")
+						return
+					}
+					if existing == nil {
+						httpapi.Error(w, http.StatusNotFound, "not_found", "schedule not found")
+						return
+					}
+					sched.Auto = existing.Auto
+					sched.CreatedAt = existing.CreatedAt
+				}
+				if _, ok := listingsRegistry.Get(sched.Provider); !ok {
+					httpapi.Error(w, http.StatusBadRequest, "unknown_provider", "unknown listings provider")
+					return
+				}
+
+				if err := listings.SaveIngestSchedule(r.Context(), cfg.ProjectID, sched); err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_schedule", err.Error())
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"schedule": sched})
+			}
+			r.Post("/", saveSchedule)
+			r.Put("/{id}", saveSchedule)
+
+			// DELETE /api/listings/schedules/{id}
+			r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+				uc := auth.FromContext(r.Context())
+				if uc == nil {
+					httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+					return
+				}
+				if uc.Role != "admin" {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+					return
+				}
+
+				id := chi.URLParam(r, "id")
+				if err := listings.DeleteIngestSchedule(r.Context(), cfg.ProjectID, id); err != nil {
+					log.Printf("[listings] DeleteIngestSchedule error for %s: %v", id, err)
+					httpapi.Error(w, http.StatusInternalServerError, "schedule_error", "failed to delete schedule")
+					return
+				}
+				httpapi.JSON(w, http.StatusOK, map[string]any{"success": true})
+			})
+		})
+	})
+
+	// Property endpoints (our own inventory in the properties collection)
+	r.Route("/api/properties", func(r chi.Router) {
+		// GET /api/properties
+		// Filterable, sortable property list with cursor pagination.
+		// Filters: type, status, city, state, zip, source, dealQuality,
+		// minPrice/maxPrice, minBeds/maxBeds, minBaths/maxBaths, minSqft/maxSqft.
+		// sort=price|updatedAt|pricePerSqft|dealQuality, prefixed with "-" for
+		// descending (default -updatedAt). dealQuality ascending lists the best
+		// automatic underwriting grades first. Pass nextCursor back as cursor for the next page.
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+
+			params := r.URL.Query()
+			q := firestore.PropertyQuery{
+				Type:   strings.TrimSpace(params.Get("type")),
+				Status: strings.TrimSpace(params.Get("status")),
+				City:   strings.TrimSpace(params.Get("city")),
+				State:  strings.TrimSpace(params.Get("state")),
+				Zip:    strings.TrimSpace(params.Get("zip")),
+				Source: strings.TrimSpace(params.Get("source")),
+				Cursor: strings.TrimSpace(params.Get("cursor")),
+
+				DealQuality: strings.TrimSpace(params.Get("dealQuality")),
+			}
+			if l := strings.TrimSpace(params.Get("limit")); l != "" {
+				if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 500 {
+					q.Limit = n
+				}
+			}
+			if s := strings.TrimSpace(params.Get("sort")); s != "" {
+				q.Sort = strings.TrimPrefix(s, "-")
+				q.Desc = strings.HasPrefix(s, "-")
+				switch q.Sort {
+				case firestore.SortPrice, firestore.SortUpdatedAt, firestore.SortPricePerSqft, firestore.SortDealQuality:
+				default:
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "sort must be price, updatedAt, pricePerSqft or dealQuality")
+					return
+				}
+			}
+			var badParam string
+			float := func(key string) float64 {
+				v := strings.TrimSpace(params.Get(key))
+				if v == "" {
+					return 0
+				}
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil || f < 0 {
+					badParam = key
+				}
+				return f
+			}
+			q.MinPrice, q.MaxPrice = float("minPrice"), float("maxPrice")
+			q.MinBeds, q.MaxBeds = int(float("minBeds")), int(float("maxBeds"))
+			q.MinBaths, q.MaxBaths = float("minBaths"), float("maxBaths")
+			q.MinSqft, q.MaxSqft = int(float("minSqft")), int(float("maxSqft"))
+			if badParam != "" {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", badParam+" must be a non-negative number")
+				return
+			}
+
+			client, err := firestore.Client(r.Context(), cfg.ProjectID)
+			if err != nil {
+				log.Printf("[properties] Firestore client error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to list properties")
+				return
+			}
+			page, err := firestore.NewPropertyRepository(client).List(r.Context(), q)
+			if errors.Is(err, firestore.ErrInvalidCursor) {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_cursor", "cursor is invalid or does not match the sort")
+				return
+			}
+			if err != nil {
+				log.Printf("[properties] List error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "list_error", "failed to list properties")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, page)
+		})
+
+		// GET /api/properties/search
+		// Geo search over our own inventory. Select the area with one of:
+		//   bbox=south,west,north,east
+		//   lat=..&lng=..&radius=meters
+		//   polygon=lat,lng;lat,lng;lat,lng
+		// Optional: status, limit (<=500), sort=distance|none, nearLat/nearLng.
+		// "truncated" is true when a dense area had more candidates than were
+		// scanned; narrow the area for complete results.
+		r.Get("/search", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+
+			params := r.URL.Query()
+			q := firestore.GeoQuery{
+				Status:         strings.TrimSpace(params.Get("status")),
+				SortByDistance: params.Get("sort") != "none",
+			}
+			if l := strings.TrimSpace(params.Get("limit")); l != "" {
+				if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 500 {
+					q.Limit = n
+				}
+			}
+			point := func(latKey, lngKey string) (*geo.Point, error) {
+				lat, err1 := strconv.ParseFloat(params.Get(latKey), 64)
+				lng, err2 := strconv.ParseFloat(params.Get(lngKey), 64)
+				if err1 != nil || err2 != nil {
+					return nil, fmt.Errorf("%s and %s must be numbers", latKey, lngKey)
+				}
+				return &geo.Point{Lat: lat, Lng: lng}, nil
+			}
+
+			var err error
+			switch {
+			case params.Get("polygon") != "":
+				q.Polygon, err = geo.ParsePolygon(params.Get("polygon"))
+			case params.Get("radius") != "":
+				if q.Center, err = point("lat", "lng"); err == nil {
+					q.RadiusMeters, err = strconv.ParseFloat(params.Get("radius"), 64)
+				}
+			case params.Get("bbox") != "":
+				var b geo.BBox
+				if b, err = geo.ParseBBox(params.Get("bbox")); err == nil {
+					q.BBox = &b
+				}
+			default:
+				err = fmt.Errorf("one of bbox, radius (with lat/lng) or polygon is required")
+			}
+			if err == nil && params.Get("nearLat") != "" {
+				q.Near, err = point("nearLat", "nearLng")
+			}
+			if err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+
+			client, err := firestore.Client(r.Context(), cfg.ProjectID)
+			if err != nil {
+				log.Printf("[properties] Firestore client error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to search properties")
+				return
+			}
+			res, err := firestore.NewPropertyRepository(client).SearchGeo(r.Context(), q)
+			if err != nil {
+				log.Printf("[properties] SearchGeo error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "search_error", "failed to search properties")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, map[string]any{"results": res.Hits, "count": len(res.Hits), "truncated": res.Truncated})
+		})
+
+		// GET /api/properties/{id}/history
+		// Returns the price/status change events recorded by ingest for a
+		// property along with days-on-market and price-cut statistics.
+		r.Get("/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+
+			id := chi.URLParam(r, "id")
+			if strings.TrimSpace(id) == "" {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "property id is required")
 				return
 			}
 
-			providers := listingsRegistry.Providers()
-			out := make([]map[string]any, 0, len(providers))
-			for _, p := range providers {
-				out = append(out, map[string]any{
-					"key":     string(p.Key()),
-					"name":    p.DisplayName(),
-					"enabled": p.Enabled(),
-				})
+			events, err := listings.ListPropertyHistory(r.Context(), cfg.ProjectID, id)
+			if err != nil {
+				log.Printf("[properties] ListPropertyHistory error for %s: %v", id, err)
+				httpapi.Error(w, http.StatusInternalServerError, "history_error", "failed to load property history")
+				return
 			}
 
-			httpapi.JSON(w, http.StatusOK, map[string]any{"providers": out})
+			httpapi.JSON(w, http.StatusOK, listings.BuildPropertyTimeline(id, events, time.Now()))
 		})
 
-		// POST /api/listings/ingest/{provider}
-		// Triggers a one-off ingest run from a configured provider. This is
-		// restricted to admin/dev roles and is typically invoked by a
-		// Cloud Scheduler job or an internal operator.
-		r.Post("/ingest/{provider}", func(w http.ResponseWriter, r *http.Request) {
+		// GET /api/properties/{id}/provenance
+		// Returns which provider set each field of a merged property and when,
+		// the providers contributing to it, and the precedence policy applied.
+		r.Get("/{id}/provenance", func(w http.ResponseWriter, r *http.Request) {
 			uc := auth.FromContext(r.Context())
 			if uc == nil {
 				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
 				return
 			}
-			if uc.Role != "admin" && uc.Role != "agent" {
-				// For now, restrict to staff-like roles; can be tightened to dev/admin only.
-				httpapi.Error(w, http.StatusForbidden, "forbidden", "insufficient role to trigger ingest")
+
+			id := chi.URLParam(r, "id")
+			if strings.TrimSpace(id) == "" {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "property id is required")
 				return
 			}
 
-			providerKeyStr := chi.URLParam(r, "provider")
-			pk := listings.ProviderKey(strings.ToLower(providerKeyStr))
-			prov, ok := listingsRegistry.Get(pk)
-			if !ok {
-				httpapi.Error(w, http.StatusNotFound, "unknown_provider", "unknown listings provider")
+			prov, err := listings.GetPropertyProvenance(r.Context(), cfg.ProjectID, id)
+			if err != nil {
+				log.Printf("[properties] GetPropertyProvenance error for %s: %v", id, err)
+				httpapi.Error(w, http.StatusInternalServerError, "provenance_error", "failed to load property provenance")
 				return
 			}
-
-			if !prov.Enabled() {
-				httpapi.Error(w, http.StatusServiceUnavailable, "provider_not_configured", "provider is not configured; set API credentials and base URL")
+			if prov == nil {
+				httpapi.Error(w, http.StatusNotFound, "not_found", "property not found")
 				return
 			}
+			httpapi.JSON(w, http.StatusOK, prov)
+		})
 
-			var body struct {
-				Since       *time.Time             `json:"since,omitempty"`
-				Region      *listings.RegionFilter `json:"region,omitempty"`
-				Limit       int                    `json:"limit,omitempty"`
-				MaxPages    int                    `json:"maxPages,omitempty"`
-				IncludeSold bool                   `json:"includeSold,omitempty"`
-				AgentUID    string                 `json:"agentUid,omitempty"`
+		// Agents create, edit and withdraw their own pocket and off-market
+		// listings; admins may act on any property.
+		propertyActor := func(w http.ResponseWriter, r *http.Request) (listings.PropertyActor, bool) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return listings.PropertyActor{}, false
 			}
-			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
-				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+			if uc.Role != "agent" && uc.Role != "admin" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "insufficient role to manage properties")
+				return listings.PropertyActor{}, false
+			}
+			return listings.PropertyActor{UID: uc.UID, Admin: uc.Role == "admin"}, true
+		}
+		propertyWriteError := func(w http.ResponseWriter, id string, err error) {
+			var verr *listings.PropertyValidationError
+			switch {
+			case errors.As(err, &verr):
+				httpapi.Error(w, http.StatusBadRequest, "invalid_property", verr.Error())
+			case errors.Is(err, listings.ErrPropertyNotFound):
+				httpapi.Error(w, http.StatusNotFound, "not_found", "property not found")
+			case errors.Is(err, listings.ErrNotPropertyOwner):
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "property belongs to another agent")
+			case errors.Is(err, listings.ErrNotAgentProperty):
+				httpapi.Error(w, http.StatusConflict, "not_agent_property", "only properties created through the API can be deleted; withdraw it instead")
+			default:
+				log.Printf("[properties] write error for %s: %v", id, err)
+				httpapi.Error(w, http.StatusInternalServerError, "property_error", "failed to save property")
+			}
+		}
+
+		// POST /api/properties
+		// Creates an agent-owned listing from a RESO-named property body
+		// (ListPrice, StandardStatus, Address, ...). The caller owns it; admins
+		// may set AgentId. Status defaults to active.
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			actor, ok := propertyActor(w, r)
+			if !ok {
 				return
 			}
 
-			params := listings.FetchParams{
-				Limit:       body.Limit,
-				MaxPages:    body.MaxPages,
-				IncludeSold: body.IncludeSold,
+			var body firestore.Property
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
 			}
-			if body.Since != nil {
-				params.Since = *body.Since
+			if err := listings.CreateAgentProperty(r.Context(), cfg.ProjectID, actor, &body); err != nil {
+				propertyWriteError(w, "new property", err)
+				return
 			}
-			if body.Region != nil {
-				params.Region = *body.Region
-			}
-			// If this is an MLS ingest scoped to a specific agent and no explicit
-			// region was provided, fall back to that agent's MLS defaults.
-			if params.Region == (listings.RegionFilter{}) && body.AgentUID != "" && pk == listings.ProviderMLS {
-				if conn, err := listings.GetAgentMLSConnection(r.Context(), cfg.ProjectID, body.AgentUID); err == nil && conn != nil {
-					if conn.DefaultCity != "" || conn.DefaultState != "" {
-						params.Region = listings.RegionFilter{
-							City:  conn.DefaultCity,
-							State: conn.DefaultState,
-						}
-					}
-				}
+			httpapi.JSON(w, http.StatusCreated, body)
+		})
+
+		// GET /api/properties/{id}
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
 			}
 
-			res, err := prov.FetchListings(r.Context(), params)
+			id := chi.URLParam(r, "id")
+			client, err := firestore.Client(r.Context(), cfg.ProjectID)
 			if err != nil {
-				if errors.Is(err, listings.ErrNotConfigured) {
-					httpapi.Error(w, http.StatusServiceUnavailable, "provider_not_configured", "provider is not yet implemented or configured")
-					return
-				}
-				log.Printf("[listings] ingest error for provider %s: %v", prov.Key(), err)
-				httpapi.Error(w, http.StatusInternalServerError, "ingest_error", "failed to fetch listings from provider")
+				log.Printf("[properties] Firestore client error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to load property")
+				return
+			}
+			p, err := firestore.NewPropertyRepository(client).GetByID(r.Context(), id)
+			if firestore.IsNotFound(err) {
+				httpapi.Error(w, http.StatusNotFound, "not_found", "property not found")
+				return
+			}
+			if err != nil {
+				log.Printf("[properties] GetByID error for %s: %v", id, err)
+				httpapi.Error(w, http.StatusInternalServerError, "property_error", "failed to load property")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, p)
+		})
+
+		// PATCH /api/properties/{id}
+		// Updates the fields present in a RESO-named property body. Fields an
+		// agent sets are kept over later provider feeds.
+		r.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			actor, ok := propertyActor(w, r)
+			if !ok {
 				return
 			}
 
-			// Normalize into Firestore properties collection. This helper is
-			// intentionally conservative and uses Merge semantics so existing
-			// documents created by legacy flows are not clobbered.
-			summary, err := listings.UpsertExternalListingsToFirestore(r.Context(), cfg.ProjectID, res)
+			id := chi.URLParam(r, "id")
+			var body firestore.Property
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
+			}
+			p, err := listings.UpdateAgentProperty(r.Context(), cfg.ProjectID, actor, id, &body)
 			if err != nil {
-				log.Printf("[listings] Firestore upsert error for provider %s: %v", prov.Key(), err)
-				httpapi.Error(w, http.StatusInternalServerError, "ingest_persist_error", "failed to persist listings into Firestore")
+				propertyWriteError(w, id, err)
 				return
 			}
+			httpapi.JSON(w, http.StatusOK, p)
+		})
 
-			// Record a lightweight sync heartbeat for agent-scoped MLS ingests so
-			// admin tooling can display "last MLS sync" per agent.
-			if body.AgentUID != "" && pk == listings.ProviderMLS {
-				if err := listings.TouchAgentMLSLastSynced(r.Context(), cfg.ProjectID, body.AgentUID, time.Now()); err != nil {
-					log.Printf("[listings] failed to touch MLS lastSyncedAt for agent %s: %v", body.AgentUID, err)
-				}
+		// DELETE /api/properties/{id}
+		// Withdraws the property (status off_market). With ?purge=true an
+		// admin removes a property created through the API outright.
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			actor, ok := propertyActor(w, r)
+			if !ok {
+				return
 			}
 
-			resp := map[string]any{
-				"provider": string(res.Provider),
-				"fetched":  len(res.Listings),
-				"nextPage": res.NextPage,
+			id := chi.URLParam(r, "id")
+			if r.URL.Query().Get("purge") == "true" {
+				if !actor.Admin {
+					httpapi.Error(w, http.StatusForbidden, "forbidden", "only admins can purge properties")
+					return
+				}
+				if err := listings.DeleteAgentProperty(r.Context(), cfg.ProjectID, actor, id); err != nil {
+					propertyWriteError(w, id, err)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
 			}
-			if summary != nil {
-				resp["attempted"] = summary.Attempted
-				resp["created"] = summary.Created
-				resp["updated"] = summary.Updated
-				resp["skipped"] = summary.Skipped
+			p, err := listings.WithdrawAgentProperty(r.Context(), cfg.ProjectID, actor, id)
+			if err != nil {
+				propertyWriteError(w, id, err)
+				return
 			}
-			httpapi.JSON(w, http.StatusOK, resp)
+			httpapi.JSON(w, http.StatusOK, p)
 		})
 	})
 
@@ -1242,6 +2769,279 @@ func main() {
 		})
 	})
 
+	// Saved search endpoints (user-scoped). Matches from ingest are delivered
+	// through the notifications collection.
+	r.Route("/api/saved-searches", func(r chi.Router) {
+		// GET /api/saved-searches
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+
+			searches, err := listings.ListSavedSearches(r.Context(), cfg.ProjectID, uc.UID)
+			if err != nil {
+				log.Printf("[saved-searches] list error for user %s: %v", uc.UID, err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to load saved searches")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, map[string]any{"searches": searches})
+		})
+
+		// POST /api/saved-searches
+		// Body: { "name": "...", "criteria": { ... }, "frequency": "instant|daily" }
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+
+			var body listings.SavedSearch
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
+			}
+			body.UserID = uc.UID
+			body.DealID = ""
+			if err := body.Validate(); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+			if err := listings.CreateSavedSearch(r.Context(), cfg.ProjectID, &body); err != nil {
+				log.Printf("[saved-searches] create error for user %s: %v", uc.UID, err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to save search")
+				return
+			}
+			httpapi.JSON(w, http.StatusCreated, body)
+		})
+
+		// DELETE /api/saved-searches/{id}
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+
+			ok, err := listings.DeleteSavedSearch(r.Context(), cfg.ProjectID, uc.UID, chi.URLParam(r, "id"))
+			if err != nil {
+				log.Printf("[saved-searches] delete error for user %s: %v", uc.UID, err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to delete saved search")
+				return
+			}
+			if !ok {
+				httpapi.Error(w, http.StatusNotFound, "not_found", "saved search not found")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, map[string]any{"success": true})
+		})
+	})
+
+	// Outbound syndication feeds of agent-owned properties. Agents manage
+	// feeds of their own listings; admins may create feeds across agents.
+	// Portals fetch GET /api/feeds/{id}/{format}?token=... with the token
+	// returned when the feed was created.
+	r.Route("/api/feeds", func(r chi.Router) {
+		// GET /api/feeds
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" && uc.Role != "agent" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "agent or admin role required")
+				return
+			}
+
+			owner := uc.UID
+			if uc.Role == "admin" {
+				owner = r.URL.Query().Get("ownerUid")
+			}
+			feeds, err := listings.ListSyndicationFeeds(r.Context(), cfg.ProjectID, owner)
+			if err != nil {
+				log.Printf("[feeds] list error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to load feeds")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, map[string]any{"feeds": feeds})
+		})
+
+		// POST /api/feeds
+		// Body: { "name": "...", "agentId": "...", "statuses": ["active", "pending"] }
+		// The response carries the feed token once; store it with the portal.
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			if uc.Role != "admin" && uc.Role != "agent" {
+				httpapi.Error(w, http.StatusForbidden, "forbidden", "agent or admin role required")
+				return
+			}
+
+			var body listings.SyndicationFeed
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+				return
+			}
+			body.OwnerUID = uc.UID
+			if uc.Role != "admin" {
+				body.AgentID = uc.UID
+			}
+			if err := body.Validate(); err != nil {
+				httpapi.Error(w, http.StatusBadRequest, "invalid_request", err.Error())
+				return
+			}
+			token, err := listings.CreateSyndicationFeed(r.Context(), cfg.ProjectID, &body)
+			if err != nil {
+				log.Printf("[feeds] create error for user %s: %v", uc.UID, err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to create feed")
+				return
+			}
+			urls := map[string]string{}
+			for _, format := range listings.FeedFormats {
+				urls[format] = "/api/feeds/" + body.ID + "/" + format + "?token=" + token
+			}
+			httpapi.JSON(w, http.StatusCreated, map[string]any{"feed": body, "token": token, "urls": urls})
+		})
+
+		// loadOwnedFeed loads a feed the caller may manage, writing the error
+		// response and returning nil otherwise.
+		loadOwnedFeed := func(w http.ResponseWriter, r *http.Request) *listings.SyndicationFeed {
+			uc := auth.FromContext(r.Context())
+			if uc == nil {
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return nil
+			}
+			feed, err := listings.GetSyndicationFeed(r.Context(), cfg.ProjectID, chi.URLParam(r, "id"))
+			if err != nil {
+				log.Printf("[feeds] get error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to load feed")
+				return nil
+			}
+			if feed == nil || (uc.Role != "admin" && feed.OwnerUID != uc.UID) {
+				httpapi.Error(w, http.StatusNotFound, "not_found", "feed not found")
+				return nil
+			}
+			return feed
+		}
+
+		// DELETE /api/feeds/{id}
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			feed := loadOwnedFeed(w, r)
+			if feed == nil {
+				return
+			}
+			if err := listings.DeleteSyndicationFeed(r.Context(), cfg.ProjectID, feed.ID); err != nil {
+				log.Printf("[feeds] delete error for feed %s: %v", feed.ID, err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to delete feed")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, map[string]any{"success": true})
+		})
+
+		// POST /api/feeds/{id}/generate?full=true
+		// Regenerates a feed now instead of waiting for its next fetch.
+		// Without full only properties changed since the last generation
+		// are read.
+		r.Post("/{id}/generate", func(w http.ResponseWriter, r *http.Request) {
+			feed := loadOwnedFeed(w, r)
+			if feed == nil {
+				return
+			}
+			full := false
+			if v := r.URL.Query().Get("full"); v != "" {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					httpapi.Error(w, http.StatusBadRequest, "invalid_request", "full must be true or false")
+					return
+				}
+				full = b
+			}
+			gen, err := listings.GenerateFeed(r.Context(), cfg.ProjectID, feed.ID, full)
+			if err != nil {
+				log.Printf("[feeds] generate error for feed %s: %v", feed.ID, err)
+				httpapi.Error(w, http.StatusInternalServerError, "feed_error", "failed to generate feed")
+				return
+			}
+			if gen == nil {
+				httpapi.Error(w, http.StatusNotFound, "not_found", "feed not found")
+				return
+			}
+			httpapi.JSON(w, http.StatusOK, gen)
+		})
+
+		// GET /api/feeds/{id}/{format}
+		// Serves a feed as reso (JSON), zillow (XML) or csv. Authenticated by
+		// the feed token (?token= or X-Feed-Token), or as the feed's owner
+		// or an admin. Feeds older than listings.FeedRefreshInterval are
+		// regenerated incrementally first. Responses carry an ETag and
+		// honour If-None-Match.
+		r.Get("/{id}/{format}", func(w http.ResponseWriter, r *http.Request) {
+			format := strings.ToLower(chi.URLParam(r, "format"))
+			if listings.FeedContentType(format) == "" {
+				httpapi.Error(w, http.StatusNotFound, "unknown_format", "feed format must be one of reso, zillow or csv")
+				return
+			}
+			feed, err := listings.GetSyndicationFeed(r.Context(), cfg.ProjectID, chi.URLParam(r, "id"))
+			if err != nil {
+				log.Printf("[feeds] get error: %v", err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to load feed")
+				return
+			}
+			token := r.URL.Query().Get("token")
+			if token == "" {
+				token = r.Header.Get("X-Feed-Token")
+			}
+			uc := auth.FromContext(r.Context())
+			allowed := feed != nil && (feed.CheckToken(token) ||
+				(uc != nil && (uc.Role == "admin" || uc.UID == feed.OwnerUID)))
+			if !allowed {
+				// Unknown feeds and bad tokens look the same to callers.
+				httpapi.Error(w, http.StatusUnauthorized, "unauthorized", "valid feed token required")
+				return
+			}
+
+			if feed.Stale(time.Now()) {
+				gen, err := listings.GenerateFeed(r.Context(), cfg.ProjectID, feed.ID, false)
+				if err != nil {
+					// Serve the last generation rather than failing the fetch.
+					log.Printf("[feeds] generate error for feed %s: %v", feed.ID, err)
+				} else if gen != nil {
+					feed = gen.Feed
+				}
+			}
+
+			etag := feed.ETag(format)
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "private, no-cache")
+			if match := r.Header.Get("If-None-Match"); match != "" {
+				for _, candidate := range strings.Split(match, ",") {
+					candidate = strings.TrimSpace(candidate)
+					if candidate == etag || candidate == "*" {
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+				}
+			}
+
+			items, err := listings.ListFeedListings(r.Context(), cfg.ProjectID, feed.ID)
+			if err != nil {
+				log.Printf("[feeds] listings error for feed %s: %v", feed.ID, err)
+				httpapi.Error(w, http.StatusInternalServerError, "firestore_error", "failed to load feed listings")
+				return
+			}
+			w.Header().Set("Content-Type", listings.FeedContentType(format))
+			if err := listings.WriteFeed(w, format, feed, items); err != nil {
+				log.Printf("[feeds] render error for feed %s (%s): %v", feed.ID, format, err)
+			}
+		})
+	})
+
 	// Notification endpoints (user-scoped in-app notifications)
 	r.Route("/api/notifications", func(r chi.Router) {
 		// GET /api/notifications
@@ -1474,6 +3274,32 @@ func main() {
 	}
 }
 
+// decodeMLSConnection reads an MLS board connection body for agentUID,
+// writing a 400 and returning false when it is not valid JSON.
+func decodeMLSConnection(w http.ResponseWriter, r *http.Request, agentUID string) (*listings.AgentMLSConnection, bool) {
+	var body struct {
+		Board        string `json:"board"`
+		MLSAgentID   string `json:"mlsAgentId"`
+		MLSOfficeID  string `json:"mlsOfficeId"`
+		DefaultCity  string `json:"defaultCity"`
+		DefaultState string `json:"defaultState"`
+		Enabled      bool   `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httpapi.Error(w, http.StatusBadRequest, "invalid_request", "invalid JSON body")
+		return nil, false
+	}
+	return &listings.AgentMLSConnection{
+		AgentUID:     agentUID,
+		Board:        strings.TrimSpace(body.Board),
+		MLSAgentID:   strings.TrimSpace(body.MLSAgentID),
+		MLSOfficeID:  strings.TrimSpace(body.MLSOfficeID),
+		DefaultCity:  strings.TrimSpace(body.DefaultCity),
+		DefaultState: strings.TrimSpace(body.DefaultState),
+		Enabled:      body.Enabled,
+	}, true
+}
+
 // updateUserSubscriptionEntitlement writes a simplified subscription entitlement
 // snapshot into the Firestore users collection under subscriptions.assiduousRealty.
 func updateUserSubscriptionEntitlement(ctx context.Context, projectID string, sub *stripe.Subscription) error {
@@ -0,0 +1,71 @@
+// Command importlistings loads a CSV, RESO JSON or IDX file drop into the
+// properties collection using the same pipeline as provider ingest.
+//
+//	importlistings -format csv -provider wholesaler -profile profile.json -preview listings.csv
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SirsiMaster/assiduous/backend/pkg/config"
+	"github.com/SirsiMaster/assiduous/backend/pkg/listings"
+)
+
+func main() {
+	format := flag.String("format", listings.ImportFormatCSV, "file format: csv, reso_json or idx")
+	provider := flag.String("provider", string(listings.ProviderFile), "source key recorded on imported properties")
+	profilePath := flag.String("profile", "", "path to a JSON column-mapping profile (csv/idx)")
+	preview := flag.Bool("preview", false, "parse and validate without writing to Firestore")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] FILE\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := listings.ImportOptions{
+		Format:   strings.ToLower(*format),
+		Provider: listings.ProviderKey(strings.ToLower(*provider)),
+	}
+	if *profilePath != "" {
+		data, err := os.ReadFile(*profilePath)
+		if err != nil {
+			log.Fatalf("[import] failed to read profile: %v", err)
+		}
+		var profile listings.ImportProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			log.Fatalf("[import] invalid profile: %v", err)
+		}
+		opts.Profile = &profile
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("[import] %v", err)
+	}
+	defer f.Close()
+
+	cfg := config.Load()
+	rep, err := listings.ImportListingsFile(context.Background(), cfg.ProjectID, f, opts, *preview, "cli")
+	if err != nil {
+		log.Fatalf("[import] %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rep); err != nil {
+		log.Fatalf("[import] %v", err)
+	}
+	if rep.Invalid > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,381 @@
+package address
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Address is a parsed US postal address broken into the components USPS
+// Publication 28 uses for standardization. All fields are stored upper-cased
+// and abbreviated once the address has been through Parse or Standardize.
+type Address struct {
+	Number          string `json:"number,omitempty"`
+	PreDirectional  string `json:"preDirectional,omitempty"`
+	StreetName      string `json:"streetName,omitempty"`
+	Suffix          string `json:"suffix,omitempty"`
+	PostDirectional string `json:"postDirectional,omitempty"`
+	UnitType        string `json:"unitType,omitempty"`
+	UnitNumber      string `json:"unitNumber,omitempty"`
+	City            string `json:"city,omitempty"`
+	State           string `json:"state,omitempty"`
+	ZIP5            string `json:"zip5,omitempty"`
+	ZIP4            string `json:"zip4,omitempty"`
+}
+
+var (
+	zipPattern    = regexp.MustCompile(`^(\d{5})(?:-?(\d{4}))?$`)
+	numberPattern = regexp.MustCompile(`^\d+[A-Z]?(?:-\d+[A-Z]?)?$|^\d+/\d+$`)
+	// punctuation that USPS drops from delivery lines; '#' and '-' and '/' are
+	// meaningful in unit and house numbers so they are kept.
+	punctReplacer = strings.NewReplacer(".", "", "'", "", "\"", "", ";", " ", "\t", " ", "\n", " ")
+)
+
+// Parse splits a free-form single-line address such as
+// "123 North Main Street Apt 4B, Springfield, Illinois 62704" into its
+// standardized components. Parsing is best-effort: components that cannot be
+// identified are left empty rather than reported as errors.
+func Parse(s string) Address {
+	s = clean(s)
+	if s == "" {
+		return Address{}
+	}
+
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	parts = dropEmpty(parts)
+	if len(parts) == 0 {
+		return Address{}
+	}
+
+	var a Address
+
+	// Peel ZIP and state off the tail of the last segment (or a trailing
+	// segment of their own, e.g. "..., Springfield, IL, 62704").
+	tail := strings.Fields(parts[len(parts)-1])
+	if n := len(tail); n > 0 {
+		if m := zipPattern.FindStringSubmatch(tail[n-1]); m != nil {
+			a.ZIP5, a.ZIP4 = m[1], m[2]
+			tail = tail[:n-1]
+		} else if n == 1 && len(tail[0]) == 9 && isDigits(tail[0]) {
+			a.ZIP5, a.ZIP4 = tail[0][:5], tail[0][5:]
+			tail = tail[:0]
+		}
+	}
+	if len(tail) == 0 && len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+		tail = strings.Fields(parts[len(parts)-1])
+	}
+	if st, rest := takeState(tail); st != "" {
+		a.State = st
+		tail = rest
+	}
+	if len(tail) == 0 && len(parts) > 1 && a.State != "" {
+		parts = parts[:len(parts)-1]
+		tail = strings.Fields(parts[len(parts)-1])
+	}
+
+	switch {
+	case len(parts) == 1:
+		// No commas: the street and city share one run of tokens, so split
+		// after the last street-looking token.
+		street, city := splitStreetCity(tail)
+		a.parseStreet(street)
+		a.City = strings.Join(city, " ")
+	default:
+		a.City = strings.Join(tail, " ")
+		street := parts[0]
+		for _, p := range parts[1 : len(parts)-1] {
+			street += " " + p
+		}
+		a.parseStreet(strings.Fields(street))
+	}
+
+	return a
+}
+
+// Standardize builds an Address from already-separated components, as found on
+// provider payloads and form inputs. street2 is typically a unit line.
+func Standardize(street1, street2, city, state, postal string) Address {
+	a := Address{}
+	street := clean(street1)
+	if s2 := clean(street2); s2 != "" {
+		street += " " + s2
+	}
+	a.parseStreet(strings.Fields(street))
+	a.City = strings.Join(strings.Fields(clean(city)), " ")
+	if st, rest := takeState(strings.Fields(clean(state))); st != "" && len(rest) == 0 {
+		a.State = st
+	} else {
+		a.State = strings.Join(strings.Fields(clean(state)), " ")
+	}
+	a.ZIP5, a.ZIP4 = FormatZIP(postal)
+	return a
+}
+
+// FormatZIP normalizes a US postal code into its 5-digit ZIP and optional
+// 4-digit add-on. Values that are not recognizably US ZIP codes are returned
+// upper-cased as zip5 with an empty zip4 so foreign postal codes survive.
+func FormatZIP(postal string) (zip5, zip4 string) {
+	p := strings.ToUpper(strings.TrimSpace(postal))
+	p = strings.ReplaceAll(p, " ", "")
+	if m := zipPattern.FindStringSubmatch(p); m != nil {
+		return m[1], m[2]
+	}
+	if len(p) == 9 && isDigits(p) {
+		return p[:5], p[5:]
+	}
+	return p, ""
+}
+
+// ZIP returns the ZIP code in USPS "12345-6789" form, or just the 5-digit ZIP
+// when no add-on is known.
+func (a Address) ZIP() string {
+	if a.ZIP4 != "" {
+		return a.ZIP5 + "-" + a.ZIP4
+	}
+	return a.ZIP5
+}
+
+// DeliveryLine returns the primary address line, e.g. "123 N MAIN ST APT 4B".
+func (a Address) DeliveryLine() string {
+	return joinNonEmpty(" ",
+		a.Number, a.PreDirectional, a.StreetName, a.Suffix, a.PostDirectional,
+		a.UnitType, a.UnitNumber,
+	)
+}
+
+// StreetLine returns the delivery line without the secondary unit, for systems
+// that carry the unit on a separate line.
+func (a Address) StreetLine() string {
+	return joinNonEmpty(" ", a.Number, a.PreDirectional, a.StreetName, a.Suffix, a.PostDirectional)
+}
+
+// UnitLine returns the secondary unit designator and number, e.g. "APT 4B".
+func (a Address) UnitLine() string {
+	return joinNonEmpty(" ", a.UnitType, a.UnitNumber)
+}
+
+// LastLine returns the city/state/ZIP line, e.g. "SPRINGFIELD IL 62704-1234".
+func (a Address) LastLine() string {
+	return joinNonEmpty(" ", a.City, a.State, a.ZIP())
+}
+
+// String renders the address on a single line in USPS order.
+func (a Address) String() string {
+	return joinNonEmpty(", ", a.DeliveryLine(), a.LastLine())
+}
+
+// Key returns a stable, lower-case identifier for the physical address that
+// is safe to use in Firestore document ids and search indexes. Two inputs that
+// standardize to the same delivery point produce the same key. The ZIP5 is
+// preferred over city/state because city names vary more across sources.
+func (a Address) Key() string {
+	loc := a.ZIP5
+	if loc == "" {
+		loc = joinNonEmpty(" ", a.City, a.State)
+	}
+	// The unit designator is left out on purpose: feeds disagree on "APT 4"
+	// versus "#4" versus "UNIT 4" for the same door.
+	unit := a.UnitNumber
+	if unit == "" {
+		unit = a.UnitType
+	}
+	raw := joinNonEmpty(" ",
+		a.Number, a.PreDirectional, a.StreetName, a.Suffix, a.PostDirectional,
+		unit, loc,
+	)
+	if raw == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(raw) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// IsZero reports whether no component of the address is populated.
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// parseStreet fills the street components from a tokenized delivery line.
+func (a *Address) parseStreet(tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+
+	// Secondary unit: everything from the first unit designator onwards.
+	for i, t := range tokens {
+		if i == 0 {
+			continue
+		}
+		if strings.HasPrefix(t, "#") {
+			a.UnitType = "#"
+			a.UnitNumber = strings.Join(append([]string{strings.TrimPrefix(t, "#")}, tokens[i+1:]...), " ")
+			a.UnitNumber = strings.TrimSpace(a.UnitNumber)
+			tokens = tokens[:i]
+			break
+		}
+		abbr, ok := unitDesignators[t]
+		if !ok || i < 2 {
+			continue
+		}
+		if i+1 < len(tokens) || unitNeedsNoNumber[abbr] {
+			a.UnitType = abbr
+			rest := tokens[i+1:]
+			if len(rest) > 0 && strings.HasPrefix(rest[0], "#") {
+				rest[0] = strings.TrimPrefix(rest[0], "#")
+			}
+			a.UnitNumber = strings.TrimSpace(strings.Join(rest, " "))
+			tokens = tokens[:i]
+			break
+		}
+	}
+
+	if len(tokens) > 0 && numberPattern.MatchString(tokens[0]) {
+		a.Number = tokens[0]
+		tokens = tokens[1:]
+	}
+
+	// A leading directional is only a pre-directional when a street name is
+	// left after it; "10 NORTH ST" is North Street, not N Street.
+	for _, n := range []int{2, 1} {
+		if len(tokens) <= n {
+			continue
+		}
+		d, ok := directionals[strings.Join(tokens[:n], " ")]
+		if !ok {
+			continue
+		}
+		rest := tokens[n:]
+		if _, isSuffix := streetSuffixes[rest[0]]; len(rest) == 1 && isSuffix {
+			continue
+		}
+		a.PreDirectional = d
+		tokens = rest
+		break
+	}
+
+	if n := len(tokens); n > 1 {
+		if d, ok := directionals[tokens[n-1]]; ok {
+			a.PostDirectional = d
+			tokens = tokens[:n-1]
+		}
+	}
+
+	if n := len(tokens); n > 1 {
+		if s, ok := streetSuffixes[tokens[n-1]]; ok {
+			a.Suffix = s
+			tokens = tokens[:n-1]
+		}
+	}
+
+	a.StreetName = strings.Join(tokens, " ")
+}
+
+// splitStreetCity splits a comma-less token run such as
+// "123 MAIN ST APT 4 SPRINGFIELD" into street and city tokens. The split point
+// is after the last street suffix that still has tokens after it (plus any
+// trailing directional and unit), so cities like "OAK PARK" stay intact.
+func splitStreetCity(tokens []string) (street, city []string) {
+	split := -1
+	for i := len(tokens) - 2; i > 0; i-- {
+		if _, ok := streetSuffixes[tokens[i]]; ok {
+			split = i + 1
+			break
+		}
+	}
+	if split < 0 {
+		return tokens, nil
+	}
+	if split < len(tokens) {
+		if _, ok := directionals[tokens[split]]; ok && split+1 < len(tokens) {
+			split++
+		}
+	}
+	if split < len(tokens) {
+		t := tokens[split]
+		if _, ok := unitDesignators[t]; ok && split+1 < len(tokens) {
+			split += 2
+		} else if strings.HasPrefix(t, "#") {
+			split++
+			if t == "#" && split < len(tokens) {
+				split++
+			}
+		}
+	}
+	if split > len(tokens) {
+		split = len(tokens)
+	}
+	return tokens[:split], tokens[split:]
+}
+
+// takeState looks for a state name or abbreviation at the end of tokens and
+// returns its USPS abbreviation along with the remaining tokens.
+func takeState(tokens []string) (string, []string) {
+	// Longest match first so "WEST VIRGINIA" wins over "VIRGINIA".
+	for n := 3; n >= 1; n-- {
+		if len(tokens) < n {
+			continue
+		}
+		cand := strings.Join(tokens[len(tokens)-n:], " ")
+		if n == 1 && len(cand) == 2 {
+			if _, ok := stateAbbreviations[cand]; ok {
+				return cand, tokens[:len(tokens)-n]
+			}
+		}
+		if abbr, ok := stateNames[cand]; ok {
+			return abbr, tokens[:len(tokens)-n]
+		}
+	}
+	return "", tokens
+}
+
+func clean(s string) string {
+	s = strings.ToUpper(punctReplacer.Replace(s))
+	// Treat "APT#4" and "# 4" uniformly.
+	s = strings.ReplaceAll(s, "# ", "#")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func dropEmpty(in []string) []string {
+	out := in[:0]
+	for _, s := range in {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, sep)
+}
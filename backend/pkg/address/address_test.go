@@ -0,0 +1,164 @@
+package address
+
+import "testing"
+
+// TestParse verifies that free-form addresses are split into USPS-standard
+// components across the shapes we see from feeds and user input.
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Address
+	}{
+		{
+			name: "full address with spelled out parts",
+			in:   "123 North Main Street Apartment 4B, Springfield, Illinois 62704-1234",
+			want: Address{Number: "123", PreDirectional: "N", StreetName: "MAIN", Suffix: "ST", UnitType: "APT", UnitNumber: "4B", City: "SPRINGFIELD", State: "IL", ZIP5: "62704", ZIP4: "1234"},
+		},
+		{
+			name: "already standardized",
+			in:   "500 W MADISON ST STE 1000, CHICAGO, IL 60661",
+			want: Address{Number: "500", PreDirectional: "W", StreetName: "MADISON", Suffix: "ST", UnitType: "STE", UnitNumber: "1000", City: "CHICAGO", State: "IL", ZIP5: "60661"},
+		},
+		{
+			name: "post directional and hash unit",
+			in:   "1600 Pennsylvania Ave. NW #2, Washington, DC 20500",
+			want: Address{Number: "1600", StreetName: "PENNSYLVANIA", Suffix: "AVE", PostDirectional: "NW", UnitType: "#", UnitNumber: "2", City: "WASHINGTON", State: "DC", ZIP5: "20500"},
+		},
+		{
+			name: "no commas",
+			in:   "42 Elm Boulevard Springfield IL 62701",
+			want: Address{Number: "42", StreetName: "ELM", Suffix: "BLVD", City: "SPRINGFIELD", State: "IL", ZIP5: "62701"},
+		},
+		{
+			name: "multi-word state and city",
+			in:   "7 Ocean Drive, Virginia Beach, West Virginia",
+			want: Address{Number: "7", StreetName: "OCEAN", Suffix: "DR", City: "VIRGINIA BEACH", State: "WV"},
+		},
+		{
+			name: "nine digit zip without dash and separate zip segment",
+			in:   "88 Lake Shore Rd, Austin, TX, 787011234",
+			want: Address{Number: "88", StreetName: "LAKE SHORE", Suffix: "RD", City: "AUSTIN", State: "TX", ZIP5: "78701", ZIP4: "1234"},
+		},
+		{
+			name: "unit on its own segment",
+			in:   "9 Park Place, Unit 3, Hoboken, NJ 07030",
+			want: Address{Number: "9", StreetName: "PARK", Suffix: "PL", UnitType: "UNIT", UnitNumber: "3", City: "HOBOKEN", State: "NJ", ZIP5: "07030"},
+		},
+		{
+			name: "directional is the street name",
+			in:   "10 North Street, Boston, MA 02109",
+			want: Address{Number: "10", StreetName: "NORTH", Suffix: "ST", City: "BOSTON", State: "MA", ZIP5: "02109"},
+		},
+		{
+			name: "empty",
+			in:   "   ",
+			want: Address{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.in)
+			if got != tt.want {
+				t.Fatalf("Parse(%q)\n got: %+v\nwant: %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStandardize verifies component-wise standardization of provider-style
+// address fields.
+func TestStandardize(t *testing.T) {
+	tests := []struct {
+		name                               string
+		street1, street2, city, state, zip string
+		wantLine, wantLast                 string
+	}{
+		{
+			name:    "portal payload",
+			street1: "2100 south west 3rd avenue", street2: "suite 200",
+			city: "miami", state: "florida", zip: "33129",
+			wantLine: "2100 SW 3RD AVE STE 200", wantLast: "MIAMI FL 33129",
+		},
+		{
+			name:    "zip plus four with space",
+			street1: "15 Oak Ct", city: "Denver", state: "co", zip: "80202 1234",
+			wantLine: "15 OAK CT", wantLast: "DENVER CO 80202-1234",
+		},
+		{
+			name:    "foreign postal code survives",
+			street1: "1 Front St", city: "Toronto", state: "ON", zip: "m5j 2n1",
+			wantLine: "1 FRONT ST", wantLast: "TORONTO ON M5J2N1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Standardize(tt.street1, tt.street2, tt.city, tt.state, tt.zip)
+			if got := a.DeliveryLine(); got != tt.wantLine {
+				t.Errorf("DeliveryLine() = %q, want %q", got, tt.wantLine)
+			}
+			if got := a.LastLine(); got != tt.wantLast {
+				t.Errorf("LastLine() = %q, want %q", got, tt.wantLast)
+			}
+		})
+	}
+}
+
+// TestKeyIsStableAcrossSpellings verifies that different spellings of the same
+// delivery point collapse to one key.
+func TestKeyIsStableAcrossSpellings(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Address
+	}{
+		{
+			name: "suffix and directional spelling",
+			a:    Parse("123 North Main Street, Springfield, IL 62704"),
+			b:    Parse("123 N. Main St, SPRINGFIELD, Illinois 62704-0001"),
+		},
+		{
+			name: "unit designator spelling",
+			a:    Parse("55 Pine Ave Apt 7, Reno, NV 89501"),
+			b:    Standardize("55 pine avenue", "#7", "reno", "nv", "89501"),
+		},
+		{
+			name: "city spelling ignored when zip present",
+			a:    Parse("8 Bay Rd, St Petersburg, FL 33701"),
+			b:    Parse("8 Bay Road, Saint Petersburg, FL 33701"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ka, kb := tt.a.Key(), tt.b.Key()
+			if ka == "" || ka != kb {
+				t.Fatalf("keys differ: %q vs %q", ka, kb)
+			}
+		})
+	}
+
+	if got, want := Parse("123 N Main St Apt 4B, Springfield, IL 62704").Key(), "123-n-main-st-4b-62704"; got != want {
+		t.Fatalf("Key() = %q, want %q", got, want)
+	}
+}
+
+// TestFormatZIP covers the ZIP normalization rules on their own.
+func TestFormatZIP(t *testing.T) {
+	tests := []struct {
+		in, zip5, zip4 string
+	}{
+		{"62704", "62704", ""},
+		{"62704-1234", "62704", "1234"},
+		{"627041234", "62704", "1234"},
+		{" 62704 ", "62704", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		z5, z4 := FormatZIP(tt.in)
+		if z5 != tt.zip5 || z4 != tt.zip4 {
+			t.Errorf("FormatZIP(%q) = (%q, %q), want (%q, %q)", tt.in, z5, z4, tt.zip5, tt.zip4)
+		}
+	}
+}
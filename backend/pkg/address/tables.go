@@ -0,0 +1,192 @@
+package address
+
+// Lookup tables derived from USPS Publication 28. Keys are upper-cased input
+// spellings; values are the standard abbreviations. Standard abbreviations
+// map to themselves so already-clean input is a no-op.
+
+// directionals covers Pub 28 Appendix B.
+var directionals = map[string]string{
+	"N": "N", "NORTH": "N",
+	"S": "S", "SOUTH": "S",
+	"E": "E", "EAST": "E",
+	"W": "W", "WEST": "W",
+	"NE": "NE", "NORTHEAST": "NE", "NORTH EAST": "NE",
+	"NW": "NW", "NORTHWEST": "NW", "NORTH WEST": "NW",
+	"SE": "SE", "SOUTHEAST": "SE", "SOUTH EAST": "SE",
+	"SW": "SW", "SOUTHWEST": "SW", "SOUTH WEST": "SW",
+}
+
+// unitDesignators covers the secondary unit designators from Pub 28
+// Appendix C2 that show up in listing feeds.
+var unitDesignators = map[string]string{
+	"APT": "APT", "APARTMENT": "APT",
+	"BSMT": "BSMT", "BASEMENT": "BSMT",
+	"BLDG": "BLDG", "BUILDING": "BLDG",
+	"DEPT": "DEPT", "DEPARTMENT": "DEPT",
+	"FL": "FL", "FLOOR": "FL",
+	"FRNT": "FRNT", "FRONT": "FRNT",
+	"LOWR": "LOWR", "LOWER": "LOWR",
+	"LOT": "LOT",
+	"OFC": "OFC", "OFFICE": "OFC",
+	"PH": "PH", "PENTHOUSE": "PH",
+	"REAR": "REAR",
+	"RM":   "RM", "ROOM": "RM",
+	"SPC": "SPC", "SPACE": "SPC",
+	"STE": "STE", "SUITE": "STE",
+	"TRLR": "TRLR", "TRAILER": "TRLR",
+	"UNIT": "UNIT",
+	"UPPR": "UPPR", "UPPER": "UPPR",
+}
+
+// unitNeedsNoNumber lists designators that are valid without a trailing
+// number (e.g. "123 MAIN ST REAR").
+var unitNeedsNoNumber = map[string]bool{
+	"BSMT": true,
+	"FRNT": true,
+	"LOWR": true,
+	"OFC":  true,
+	"PH":   true,
+	"REAR": true,
+	"UPPR": true,
+}
+
+// streetSuffixes covers the common street suffixes from Pub 28 Appendix C1,
+// including frequent misspellings seen in portal data.
+var streetSuffixes = map[string]string{
+	"ALLEY": "ALY", "ALLEE": "ALY", "ALLY": "ALY", "ALY": "ALY",
+	"ANNEX": "ANX", "ANX": "ANX",
+	"ARCADE": "ARC", "ARC": "ARC",
+	"AVENUE": "AVE", "AV": "AVE", "AVE": "AVE", "AVEN": "AVE", "AVENU": "AVE", "AVN": "AVE", "AVNUE": "AVE",
+	"BAYOU": "BYU", "BYU": "BYU",
+	"BEACH": "BCH", "BCH": "BCH",
+	"BEND": "BND", "BND": "BND",
+	"BLUFF": "BLF", "BLF": "BLF",
+	"BOULEVARD": "BLVD", "BLVD": "BLVD", "BOUL": "BLVD", "BOULV": "BLVD",
+	"BRANCH": "BR", "BR": "BR",
+	"BRIDGE": "BRG", "BRG": "BRG",
+	"BROOK": "BRK", "BRK": "BRK",
+	"BYPASS": "BYP", "BYP": "BYP",
+	"CAUSEWAY": "CSWY", "CSWY": "CSWY",
+	"CENTER": "CTR", "CENTRE": "CTR", "CTR": "CTR", "CNTR": "CTR",
+	"CIRCLE": "CIR", "CIR": "CIR", "CIRC": "CIR", "CRCL": "CIR",
+	"CLIFF": "CLF", "CLF": "CLF",
+	"CLUB": "CLB", "CLB": "CLB",
+	"COMMON": "CMN", "CMN": "CMN",
+	"CORNER": "COR", "COR": "COR",
+	"COURSE": "CRSE", "CRSE": "CRSE",
+	"COURT": "CT", "CT": "CT",
+	"COVE": "CV", "CV": "CV",
+	"CREEK": "CRK", "CRK": "CRK",
+	"CRESCENT": "CRES", "CRES": "CRES",
+	"CROSSING": "XING", "XING": "XING",
+	"DALE": "DL", "DL": "DL",
+	"DRIVE": "DR", "DR": "DR", "DRIV": "DR", "DRV": "DR",
+	"ESTATE": "EST", "EST": "EST",
+	"ESTATES": "ESTS", "ESTS": "ESTS",
+	"EXPRESSWAY": "EXPY", "EXPY": "EXPY", "EXPRESS": "EXPY",
+	"EXTENSION": "EXT", "EXT": "EXT",
+	"FALLS": "FLS", "FLS": "FLS",
+	"FERRY": "FRY", "FRY": "FRY",
+	"FIELD": "FLD", "FLD": "FLD",
+	"FIELDS": "FLDS", "FLDS": "FLDS",
+	"FOREST": "FRST", "FRST": "FRST",
+	"FORK": "FRK", "FRK": "FRK",
+	"FREEWAY": "FWY", "FWY": "FWY",
+	"GARDEN": "GDN", "GDN": "GDN",
+	"GARDENS": "GDNS", "GDNS": "GDNS",
+	"GATEWAY": "GTWY", "GTWY": "GTWY",
+	"GLEN": "GLN", "GLN": "GLN",
+	"GREEN": "GRN", "GRN": "GRN",
+	"GROVE": "GRV", "GRV": "GRV",
+	"HARBOR": "HBR", "HBR": "HBR",
+	"HAVEN": "HVN", "HVN": "HVN",
+	"HEIGHTS": "HTS", "HTS": "HTS",
+	"HIGHWAY": "HWY", "HWY": "HWY", "HIWAY": "HWY",
+	"HILL": "HL", "HL": "HL",
+	"HILLS": "HLS", "HLS": "HLS",
+	"HOLLOW": "HOLW", "HOLW": "HOLW",
+	"ISLAND": "IS", "IS": "IS",
+	"JUNCTION": "JCT", "JCT": "JCT",
+	"KNOLL": "KNL", "KNL": "KNL",
+	"LAKE": "LK", "LK": "LK",
+	"LAKES": "LKS", "LKS": "LKS",
+	"LANDING": "LNDG", "LNDG": "LNDG",
+	"LANE": "LN", "LN": "LN",
+	"LOOP":  "LOOP",
+	"MANOR": "MNR", "MNR": "MNR",
+	"MEADOW": "MDW", "MDW": "MDW",
+	"MEADOWS": "MDWS", "MDWS": "MDWS",
+	"MILL": "ML", "ML": "ML",
+	"MOUNT": "MT", "MT": "MT",
+	"MOUNTAIN": "MTN", "MTN": "MTN",
+	"ORCHARD": "ORCH", "ORCH": "ORCH",
+	"OVAL":    "OVAL",
+	"PARK":    "PARK",
+	"PARKWAY": "PKWY", "PKWY": "PKWY", "PKY": "PKWY", "PARKWY": "PKWY",
+	"PASS":  "PASS",
+	"PATH":  "PATH",
+	"PIKE":  "PIKE",
+	"PINES": "PNES", "PNES": "PNES",
+	"PLACE": "PL", "PL": "PL",
+	"PLAZA": "PLZ", "PLZ": "PLZ",
+	"POINT": "PT", "PT": "PT",
+	"PORT": "PRT", "PRT": "PRT",
+	"PRAIRIE": "PR", "PR": "PR",
+	"RANCH": "RNCH", "RNCH": "RNCH",
+	"RIDGE": "RDG", "RDG": "RDG",
+	"RIVER": "RIV", "RIV": "RIV",
+	"ROAD": "RD", "RD": "RD",
+	"ROUTE": "RTE", "RTE": "RTE",
+	"ROW":   "ROW",
+	"RUN":   "RUN",
+	"SHORE": "SHR", "SHR": "SHR",
+	"SHORES": "SHRS", "SHRS": "SHRS",
+	"SPRING": "SPG", "SPG": "SPG",
+	"SPRINGS": "SPGS", "SPGS": "SPGS",
+	"SQUARE": "SQ", "SQ": "SQ",
+	"STATION": "STA", "STA": "STA",
+	"STREET": "ST", "ST": "ST", "STR": "ST", "STRT": "ST",
+	"SUMMIT": "SMT", "SMT": "SMT",
+	"TERRACE": "TER", "TER": "TER", "TERR": "TER",
+	"TRACE": "TRCE", "TRCE": "TRCE",
+	"TRAIL": "TRL", "TRL": "TRL", "TRAILS": "TRL",
+	"TUNNEL": "TUNL", "TUNL": "TUNL",
+	"TURNPIKE": "TPKE", "TPKE": "TPKE",
+	"UNION": "UN", "UN": "UN",
+	"VALLEY": "VLY", "VLY": "VLY",
+	"VIEW": "VW", "VW": "VW",
+	"VILLAGE": "VLG", "VLG": "VLG",
+	"VISTA": "VIS", "VIS": "VIS",
+	"WALK": "WALK",
+	"WAY":  "WAY", "WY": "WAY",
+	"WELLS": "WLS", "WLS": "WLS",
+	"WOODS": "WDS", "WDS": "WDS",
+}
+
+// stateNames maps full state, district and territory names to USPS codes.
+var stateNames = map[string]string{
+	"ALABAMA": "AL", "ALASKA": "AK", "ARIZONA": "AZ", "ARKANSAS": "AR",
+	"CALIFORNIA": "CA", "COLORADO": "CO", "CONNECTICUT": "CT", "DELAWARE": "DE",
+	"DISTRICT OF COLUMBIA": "DC", "FLORIDA": "FL", "GEORGIA": "GA", "HAWAII": "HI",
+	"IDAHO": "ID", "ILLINOIS": "IL", "INDIANA": "IN", "IOWA": "IA",
+	"KANSAS": "KS", "KENTUCKY": "KY", "LOUISIANA": "LA", "MAINE": "ME",
+	"MARYLAND": "MD", "MASSACHUSETTS": "MA", "MICHIGAN": "MI", "MINNESOTA": "MN",
+	"MISSISSIPPI": "MS", "MISSOURI": "MO", "MONTANA": "MT", "NEBRASKA": "NE",
+	"NEVADA": "NV", "NEW HAMPSHIRE": "NH", "NEW JERSEY": "NJ", "NEW MEXICO": "NM",
+	"NEW YORK": "NY", "NORTH CAROLINA": "NC", "NORTH DAKOTA": "ND", "OHIO": "OH",
+	"OKLAHOMA": "OK", "OREGON": "OR", "PENNSYLVANIA": "PA", "RHODE ISLAND": "RI",
+	"SOUTH CAROLINA": "SC", "SOUTH DAKOTA": "SD", "TENNESSEE": "TN", "TEXAS": "TX",
+	"UTAH": "UT", "VERMONT": "VT", "VIRGINIA": "VA", "WASHINGTON": "WA",
+	"WEST VIRGINIA": "WV", "WISCONSIN": "WI", "WYOMING": "WY",
+	"PUERTO RICO": "PR", "GUAM": "GU", "VIRGIN ISLANDS": "VI",
+	"AMERICAN SAMOA": "AS", "NORTHERN MARIANA ISLANDS": "MP",
+}
+
+// stateAbbreviations is the set of valid two-letter USPS state codes.
+var stateAbbreviations = func() map[string]struct{} {
+	out := make(map[string]struct{}, len(stateNames))
+	for _, abbr := range stateNames {
+		out[abbr] = struct{}{}
+	}
+	return out
+}()
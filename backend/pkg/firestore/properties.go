@@ -2,26 +2,19 @@ package firestore
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
-)
 
-// Property represents the core fields used by the backend services.
-// This should mirror the Firestore properties collection as documented
-// in DATA_MODEL.md. Additional fields can be added as needed.
-type Property struct {
-	ID         string                 `firestore:"-" json:"id"`
-	Address    string                 `firestore:"address" json:"address"`
-	Price      float64                `firestore:"price" json:"price"`
-	Bedrooms   int32                  `firestore:"bedrooms" json:"bedrooms"`
-	Bathrooms  float64                `firestore:"bathrooms" json:"bathrooms"`
-	SquareFeet int32                  `firestore:"squareFeet" json:"squareFeet"`
-	Type       string                 `firestore:"type" json:"type"`
-	Status     string                 `firestore:"status" json:"status"`
-	AgentID    string                 `firestore:"agentId" json:"agentId"`
-	Extra      map[string]interface{} `firestore:"-" json:"extra,omitempty"`
-}
+	"github.com/SirsiMaster/assiduous/backend/pkg/geo"
+)
 
 // PropertyRepository wraps Firestore access for properties.
 type PropertyRepository struct {
@@ -39,16 +32,11 @@ func (r *PropertyRepository) GetByID(ctx context.Context, id string) (*Property,
 	if err != nil {
 		return nil, err
 	}
-	var p Property
-	if err := doc.DataTo(&p); err != nil {
-		return nil, err
-	}
-	p.ID = doc.Ref.ID
-	return &p, nil
+	return PropertyFromData(doc.Ref.ID, doc.Data()), nil
 }
 
 // ListBasic returns a limited list of properties for simple listing UIs.
-// Filtering/sorting will be expanded as we wire more endpoints.
+// Use List for filtered, sorted and paginated results.
 func (r *PropertyRepository) ListBasic(ctx context.Context, limit int) ([]*Property, error) {
 	if limit <= 0 {
 		limit = 50
@@ -65,12 +53,418 @@ func (r *PropertyRepository) ListBasic(ctx context.Context, limit int) ([]*Prope
 			}
 			return nil, err
 		}
-		var p Property
-		if err := doc.DataTo(&p); err != nil {
+		out = append(out, PropertyFromData(doc.Ref.ID, doc.Data()))
+	}
+	return out, nil
+}
+
+// GeoQuery describes a geospatial property search. Exactly one of BBox,
+// Radius (Center + RadiusMeters) or Polygon selects the area.
+type GeoQuery struct {
+	BBox         *geo.BBox
+	Center       *geo.Point
+	RadiusMeters float64
+	Polygon      geo.Polygon
+	// Near overrides the point results are ordered by; it defaults to the
+	// radius center or the middle of the box/polygon.
+	Near   *geo.Point
+	Status string
+	Limit  int
+	// SortByDistance orders results nearest first.
+	SortByDistance bool
+}
+
+// GeoHit is a single geo search result.
+type GeoHit struct {
+	ID             string         `json:"id"`
+	Location       geo.Point      `json:"location"`
+	DistanceMeters float64        `json:"distanceMeters"`
+	Property       map[string]any `json:"property"`
+}
+
+// GeoResult is the outcome of a geo search. Truncated is set when a geohash
+// cell held more candidates than were read, so the hits may be incomplete
+// and, when sorted by distance, not the nearest.
+type GeoResult struct {
+	Hits      []*GeoHit `json:"results"`
+	Truncated bool      `json:"truncated"`
+}
+
+const (
+	// geoCoverCells caps the number of geohash range queries per search.
+	geoCoverCells = 12
+	// geoScanPerCell caps documents read per range query.
+	geoScanPerCell = 500
+)
+
+// SearchGeo finds properties inside the query area using the geohash field
+// written at ingest. The area is covered with geohash prefix ranges, then each
+// candidate is checked exactly against its coordinates.
+func (r *PropertyRepository) SearchGeo(ctx context.Context, q GeoQuery) (*GeoResult, error) {
+	var (
+		bounds   geo.BBox
+		contains func(geo.Point) bool
+		near     geo.Point
+	)
+	switch {
+	case len(q.Polygon) > 0:
+		if len(q.Polygon) < 3 {
+			return nil, fmt.Errorf("polygon needs at least 3 points")
+		}
+		bounds = q.Polygon.Bounds()
+		contains = q.Polygon.Contains
+		near = q.Polygon.Centroid()
+	case q.Center != nil:
+		if q.RadiusMeters <= 0 {
+			return nil, fmt.Errorf("radius must be positive")
+		}
+		c := *q.Center
+		bounds = geo.RadiusBBox(c, q.RadiusMeters)
+		contains = func(p geo.Point) bool { return geo.Distance(c, p) <= q.RadiusMeters }
+		near = c
+	case q.BBox != nil:
+		bounds = *q.BBox
+		contains = bounds.Contains
+		near = bounds.Center()
+	default:
+		return nil, fmt.Errorf("a bounding box, radius or polygon is required")
+	}
+	if err := bounds.Validate(); err != nil {
+		return nil, err
+	}
+	if q.Near != nil {
+		near = *q.Near
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	c := &geoCollector{contains: contains, near: near, seen: map[string]bool{}}
+	for _, prefix := range geo.Cover(bounds, geoCoverCells) {
+		query := r.client.Collection("properties").Query
+		if q.Status != "" {
+			query = query.Where("status", "==", q.Status)
+		}
+		if prefix != "" {
+			query = query.Where("geohash", ">=", prefix).Where("geohash", "<", prefix+"~")
+		} else {
+			query = query.Where("geohash", ">", "")
+		}
+		snap, err := query.OrderBy("geohash", firestore.Asc).Limit(geoScanPerCell).Documents(ctx).GetAll()
+		if err != nil {
 			return nil, err
 		}
-		p.ID = doc.Ref.ID
-		out = append(out, &p)
+		docs := make([]geoDoc, len(snap))
+		for i, doc := range snap {
+			docs[i] = geoDoc{id: doc.Ref.ID, data: doc.Data()}
+		}
+		c.addCell(docs)
 	}
-	return out, nil
+	return c.result(q.SortByDistance, limit), nil
+}
+
+// geoDoc is a candidate document read from one geohash cell.
+type geoDoc struct {
+	id   string
+	data map[string]any
+}
+
+// geoCollector gathers the candidates of each geohash cell that fall inside
+// the search area.
+type geoCollector struct {
+	contains  func(geo.Point) bool
+	near      geo.Point
+	seen      map[string]bool
+	hits      []*GeoHit
+	truncated bool
+}
+
+// addCell adds one cell's documents. A cell that filled the scan cap may
+// have held more, so the result is marked truncated.
+func (c *geoCollector) addCell(docs []geoDoc) {
+	if len(docs) >= geoScanPerCell {
+		c.truncated = true
+	}
+	for _, d := range docs {
+		if c.seen[d.id] {
+			continue
+		}
+		c.seen[d.id] = true
+		pt, ok := documentLocation(d.data)
+		if !ok || !c.contains(pt) {
+			continue
+		}
+		c.hits = append(c.hits, &GeoHit{
+			ID:             d.id,
+			Location:       pt,
+			DistanceMeters: geo.Distance(c.near, pt),
+			Property:       d.data,
+		})
+	}
+}
+
+func (c *geoCollector) result(sortByDistance bool, limit int) *GeoResult {
+	hits := c.hits
+	if sortByDistance {
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].DistanceMeters < hits[j].DistanceMeters })
+	}
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return &GeoResult{Hits: hits, Truncated: c.truncated}
+}
+
+// documentLocation reads address.coordinates as written by listings ingest.
+func documentLocation(data map[string]any) (geo.Point, bool) {
+	addr, _ := data["address"].(map[string]any)
+	coords, _ := addr["coordinates"].(map[string]any)
+	lat, ok1 := coords["latitude"].(float64)
+	lng, ok2 := coords["longitude"].(float64)
+	if !ok1 || !ok2 {
+		return geo.Point{}, false
+	}
+	pt := geo.Point{Lat: lat, Lng: lng}
+	return pt, pt.Valid()
+}
+
+// Property list sort fields accepted by PropertyQuery.Sort.
+const (
+	SortUpdatedAt    = "updatedAt"
+	SortPrice        = "price"
+	SortPricePerSqft = "pricePerSqft"
+	// SortDealQuality orders by automatic underwriting grade, best first
+	// when ascending.
+	SortDealQuality = "dealQuality"
+)
+
+// sortFields maps PropertyQuery.Sort values onto document field paths.
+var sortFields = map[string]string{
+	SortUpdatedAt:    "updatedAt",
+	SortPrice:        "price",
+	SortPricePerSqft: "pricePerSqft",
+	SortDealQuality:  "underwriting.qualityRank",
+}
+
+// PropertyQuery describes a filtered, sorted page of properties. Equality
+// filters and a price range on price sorts run in Firestore; the remaining
+// ranges are applied to each page as it is read.
+type PropertyQuery struct {
+	Type   string
+	Status string
+	City   string
+	State  string
+	Zip    string
+	Source string
+	// DealQuality matches the automatic underwriting grade, e.g.
+	// "A (Excellent)".
+	DealQuality string
+
+	MinPrice, MaxPrice float64
+	MinBeds, MaxBeds   int
+	MinBaths, MaxBaths float64
+	MinSqft, MaxSqft   int
+
+	// Sort is one of the Sort* fields; Desc reverses it. Defaults to
+	// updatedAt descending. Documents missing the sort field are excluded.
+	Sort string
+	Desc bool
+
+	Limit int
+	// Cursor is the opaque NextCursor from a previous page.
+	Cursor string
+}
+
+// PropertyPage is one page of PropertyQuery results.
+type PropertyPage struct {
+	Properties []map[string]any `json:"properties"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// propertyCursor is the decoded form of PropertyPage.NextCursor: the sort
+// value and document id of the last document read.
+type propertyCursor struct {
+	Sort string  `json:"s"`
+	Desc bool    `json:"d,omitempty"`
+	ID   string  `json:"id"`
+	Num  float64 `json:"n,omitempty"`
+	Time string  `json:"t,omitempty"`
+}
+
+// maxListScan caps documents read per List call when in-memory filters
+// discard most of a page.
+const maxListScan = 2000
+
+// ErrInvalidCursor is returned by List for a malformed or mismatched cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// List returns a page of properties matching q, ordered by the requested
+// sort field with document id as tie-breaker so cursors are stable.
+func (r *PropertyRepository) List(ctx context.Context, q PropertyQuery) (*PropertyPage, error) {
+	if q.Sort == "" {
+		q.Sort, q.Desc = SortUpdatedAt, true
+	}
+	sortField, ok := sortFields[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort %q", q.Sort)
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	dir := firestore.Asc
+	if q.Desc {
+		dir = firestore.Desc
+	}
+
+	query := r.client.Collection("properties").Query
+	for _, f := range []struct{ field, value string }{
+		{"type", q.Type},
+		{"status", q.Status},
+		{"address.city", q.City},
+		{"address.state", q.State},
+		{"address.postalCode", q.Zip},
+		{"source", q.Source},
+		{"underwriting.dealQuality", q.DealQuality},
+	} {
+		if f.value != "" {
+			query = query.Where(f.field, "==", f.value)
+		}
+	}
+	// Firestore requires the first orderBy to match any inequality field, so
+	// only a price range on a price sort can be pushed into the query.
+	if q.Sort == SortPrice {
+		if q.MinPrice > 0 {
+			query = query.Where("price", ">=", q.MinPrice)
+		}
+		if q.MaxPrice > 0 {
+			query = query.Where("price", "<=", q.MaxPrice)
+		}
+	}
+	query = query.OrderBy(sortField, dir).OrderBy(firestore.DocumentID, dir)
+
+	if q.Cursor != "" {
+		c, err := decodePropertyCursor(q.Cursor)
+		if err != nil || c.Sort != q.Sort || c.Desc != q.Desc {
+			return nil, ErrInvalidCursor
+		}
+		var v any = c.Num
+		if q.Sort == SortUpdatedAt {
+			t, err := time.Parse(time.RFC3339Nano, c.Time)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			v = t
+		}
+		query = query.StartAfter(v, c.ID)
+	}
+
+	page := &PropertyPage{Properties: []map[string]any{}}
+	batch := limit * 2
+	if batch < 50 {
+		batch = 50
+	}
+	var last *firestore.DocumentSnapshot
+	scanned := 0
+	for scanned < maxListScan {
+		snap, err := query.Limit(batch).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for i, doc := range snap {
+			last = doc
+			scanned++
+			data := doc.Data()
+			if !q.matches(data) {
+				continue
+			}
+			data["id"] = doc.Ref.ID
+			page.Properties = append(page.Properties, data)
+			if len(page.Properties) == limit {
+				if i < len(snap)-1 || len(snap) == batch {
+					page.NextCursor = encodePropertyCursor(q, last)
+				}
+				return page, nil
+			}
+		}
+		if len(snap) < batch {
+			return page, nil
+		}
+		query = query.StartAfter(fieldValue(last.Data(), sortField), last.Ref.ID)
+	}
+	// Scan budget spent: return what we have and let the caller resume.
+	page.NextCursor = encodePropertyCursor(q, last)
+	return page, nil
+}
+
+// matches applies the range filters not expressed in the Firestore query.
+func (q PropertyQuery) matches(data map[string]any) bool {
+	between := func(field string, lo, hi float64) bool {
+		if lo <= 0 && hi <= 0 {
+			return true
+		}
+		v, ok := toFloat(data[field])
+		if !ok {
+			return false
+		}
+		return (lo <= 0 || v >= lo) && (hi <= 0 || v <= hi)
+	}
+	return (q.Sort == SortPrice || between("price", q.MinPrice, q.MaxPrice)) &&
+		between("bedrooms", float64(q.MinBeds), float64(q.MaxBeds)) &&
+		between("bathrooms", q.MinBaths, q.MaxBaths) &&
+		between("squareFeet", float64(q.MinSqft), float64(q.MaxSqft))
+}
+
+func encodePropertyCursor(q PropertyQuery, doc *firestore.DocumentSnapshot) string {
+	c := propertyCursor{Sort: q.Sort, Desc: q.Desc, ID: doc.Ref.ID}
+	switch v := fieldValue(doc.Data(), sortFields[q.Sort]).(type) {
+	case time.Time:
+		c.Time = v.Format(time.RFC3339Nano)
+	default:
+		c.Num, _ = toFloat(v)
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePropertyCursor(s string) (*propertyCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c propertyCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// fieldValue reads a dotted field path such as "underwriting.qualityRank".
+func fieldValue(data map[string]any, path string) any {
+	var v any = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
 }
@@ -0,0 +1,84 @@
+package firestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SirsiMaster/assiduous/backend/pkg/geo"
+)
+
+// TestPropertyQueryMatches verifies in-memory range filters, including that
+// a price range is left to Firestore on price sorts.
+func TestPropertyQueryMatches(t *testing.T) {
+	doc := map[string]any{"price": 450000.0, "bedrooms": int64(3), "bathrooms": 2.5, "squareFeet": int64(1800)}
+	tests := []struct {
+		name string
+		q    PropertyQuery
+		want bool
+	}{
+		{"no filters", PropertyQuery{}, true},
+		{"beds in range", PropertyQuery{MinBeds: 3, MaxBeds: 4}, true},
+		{"too few baths", PropertyQuery{MinBaths: 3}, false},
+		{"sqft ceiling", PropertyQuery{MaxSqft: 1500}, false},
+		{"price range", PropertyQuery{Sort: SortUpdatedAt, MaxPrice: 400000}, false},
+		{"price range on price sort", PropertyQuery{Sort: SortPrice, MaxPrice: 400000}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.q.matches(doc); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if (PropertyQuery{MinSqft: 1}).matches(map[string]any{}) {
+		t.Error("documents missing a filtered field should not match")
+	}
+}
+
+// TestDecodePropertyCursor rejects garbage and cursors without an id.
+func TestDecodePropertyCursor(t *testing.T) {
+	for _, s := range []string{"not base64!", "e30"} {
+		if _, err := decodePropertyCursor(s); err == nil {
+			t.Errorf("decodePropertyCursor(%q) should fail", s)
+		}
+	}
+	c, err := decodePropertyCursor("eyJzIjoicHJpY2UiLCJkIjp0cnVlLCJpZCI6InAxIiwibiI6MTIzfQ")
+	if err != nil || c.Sort != SortPrice || !c.Desc || c.ID != "p1" || c.Num != 123 {
+		t.Errorf("decoded %+v, %v", c, err)
+	}
+}
+
+// TestGeoCollectorTruncated verifies a cell that fills the per-cell scan cap
+// marks the result truncated, and results are limited nearest first.
+func TestGeoCollectorTruncated(t *testing.T) {
+	area := geo.BBox{South: 30, West: -98, North: 31, East: -97}
+	newCollector := func() *geoCollector {
+		return &geoCollector{contains: area.Contains, near: area.Center(), seen: map[string]bool{}}
+	}
+	doc := func(id string, lat float64) geoDoc {
+		return geoDoc{id: id, data: map[string]any{"address": map[string]any{
+			"coordinates": map[string]any{"latitude": lat, "longitude": -97.5},
+		}}}
+	}
+
+	full := make([]geoDoc, geoScanPerCell)
+	for i := range full {
+		full[i] = doc(fmt.Sprintf("p%d", i), 30.01+float64(i)*0.001)
+	}
+	c := newCollector()
+	c.addCell(full)
+	c.addCell([]geoDoc{doc("p0", 30.5), doc("outside", 40)})
+	res := c.result(true, 10)
+	if !res.Truncated || len(res.Hits) != 10 {
+		t.Fatalf("truncated=%v hits=%d", res.Truncated, len(res.Hits))
+	}
+	for i := 1; i < len(res.Hits); i++ {
+		if res.Hits[i].DistanceMeters < res.Hits[i-1].DistanceMeters {
+			t.Fatal("hits not sorted by distance")
+		}
+	}
+
+	c = newCollector()
+	c.addCell(full[:geoScanPerCell-1])
+	if res := c.result(true, 10); res.Truncated {
+		t.Error("cell under the scan cap reported truncated")
+	}
+}
@@ -0,0 +1,233 @@
+package firestore
+
+import (
+	"strings"
+	"time"
+)
+
+// Property is a properties document. Fields are named after the RESO Data
+// Dictionary, while the firestore tags keep the document keys written by
+// listings ingest and the legacy client (see DATA_MODEL.md). Keys the model
+// does not know are kept in Extra, so a document survives a round trip
+// through PropertyFromData and Data.
+type Property struct {
+	// ID is the document id, which serves as the RESO ListingKey.
+	ID string `firestore:"-" json:"ListingKey"`
+	// ListingID is the listing's id in its originating system.
+	ListingID             string          `firestore:"externalId,omitempty" json:"ListingId,omitempty"`
+	OriginatingSystemName string          `firestore:"source,omitempty" json:"OriginatingSystemName,omitempty"`
+	StandardStatus        string          `firestore:"status,omitempty" json:"StandardStatus,omitempty"`
+	ListPrice             float64         `firestore:"price,omitempty" json:"ListPrice,omitempty"`
+	Address               PropertyAddress `firestore:"address" json:"Address"`
+
+	BedroomsTotal         int     `firestore:"bedrooms,omitempty" json:"BedroomsTotal,omitempty"`
+	BathroomsTotalDecimal float64 `firestore:"bathrooms,omitempty" json:"BathroomsTotalDecimal,omitempty"`
+	LivingArea            int     `firestore:"squareFeet,omitempty" json:"LivingArea,omitempty"`
+	LotSizeSquareFeet     float64 `firestore:"lotSize,omitempty" json:"LotSizeSquareFeet,omitempty"`
+	YearBuilt             int     `firestore:"yearBuilt,omitempty" json:"YearBuilt,omitempty"`
+	Stories               int     `firestore:"stories,omitempty" json:"Stories,omitempty"`
+	ParkingTotal          int     `firestore:"parkingTotal,omitempty" json:"ParkingTotal,omitempty"`
+	GarageSpaces          int     `firestore:"garageSpaces,omitempty" json:"GarageSpaces,omitempty"`
+	PropertyType          string  `firestore:"type,omitempty" json:"PropertyType,omitempty"`
+	PropertySubType       string  `firestore:"propertySubType,omitempty" json:"PropertySubType,omitempty"`
+	AssociationFee        float64 `firestore:"hoaFees,omitempty" json:"AssociationFee,omitempty"`
+
+	PublicRemarks string   `firestore:"description,omitempty" json:"PublicRemarks,omitempty"`
+	PhotoURLs     []string `firestore:"images,omitempty" json:"PhotoURLs,omitempty"`
+
+	// ListAgentKey and ListOfficeKey identify the listing agent and office in
+	// the originating system. AgentID is the Assiduous agent who owns the
+	// listing, if any.
+	ListAgentKey  string `firestore:"listAgentKey,omitempty" json:"ListAgentKey,omitempty"`
+	ListOfficeKey string `firestore:"listOfficeKey,omitempty" json:"ListOfficeKey,omitempty"`
+	AgentID       string `firestore:"agentId,omitempty" json:"AgentId,omitempty"`
+
+	OnMarketDate           time.Time `firestore:"listedAt,omitempty" json:"OnMarketDate,omitempty"`
+	OriginalEntryTimestamp time.Time `firestore:"createdAt,omitempty" json:"OriginalEntryTimestamp,omitempty"`
+	ModificationTimestamp  time.Time `firestore:"updatedAt,omitempty" json:"ModificationTimestamp,omitempty"`
+
+	// Extra holds document keys the model does not know, such as provenance,
+	// sources and media state. It is bookkeeping, so it is not sent to
+	// clients.
+	Extra map[string]any `firestore:"-" json:"-"`
+}
+
+// PropertyAddress is the address map of a properties document. Documents
+// written by the legacy client store the address as a single string, which
+// is read into UnparsedAddress.
+type PropertyAddress struct {
+	UnparsedAddress string  `firestore:"street,omitempty" json:"UnparsedAddress,omitempty"`
+	City            string  `firestore:"city,omitempty" json:"City,omitempty"`
+	StateOrProvince string  `firestore:"state,omitempty" json:"StateOrProvince,omitempty"`
+	PostalCode      string  `firestore:"postalCode,omitempty" json:"PostalCode,omitempty"`
+	Latitude        float64 `firestore:"-" json:"Latitude,omitempty"`
+	Longitude       float64 `firestore:"-" json:"Longitude,omitempty"`
+	// Extra holds address keys the model does not know.
+	Extra map[string]any `firestore:"-" json:"-"`
+}
+
+// propertyKeys are the document keys decoded into Property fields.
+var propertyKeys = map[string]bool{
+	"externalId": true, "source": true, "status": true, "price": true,
+	"address": true, "bedrooms": true, "bathrooms": true, "squareFeet": true,
+	"lotSize": true, "yearBuilt": true, "stories": true, "parkingTotal": true,
+	"garageSpaces": true, "type": true, "propertySubType": true,
+	"hoaFees": true, "description": true, "images": true,
+	"listAgentKey": true, "listOfficeKey": true, "agentId": true,
+	"listedAt": true, "createdAt": true, "updatedAt": true,
+}
+
+// addressKeys are the address map keys decoded into PropertyAddress fields.
+var addressKeys = map[string]bool{
+	"street": true, "city": true, "state": true, "postalCode": true, "coordinates": true,
+}
+
+// PropertyFromData decodes a properties document. Values of an unexpected
+// type are left zero rather than failing the read, and keys the model does
+// not know are collected in Extra.
+func PropertyFromData(id string, data map[string]any) *Property {
+	str := func(m map[string]any, k string) string { s, _ := m[k].(string); return s }
+	num := func(k string) float64 { f, _ := toFloat(data[k]); return f }
+	date := func(k string) time.Time { t, _ := data[k].(time.Time); return t }
+
+	p := &Property{
+		ID:                     id,
+		ListingID:              str(data, "externalId"),
+		OriginatingSystemName:  str(data, "source"),
+		StandardStatus:         str(data, "status"),
+		ListPrice:              num("price"),
+		BedroomsTotal:          int(num("bedrooms")),
+		BathroomsTotalDecimal:  num("bathrooms"),
+		LivingArea:             int(num("squareFeet")),
+		LotSizeSquareFeet:      num("lotSize"),
+		YearBuilt:              int(num("yearBuilt")),
+		Stories:                int(num("stories")),
+		ParkingTotal:           int(num("parkingTotal")),
+		GarageSpaces:           int(num("garageSpaces")),
+		PropertyType:           str(data, "type"),
+		PropertySubType:        str(data, "propertySubType"),
+		AssociationFee:         num("hoaFees"),
+		PublicRemarks:          str(data, "description"),
+		ListAgentKey:           str(data, "listAgentKey"),
+		ListOfficeKey:          str(data, "listOfficeKey"),
+		AgentID:                str(data, "agentId"),
+		OnMarketDate:           date("listedAt"),
+		OriginalEntryTimestamp: date("createdAt"),
+		ModificationTimestamp:  date("updatedAt"),
+	}
+
+	switch addr := data["address"].(type) {
+	case string:
+		p.Address.UnparsedAddress = addr
+	case map[string]any:
+		p.Address.UnparsedAddress = str(addr, "street")
+		p.Address.City = str(addr, "city")
+		p.Address.StateOrProvince = str(addr, "state")
+		p.Address.PostalCode = str(addr, "postalCode")
+		if coords, ok := addr["coordinates"].(map[string]any); ok {
+			p.Address.Latitude, _ = toFloat(coords["latitude"])
+			p.Address.Longitude, _ = toFloat(coords["longitude"])
+		}
+		for k, v := range addr {
+			if !addressKeys[k] {
+				if p.Address.Extra == nil {
+					p.Address.Extra = map[string]any{}
+				}
+				p.Address.Extra[k] = v
+			}
+		}
+	}
+
+	switch imgs := data["images"].(type) {
+	case []any:
+		for _, v := range imgs {
+			if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+				p.PhotoURLs = append(p.PhotoURLs, s)
+			}
+		}
+	case []string:
+		p.PhotoURLs = append(p.PhotoURLs, imgs...)
+	}
+
+	for k, v := range data {
+		if !propertyKeys[k] {
+			if p.Extra == nil {
+				p.Extra = map[string]any{}
+			}
+			p.Extra[k] = v
+		}
+	}
+	return p
+}
+
+// Data encodes p as a properties document. Zero fields are omitted, so the
+// result can be merged onto an existing document without clearing values
+// p does not carry. Extra keys are written too, but never override a model
+// field.
+func (p *Property) Data() map[string]any {
+	data := make(map[string]any, len(p.Extra)+len(propertyKeys))
+	for k, v := range p.Extra {
+		if !propertyKeys[k] {
+			data[k] = v
+		}
+	}
+	set := func(k string, v any, ok bool) {
+		if ok {
+			data[k] = v
+		}
+	}
+	set("externalId", p.ListingID, p.ListingID != "")
+	set("source", p.OriginatingSystemName, p.OriginatingSystemName != "")
+	set("status", p.StandardStatus, p.StandardStatus != "")
+	set("price", p.ListPrice, p.ListPrice > 0)
+	set("bedrooms", p.BedroomsTotal, p.BedroomsTotal > 0)
+	set("bathrooms", p.BathroomsTotalDecimal, p.BathroomsTotalDecimal > 0)
+	set("squareFeet", p.LivingArea, p.LivingArea > 0)
+	set("lotSize", p.LotSizeSquareFeet, p.LotSizeSquareFeet > 0)
+	set("yearBuilt", p.YearBuilt, p.YearBuilt > 0)
+	set("stories", p.Stories, p.Stories > 0)
+	set("parkingTotal", p.ParkingTotal, p.ParkingTotal > 0)
+	set("garageSpaces", p.GarageSpaces, p.GarageSpaces > 0)
+	set("type", p.PropertyType, p.PropertyType != "")
+	set("propertySubType", p.PropertySubType, p.PropertySubType != "")
+	set("hoaFees", p.AssociationFee, p.AssociationFee > 0)
+	set("description", p.PublicRemarks, p.PublicRemarks != "")
+	set("images", p.PhotoURLs, len(p.PhotoURLs) > 0)
+	set("listAgentKey", p.ListAgentKey, p.ListAgentKey != "")
+	set("listOfficeKey", p.ListOfficeKey, p.ListOfficeKey != "")
+	set("agentId", p.AgentID, p.AgentID != "")
+	set("listedAt", p.OnMarketDate, !p.OnMarketDate.IsZero())
+	set("createdAt", p.OriginalEntryTimestamp, !p.OriginalEntryTimestamp.IsZero())
+	set("updatedAt", p.ModificationTimestamp, !p.ModificationTimestamp.IsZero())
+	if addr := p.Address.data(); len(addr) > 0 {
+		data["address"] = addr
+	}
+	return data
+}
+
+// data encodes the address map. Coordinates are only written when both are
+// set, matching listings ingest.
+func (a PropertyAddress) data() map[string]any {
+	out := make(map[string]any, len(a.Extra)+len(addressKeys))
+	for k, v := range a.Extra {
+		if !addressKeys[k] {
+			out[k] = v
+		}
+	}
+	set := func(k, v string) {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	set("street", a.UnparsedAddress)
+	set("city", a.City)
+	set("state", a.StateOrProvince)
+	set("postalCode", a.PostalCode)
+	if a.Latitude != 0 && a.Longitude != 0 {
+		out["coordinates"] = map[string]any{
+			"latitude":  a.Latitude,
+			"longitude": a.Longitude,
+		}
+	}
+	return out
+}
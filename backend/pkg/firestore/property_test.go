@@ -0,0 +1,113 @@
+package firestore
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPropertyRoundTrip verifies documents decode into the RESO-named model,
+// unknown keys land in Extra and Data writes the same document back.
+func TestPropertyRoundTrip(t *testing.T) {
+	listed := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	doc := map[string]any{
+		"externalId":   "A1",
+		"source":       "mls",
+		"status":       "Active",
+		"price":        450000.0,
+		"bedrooms":     int64(3),
+		"bathrooms":    2.5,
+		"squareFeet":   int64(1800),
+		"stories":      int64(2),
+		"garageSpaces": int64(1),
+		"description":  "Bungalow",
+		"images":       []any{"a.jpg"},
+		"listAgentKey": "AG-7",
+		"agentId":      "uid-1",
+		"listedAt":     listed,
+		"address": map[string]any{
+			"street": "12 Main St", "city": "Austin", "state": "TX", "postalCode": "78701",
+			"coordinates": map[string]any{"latitude": 30.27, "longitude": -97.74},
+			"county":      "Travis",
+		},
+		"geohash":      "9v6kp",
+		"underwriting": map[string]any{"dealQuality": "A"},
+	}
+
+	p := PropertyFromData("mls_A1", doc)
+	if p.ID != "mls_A1" || p.ListPrice != 450000 || p.BedroomsTotal != 3 || p.LivingArea != 1800 ||
+		p.Stories != 2 || p.GarageSpaces != 1 || p.PublicRemarks != "Bungalow" || p.ListAgentKey != "AG-7" ||
+		p.AgentID != "uid-1" || !p.OnMarketDate.Equal(listed) {
+		t.Errorf("property = %+v", p)
+	}
+	if p.Address.StateOrProvince != "TX" || p.Address.Latitude != 30.27 || p.Address.Extra["county"] != "Travis" {
+		t.Errorf("address = %+v", p.Address)
+	}
+	if len(p.Extra) != 2 || p.Extra["geohash"] != "9v6kp" {
+		t.Errorf("extra = %v", p.Extra)
+	}
+
+	got := p.Data()
+	for k, v := range doc {
+		if _, ok := got[k]; !ok {
+			t.Errorf("Data dropped %s", k)
+			continue
+		}
+		if k == "images" || k == "address" || k == "underwriting" {
+			continue
+		}
+		if gf, ok := toFloat(got[k]); ok {
+			if wf, _ := toFloat(v); gf != wf {
+				t.Errorf("%s = %v, want %v", k, got[k], v)
+			}
+		} else if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if !reflect.DeepEqual(got["address"], doc["address"]) {
+		t.Errorf("address = %v", got["address"])
+	}
+
+	// Extra never overrides a model field.
+	p.Extra["price"] = 1.0
+	if p.Data()["price"] != 450000.0 {
+		t.Error("Extra overrode price")
+	}
+}
+
+// TestPropertyLegacyAddress verifies string addresses from the legacy client
+// and mistyped values decode without failing.
+func TestPropertyLegacyAddress(t *testing.T) {
+	p := PropertyFromData("p1", map[string]any{"address": "12 Main St, Austin TX", "price": "n/a"})
+	if p.Address.UnparsedAddress != "12 Main St, Austin TX" || p.ListPrice != 0 {
+		t.Errorf("property = %+v", p)
+	}
+	if addr := p.Data()["address"].(map[string]any); addr["street"] != "12 Main St, Austin TX" {
+		t.Errorf("address = %v", addr)
+	}
+}
+
+// TestPropertyJSONOmitsExtra verifies document bookkeeping kept in Extra is
+// not serialized to clients.
+func TestPropertyJSONOmitsExtra(t *testing.T) {
+	p := PropertyFromData("mls_1", map[string]any{
+		"price":      450000.0,
+		"provenance": map[string]any{"price": map[string]any{"source": "mls"}},
+		"rawPayload": map[string]any{"uri": "gs://raw/mls_1.json"},
+		"address":    map[string]any{"street": "12 Oak St", "county": "Travis"},
+	})
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"Extra", "provenance", "rawPayload", "Travis"} {
+		if strings.Contains(string(b), key) {
+			t.Errorf("JSON exposes %s: %s", key, b)
+		}
+	}
+	if !strings.Contains(string(b), `"ListPrice":450000`) {
+		t.Errorf("JSON = %s", b)
+	}
+}
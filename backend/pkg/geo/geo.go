@@ -0,0 +1,278 @@
+// Package geo provides geohash encoding, range covering and simple spherical
+// geometry used to index and search property coordinates in Firestore.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// earthRadiusMeters is the mean Earth radius used by Distance.
+const earthRadiusMeters = 6371008.8
+
+// DefaultPrecision is the geohash length stored on documents (~4.8m x 4.8m).
+const DefaultPrecision = 9
+
+// Point is a latitude/longitude pair in degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Valid reports whether p is a real coordinate. (0,0) is treated as missing
+// since providers use it as a placeholder.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180 && !(p.Lat == 0 && p.Lng == 0)
+}
+
+// BBox is a latitude/longitude bounding box. Boxes crossing the antimeridian
+// are not supported.
+type BBox struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+// Validate checks the box is well formed.
+func (b BBox) Validate() error {
+	if b.South < -90 || b.North > 90 || b.West < -180 || b.East > 180 {
+		return fmt.Errorf("bounding box out of range")
+	}
+	if b.South > b.North || b.West > b.East {
+		return fmt.Errorf("bounding box must have south <= north and west <= east")
+	}
+	return nil
+}
+
+// Contains reports whether p lies inside b (inclusive).
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.South && p.Lat <= b.North && p.Lng >= b.West && p.Lng <= b.East
+}
+
+// Center returns the midpoint of b.
+func (b BBox) Center() Point {
+	return Point{Lat: (b.South + b.North) / 2, Lng: (b.West + b.East) / 2}
+}
+
+// Encode returns the geohash of p at the given precision (1-12).
+func Encode(p Point, precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > 12 {
+		precision = 12
+	}
+	latLo, latHi := -90.0, 90.0
+	lngLo, lngHi := -180.0, 180.0
+	var sb strings.Builder
+	even := true
+	bit, ch := 0, 0
+	for sb.Len() < precision {
+		if even {
+			mid := (lngLo + lngHi) / 2
+			if p.Lng >= mid {
+				ch |= 1 << (4 - bit)
+				lngLo = mid
+			} else {
+				lngHi = mid
+			}
+		} else {
+			mid := (latLo + latHi) / 2
+			if p.Lat >= mid {
+				ch |= 1 << (4 - bit)
+				latLo = mid
+			} else {
+				latHi = mid
+			}
+		}
+		even = !even
+		if bit < 4 {
+			bit++
+		} else {
+			sb.WriteByte(base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// CellSize returns the height and width in degrees of a geohash cell at the
+// given precision.
+func CellSize(precision int) (latDeg, lngDeg float64) {
+	bits := 5 * precision
+	lngBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lngBits))
+}
+
+// Cover returns geohash prefixes whose cells together cover b, using the
+// finest precision that needs at most maxCells prefixes. Each prefix maps to a
+// single range query (prefix <= geohash < prefix+"~").
+func Cover(b BBox, maxCells int) []string {
+	if maxCells < 1 {
+		maxCells = 1
+	}
+	best := []string{""}
+	for precision := 1; precision <= DefaultPrecision; precision++ {
+		cells := coverAt(b, precision, maxCells)
+		if cells == nil {
+			break
+		}
+		best = cells
+	}
+	return best
+}
+
+// coverAt enumerates the cells covering b at precision, or nil when more than
+// limit are needed.
+func coverAt(b BBox, precision, limit int) []string {
+	h, w := CellSize(precision)
+	rows := int(math.Floor(b.North/h) - math.Floor(b.South/h) + 1)
+	cols := int(math.Floor(b.East/w) - math.Floor(b.West/w) + 1)
+	if rows*cols > limit {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	for i := 0; i < rows; i++ {
+		lat := math.Min(b.South+float64(i)*h, b.North)
+		for j := 0; j < cols; j++ {
+			lng := math.Min(b.West+float64(j)*w, b.East)
+			gh := Encode(Point{Lat: lat, Lng: lng}, precision)
+			if !seen[gh] {
+				seen[gh] = true
+				out = append(out, gh)
+			}
+		}
+	}
+	// Stepping by cell size from the south-west corner can skip the last
+	// row/column; make sure the other corners are covered too.
+	for _, p := range []Point{{b.North, b.West}, {b.South, b.East}, {b.North, b.East}} {
+		gh := Encode(p, precision)
+		if !seen[gh] {
+			seen[gh] = true
+			out = append(out, gh)
+		}
+	}
+	if len(out) > limit {
+		return nil
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Distance returns the great-circle distance between a and b in meters.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// RadiusBBox returns a box enclosing the circle of radius meters around c.
+func RadiusBBox(c Point, meters float64) BBox {
+	dLat := meters / earthRadiusMeters * 180 / math.Pi
+	cos := math.Cos(c.Lat * math.Pi / 180)
+	dLng := 180.0
+	if cos > 1e-9 {
+		dLng = math.Min(180, dLat/cos)
+	}
+	return BBox{
+		South: math.Max(-90, c.Lat-dLat),
+		North: math.Min(90, c.Lat+dLat),
+		West:  math.Max(-180, c.Lng-dLng),
+		East:  math.Min(180, c.Lng+dLng),
+	}
+}
+
+// Polygon is a closed ring of points; the closing edge is implied.
+type Polygon []Point
+
+// Bounds returns the bounding box of the polygon.
+func (pg Polygon) Bounds() BBox {
+	b := BBox{South: 90, North: -90, West: 180, East: -180}
+	for _, p := range pg {
+		b.South = math.Min(b.South, p.Lat)
+		b.North = math.Max(b.North, p.Lat)
+		b.West = math.Min(b.West, p.Lng)
+		b.East = math.Max(b.East, p.Lng)
+	}
+	return b
+}
+
+// Centroid returns the vertex average of the polygon, which is adequate for
+// ordering results by distance.
+func (pg Polygon) Centroid() Point {
+	var c Point
+	for _, p := range pg {
+		c.Lat += p.Lat
+		c.Lng += p.Lng
+	}
+	if n := float64(len(pg)); n > 0 {
+		c.Lat /= n
+		c.Lng /= n
+	}
+	return c
+}
+
+// Contains reports whether p lies inside the polygon using ray casting.
+func (pg Polygon) Contains(p Point) bool {
+	inside := false
+	for i, j := 0, len(pg)-1; i < len(pg); j, i = i, i+1 {
+		a, b := pg[i], pg[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ParseBBox parses "south,west,north,east".
+func ParseBBox(s string) (BBox, error) {
+	v, err := parseFloats(s, ",")
+	if err != nil || len(v) != 4 {
+		return BBox{}, fmt.Errorf("bbox must be south,west,north,east")
+	}
+	b := BBox{South: v[0], West: v[1], North: v[2], East: v[3]}
+	return b, b.Validate()
+}
+
+// ParsePolygon parses "lat,lng;lat,lng;..." with at least three vertices.
+func ParsePolygon(s string) (Polygon, error) {
+	var pg Polygon
+	for _, pair := range strings.Split(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		v, err := parseFloats(pair, ",")
+		if err != nil || len(v) != 2 {
+			return nil, fmt.Errorf("polygon must be lat,lng;lat,lng;...")
+		}
+		pg = append(pg, Point{Lat: v[0], Lng: v[1]})
+	}
+	if len(pg) < 3 {
+		return nil, fmt.Errorf("polygon needs at least 3 points")
+	}
+	return pg, nil
+}
+
+func parseFloats(s, sep string) ([]float64, error) {
+	parts := strings.Split(s, sep)
+	out := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
@@ -0,0 +1,76 @@
+package geo
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestEncode checks against well-known geohash values.
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		p    Point
+		prec int
+		want string
+	}{
+		{Point{57.64911, 10.40744}, 11, "u4pruydqqvj"},
+		{Point{37.7749, -122.4194}, 6, "9q8yyk"},
+		{Point{-33.8688, 151.2093}, 5, "r3gx2"},
+	}
+	for _, tt := range tests {
+		if got := Encode(tt.p, tt.prec); got != tt.want {
+			t.Errorf("Encode(%v, %d) = %q, want %q", tt.p, tt.prec, got, tt.want)
+		}
+	}
+}
+
+// TestCoverContainsPoints verifies every point inside a box falls under one
+// of the covering prefixes.
+func TestCoverContainsPoints(t *testing.T) {
+	b := BBox{South: 30.20, West: -97.80, North: 30.35, East: -97.65}
+	cells := Cover(b, 16)
+	if len(cells) == 0 || len(cells) > 16 {
+		t.Fatalf("Cover returned %d cells", len(cells))
+	}
+	for lat := b.South; lat <= b.North; lat += 0.01 {
+		for lng := b.West; lng <= b.East; lng += 0.01 {
+			gh := Encode(Point{lat, lng}, DefaultPrecision)
+			found := false
+			for _, c := range cells {
+				if strings.HasPrefix(gh, c) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("point %v,%v (%s) not covered by %v", lat, lng, gh, cells)
+			}
+		}
+	}
+}
+
+// TestDistanceAndShapes covers haversine distance, radius boxes and polygon
+// containment.
+func TestDistanceAndShapes(t *testing.T) {
+	austin := Point{30.2672, -97.7431}
+	dallas := Point{32.7767, -96.7970}
+	if d := Distance(austin, dallas) / 1000; math.Abs(d-293) > 3 {
+		t.Errorf("Austin-Dallas = %.1fkm, want ~293km", d)
+	}
+
+	box := RadiusBBox(austin, 5000)
+	for _, bearing := range []Point{{0.0449, 0}, {-0.0449, 0}, {0, 0.0519}, {0, -0.0519}} {
+		p := Point{austin.Lat + bearing.Lat, austin.Lng + bearing.Lng}
+		if !box.Contains(p) {
+			t.Errorf("radius box %+v should contain %v", box, p)
+		}
+	}
+
+	square := Polygon{{0, 0}, {0, 10}, {10, 10}, {10, 0}}
+	if !square.Contains(Point{5, 5}) || square.Contains(Point{11, 5}) {
+		t.Error("polygon containment is wrong")
+	}
+	if b := square.Bounds(); b != (BBox{South: 0, West: 0, North: 10, East: 10}) {
+		t.Errorf("Bounds = %+v", b)
+	}
+}
@@ -2,39 +2,146 @@ package listings
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	gfs "cloud.google.com/go/firestore"
 	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// AgentMLSConnection captures per-agent MLS metadata used by ingest. This is
+// mlsConnectionsCollection holds one document per agent and board. Documents
+// created before agents could join several boards are keyed by the bare agent
+// uid; newer ones by MLSConnectionID.
+const mlsConnectionsCollection = "mls_connections"
+
+// ErrConnectionExists is returned when an agent already has a connection to
+// the same board.
+var ErrConnectionExists = errors.New("agent already has a connection to this MLS board")
+
+// ErrConnectionRequired is returned when a paged ingest is resumed for an
+// agent with several enabled boards without naming the board.
+var ErrConnectionRequired = errors.New("connectionId is required to resume an agent with several MLS boards")
+
+// AgentMLSConnection captures one of an agent's MLS board memberships used by
+// ingest. Agents near state lines often belong to two or three boards. This is
 // intentionally limited to identifiers and preferences (no raw passwords or
-// secrets) so we can safely keep it in Firestore.
+// secrets) so we can safely keep it in Firestore. Each board's own MLS secrets
+// are stored encrypted in mls_credentials under the connection id (see
+// StoreAgentMLSCredentials).
 type AgentMLSConnection struct {
-	AgentUID    string    `firestore:"agentUid" json:"agentUid"`
-	Board       string    `firestore:"board" json:"board"`                 // e.g. Bright MLS, CRMLS
-	MLSAgentID  string    `firestore:"mlsAgentId" json:"mlsAgentId"`       // agent identifier in the MLS
-	MLSOfficeID string    `firestore:"mlsOfficeId" json:"mlsOfficeId"`     // brokerage/office id
-	DefaultCity string    `firestore:"defaultCity" json:"defaultCity"`     // default search city for this agent
-	DefaultState string   `firestore:"defaultState" json:"defaultState"`   // default state/region
-	Enabled     bool      `firestore:"enabled" json:"enabled"`
+	ID           string    `firestore:"-" json:"id"`
+	AgentUID     string    `firestore:"agentUid" json:"agentUid"`
+	Board        string    `firestore:"board" json:"board"`               // e.g. Bright MLS, CRMLS
+	MLSAgentID   string    `firestore:"mlsAgentId" json:"mlsAgentId"`     // agent identifier in the MLS
+	MLSOfficeID  string    `firestore:"mlsOfficeId" json:"mlsOfficeId"`   // brokerage/office id
+	DefaultCity  string    `firestore:"defaultCity" json:"defaultCity"`   // default search city for this board
+	DefaultState string    `firestore:"defaultState" json:"defaultState"` // default state/region
+	Enabled      bool      `firestore:"enabled" json:"enabled"`
 	LastSyncedAt time.Time `firestore:"lastSyncedAt" json:"lastSyncedAt"`
-	UpdatedAt   time.Time `firestore:"updatedAt" json:"updatedAt"`
+	UpdatedAt    time.Time `firestore:"updatedAt" json:"updatedAt"`
+}
+
+// Region returns the connection's default search region.
+func (c *AgentMLSConnection) Region() RegionFilter {
+	return RegionFilter{City: c.DefaultCity, State: c.DefaultState}
+}
+
+// SameBoard reports whether board names the connection's board, comparing
+// them the way MLSConnectionID does.
+func (c *AgentMLSConnection) SameBoard(board string) bool {
+	return MLSConnectionID(c.AgentUID, board) == MLSConnectionID(c.AgentUID, c.Board)
+}
+
+// MLSConnectionID returns the document id for an agent's connection to board:
+// the agent uid and a slug of the board name.
+func MLSConnectionID(agentUID, board string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(board) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(sb.String(), "-")
+	if slug == "" {
+		return agentUID
+	}
+	return agentUID + "_" + slug
+}
+
+// ListAgentMLSConnections returns every board connection for an agent, ordered
+// by board name.
+func ListAgentMLSConnections(ctx context.Context, projectID, agentUID string) ([]*AgentMLSConnection, error) {
+	if projectID == "" || agentUID == "" {
+		return nil, fmt.Errorf("projectID and agentUID are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := client.Collection(mlsConnectionsCollection).Where("agentUid", "==", agentUID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*AgentMLSConnection, 0, len(snap))
+	for _, doc := range snap {
+		var conn AgentMLSConnection
+		if err := doc.DataTo(&conn); err != nil {
+			return nil, err
+		}
+		conn.ID = doc.Ref.ID
+		out = append(out, &conn)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Board != out[j].Board {
+			return out[i].Board < out[j].Board
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
 }
 
-// GetAgentMLSConnection loads the MLS connection document for a given agent
-// uid from the mls_connections collection. It returns (nil, nil) when no
-// connection has been configured yet.
+// GetAgentMLSConnection loads the agent's primary MLS connection: the
+// original single-board document when present, otherwise the first board. It
+// returns (nil, nil) when no connection has been configured yet.
 func GetAgentMLSConnection(ctx context.Context, projectID, agentUID string) (*AgentMLSConnection, error) {
 	if agentUID == "" {
 		return nil, nil
 	}
+	conns, err := ListAgentMLSConnections(ctx, projectID, agentUID)
+	if err != nil || len(conns) == 0 {
+		return nil, err
+	}
+	for _, conn := range conns {
+		if conn.ID == agentUID {
+			return conn, nil
+		}
+	}
+	return conns[0], nil
+}
+
+// GetAgentMLSConnectionByID loads one of the agent's board connections. It
+// returns (nil, nil) when the connection does not exist or belongs to another
+// agent.
+func GetAgentMLSConnectionByID(ctx context.Context, projectID, agentUID, id string) (*AgentMLSConnection, error) {
+	if projectID == "" || agentUID == "" || id == "" {
+		return nil, fmt.Errorf("projectID, agentUID and id are required")
+	}
 	client, err := fs.Client(ctx, projectID)
 	if err != nil {
 		return nil, err
 	}
 
-	doc, err := client.Collection("mls_connections").Doc(agentUID).Get(ctx)
+	doc, err := client.Collection(mlsConnectionsCollection).Doc(id).Get(ctx)
 	if err != nil {
 		// If the document does not exist we treat it as no configuration rather
 		// than an error; callers can present an empty state in the UI.
@@ -48,11 +155,64 @@ func GetAgentMLSConnection(ctx context.Context, projectID, agentUID string) (*Ag
 	if err := doc.DataTo(&conn); err != nil {
 		return nil, err
 	}
+	if conn.AgentUID != agentUID {
+		return nil, nil
+	}
+	conn.ID = doc.Ref.ID
 	return &conn, nil
 }
 
-// UpsertAgentMLSConnection writes the agent's MLS connection metadata using a
-// merge update so we do not clobber unrelated fields if we add them later.
+// CreateAgentMLSConnection adds a board connection for conn.AgentUID keyed by
+// MLSConnectionID. It returns ErrConnectionExists when the agent is already
+// connected to the board, including through its original single-board
+// connection keyed by the agent uid.
+func CreateAgentMLSConnection(ctx context.Context, projectID string, conn *AgentMLSConnection) error {
+	if projectID == "" || conn == nil || conn.AgentUID == "" {
+		return fmt.Errorf("projectID and agentUID are required")
+	}
+	if strings.TrimSpace(conn.Board) == "" {
+		return fmt.Errorf("board is required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	conn.ID = MLSConnectionID(conn.AgentUID, conn.Board)
+	conn.UpdatedAt = time.Now()
+	col := client.Collection(mlsConnectionsCollection)
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *gfs.Transaction) error {
+		doc, err := tx.Get(col.Doc(conn.AgentUID))
+		switch {
+		case err == nil:
+			var legacy AgentMLSConnection
+			if err := doc.DataTo(&legacy); err != nil {
+				return err
+			}
+			legacy.AgentUID = conn.AgentUID
+			if boardTaken(&legacy, conn.Board) {
+				return ErrConnectionExists
+			}
+		case !fs.IsNotFound(err):
+			return err
+		}
+		return tx.Create(col.Doc(conn.ID), conn)
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrConnectionExists
+	}
+	return err
+}
+
+// boardTaken reports whether existing already connects its agent to board.
+func boardTaken(existing *AgentMLSConnection, board string) bool {
+	return existing != nil && strings.TrimSpace(existing.Board) != "" && existing.SameBoard(board)
+}
+
+// UpsertAgentMLSConnection writes an MLS connection using a merge update so we
+// do not clobber unrelated fields if we add them later. Without an ID the
+// agent's original single-board document is written, which keeps the
+// one-connection API working.
 func UpsertAgentMLSConnection(ctx context.Context, projectID string, conn *AgentMLSConnection) error {
 	if conn == nil || conn.AgentUID == "" {
 		return nil
@@ -61,6 +221,9 @@ func UpsertAgentMLSConnection(ctx context.Context, projectID string, conn *Agent
 	if err != nil {
 		return err
 	}
+	if conn.ID == "" {
+		conn.ID = conn.AgentUID
+	}
 
 	conn.UpdatedAt = time.Now()
 	data := map[string]any{
@@ -77,16 +240,52 @@ func UpsertAgentMLSConnection(ctx context.Context, projectID string, conn *Agent
 		data["lastSyncedAt"] = conn.LastSyncedAt
 	}
 
-	_, err = client.Collection("mls_connections").Doc(conn.AgentUID).Set(ctx, data, fs.MergeAll())
+	_, err = client.Collection(mlsConnectionsCollection).Doc(conn.ID).Set(ctx, data, fs.MergeAll())
 	return err
 }
 
+// DeleteAgentMLSConnection removes one of the agent's board connections and
+// the credentials stored for it. It reports false when the connection does
+// not exist or belongs to another agent.
+func DeleteAgentMLSConnection(ctx context.Context, projectID, agentUID, id string) (bool, error) {
+	conn, err := GetAgentMLSConnectionByID(ctx, projectID, agentUID, id)
+	if err != nil || conn == nil {
+		return false, err
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return false, err
+	}
+	if _, err := client.Collection(mlsConnectionsCollection).Doc(id).Delete(ctx); err != nil {
+		return false, err
+	}
+	if _, err := client.Collection(mlsCredentialsCollection).Doc(credentialKey(agentUID, id)).Delete(ctx); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// enabledAgentMLSConnections returns the agent's enabled board connections.
+func enabledAgentMLSConnections(ctx context.Context, projectID, agentUID string) ([]*AgentMLSConnection, error) {
+	conns, err := ListAgentMLSConnections(ctx, projectID, agentUID)
+	if err != nil {
+		return nil, err
+	}
+	out := conns[:0]
+	for _, conn := range conns {
+		if conn.Enabled {
+			out = append(out, conn)
+		}
+	}
+	return out, nil
+}
+
 // TouchAgentMLSLastSynced updates only the lastSyncedAt (and updatedAt) fields
-// for an agent's MLS connection document. It is safe to call even if the
-// document does not yet exist; Firestore will create it with a minimal payload
-// that can be enriched later via UpsertAgentMLSConnection.
-func TouchAgentMLSLastSynced(ctx context.Context, projectID, agentUID string, ts time.Time) error {
-	if projectID == "" || agentUID == "" {
+// of one MLS connection document. It is safe to call even if the document
+// does not yet exist; Firestore will create it with a minimal payload that can
+// be enriched later via UpsertAgentMLSConnection.
+func TouchAgentMLSLastSynced(ctx context.Context, projectID, connectionID string, ts time.Time) error {
+	if projectID == "" || connectionID == "" {
 		return nil
 	}
 	client, err := fs.Client(ctx, projectID)
@@ -96,7 +295,7 @@ func TouchAgentMLSLastSynced(ctx context.Context, projectID, agentUID string, ts
 	if ts.IsZero() {
 		ts = time.Now()
 	}
-	_, err = client.Collection("mls_connections").Doc(agentUID).Set(ctx, map[string]any{
+	_, err = client.Collection(mlsConnectionsCollection).Doc(connectionID).Set(ctx, map[string]any{
 		"lastSyncedAt": ts,
 		"updatedAt":    ts,
 	}, fs.MergeAll())
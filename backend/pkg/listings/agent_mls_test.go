@@ -0,0 +1,157 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestMLSConnectionID verifies board names are slugged into stable ids and
+// that a missing board falls back to the original per-agent document.
+func TestMLSConnectionID(t *testing.T) {
+	cases := map[string]string{
+		"Bright MLS":               "uid1_bright-mls",
+		"  CRMLS ":                 "uid1_crmls",
+		"Stellar MLS (FL) / Tampa": "uid1_stellar-mls-fl-tampa",
+		"":                         "uid1",
+		"---":                      "uid1",
+	}
+	for board, want := range cases {
+		if got := MLSConnectionID("uid1", board); got != want {
+			t.Errorf("MLSConnectionID(%q) = %q, want %q", board, got, want)
+		}
+	}
+}
+
+// TestSameBoard verifies board comparisons ignore case and punctuation.
+func TestSameBoard(t *testing.T) {
+	c := &AgentMLSConnection{AgentUID: "uid1", Board: "Bright MLS"}
+	if !c.SameBoard("bright-mls") || !c.SameBoard(" BRIGHT MLS ") || c.SameBoard("CRMLS") {
+		t.Error("board comparison mismatch")
+	}
+}
+
+// TestBoardTaken verifies a new connection conflicts with the agent's
+// original single-board connection on the same board.
+func TestBoardTaken(t *testing.T) {
+	legacy := &AgentMLSConnection{ID: "uid1", AgentUID: "uid1", Board: "Bright MLS"}
+	if !boardTaken(legacy, "bright mls") {
+		t.Error("duplicate of the legacy board allowed")
+	}
+	if boardTaken(legacy, "CRMLS") || boardTaken(nil, "Bright MLS") ||
+		boardTaken(&AgentMLSConnection{ID: "uid1", AgentUID: "uid1"}, "Bright MLS") {
+		t.Error("unrelated board reported as taken")
+	}
+}
+
+// TestIngestOutcomeMerge verifies fanned-out board outcomes are totalled and
+// their errors attributed to the board.
+func TestIngestOutcomeMerge(t *testing.T) {
+	out := &IngestOutcome{Provider: ProviderMLS, Summary: &UpsertSummary{Provider: ProviderMLS}}
+	out.merge(&IngestOutcome{
+		ConnectionID: "uid1_bright-mls",
+		Fetched:      10,
+		Pages:        1,
+		Summary:      &UpsertSummary{Attempted: 10, Created: 4},
+		Alerts:       2,
+	}, "Bright MLS")
+	out.merge(&IngestOutcome{
+		ConnectionID:     "uid1_crmls",
+		Fetched:          5,
+		Pages:            2,
+		Summary:          &UpsertSummary{Attempted: 5, Updated: 5},
+		Errors:           []string{"page 3: timeout"},
+		AgentCredentials: true,
+	}, "CRMLS")
+
+	if out.Fetched != 15 || out.Pages != 3 || out.Alerts != 2 || !out.AgentCredentials {
+		t.Errorf("totals = %+v", out)
+	}
+	if out.Summary.Attempted != 15 || out.Summary.Created != 4 || out.Summary.Updated != 5 {
+		t.Errorf("summary = %+v", out.Summary)
+	}
+	if len(out.Errors) != 1 || out.Errors[0] != "CRMLS: page 3: timeout" {
+		t.Errorf("errors = %v", out.Errors)
+	}
+	if len(out.Connections) != 2 || out.Connections[1].ConnectionID != "uid1_crmls" {
+		t.Errorf("connections = %+v", out.Connections)
+	}
+}
+
+// boardProvider records the credentials it was given so a fan-out can be
+// checked board by board.
+type boardProvider struct {
+	stubProvider
+	creds MLSCredentials
+}
+
+func (p boardProvider) WithCredentials(creds MLSCredentials) Provider {
+	p.creds = creds
+	return p
+}
+
+// TestIngestBoardsUsesEachBoardsCredentials verifies a two-board fan-out
+// authenticates every board with the credentials stored for it.
+func TestIngestBoardsUsesEachBoardsCredentials(t *testing.T) {
+	stored := map[string]MLSCredentials{
+		"uid1_bright-mls": {ClientID: "bright", BaseURL: "https://api.bridgedataoutput.com"},
+		"uid1_crmls":      {ClientID: "crmls", BaseURL: "https://api.mlsgrid.com"},
+	}
+	prev := loadMLSCredentials
+	loadMLSCredentials = func(_ context.Context, _, agentUID, connectionID string) (*MLSCredentials, error) {
+		if c, ok := stored[credentialKey(agentUID, connectionID)]; ok {
+			return &c, nil
+		}
+		return nil, nil
+	}
+	t.Cleanup(func() { loadMLSCredentials = prev })
+
+	conns := []*AgentMLSConnection{
+		{ID: "uid1_bright-mls", AgentUID: "uid1", Board: "Bright MLS"},
+		{ID: "uid1_crmls", AgentUID: "uid1", Board: "CRMLS"},
+	}
+	used := map[string]MLSCredentials{}
+	req := IngestRequest{Provider: ProviderMLS, AgentUID: "uid1"}
+	out, err := ingestBoards(req, conns, func(boardReq IngestRequest, conn *AgentMLSConnection) (*IngestOutcome, error) {
+		prov, asAgent, err := agentProvider(context.Background(), "p", boardProvider{stubProvider: stubProvider{key: ProviderMLS}}, boardReq.AgentUID, boardReq.ConnectionID)
+		if err != nil {
+			return nil, err
+		}
+		used[conn.ID] = prov.(boardProvider).creds
+		return &IngestOutcome{ConnectionID: boardReq.ConnectionID, AgentCredentials: asAgent, Summary: &UpsertSummary{}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range stored {
+		if used[id] != want {
+			t.Errorf("board %s used %+v, want %+v", id, used[id], want)
+		}
+	}
+	if len(out.Connections) != 2 || out.Connections[0].ConnectionID != "uid1_bright-mls" || !out.AgentCredentials {
+		t.Errorf("outcome = %+v", out)
+	}
+
+	// A board failing does not stop the other.
+	out, err = ingestBoards(req, conns, func(boardReq IngestRequest, conn *AgentMLSConnection) (*IngestOutcome, error) {
+		if conn.Board == "Bright MLS" {
+			return nil, errors.New("401")
+		}
+		return &IngestOutcome{ConnectionID: conn.ID, Summary: &UpsertSummary{}}, nil
+	})
+	if err != nil || len(out.Connections) != 1 || len(out.Errors) != 1 || out.Errors[0] != "Bright MLS: 401" {
+		t.Errorf("partial outcome = %+v, err = %v", out, err)
+	}
+}
+
+// TestCredentialKey verifies credentials are keyed per board and never
+// resolve to another agent's connection.
+func TestCredentialKey(t *testing.T) {
+	if credentialKey("uid1", "") != "uid1" || credentialKey("uid1", "uid1_crmls") != "uid1_crmls" {
+		t.Error("credential key mismatch")
+	}
+	if !ownsConnectionID("uid1", "uid1") || !ownsConnectionID("uid1", "uid1_crmls") ||
+		ownsConnectionID("uid1", "uid12_crmls") || ownsConnectionID("uid1", "uid2_crmls") {
+		t.Error("connection ownership mismatch")
+	}
+}
@@ -0,0 +1,417 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/geo"
+)
+
+// SourceAgent is the provenance source of values an agent entered through
+// the property API. Provider feeds never override them.
+const SourceAgent ProviderKey = "agent"
+
+// agentPropertyPrefix prefixes the ids of properties created by agents, so
+// they cannot collide with a provider's {provider}_{externalId} ids.
+const agentPropertyPrefix = "agent_"
+
+// auditLogsCollection is the audit trail shared with the Cloud Functions.
+const auditLogsCollection = "audit_logs"
+
+// Audit log types written for property API writes.
+const (
+	AuditPropertyCreate   = "property_create"
+	AuditPropertyUpdate   = "property_update"
+	AuditPropertyWithdraw = "property_withdraw"
+	AuditPropertyDelete   = "property_delete"
+)
+
+// statusReasonWithdrawn marks a property its agent took off the market.
+const statusReasonWithdrawn = "withdrawn"
+
+var (
+	// ErrPropertyNotFound is returned when the property does not exist.
+	ErrPropertyNotFound = errors.New("property not found")
+	// ErrNotPropertyOwner is returned when an agent writes a property
+	// another agent owns.
+	ErrNotPropertyOwner = errors.New("property belongs to another agent")
+	// ErrNotAgentProperty is returned when deleting a property that was not
+	// created through the property API.
+	ErrNotAgentProperty = errors.New("property was not created by an agent")
+)
+
+// agentRequiredRules are quality warnings an agent's own listing must not
+// have: unlike a feed row, the agent can fix them before saving.
+var agentRequiredRules = map[string]bool{
+	"price_missing":  true,
+	"street_missing": true,
+	"state_missing":  true,
+}
+
+// PropertyActor is the user writing a property through the API.
+type PropertyActor struct {
+	UID string
+	// Admin may write any property and assign it to any agent.
+	Admin bool
+}
+
+// owns reports whether a may write a property owned by agentID.
+func (a PropertyActor) owns(agentID string) bool {
+	return a.Admin || (a.UID != "" && agentID == a.UID)
+}
+
+// PropertyValidationError lists the rules an agent's property failed.
+type PropertyValidationError struct {
+	Issues []QualityIssue
+}
+
+func (e *PropertyValidationError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.Message
+	}
+	return "invalid property: " + strings.Join(msgs, "; ")
+}
+
+// ValidateAgentProperty checks a property an agent is saving. It runs the
+// ingest quality rules, treating any error and a missing price, street or
+// state as fatal, and requires a known status.
+func ValidateAgentProperty(p *fs.Property, now time.Time) error {
+	var issues []QualityIssue
+	for _, issue := range ValidateListing(listingFromProperty(p), 0, now).Issues {
+		if issue.Severity == SeverityError || agentRequiredRules[issue.Rule] {
+			issue.Severity = SeverityError
+			issues = append(issues, issue)
+		}
+	}
+	if NormalizeStatus(p.StandardStatus) == StatusUnknown {
+		issues = append(issues, QualityIssue{"status_invalid", "status", SeverityError, fmt.Sprintf("status %q is not recognized", p.StandardStatus), 0})
+	}
+	if len(issues) > 0 {
+		return &PropertyValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// listingFromProperty maps a property back onto a listing so validation and
+// history detection can be shared with ingest.
+func listingFromProperty(p *fs.Property) ExternalListing {
+	return ExternalListing{
+		Source:     SourceAgent,
+		ExternalID: p.ID,
+		Status:     p.StandardStatus,
+		ListPrice:  p.ListPrice,
+		Address: Address{
+			Street1: p.Address.UnparsedAddress,
+			City:    p.Address.City,
+			State:   p.Address.StateOrProvince,
+			Postal:  p.Address.PostalCode,
+			Lat:     p.Address.Latitude,
+			Lng:     p.Address.Longitude,
+		},
+		Beds:            float64(p.BedroomsTotal),
+		Baths:           p.BathroomsTotalDecimal,
+		Sqft:            float64(p.LivingArea),
+		Lat:             p.Address.Latitude,
+		Lng:             p.Address.Longitude,
+		LotSizeSqft:     p.LotSizeSquareFeet,
+		HOAFee:          p.AssociationFee,
+		YearBuilt:       p.YearBuilt,
+		Stories:         p.Stories,
+		ParkingTotal:    p.ParkingTotal,
+		GarageSpaces:    p.GarageSpaces,
+		PropertySubType: p.PropertySubType,
+		Remarks:         p.PublicRemarks,
+		Photos:          p.PhotoURLs,
+		ListedAt:        p.OnMarketDate,
+	}
+}
+
+// sanitizeAgentProperty clears what a client may not set: the id, the
+// originating system, timestamps and keys outside the model. Agents cannot
+// reassign a property; admins can.
+func sanitizeAgentProperty(p *fs.Property, actor PropertyActor) {
+	p.ID = ""
+	p.ListingID = ""
+	p.OriginatingSystemName = ""
+	p.OriginalEntryTimestamp = time.Time{}
+	p.ModificationTimestamp = time.Time{}
+	p.Extra = nil
+	p.Address.Extra = nil
+	if !actor.Admin {
+		p.AgentID = ""
+	}
+	if p.StandardStatus != "" {
+		if s := NormalizeStatus(p.StandardStatus); s != StatusUnknown {
+			p.StandardStatus = s
+		}
+	}
+}
+
+// agentFields completes a properties update from an agent: the search keys
+// ingest derives from the address and price, and agent provenance for every
+// resolved field it sets. merged is the property as it will read after the
+// update.
+func agentFields(prev, data map[string]any, merged *fs.Property, now time.Time) {
+	if _, ok := data["address"]; ok {
+		l := listingFromProperty(merged)
+		if pt := (geo.Point{Lat: l.Lat, Lng: l.Lng}); pt.Valid() {
+			data["geohash"] = geo.Encode(pt, geo.DefaultPrecision)
+		}
+		if key := StandardAddress(l.Address).Key(); key != "" {
+			data["addressKey"] = key
+		}
+	}
+	derivePricePerSqft(prev, data)
+	if imgs, ok := data["images"]; ok && mediaChanged(prev, stringList(imgs)) {
+		data["mediaStatus"] = MediaPending
+	}
+
+	prov := map[string]any{}
+	for field, keys := range provenanceFields {
+		if _, ok := data[keys[0]]; ok {
+			prov[field] = map[string]any{"source": string(SourceAgent), "at": now}
+		}
+	}
+	if len(prov) > 0 {
+		data["provenance"] = prov
+	}
+	data["updatedAt"] = now
+}
+
+// overlayDoc returns prev with update applied the way a MergeAll write
+// would: nested maps are merged rather than replaced.
+func overlayDoc(prev, update map[string]any) map[string]any {
+	out := make(map[string]any, len(prev)+len(update))
+	for k, v := range prev {
+		out[k] = v
+	}
+	for k, v := range update {
+		if m, ok := v.(map[string]any); ok {
+			if cur, ok := out[k].(map[string]any); ok {
+				v = overlayDoc(cur, m)
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// auditEntry is an audit_logs document for a property write.
+type auditEntry struct {
+	Type       string        `firestore:"type"`
+	PropertyID string        `firestore:"propertyId"`
+	AgentID    string        `firestore:"agentId,omitempty"`
+	ActorUID   string        `firestore:"actorUid"`
+	Admin      bool          `firestore:"admin"`
+	Changes    []FieldChange `firestore:"changes,omitempty"`
+	Timestamp  time.Time     `firestore:"timestamp"`
+}
+
+// auditPropertyWrite appends to the audit trail. The property write already
+// succeeded, so failures are only logged.
+func auditPropertyWrite(ctx context.Context, client *gfs.Client, typ, propertyID, agentID string, actor PropertyActor, changes []FieldChange, now time.Time) {
+	entry := auditEntry{
+		Type:       typ,
+		PropertyID: propertyID,
+		AgentID:    agentID,
+		ActorUID:   actor.UID,
+		Admin:      actor.Admin,
+		Changes:    changes,
+		Timestamp:  now,
+	}
+	if _, _, err := client.Collection(auditLogsCollection).Add(ctx, entry); err != nil {
+		log.Printf("[listings] failed to write %s audit log for property %s: %v", typ, propertyID, err)
+	}
+}
+
+// loadOwnedProperty reads a property and checks actor may write it.
+func loadOwnedProperty(ctx context.Context, client *gfs.Client, actor PropertyActor, id string) (*gfs.DocumentRef, map[string]any, error) {
+	ref := client.Collection("properties").Doc(id)
+	snap, err := ref.Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return nil, nil, ErrPropertyNotFound
+		}
+		return nil, nil, err
+	}
+	prev := snap.Data()
+	if agentID, _ := prev["agentId"].(string); !actor.owns(agentID) {
+		return nil, nil, ErrNotPropertyOwner
+	}
+	return ref, prev, nil
+}
+
+// CreateAgentProperty stores a pocket or off-market listing entered by an
+// agent and fills in its id and timestamps. The property belongs to the
+// actor unless an admin names another agent; its status defaults to active.
+func CreateAgentProperty(ctx context.Context, projectID string, actor PropertyActor, p *fs.Property) error {
+	if projectID == "" || actor.UID == "" || p == nil {
+		return fmt.Errorf("projectID, actor and property are required")
+	}
+	sanitizeAgentProperty(p, actor)
+	if p.AgentID == "" {
+		p.AgentID = actor.UID
+	}
+	if p.StandardStatus == "" {
+		p.StandardStatus = StatusActive
+	}
+	now := time.Now()
+	if err := ValidateAgentProperty(p, now); err != nil {
+		return err
+	}
+
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	p.ID = agentPropertyPrefix + client.Collection("properties").NewDoc().ID
+	p.OriginatingSystemName = string(SourceAgent)
+	p.OriginalEntryTimestamp, p.ModificationTimestamp = now, now
+	if p.OnMarketDate.IsZero() && p.StandardStatus == StatusActive {
+		p.OnMarketDate = now
+	}
+
+	data := p.Data()
+	agentFields(nil, data, p, now)
+	if _, err := client.Collection("properties").Doc(p.ID).Create(ctx, data); err != nil {
+		return err
+	}
+
+	if err := recordHistoryEvents(ctx, client, p.ID, detectListingChanges(nil, listingFromProperty(p), now)); err != nil {
+		log.Printf("[listings] failed to record history for property %s: %v", p.ID, err)
+	}
+	auditPropertyWrite(ctx, client, AuditPropertyCreate, p.ID, p.AgentID, actor, diffFields(nil, data), now)
+	return nil
+}
+
+// UpdateAgentProperty applies the non-zero fields of patch to a property the
+// actor owns and returns the updated property. Fields it sets are marked as
+// agent-owned, so later ingest runs leave them alone.
+func UpdateAgentProperty(ctx context.Context, projectID string, actor PropertyActor, id string, patch *fs.Property) (*fs.Property, error) {
+	if projectID == "" || actor.UID == "" || id == "" || patch == nil {
+		return nil, fmt.Errorf("projectID, actor, id and patch are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	ref, prev, err := loadOwnedProperty(ctx, client, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sanitizeAgentProperty(patch, actor)
+	now := time.Now()
+	data := patch.Data()
+	merged := fs.PropertyFromData(id, overlayDoc(prev, data))
+	if err := ValidateAgentProperty(merged, now); err != nil {
+		return nil, err
+	}
+	changes := diffFields(prev, data)
+	if len(changes) == 0 {
+		return merged, nil
+	}
+
+	agentFields(prev, data, merged, now)
+	if _, ok := data["status"]; ok {
+		data["statusReason"] = gfs.Delete
+	}
+	if _, err := ref.Set(ctx, data, fs.MergeAll()); err != nil {
+		return nil, err
+	}
+	merged.ModificationTimestamp = now
+
+	if err := recordHistoryEvents(ctx, client, id, detectListingChanges(prev, listingFromProperty(patch), now)); err != nil {
+		log.Printf("[listings] failed to record history for property %s: %v", id, err)
+	}
+	auditPropertyWrite(ctx, client, AuditPropertyUpdate, id, merged.AgentID, actor, changes, now)
+	return merged, nil
+}
+
+// WithdrawAgentProperty takes a property the actor owns off the market. The
+// document and its history stay, and withdrawing twice is a no-op.
+func WithdrawAgentProperty(ctx context.Context, projectID string, actor PropertyActor, id string) (*fs.Property, error) {
+	if projectID == "" || actor.UID == "" || id == "" {
+		return nil, fmt.Errorf("projectID, actor and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	ref, prev, err := loadOwnedProperty(ctx, client, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if status, _ := prev["status"].(string); NormalizeStatus(status) == StatusOffMarket {
+		return fs.PropertyFromData(id, prev), nil
+	}
+
+	now := time.Now()
+	data := map[string]any{
+		"status":       StatusOffMarket,
+		"statusReason": statusReasonWithdrawn,
+		"withdrawnAt":  now,
+	}
+	changes := diffFields(prev, data)
+	agentFields(prev, data, nil, now)
+	if _, err := ref.Set(ctx, data, fs.MergeAll()); err != nil {
+		return nil, err
+	}
+
+	l := ExternalListing{Source: SourceAgent, Status: StatusOffMarket}
+	if err := recordHistoryEvents(ctx, client, id, detectListingChanges(prev, l, now)); err != nil {
+		log.Printf("[listings] failed to record history for property %s: %v", id, err)
+	}
+	agentID, _ := prev["agentId"].(string)
+	auditPropertyWrite(ctx, client, AuditPropertyWithdraw, id, agentID, actor, changes, now)
+	return fs.PropertyFromData(id, overlayDoc(prev, data)), nil
+}
+
+// DeleteAgentProperty removes a property created through the property API,
+// along with its history. Provider listings cannot be deleted this way; the
+// next ingest would only recreate them.
+func DeleteAgentProperty(ctx context.Context, projectID string, actor PropertyActor, id string) error {
+	if projectID == "" || actor.UID == "" || id == "" {
+		return fmt.Errorf("projectID, actor and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	ref, prev, err := loadOwnedProperty(ctx, client, actor, id)
+	if err != nil {
+		return err
+	}
+	if source, _ := prev["source"].(string); ProviderKey(source) != SourceAgent {
+		return ErrNotAgentProperty
+	}
+
+	history, err := ref.Collection("history").DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(history); start += maxBatchWrites {
+		end := min(start+maxBatchWrites, len(history))
+		batch := client.Batch()
+		for _, h := range history[start:end] {
+			batch.Delete(h)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	if _, err := ref.Delete(ctx); err != nil {
+		return err
+	}
+
+	agentID, _ := prev["agentId"].(string)
+	auditPropertyWrite(ctx, client, AuditPropertyDelete, id, agentID, actor, nil, time.Now())
+	return nil
+}
@@ -0,0 +1,156 @@
+package listings
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+)
+
+func sampleAgentProperty() *fs.Property {
+	return &fs.Property{
+		StandardStatus: "Coming Soon",
+		ListPrice:      525000,
+		Address: fs.PropertyAddress{
+			UnparsedAddress: "12 Oak St", City: "Austin", StateOrProvince: "TX", PostalCode: "78701",
+			Latitude: 30.27, Longitude: -97.74,
+		},
+		BedroomsTotal: 3,
+		LivingArea:    1800,
+	}
+}
+
+// TestValidateAgentProperty verifies feed warnings an agent can fix are
+// fatal and unknown statuses are rejected.
+func TestValidateAgentProperty(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	p := sampleAgentProperty()
+	sanitizeAgentProperty(p, PropertyActor{UID: "a1"})
+	if p.StandardStatus != StatusActive {
+		t.Errorf("status = %q", p.StandardStatus)
+	}
+	if err := ValidateAgentProperty(p, now); err != nil {
+		t.Fatalf("valid property rejected: %v", err)
+	}
+
+	p.ListPrice = 0
+	p.Address.StateOrProvince = ""
+	p.StandardStatus = "bogus"
+	var verr *PropertyValidationError
+	if err := ValidateAgentProperty(p, now); !errors.As(err, &verr) || len(verr.Issues) != 3 {
+		t.Fatalf("err = %v", err)
+	}
+	for _, issue := range verr.Issues {
+		if issue.Severity != SeverityError {
+			t.Errorf("issue %s severity = %s", issue.Rule, issue.Severity)
+		}
+	}
+
+	// Sqft is only a warning: pocket listings may not know it yet.
+	p = sampleAgentProperty()
+	p.LivingArea = 0
+	if err := ValidateAgentProperty(p, now); err != nil {
+		t.Errorf("missing sqft rejected: %v", err)
+	}
+}
+
+// TestSanitizeAgentProperty verifies clients cannot set ids, sources or
+// keys outside the model, and only admins can reassign.
+func TestSanitizeAgentProperty(t *testing.T) {
+	p := &fs.Property{
+		ID: "mls_1", ListingID: "1", OriginatingSystemName: "mls", AgentID: "a2",
+		Extra: map[string]any{"provenance": map[string]any{}},
+	}
+	sanitizeAgentProperty(p, PropertyActor{UID: "a1"})
+	if p.ID != "" || p.ListingID != "" || p.OriginatingSystemName != "" || p.AgentID != "" || p.Extra != nil {
+		t.Errorf("agent property = %+v", p)
+	}
+	p.AgentID = "a2"
+	sanitizeAgentProperty(p, PropertyActor{UID: "admin", Admin: true})
+	if p.AgentID != "a2" {
+		t.Error("admin could not assign the agent")
+	}
+
+	if !(PropertyActor{UID: "a1"}).owns("a1") || (PropertyActor{UID: "a1"}).owns("a2") ||
+		(PropertyActor{UID: "a1"}).owns("") || !(PropertyActor{UID: "x", Admin: true}).owns("a2") {
+		t.Error("ownership mismatch")
+	}
+}
+
+// TestAgentFields verifies agent writes derive search keys and claim
+// provenance for the fields they set.
+func TestAgentFields(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	prev := map[string]any{
+		"price":      500000.0,
+		"squareFeet": int64(2000),
+		"address":    map[string]any{"street": "12 Oak St", "city": "Austin", "state": "TX"},
+	}
+	patch := &fs.Property{ListPrice: 480000, Address: fs.PropertyAddress{PostalCode: "78701"}}
+	data := patch.Data()
+	merged := fs.PropertyFromData("agent_1", overlayDoc(prev, data))
+	if merged.Address.City != "Austin" || merged.Address.PostalCode != "78701" {
+		t.Fatalf("merged address = %+v", merged.Address)
+	}
+
+	agentFields(prev, data, merged, now)
+	if data["pricePerSqft"] != 240.0 || data["addressKey"] == nil {
+		t.Errorf("derived = %v", data)
+	}
+	prov, _ := data["provenance"].(map[string]any)
+	if len(prov) != 2 || prov["price"] == nil || prov["address"] == nil {
+		t.Errorf("provenance = %v", prov)
+	}
+	if _, ok := data["mediaStatus"]; ok {
+		t.Error("media queued without an images change")
+	}
+}
+
+// TestAgentFieldsQueuesMedia verifies agent-entered photos are queued for the
+// media worker only when the photo list changes.
+func TestAgentFieldsQueuesMedia(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	prev := map[string]any{"images": []any{"https://cdn.example/a.jpg"}, "mediaStatus": MediaReady}
+
+	data := map[string]any{"images": []string{"https://cdn.example/a.jpg", "https://cdn.example/b.jpg"}}
+	agentFields(prev, data, nil, now)
+	if data["mediaStatus"] != MediaPending {
+		t.Errorf("changed photos: mediaStatus = %v", data["mediaStatus"])
+	}
+
+	data = map[string]any{"images": []string{"https://cdn.example/a.jpg"}}
+	agentFields(prev, data, nil, now)
+	if _, ok := data["mediaStatus"]; ok {
+		t.Errorf("unchanged photos: mediaStatus = %v", data["mediaStatus"])
+	}
+
+	data = map[string]any{"images": []string{"https://cdn.example/a.jpg"}}
+	agentFields(nil, data, nil, now)
+	if data["mediaStatus"] != MediaPending {
+		t.Errorf("new property: mediaStatus = %v", data["mediaStatus"])
+	}
+}
+
+// TestIngestKeepsAgentFields verifies provider feeds never override values
+// an agent set, whatever the precedence policy.
+func TestIngestKeepsAgentFields(t *testing.T) {
+	t0 := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	prev := provenanceDoc(map[string]FieldProvenance{
+		"status": {SourceAgent, t0},
+		"price":  {SourceAgent, t0},
+	}, map[string]any{"status": StatusOffMarket, "price": 480000.0})
+
+	l := ExternalListing{ExternalID: "m1", Source: ProviderMLS, Status: "Active", ListPrice: 499000, Beds: 3, UpdatedAt: t0.Add(time.Hour)}
+	data := propertyFields(l)
+	rejected := resolveFields(DefaultPrecedencePolicy(), prev, data, ProviderMLS, t0.Add(time.Hour))
+	if len(rejected) != 2 || data["status"] != nil || data["price"] != nil || data["bedrooms"] == nil {
+		t.Errorf("rejected = %v, data = %v", rejected, data)
+	}
+
+	update := map[string]any{"status": "Active", "price": 499000.0}
+	dropForeignFields(prev, update, ProviderMLS)
+	if len(update) != 0 {
+		t.Errorf("remap update = %v", update)
+	}
+}
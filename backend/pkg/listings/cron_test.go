@@ -0,0 +1,45 @@
+package listings
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCronNext verifies next-activation computation for the expressions used
+// by ingest schedules.
+func TestCronNext(t *testing.T) {
+	from := time.Date(2025, 3, 14, 10, 17, 30, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2025, 3, 17, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 8 1,15 * 0", time.Date(2025, 3, 15, 8, 0, 0, 0, time.UTC)}, // day-of-month OR Sunday
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"17 10 * * *", time.Date(2025, 3, 15, 10, 17, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		cs, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) returned error: %v", tt.expr, err)
+		}
+		if got := cs.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestParseCronRejectsInvalid verifies malformed expressions are rejected.
+func TestParseCronRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a b c d e"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) expected error", expr)
+		}
+	}
+}
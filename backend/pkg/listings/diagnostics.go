@@ -0,0 +1,193 @@
+package listings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+)
+
+// Connection test diagnoses reported by ConnectionTest.Diagnosis.
+const (
+	DiagnosisOK            = "ok"
+	DiagnosisNotConfigured = "not_configured"
+	DiagnosisAuthFailed    = "auth_failed"
+	DiagnosisUpstreamError = "upstream_error"
+	DiagnosisCircuitOpen   = "circuit_open"
+	DiagnosisBadResponse   = "bad_response"
+	DiagnosisRequestError  = "request_error"
+)
+
+// ConnectionTest is the result of a minimal live call to a provider.
+type ConnectionTest struct {
+	Provider    ProviderKey `json:"provider"`
+	Configured  bool        `json:"configured"`
+	OK          bool        `json:"ok"`
+	Diagnosis   string      `json:"diagnosis"`
+	LatencyMs   int64       `json:"latencyMs"`
+	HTTPStatus  int         `json:"httpStatus,omitempty"`
+	AuthValid   *bool       `json:"authValid,omitempty"`
+	SampleCount int         `json:"sampleCount"`
+	Error       string      `json:"error,omitempty"`
+	CheckedAt   time.Time   `json:"checkedAt"`
+}
+
+// TestProviderConnection fetches a single listing from p without persisting
+// anything and classifies the outcome, so admins can tell a bad key
+// (auth_failed) from an upstream outage (upstream_error).
+func TestProviderConnection(ctx context.Context, p Provider) *ConnectionTest {
+	res := &ConnectionTest{Provider: p.Key(), Configured: p.Enabled(), CheckedAt: time.Now()}
+	if !res.Configured {
+		res.Diagnosis = DiagnosisNotConfigured
+		res.Error = ErrNotConfigured.Error()
+		return res
+	}
+
+	start := time.Now()
+	out, err := p.FetchListings(ctx, FetchParams{Limit: 1, MaxPages: 1})
+	res.LatencyMs = time.Since(start).Milliseconds()
+
+	valid := func(v bool) *bool { return &v }
+	var se *StatusError
+	switch {
+	case err == nil:
+		res.OK = true
+		res.Diagnosis = DiagnosisOK
+		res.HTTPStatus = http.StatusOK
+		res.AuthValid = valid(true)
+		if out != nil {
+			res.SampleCount = len(out.Listings)
+		}
+		return res
+	case errors.Is(err, ErrNotConfigured):
+		res.Diagnosis = DiagnosisNotConfigured
+	case errors.Is(err, ErrCircuitOpen):
+		res.Diagnosis = DiagnosisCircuitOpen
+	case errors.As(err, &se):
+		res.HTTPStatus = se.StatusCode
+		switch {
+		case se.StatusCode == http.StatusUnauthorized || se.StatusCode == http.StatusForbidden:
+			res.Diagnosis = DiagnosisAuthFailed
+			res.AuthValid = valid(false)
+		case se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500:
+			res.Diagnosis = DiagnosisUpstreamError
+		default:
+			// The upstream accepted our credentials but rejected the request.
+			res.Diagnosis = DiagnosisRequestError
+			res.AuthValid = valid(true)
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		res.Diagnosis = DiagnosisUpstreamError
+	default:
+		res.Diagnosis = DiagnosisUpstreamError
+		if isDecodeError(err) {
+			res.Diagnosis = DiagnosisBadResponse
+			res.HTTPStatus = http.StatusOK
+			res.AuthValid = valid(true)
+		}
+	}
+	res.Error = err.Error()
+	return res
+}
+
+// isDecodeError reports whether err came from decoding a provider payload,
+// which means the upstream answered 200 with a body we cannot read.
+func isDecodeError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// ProviderRunStats summarizes recent ingest runs for a provider from the
+// ingest_runs ledger.
+type ProviderRunStats struct {
+	Provider      ProviderKey `json:"provider"`
+	Since         time.Time   `json:"since"`
+	Runs          int         `json:"runs"`
+	Succeeded     int         `json:"succeeded"`
+	Partial       int         `json:"partial"`
+	Failed        int         `json:"failed"`
+	SuccessRate   float64     `json:"successRate"`
+	ErrorRate     float64     `json:"errorRate"`
+	AvgDurationMs int64       `json:"avgDurationMs"`
+	LastSuccessAt time.Time   `json:"lastSuccessAt,omitempty"`
+	LastFailureAt time.Time   `json:"lastFailureAt,omitempty"`
+	LastError     string      `json:"lastError,omitempty"`
+}
+
+// maxStatsRuns bounds how many ledger entries ProviderStats reads.
+const maxStatsRuns = 500
+
+// ProviderStats computes success and error rates for provider over runs that
+// started at or after since. Runs still in progress are ignored.
+func ProviderStats(ctx context.Context, projectID string, provider ProviderKey, since time.Time) (*ProviderRunStats, error) {
+	if projectID == "" || provider == "" {
+		return nil, fmt.Errorf("projectID and provider are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := client.Collection(ingestRunsCollection).
+		Where("provider", "==", string(provider)).
+		Where("startedAt", ">=", since).
+		OrderBy("startedAt", gfs.Desc).
+		Limit(maxStatsRuns).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]*IngestRun, 0, len(snap))
+	for _, doc := range snap {
+		var run IngestRun
+		if err := doc.DataTo(&run); err != nil {
+			continue
+		}
+		runs = append(runs, &run)
+	}
+	stats := summarizeRuns(runs)
+	stats.Provider = provider
+	stats.Since = since
+	return stats, nil
+}
+
+// summarizeRuns aggregates runs ordered most recent first.
+func summarizeRuns(runs []*IngestRun) *ProviderRunStats {
+	stats := &ProviderRunStats{}
+	var totalMs int64
+	for _, run := range runs {
+		switch run.Status {
+		case RunStatusSucceeded:
+			stats.Succeeded++
+			if stats.LastSuccessAt.IsZero() {
+				stats.LastSuccessAt = run.StartedAt
+			}
+		case RunStatusPartial:
+			stats.Partial++
+		case RunStatusFailed:
+			stats.Failed++
+			if stats.LastFailureAt.IsZero() {
+				stats.LastFailureAt = run.StartedAt
+				if len(run.Errors) > 0 {
+					stats.LastError = run.Errors[len(run.Errors)-1]
+				}
+			}
+		default:
+			continue
+		}
+		stats.Runs++
+		totalMs += run.DurationMs
+	}
+	if stats.Runs > 0 {
+		stats.SuccessRate = float64(stats.Succeeded) / float64(stats.Runs)
+		stats.ErrorRate = float64(stats.Failed) / float64(stats.Runs)
+		stats.AvgDurationMs = totalMs / int64(stats.Runs)
+	}
+	return stats
+}
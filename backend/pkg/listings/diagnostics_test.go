@@ -0,0 +1,73 @@
+package listings
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestProviderConnectionDiagnosis verifies auth failures, outages and
+// successful calls are classified distinctly.
+func TestProviderConnectionDiagnosis(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		diagnosis string
+		authValid *bool
+		samples   int
+	}{
+		{"ok", http.StatusOK, `{"listings":[{"externalId":"1"}]}`, DiagnosisOK, boolPtr(true), 1},
+		{"bad key", http.StatusUnauthorized, ``, DiagnosisAuthFailed, boolPtr(false), 0},
+		{"outage", http.StatusServiceUnavailable, ``, DiagnosisUpstreamError, nil, 0},
+		{"garbage", http.StatusOK, `<html>`, DiagnosisBadResponse, boolPtr(true), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			p := &ZillowProvider{
+				client:  NewProviderClient(ClientConfig{MaxRetries: -1}),
+				key:     ProviderZillow,
+				BaseURL: srv.URL,
+				APIKey:  "k",
+			}
+			res := TestProviderConnection(context.Background(), p)
+			if res.Diagnosis != tt.diagnosis || res.SampleCount != tt.samples {
+				t.Errorf("diagnosis=%s samples=%d, want %s/%d (err=%s)", res.Diagnosis, res.SampleCount, tt.diagnosis, tt.samples, res.Error)
+			}
+			if (res.AuthValid == nil) != (tt.authValid == nil) || (res.AuthValid != nil && *res.AuthValid != *tt.authValid) {
+				t.Errorf("authValid=%v, want %v", res.AuthValid, tt.authValid)
+			}
+		})
+	}
+
+	res := TestProviderConnection(context.Background(), &ZillowProvider{key: ProviderZillow})
+	if res.Configured || res.Diagnosis != DiagnosisNotConfigured {
+		t.Errorf("unconfigured provider: %+v", res)
+	}
+}
+
+// TestSummarizeRuns verifies rate computation over the run ledger.
+func TestSummarizeRuns(t *testing.T) {
+	runs := []*IngestRun{
+		{Status: RunStatusFailed, Errors: []string{"Zillow provider returned status 401"}, DurationMs: 100},
+		{Status: RunStatusSucceeded, DurationMs: 300},
+		{Status: RunStatusPartial, DurationMs: 200},
+		{Status: RunStatusRunning},
+	}
+	s := summarizeRuns(runs)
+	if s.Runs != 3 || s.Succeeded != 1 || s.Failed != 1 || s.Partial != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+	if s.ErrorRate != 1.0/3 || s.AvgDurationMs != 200 || s.LastError == "" {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func boolPtr(v bool) *bool { return &v }
@@ -0,0 +1,291 @@
+package listings
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProviderFile is the default source key for listings imported from file
+// drops when the caller does not attribute them to a specific provider.
+const ProviderFile ProviderKey = "file"
+
+// Supported bulk import formats.
+const (
+	ImportFormatCSV      = "csv"
+	ImportFormatRESOJSON = "reso_json"
+	ImportFormatIDX      = "idx"
+)
+
+// TriggerFileImport marks ledger entries created by bulk file imports.
+const TriggerFileImport = "file_import"
+
+// maxImportRowErrors caps the row errors returned and recorded per import so a
+// badly mismatched profile does not produce a multi-megabyte response.
+const maxImportRowErrors = 500
+
+// previewSampleSize is the number of mapped listings returned in preview mode.
+const previewSampleSize = 20
+
+// ImportProfile describes how to read a delimited file. Columns maps RESO Data
+// Dictionary field names (ListingId, ListPrice, City, ...) to the column
+// headers used in the file; headers not mentioned are passed through under
+// their own names, so files that already use RESO headers need no mapping.
+type ImportProfile struct {
+	Name string `json:"name,omitempty"`
+	// Delimiter is a single character; defaults to "," for CSV and "|" for IDX.
+	Delimiter string            `json:"delimiter,omitempty"`
+	Columns   map[string]string `json:"columns,omitempty"`
+}
+
+// ImportOptions controls ParseImportFile.
+type ImportOptions struct {
+	Format   string
+	Provider ProviderKey
+	Profile  *ImportProfile
+	// Owner is the importing agent's uid. When set, rows are written to
+	// property ids scoped to the agent and stamped with its agentId.
+	Owner string
+}
+
+// ImportRowError describes a record that could not be imported. Row is
+// 1-based and counts data rows (the CSV header is row 0).
+type ImportRowError struct {
+	Row        int    `json:"row"`
+	ExternalID string `json:"externalId,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ImportReport is the result of a bulk file import or preview.
+type ImportReport struct {
+	Format    string            `json:"format"`
+	Provider  ProviderKey       `json:"provider"`
+	Preview   bool              `json:"preview"`
+	Rows      int               `json:"rows"`
+	Valid     int               `json:"valid"`
+	Invalid   int               `json:"invalid"`
+	Errors    []ImportRowError  `json:"errors,omitempty"`
+	Sample    []ExternalListing `json:"sample,omitempty"`
+	Summary   *UpsertSummary    `json:"summary,omitempty"`
+	RunID     string            `json:"runId,omitempty"`
+	Alerts    int               `json:"alerts,omitempty"`
+	Truncated bool              `json:"errorsTruncated,omitempty"`
+
+	result *IngestResult
+}
+
+func (rep *ImportReport) addError(row int, externalID, msg string) {
+	rep.Invalid++
+	if len(rep.Errors) >= maxImportRowErrors {
+		rep.Truncated = true
+		return
+	}
+	rep.Errors = append(rep.Errors, ImportRowError{Row: row, ExternalID: externalID, Message: msg})
+}
+
+// ParseImportFile parses a CSV, RESO JSON or IDX flat file into an
+// IngestResult. Every record is converted into a RESO-shaped record and mapped
+// with MapRESORecord, so archived raw payloads re-map the same way as RESO
+// feeds. Records that fail to map are reported per row rather than failing the
+// whole file.
+func ParseImportFile(r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	provider := opts.Provider
+	if provider == "" {
+		provider = ProviderFile
+	}
+	rep := &ImportReport{Format: opts.Format, Provider: provider, result: &IngestResult{Provider: provider}}
+
+	var records []json.RawMessage
+	var err error
+	switch opts.Format {
+	case ImportFormatCSV:
+		records, err = readDelimited(r, opts.Profile, ',')
+	case ImportFormatIDX:
+		records, err = readDelimited(r, opts.Profile, '|')
+	case ImportFormatRESOJSON:
+		records, err = readRESOJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]int{}
+	for i, rec := range records {
+		row := i + 1
+		rep.Rows++
+		l, err := MapRESORecord(provider, rec)
+		if err != nil {
+			rep.addError(row, l.ExternalID, err.Error())
+			continue
+		}
+		if first, ok := seen[l.ExternalID]; ok {
+			rep.addError(row, l.ExternalID, fmt.Sprintf("duplicate ListingId (first seen on row %d)", first))
+			continue
+		}
+		seen[l.ExternalID] = row
+		l.OwnerUID = opts.Owner
+		rep.Valid++
+		rep.result.Listings = append(rep.result.Listings, l)
+	}
+	return rep, nil
+}
+
+// readDelimited reads a header row followed by data rows and renders each row
+// as a JSON object keyed by RESO field name.
+func readDelimited(r io.Reader, profile *ImportProfile, defaultDelim rune) ([]json.RawMessage, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = defaultDelim
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	if profile != nil && profile.Delimiter != "" {
+		d := []rune(profile.Delimiter)
+		if len(d) != 1 {
+			return nil, fmt.Errorf("profile delimiter must be a single character")
+		}
+		cr.Comma = d[0]
+	}
+	// IDX exports are frequently unquoted and contain stray quotes in remarks.
+	if cr.Comma == '|' || cr.Comma == '\t' {
+		cr.LazyQuotes = true
+	}
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("file is empty")
+		}
+		return nil, fmt.Errorf("failed to read header row: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	// Invert the profile so each header knows which RESO field it feeds.
+	names := make([]string, len(header))
+	byHeader := map[string]string{}
+	if profile != nil {
+		for field, col := range profile.Columns {
+			byHeader[strings.ToLower(strings.TrimSpace(col))] = field
+		}
+	}
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if field, ok := byHeader[strings.ToLower(h)]; ok {
+			names[i] = field
+		} else {
+			names[i] = h
+		}
+	}
+
+	var out []json.RawMessage
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Keep the row count aligned with the file so row errors point at
+			// the right line; MapRESORecord will reject the empty record.
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				out = append(out, json.RawMessage(`{}`))
+				continue
+			}
+			return nil, err
+		}
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		rec := make(map[string]string, len(row))
+		for i, v := range row {
+			if i >= len(names) || names[i] == "" {
+				continue
+			}
+			if v = strings.TrimSpace(v); v != "" {
+				rec[names[i]] = v
+			}
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// readRESOJSON accepts either a RESO Web API response ({"value": [...]}) or a
+// bare JSON array of records.
+func readRESOJSON(r io.Reader) ([]json.RawMessage, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var arr []json.RawMessage
+		if err := json.Unmarshal(data, &arr); err != nil {
+			return nil, fmt.Errorf("invalid RESO JSON: %w", err)
+		}
+		return arr, nil
+	}
+	var env struct {
+		Value []json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid RESO JSON: %w", err)
+	}
+	return env.Value, nil
+}
+
+// ImportListingsFile parses a file and, unless preview is set, writes the
+// valid records through UpsertExternalListingsToFirestore and records the run
+// in the ingest ledger. In preview mode nothing is written and a sample of the
+// mapped listings is returned instead.
+func ImportListingsFile(ctx context.Context, projectID string, r io.Reader, opts ImportOptions, preview bool, triggeredBy string) (*ImportReport, error) {
+	rep, err := ParseImportFile(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	rep.Preview = preview
+	if preview {
+		n := len(rep.result.Listings)
+		if n > previewSampleSize {
+			n = previewSampleSize
+		}
+		rep.Sample = rep.result.Listings[:n]
+		return rep, nil
+	}
+
+	req := IngestRequest{Provider: rep.Provider, Trigger: TriggerFileImport, TriggeredBy: triggeredBy}
+	run := startIngestRun(ctx, projectID, req, FetchParams{})
+	out := &IngestOutcome{Provider: rep.Provider, Pages: 1, Fetched: rep.Rows}
+	for _, e := range rep.Errors {
+		out.Errors = append(out.Errors, fmt.Sprintf("row %d: %s", e.Row, e.Message))
+	}
+
+	summary, err := UpsertExternalListingsToFirestore(ctx, projectID, rep.result)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrIngestPersist, err)
+		finishIngestRun(ctx, projectID, run, out, err)
+		return nil, err
+	}
+	out.Summary = summary
+	finishIngestRun(ctx, projectID, run, out, nil)
+	underwriteForRun(ctx, projectID, out)
+	notifyForRun(ctx, projectID, out)
+
+	rep.Summary = summary
+	rep.Alerts = out.Alerts
+	if run != nil {
+		rep.RunID = run.ID
+	}
+	return rep, nil
+}
@@ -0,0 +1,103 @@
+package listings
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseImportFileCSV verifies column-mapping profiles and row-level error
+// reporting for CSV drops.
+func TestParseImportFileCSV(t *testing.T) {
+	csvData := "MLS #,Address,City,ST,Zip,Price,Beds,Listed,Garage\n" +
+		"A1,12 Main St,Austin,TX,78701,\"$450,000\",3,2025-03-01,2\n" +
+		",9 Elm St,Austin,TX,78702,300000,2,,\n" +
+		"A3,1 Oak Ave,Austin,TX,78703,abc,4,,\n" +
+		"A1,12 Main St,Austin,TX,78701,450000,3,,\n"
+	profile := &ImportProfile{Columns: map[string]string{
+		"ListingId":       "MLS #",
+		"UnparsedAddress": "Address",
+		"StateOrProvince": "ST",
+		"PostalCode":      "Zip",
+		"ListPrice":       "Price",
+		"BedroomsTotal":   "Beds",
+		"OnMarketDate":    "Listed",
+	}}
+
+	rep, err := ParseImportFile(strings.NewReader(csvData), ImportOptions{Format: ImportFormatCSV, Provider: "wholesaler", Profile: profile})
+	if err != nil {
+		t.Fatalf("ParseImportFile returned error: %v", err)
+	}
+	if rep.Rows != 4 || rep.Valid != 1 || rep.Invalid != 3 {
+		t.Fatalf("rows=%d valid=%d invalid=%d, errors=%+v", rep.Rows, rep.Valid, rep.Invalid, rep.Errors)
+	}
+	wantRows := []int{2, 3, 4}
+	for i, e := range rep.Errors {
+		if e.Row != wantRows[i] {
+			t.Errorf("error %d on row %d, want %d (%s)", i, e.Row, wantRows[i], e.Message)
+		}
+	}
+
+	l := rep.result.Listings[0]
+	if l.ExternalID != "A1" || l.ListPrice != 450000 || l.Beds != 3 || l.Address.State != "TX" || l.ListedAt.IsZero() {
+		t.Errorf("unexpected listing: %+v", l)
+	}
+	if l.Source != "wholesaler" || l.RawFormat != RawFormatRESO {
+		t.Errorf("source=%q rawFormat=%q", l.Source, l.RawFormat)
+	}
+	if meta, _ := l.ProviderMeta.(map[string]any); meta["Garage"] != "2" {
+		t.Errorf("unmapped column not preserved: %#v", l.ProviderMeta)
+	}
+}
+
+// TestParseImportFileRESOJSON verifies RESO Web API envelopes and IDX pipe
+// files map through the same RESO mapper.
+func TestParseImportFileRESOJSON(t *testing.T) {
+	reso := `{"value":[{"ListingKey":"K1","ListingId":"L1","StreetNumber":"5","StreetName":"Pine","StreetSuffix":"Rd","UnitNumber":"2B","City":"Reno","StateOrProvince":"NV","PostalCode":"89501","ListPrice":250000,"LotSizeAcres":0.5,"Media":[{"MediaURL":"https://img/a.jpg"}]}]}`
+	rep, err := ParseImportFile(strings.NewReader(reso), ImportOptions{Format: ImportFormatRESOJSON})
+	if err != nil {
+		t.Fatalf("ParseImportFile returned error: %v", err)
+	}
+	if rep.Valid != 1 {
+		t.Fatalf("valid=%d errors=%+v", rep.Valid, rep.Errors)
+	}
+	l := rep.result.Listings[0]
+	if l.Source != ProviderFile || l.ExternalID != "L1" || l.Address.Street1 != "5 Pine Rd" || l.Address.Street2 != "Unit 2B" {
+		t.Errorf("unexpected listing: %+v", l)
+	}
+	if l.LotSizeSqft != 21780 || len(l.Photos) != 1 {
+		t.Errorf("LotSizeSqft=%v Photos=%v", l.LotSizeSqft, l.Photos)
+	}
+
+	idx := "ListingId|UnparsedAddress|City|StateOrProvince|ListPrice|PublicRemarks\nX9|7 Bay St|Tampa|FL|199000|Sellers say \"bring offers\"\n"
+	rep, err = ParseImportFile(strings.NewReader(idx), ImportOptions{Format: ImportFormatIDX})
+	if err != nil {
+		t.Fatalf("ParseImportFile(idx) returned error: %v", err)
+	}
+	if rep.Valid != 1 || rep.result.Listings[0].ListPrice != 199000 {
+		t.Errorf("idx valid=%d errors=%+v", rep.Valid, rep.Errors)
+	}
+}
+
+// TestImportOwnerScopesPropertyIDs verifies two agents importing the same
+// ListingId write to different property documents.
+func TestImportOwnerScopesPropertyIDs(t *testing.T) {
+	csvData := "ListingId,UnparsedAddress,City,StateOrProvince,ListPrice\nL1,5 Pine Rd,Reno,NV,250000\n"
+	ids := map[string]bool{}
+	for _, owner := range []string{"agentA", "agentB"} {
+		rep, err := ParseImportFile(strings.NewReader(csvData), ImportOptions{Format: ImportFormatCSV, Owner: owner})
+		if err != nil || rep.Valid != 1 {
+			t.Fatalf("%s: err=%v errors=%+v", owner, err, rep)
+		}
+		l := rep.result.Listings[0]
+		if l.OwnerUID != owner || l.ExternalID != "L1" {
+			t.Errorf("%s: listing = %+v", owner, l)
+		}
+		ids[listingPropertyID(rep.Provider, l)] = true
+	}
+	if !ids["file_agentA_L1"] || !ids["file_agentB_L1"] {
+		t.Errorf("property ids = %v", ids)
+	}
+	if got := listingPropertyID(ProviderFile, ExternalListing{ExternalID: "L1"}); got != "file_L1" {
+		t.Errorf("admin import id = %q", got)
+	}
+}
@@ -0,0 +1,278 @@
+package listings
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+)
+
+// History event types written to properties/{id}/history.
+const (
+	EventListed        = "listed"
+	EventPriceDrop     = "price_drop"
+	EventPriceIncrease = "price_increase"
+	EventBackOnMarket  = "back_on_market"
+	EventPending       = "pending"
+	EventSold          = "sold"
+	EventOffMarket     = "off_market"
+	EventStatusChange  = "status_change"
+)
+
+// Normalized listing statuses. Providers use a wide range of labels ("Closed",
+// "Under Contract", "Active Under Contract", ...); NormalizeStatus folds them
+// into this small set so history and search can reason about them.
+const (
+	StatusActive    = "active"
+	StatusPending   = "pending"
+	StatusSold      = "sold"
+	StatusOffMarket = "off_market"
+	StatusUnknown   = "unknown"
+)
+
+// HistoryEvent is a single field-level change recorded for a property during
+// ingest. Events are append-only.
+type HistoryEvent struct {
+	ID         string      `firestore:"-" json:"id"`
+	PropertyID string      `firestore:"propertyId" json:"propertyId"`
+	Type       string      `firestore:"type" json:"type"`
+	Field      string      `firestore:"field" json:"field"`
+	OldValue   any         `firestore:"oldValue" json:"oldValue"`
+	NewValue   any         `firestore:"newValue" json:"newValue"`
+	Source     ProviderKey `firestore:"source" json:"source"`
+	At         time.Time   `firestore:"at" json:"at"`
+}
+
+// PropertyTimeline summarizes a property's history for agents looking for
+// motivated sellers.
+type PropertyTimeline struct {
+	PropertyID string          `json:"propertyId"`
+	Events     []*HistoryEvent `json:"events"`
+	// DaysOnMarket counts days since the current listing period began (the
+	// last listed/back-on-market event) until it ended or until now.
+	DaysOnMarket int `json:"daysOnMarket"`
+	// CumulativeDaysOnMarket adds up every listing period in the history.
+	CumulativeDaysOnMarket int        `json:"cumulativeDaysOnMarket"`
+	PriceCuts              int        `json:"priceCuts"`
+	OriginalPrice          float64    `json:"originalPrice,omitempty"`
+	CurrentPrice           float64    `json:"currentPrice,omitempty"`
+	TotalPriceCutPct       float64    `json:"totalPriceCutPct"`
+	LastPriceCutAt         *time.Time `json:"lastPriceCutAt,omitempty"`
+}
+
+// NormalizeStatus maps a provider status label onto one of the Status*
+// constants. Empty input yields an empty string so callers can tell "not
+// provided" apart from StatusUnknown.
+func NormalizeStatus(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return ""
+	}
+	s = strings.NewReplacer("-", " ", "_", " ").Replace(s)
+	switch {
+	case strings.Contains(s, "under contract"), strings.Contains(s, "pending"),
+		strings.Contains(s, "contingent"), s == "ucb", s == "awc":
+		return StatusPending
+	case s == "sold", s == "closed", strings.HasPrefix(s, "sold "), strings.HasPrefix(s, "closed "):
+		return StatusSold
+	case s == "active", s == "new", s == "for sale", s == "coming soon", s == "back on market",
+		s == "price change", s == "reactivated", strings.HasPrefix(s, "active "):
+		return StatusActive
+	case strings.Contains(s, "off market"), s == "withdrawn", s == "expired",
+		s == "canceled", s == "cancelled", s == "delisted", s == "temporarily off market":
+		return StatusOffMarket
+	}
+	return StatusUnknown
+}
+
+// detectListingChanges compares the stored property document with an incoming
+// listing and returns the history events implied by the difference. prev is
+// nil when the property is being created.
+func detectListingChanges(prev map[string]any, l ExternalListing, now time.Time) []HistoryEvent {
+	var out []HistoryEvent
+	at := observedAt(l, now)
+	newStatus := NormalizeStatus(l.Status)
+
+	if prev == nil {
+		ev := HistoryEvent{Type: EventListed, Field: "status", NewValue: l.Status, Source: l.Source, At: at}
+		if !l.ListedAt.IsZero() {
+			ev.At = l.ListedAt
+		}
+		if newStatus == StatusPending || newStatus == StatusSold || newStatus == StatusOffMarket {
+			// First seen already past the active stage (e.g. a sold comp);
+			// there is no listing period to start.
+			ev.Type = eventForStatus(newStatus)
+		}
+		if l.ListPrice > 0 {
+			ev.Field = "price"
+			ev.NewValue = l.ListPrice
+		}
+		return append(out, ev)
+	}
+
+	if oldPrice, ok := asFloat(prev["price"]); ok && l.ListPrice > 0 && oldPrice > 0 && oldPrice != l.ListPrice {
+		typ := EventPriceIncrease
+		if l.ListPrice < oldPrice {
+			typ = EventPriceDrop
+		}
+		out = append(out, HistoryEvent{Type: typ, Field: "price", OldValue: oldPrice, NewValue: l.ListPrice, Source: l.Source, At: at})
+	}
+
+	oldRaw, _ := prev["status"].(string)
+	oldStatus := NormalizeStatus(oldRaw)
+	if newStatus != "" && oldStatus != "" && newStatus != oldStatus {
+		typ := eventForStatus(newStatus)
+		if newStatus == StatusActive && oldStatus != StatusUnknown {
+			typ = EventBackOnMarket
+		}
+		out = append(out, HistoryEvent{Type: typ, Field: "status", OldValue: oldRaw, NewValue: l.Status, Source: l.Source, At: at})
+	} else if newStatus != "" && oldStatus == "" {
+		// Status first appearing on an existing document is still a useful
+		// milestone for DOM computation.
+		out = append(out, HistoryEvent{Type: eventForStatus(newStatus), Field: "status", NewValue: l.Status, Source: l.Source, At: at})
+	}
+	return out
+}
+
+func eventForStatus(status string) string {
+	switch status {
+	case StatusActive:
+		return EventListed
+	case StatusPending:
+		return EventPending
+	case StatusSold:
+		return EventSold
+	case StatusOffMarket:
+		return EventOffMarket
+	}
+	return EventStatusChange
+}
+
+// recordHistoryEvents appends events to the property's history subcollection
+// using a single batch.
+func recordHistoryEvents(ctx context.Context, client *gfs.Client, propertyID string, events []HistoryEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	col := client.Collection("properties").Doc(propertyID).Collection("history")
+	batch := client.Batch()
+	for _, ev := range events {
+		ev.PropertyID = propertyID
+		batch.Set(col.NewDoc(), ev)
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+// ListPropertyHistory returns the history events for a property ordered from
+// oldest to newest.
+func ListPropertyHistory(ctx context.Context, projectID, propertyID string) ([]*HistoryEvent, error) {
+	if projectID == "" || propertyID == "" {
+		return nil, fmt.Errorf("projectID and propertyID are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := client.Collection("properties").Doc(propertyID).Collection("history").
+		OrderBy("at", gfs.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*HistoryEvent, 0, len(snap))
+	for _, doc := range snap {
+		var ev HistoryEvent
+		if err := doc.DataTo(&ev); err != nil {
+			continue
+		}
+		ev.ID = doc.Ref.ID
+		out = append(out, &ev)
+	}
+	return out, nil
+}
+
+// BuildPropertyTimeline computes days-on-market and price-cut statistics from a
+// property's history events. now is passed in so results are deterministic.
+func BuildPropertyTimeline(propertyID string, events []*HistoryEvent, now time.Time) PropertyTimeline {
+	sorted := make([]*HistoryEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].At.Before(sorted[j].At) })
+
+	tl := PropertyTimeline{PropertyID: propertyID, Events: sorted}
+
+	var (
+		periodStart time.Time
+		onMarket    bool
+		cumulative  time.Duration
+		current     time.Duration
+	)
+	for _, ev := range sorted {
+		switch ev.Type {
+		case EventListed, EventBackOnMarket:
+			if !onMarket {
+				periodStart = ev.At
+				onMarket = true
+			}
+		case EventPending, EventSold, EventOffMarket:
+			if onMarket {
+				current = ev.At.Sub(periodStart)
+				cumulative += current
+				onMarket = false
+			}
+		}
+
+		if ev.Field != "price" {
+			continue
+		}
+		if v, ok := asFloat(ev.NewValue); ok {
+			if tl.OriginalPrice == 0 {
+				if old, ok := asFloat(ev.OldValue); ok && old > 0 {
+					tl.OriginalPrice = old
+				} else {
+					tl.OriginalPrice = v
+				}
+			}
+			tl.CurrentPrice = v
+		}
+		if ev.Type == EventPriceDrop {
+			tl.PriceCuts++
+			at := ev.At
+			tl.LastPriceCutAt = &at
+		}
+	}
+	if onMarket {
+		current = now.Sub(periodStart)
+		cumulative += current
+	}
+
+	tl.DaysOnMarket = int(current.Hours() / 24)
+	tl.CumulativeDaysOnMarket = int(cumulative.Hours() / 24)
+	if tl.OriginalPrice > 0 && tl.CurrentPrice > 0 && tl.CurrentPrice < tl.OriginalPrice {
+		pct := (tl.OriginalPrice - tl.CurrentPrice) / tl.OriginalPrice * 100
+		tl.TotalPriceCutPct = math.Round(pct*100) / 100
+	}
+	return tl
+}
+
+// asFloat converts Firestore numeric values (int64/float64) into float64.
+func asFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,104 @@
+package listings
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDetectListingChanges verifies which history events ingest derives from
+// the difference between a stored property and an incoming listing.
+func TestDetectListingChanges(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		prev  map[string]any
+		in    ExternalListing
+		types []string
+	}{
+		{
+			name:  "new listing",
+			prev:  nil,
+			in:    ExternalListing{ListPrice: 300000, Status: "Active"},
+			types: []string{EventListed},
+		},
+		{
+			name:  "new sold comp",
+			prev:  nil,
+			in:    ExternalListing{ListPrice: 300000, Status: "Closed"},
+			types: []string{EventSold},
+		},
+		{
+			name:  "price drop",
+			prev:  map[string]any{"price": int64(300000), "status": "Active"},
+			in:    ExternalListing{ListPrice: 285000, Status: "Active"},
+			types: []string{EventPriceDrop},
+		},
+		{
+			name:  "went pending with a price bump",
+			prev:  map[string]any{"price": 300000.0, "status": "Active"},
+			in:    ExternalListing{ListPrice: 305000, Status: "Active Under Contract"},
+			types: []string{EventPriceIncrease, EventPending},
+		},
+		{
+			name:  "back on market",
+			prev:  map[string]any{"price": 300000.0, "status": "Pending"},
+			in:    ExternalListing{ListPrice: 300000, Status: "Active"},
+			types: []string{EventBackOnMarket},
+		},
+		{
+			name:  "no change",
+			prev:  map[string]any{"price": 300000.0, "status": "active"},
+			in:    ExternalListing{ListPrice: 300000, Status: "Active"},
+			types: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectListingChanges(tt.prev, tt.in, now)
+			if len(got) != len(tt.types) {
+				t.Fatalf("expected %d events, got %d (%+v)", len(tt.types), len(got), got)
+			}
+			for i, ev := range got {
+				if ev.Type != tt.types[i] {
+					t.Errorf("event %d: expected type %q, got %q", i, tt.types[i], ev.Type)
+				}
+			}
+		})
+	}
+}
+
+// TestBuildPropertyTimeline verifies days-on-market and price-cut statistics.
+func TestBuildPropertyTimeline(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d) }
+
+	events := []*HistoryEvent{
+		{Type: EventListed, Field: "price", NewValue: 400000.0, At: day(0)},
+		{Type: EventPriceDrop, Field: "price", OldValue: 400000.0, NewValue: 380000.0, At: day(20)},
+		{Type: EventPending, Field: "status", At: day(30)},
+		{Type: EventBackOnMarket, Field: "status", At: day(40)},
+		{Type: EventPriceDrop, Field: "price", OldValue: 380000.0, NewValue: 360000.0, At: day(50)},
+	}
+
+	tl := BuildPropertyTimeline("mls_1", events, day(60))
+
+	if tl.DaysOnMarket != 20 {
+		t.Errorf("expected DaysOnMarket 20, got %d", tl.DaysOnMarket)
+	}
+	if tl.CumulativeDaysOnMarket != 50 {
+		t.Errorf("expected CumulativeDaysOnMarket 50, got %d", tl.CumulativeDaysOnMarket)
+	}
+	if tl.PriceCuts != 2 {
+		t.Errorf("expected PriceCuts 2, got %d", tl.PriceCuts)
+	}
+	if tl.OriginalPrice != 400000 || tl.CurrentPrice != 360000 {
+		t.Errorf("expected prices 400000 -> 360000, got %v -> %v", tl.OriginalPrice, tl.CurrentPrice)
+	}
+	if tl.TotalPriceCutPct != 10 {
+		t.Errorf("expected TotalPriceCutPct 10, got %v", tl.TotalPriceCutPct)
+	}
+	if tl.LastPriceCutAt == nil || !tl.LastPriceCutAt.Equal(day(50)) {
+		t.Errorf("expected LastPriceCutAt %v, got %v", day(50), tl.LastPriceCutAt)
+	}
+}
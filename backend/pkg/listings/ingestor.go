@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"strings"
 	"time"
 
+	gfs "cloud.google.com/go/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/address"
 	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/geo"
+	"github.com/SirsiMaster/assiduous/backend/pkg/objectstore"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -21,14 +26,51 @@ type UpsertSummary struct {
 	Created   int         `json:"created"`
 	Updated   int         `json:"updated"`
 	Skipped   int         `json:"skipped"`
+	// HistoryEvents counts price/status change events appended to
+	// properties/{id}/history during the run.
+	HistoryEvents int `json:"historyEvents"`
+	// Quarantined counts listings held back by validation for review in
+	// listing_quarantine.
+	Quarantined int `json:"quarantined"`
+
+	// changes lists created properties and those with history events, for
+	// saved search alerts.
+	changes []PropertyChange
+	// Previews describes each listing's outcome and field changes for dry
+	// runs; it is capped at maxPreviews with PreviewsTruncated set beyond.
+	Previews          []ListingPreview `firestore:"-" json:"previews,omitempty"`
+	PreviewsTruncated bool             `firestore:"-" json:"previewsTruncated,omitempty"`
+
+	// propertyIDs lists the documents written, which differ from the
+	// provider's own ids when a listing merged into another property.
+	propertyIDs []string
 }
 
 // UpsertExternalListingsToFirestore writes provider listings into the
 // Firestore properties collection using a deterministic document id derived
 // from provider + external id. The mapping is intentionally conservative and
 // uses Merge semantics so we do not clobber any existing fields populated by
-// legacy flows.
+// legacy flows. A provider's new listing at an address another provider
+// already has merges into that property, with each field resolved by the
+// PrecedencePolicy and its provenance stored alongside. Listings failing
+// ValidateListing are quarantined instead of written.
 func UpsertExternalListingsToFirestore(ctx context.Context, projectID string, res *IngestResult) (*UpsertSummary, error) {
+	return upsertListings(ctx, projectID, res, upsertOptions{})
+}
+
+// upsertOptions adjusts upsertListings for callers other than plain ingest.
+type upsertOptions struct {
+	// skipValidation is set only for listings an admin released from
+	// quarantine.
+	skipValidation bool
+	// dryRun reads current documents and reports what would change without
+	// writing anything.
+	dryRun bool
+}
+
+// upsertListings implements UpsertExternalListingsToFirestore and
+// PreviewExternalListings.
+func upsertListings(ctx context.Context, projectID string, res *IngestResult, opts upsertOptions) (*UpsertSummary, error) {
 	if res == nil {
 		return &UpsertSummary{}, nil
 	}
@@ -41,108 +83,308 @@ func UpsertExternalListingsToFirestore(ctx context.Context, projectID string, re
 		return nil, err
 	}
 
+	var store objectstore.Store
+	if !opts.dryRun {
+		store, err = objectstore.Default(ctx)
+		if err != nil {
+			log.Printf("[listings] object store unavailable, raw payloads will not be archived: %v", err)
+		}
+	}
+
+	minScore := MinQualityScore()
+	policy := PrecedencePolicyFromEnv()
 	summary := &UpsertSummary{Provider: res.Provider}
 	for _, l := range res.Listings {
+		// Stop between listings when the run is cancelled; what was
+		// written so far stays.
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
 		summary.Attempted++
 
 		if strings.TrimSpace(l.ExternalID) == "" {
 			log.Printf("[listings] skipping listing with empty external id (provider=%s)", res.Provider)
 			summary.Skipped++
+			summary.preview(opts, ListingPreview{Action: PreviewSkip, Reason: "external id is empty"})
 			continue
 		}
 
-		id := buildPropertyID(res.Provider, l.ExternalID)
+		ownID := listingPropertyID(res.Provider, l)
+		id := ownID
 		ref := client.Collection("properties").Doc(id)
 
-		// Construct a conservative update payload. We intentionally avoid
-		// removing any fields; we only merge the ones we know about.
-		addr := map[string]any{}
-		if l.Address.Street1 != "" {
-			addr["street"] = l.Address.Street1
-		}
-		if l.Address.City != "" {
-			addr["city"] = l.Address.City
-		}
-		if l.Address.State != "" {
-			addr["state"] = l.Address.State
+		// Check existence so we can keep basic created/updated counters and
+		// diff against the previous state for history.
+		var prev map[string]any
+		snap, err := ref.Get(ctx)
+		if err != nil {
+			if status.Code(err) != codes.NotFound {
+				log.Printf("[listings] failed to read property doc id=%s: %v", id, err)
+				summary.Skipped++
+				summary.preview(opts, ListingPreview{ExternalID: l.ExternalID, PropertyID: id, Action: PreviewSkip, Reason: "failed to read current document"})
+				continue
+			}
+		} else {
+			prev = snap.Data()
 		}
-		if l.Address.Postal != "" {
-			addr["postalCode"] = l.Address.Postal
+
+		// The first time a provider lists a house another provider already
+		// has, it merges into that property rather than duplicating it. Its
+		// fields then compete under the precedence policy. An agent's
+		// imported rows stay on their own documents.
+		merged := false
+		if prev == nil && policy.MergeByAddress && l.OwnerUID == "" {
+			target, err := findMergeTarget(ctx, client, res.Provider, l)
+			if err != nil {
+				log.Printf("[listings] failed to look up merge target for property doc id=%s: %v", ownID, err)
+			} else if target != nil {
+				ref, id, prev, merged = target.Ref, target.Ref.ID, target.Data(), true
+			}
 		}
-		// If we have coordinates, expose them as a simple object with latitude
-		// and longitude fields so legacy client code can consume them without
-		// depending on Firestore SDK types.
-		if l.Lat != 0 && l.Lng != 0 {
-			addr["coordinates"] = map[string]any{
-				"latitude":  l.Lat,
-				"longitude": l.Lng,
+		now := time.Now()
+		sourceRef := map[string]any{string(res.Provider): map[string]any{
+			"externalId": l.ExternalID,
+			"lastSeenAt": now,
+		}}
+
+		// A listing reconciled away because the feed stopped returning it is
+		// back; without a provider status, assume it is for sale again.
+		seenAgain := false
+		if reason, _ := prev["statusReason"].(string); reason == statusReasonNotSeen {
+			seenAgain = true
+			if l.Status == "" {
+				l.Status = StatusActive
 			}
 		}
 
-		data := map[string]any{
-			"source":     string(l.Source),
-			"externalId": l.ExternalID,
+		// Bad feed rows are held for review rather than merged. The listing is
+		// still in the feed, so an existing document stays seen.
+		quality := ValidateListing(l, minScore, now)
+		if !opts.skipValidation && quality.Quarantine {
+			if opts.dryRun {
+				summary.Quarantined++
+				summary.preview(opts, ListingPreview{
+					ExternalID: l.ExternalID,
+					PropertyID: ownID,
+					Action:     PreviewQuarantine,
+					Issues:     issueRules(quality.Issues),
+				})
+				continue
+			}
+			if err := quarantineListing(ctx, client, ownID, l, quality, prev != nil, now); err != nil {
+				log.Printf("[listings] failed to quarantine property doc id=%s: %v", ownID, err)
+				summary.Skipped++
+				continue
+			}
+			summary.Quarantined++
+			if prev != nil {
+				touch := map[string]any{"lastSeenAt": now, "sources": sourceRef}
+				if _, err := ref.Set(ctx, touch, fs.MergeAll()); err != nil {
+					log.Printf("[listings] failed to touch lastSeenAt for quarantined property doc id=%s: %v", id, err)
+				}
+			}
+			continue
 		}
-		if len(addr) > 0 {
-			data["address"] = addr
+
+		data := propertyFields(l)
+		// Fields another source holds by precedence are left alone, and the
+		// listing's history only reflects values the document takes.
+		rejected := resolveFields(policy, prev, data, res.Provider, observedAt(l, now))
+		l = applyRejected(l, rejected)
+		if merged {
+			for _, k := range canonicalFields {
+				delete(data, k)
+			}
 		}
-		if l.ListPrice > 0 {
-			data["price"] = l.ListPrice
+		data["sources"] = sourceRef
+		if l.OwnerUID != "" {
+			data["agentId"] = l.OwnerUID
 		}
-		if l.Beds > 0 {
-			data["bedrooms"] = int32(l.Beds)
+		if _, ok := data["status"]; seenAgain && ok {
+			data["statusReason"] = gfs.Delete
 		}
-		if l.Baths > 0 {
-			data["bathrooms"] = l.Baths
+		// qualityScore and the rules that lowered it let the UI flag
+		// incomplete listings. They describe the canonical record.
+		if !merged {
+			data["qualityScore"] = quality.Score
+			data["qualityIssues"] = issueRules(quality.Issues)
 		}
-		if l.Sqft > 0 {
-			data["squareFeet"] = int32(l.Sqft)
+		// New or reordered photos are queued for the media worker.
+		if _, ok := data["images"]; ok && mediaChanged(prev, l.Photos) {
+			data["mediaStatus"] = MediaPending
 		}
-		if l.Status != "" {
-			data["status"] = l.Status
+
+		if opts.dryRun {
+			pv := ListingPreview{
+				ExternalID: l.ExternalID,
+				PropertyID: id,
+				Action:     PreviewCreate,
+				Changes:    diffFields(prev, data),
+				Rejected:   rejected,
+			}
+			if prev == nil {
+				summary.Created++
+			} else {
+				pv.Action = PreviewUpdate
+				summary.Updated++
+			}
+			if merged {
+				pv.MergedInto = id
+			}
+			for _, ev := range detectListingChanges(prev, l, now) {
+				pv.Events = append(pv.Events, ev.Type)
+			}
+			summary.HistoryEvents += len(pv.Events)
+			summary.preview(opts, pv)
+			continue
 		}
-		// Track provider-specific ids so legacy client services can still look
-		// up related documents (e.g. mls_data) by id when we add them.
-		if l.Source == ProviderMLS {
-			data["mlsId"] = l.ExternalID
+
+		// Archive the provider's raw record so fields we do not map today can
+		// be recovered later by RemapProperties. Only the provider that
+		// created the property owns its raw payload.
+		if store != nil && len(l.RawJSON) > 0 && !merged {
+			raw, err := archiveRawPayload(ctx, store, l, rawPayloadHash(prev))
+			if err != nil {
+				log.Printf("[listings] failed to archive raw payload for property doc id=%s: %v", id, err)
+			} else if raw != nil {
+				data["rawPayload"] = raw
+			}
 		}
 
 		// Timestamp bookkeeping – we only set createdAt when the document did
-		// not previously exist. updatedAt is always bumped.
-		now := time.Now()
+		// not previously exist. updatedAt is always bumped, and lastSeenAt
+		// (per source under sources) feeds off-market reconciliation.
 		data["updatedAt"] = now
+		data["lastSeenAt"] = now
 
-		// Check existence so we can keep basic created/updated counters.
-		_, err := ref.Get(ctx)
-		if err != nil {
-			if status.Code(err) == codes.NotFound {
-				data["createdAt"] = now
-				if _, err := ref.Set(ctx, data, fs.MergeAll()); err != nil {
-					log.Printf("[listings] failed to create property doc id=%s: %v", id, err)
-					summary.Skipped++
-					continue
-				}
-				summary.Created++
+		if prev == nil {
+			data["createdAt"] = now
+			if _, err := ref.Set(ctx, data, fs.MergeAll()); err != nil {
+				log.Printf("[listings] failed to create property doc id=%s: %v", id, err)
+				summary.Skipped++
 				continue
 			}
-
-			log.Printf("[listings] failed to read property doc id=%s: %v", id, err)
-			summary.Skipped++
+			summary.Created++
+			summary.propertyIDs = append(summary.propertyIDs, id)
+			events := recordListingChanges(ctx, client, id, nil, l, now)
+			summary.HistoryEvents += len(events)
+			summary.changes = append(summary.changes, PropertyChange{PropertyID: id, Reason: AlertReasonNew})
 			continue
 		}
 
 		// Document exists – merge the update payload.
 		if _, err := ref.Set(ctx, data, fs.MergeAll()); err != nil {
+			log.Printf("[listings] failed to update property doc id=%s: %v", id, err)
 			summary.Skipped++
 			continue
 		}
 		summary.Updated++
+		summary.propertyIDs = append(summary.propertyIDs, id)
+		events := recordListingChanges(ctx, client, id, prev, l, now)
+		summary.HistoryEvents += len(events)
+		if reason := changeReason(events); reason != "" {
+			summary.changes = append(summary.changes, PropertyChange{PropertyID: id, Reason: reason})
+		}
 	}
 
 	return summary, nil
 }
 
+// PropertyFromListing maps a provider listing onto the RESO-aligned property
+// model. Ingest-only bookkeeping such as the mapping version, geohash and
+// address key is added by the upsert, not here.
+func PropertyFromListing(l ExternalListing) *fs.Property {
+	return &fs.Property{
+		ListingID:             l.ExternalID,
+		OriginatingSystemName: string(l.Source),
+		StandardStatus:        l.Status,
+		ListPrice:             l.ListPrice,
+		Address: fs.PropertyAddress{
+			UnparsedAddress: l.Address.Street1,
+			City:            l.Address.City,
+			StateOrProvince: l.Address.State,
+			PostalCode:      l.Address.Postal,
+			Latitude:        l.Lat,
+			Longitude:       l.Lng,
+		},
+		BedroomsTotal:         int(l.Beds),
+		BathroomsTotalDecimal: l.Baths,
+		LivingArea:            int(l.Sqft),
+		LotSizeSquareFeet:     l.LotSizeSqft,
+		YearBuilt:             l.YearBuilt,
+		Stories:               l.Stories,
+		ParkingTotal:          l.ParkingTotal,
+		GarageSpaces:          l.GarageSpaces,
+		PropertySubType:       l.PropertySubType,
+		AssociationFee:        l.HOAFee,
+		PublicRemarks:         l.Remarks,
+		PhotoURLs:             l.Photos,
+		ListAgentKey:          l.ListAgentKey,
+		ListOfficeKey:         l.ListOfficeKey,
+		OnMarketDate:          l.ListedAt,
+	}
+}
+
+// propertyFields maps a listing onto the properties document fields it owns.
+// It is shared by ingest and RemapProperties so both derive documents the same
+// way. The mapping is conservative: zero values are omitted so merges never
+// blank out fields populated by legacy flows.
+func propertyFields(l ExternalListing) map[string]any {
+	data := PropertyFromListing(l).Data()
+	data["source"] = string(l.Source)
+	data["externalId"] = l.ExternalID
+	data["mappingVersion"] = MappingVersion
+
+	// geohash indexes the coordinates for range-based geo search.
+	if pt := (geo.Point{Lat: l.Lat, Lng: l.Lng}); pt.Valid() {
+		data["geohash"] = geo.Encode(pt, geo.DefaultPrecision)
+	}
+	// addressKey is the USPS-standardized delivery point so the same house
+	// listed by several providers can be matched for dedup and search.
+	if key := StandardAddress(l.Address).Key(); key != "" {
+		data["addressKey"] = key
+	}
+	// pricePerSqft is denormalized so searches can sort on it.
+	if l.ListPrice > 0 && l.Sqft > 0 {
+		data["pricePerSqft"] = math.Round(l.ListPrice/l.Sqft*100) / 100
+	}
+	// Track provider-specific ids so legacy client services can still look
+	// up related documents (e.g. mls_data) by id when we add them.
+	if l.Source == ProviderMLS {
+		data["mlsId"] = l.ExternalID
+	}
+	return data
+}
+
+// recordListingChanges appends history events for the difference between the
+// previous document (nil on create) and the incoming listing. Failures are
+// logged rather than failing the upsert, since the property write already
+// succeeded. It returns the events recorded.
+func recordListingChanges(ctx context.Context, client *gfs.Client, id string, prev map[string]any, l ExternalListing, now time.Time) []HistoryEvent {
+	events := detectListingChanges(prev, l, now)
+	if err := recordHistoryEvents(ctx, client, id, events); err != nil {
+		log.Printf("[listings] failed to record history for property doc id=%s: %v", id, err)
+		return nil
+	}
+	return events
+}
+
 func buildPropertyID(provider ProviderKey, externalID string) string {
 	clean := strings.ReplaceAll(strings.TrimSpace(externalID), " ", "-")
 	return fmt.Sprintf("%s_%s", provider, clean)
 }
+
+// listingPropertyID returns the properties document id for l. Rows an agent
+// imported are scoped to that agent, so two agents' files with the same
+// ListingId never write to one document.
+func listingPropertyID(provider ProviderKey, l ExternalListing) string {
+	if l.OwnerUID != "" {
+		return buildPropertyID(provider, l.OwnerUID+"_"+l.ExternalID)
+	}
+	return buildPropertyID(provider, l.ExternalID)
+}
+
+// StandardAddress converts a provider address into its USPS-standardized form.
+func StandardAddress(a Address) address.Address {
+	return address.Standardize(a.Street1, a.Street2, a.City, a.State, a.Postal)
+}
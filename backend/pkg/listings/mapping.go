@@ -0,0 +1,215 @@
+package listings
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MappingVersion identifies the current raw-payload mapping. Bump it whenever
+// MapRawListing learns new fields so RemapProperties can find documents that
+// were derived with an older mapping.
+const MappingVersion = 6
+
+// listingsPage is the envelope every HTTP provider returns. Listings are kept
+// raw so each record can be archived verbatim before mapping.
+type listingsPage struct {
+	Listings      []json.RawMessage `json:"listings"`
+	NextPageToken string            `json:"nextPageToken"`
+}
+
+// mappedKeys are the record keys consumed by MapRawListing. Everything else is
+// surfaced on ExternalListing.ProviderMeta.
+var mappedKeys = map[string]bool{
+	"externalId": true, "address": true, "listPrice": true, "beds": true,
+	"baths": true, "sqft": true, "lat": true, "lng": true, "status": true,
+	"listedAt": true, "updatedAt": true, "photos": true, "media": true,
+	"remarks": true, "publicRemarks": true, "description": true,
+	"yearBuilt": true, "lotSize": true, "lotSizeSqft": true,
+	"hoaFee": true, "hoaFees": true, "associationFee": true,
+	"stories": true, "parkingTotal": true, "parkingSpaces": true,
+	"garageSpaces": true, "propertySubType": true, "listAgentKey": true,
+	"listAgentId": true, "listOfficeKey": true, "listOfficeId": true,
+}
+
+// MapRawListing maps a single provider listing record into ExternalListing.
+// The record is preserved on RawJSON and unmapped keys on ProviderMeta so
+// nothing the provider sent is lost.
+func MapRawListing(provider ProviderKey, raw json.RawMessage) (ExternalListing, error) {
+	var rec struct {
+		ExternalID string  `json:"externalId"`
+		Address    Address `json:"address"`
+		ListPrice  float64 `json:"listPrice"`
+		Beds       float64 `json:"beds"`
+		Baths      float64 `json:"baths"`
+		Sqft       float64 `json:"sqft"`
+		Lat        float64 `json:"lat"`
+		Lng        float64 `json:"lng"`
+		Status     string  `json:"status"`
+		ListedAt   string  `json:"listedAt"`
+		UpdatedAt  string  `json:"updatedAt"`
+	}
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return ExternalListing{}, fmt.Errorf("failed to decode %s listing: %w", provider, err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return ExternalListing{}, fmt.Errorf("failed to decode %s listing: %w", provider, err)
+	}
+
+	el := ExternalListing{
+		ExternalID: rec.ExternalID,
+		Source:     provider,
+		Address:    rec.Address,
+		ListPrice:  rec.ListPrice,
+		Beds:       rec.Beds,
+		Baths:      rec.Baths,
+		Sqft:       rec.Sqft,
+		Lat:        rec.Lat,
+		Lng:        rec.Lng,
+		Status:     rec.Status,
+		RawJSON:    append([]byte(nil), raw...),
+	}
+	if rec.ListedAt != "" {
+		if t, err := time.Parse(time.RFC3339, rec.ListedAt); err == nil {
+			el.ListedAt = t
+		}
+	}
+	if rec.UpdatedAt != "" {
+		if t, err := time.Parse(time.RFC3339, rec.UpdatedAt); err == nil {
+			el.UpdatedAt = t
+		}
+	}
+
+	el.Photos = photoURLs(fields["photos"])
+	if len(el.Photos) == 0 {
+		el.Photos = photoURLs(fields["media"])
+	}
+	el.Remarks = firstString(fields, "publicRemarks", "remarks", "description")
+	if v, ok := firstNumber(fields, "yearBuilt"); ok {
+		el.YearBuilt = int(v)
+	}
+	el.LotSizeSqft, _ = firstNumber(fields, "lotSizeSqft", "lotSize")
+	el.HOAFee, _ = firstNumber(fields, "hoaFee", "hoaFees", "associationFee")
+	if v, ok := firstNumber(fields, "stories"); ok {
+		el.Stories = int(v)
+	}
+	if v, ok := firstNumber(fields, "parkingTotal", "parkingSpaces"); ok {
+		el.ParkingTotal = int(v)
+	}
+	if v, ok := firstNumber(fields, "garageSpaces"); ok {
+		el.GarageSpaces = int(v)
+	}
+	el.PropertySubType = firstString(fields, "propertySubType")
+	el.ListAgentKey = firstString(fields, "listAgentKey", "listAgentId")
+	el.ListOfficeKey = firstString(fields, "listOfficeKey", "listOfficeId")
+
+	meta := map[string]any{}
+	for k, v := range fields {
+		if !mappedKeys[k] {
+			meta[k] = v
+		}
+	}
+	if len(meta) > 0 {
+		el.ProviderMeta = meta
+	}
+	return el, nil
+}
+
+// mapListingsPage maps each record of a provider page. Records that fail to
+// map are logged and dropped rather than failing the whole page.
+func mapListingsPage(provider ProviderKey, page listingsPage) *IngestResult {
+	out := &IngestResult{Provider: provider, NextPage: page.NextPageToken}
+	for _, raw := range page.Listings {
+		el, err := MapRawListing(provider, raw)
+		if err != nil {
+			log.Printf("[listings] skipping unmappable listing: %v", err)
+			continue
+		}
+		out.Listings = append(out.Listings, el)
+	}
+	return out
+}
+
+// photoURLs accepts either a list of URL strings or a list of RESO-style media
+// objects carrying MediaURL/url fields. Media objects are ordered by their
+// Order field when present, and non-photo media (virtual tours, documents,
+// video) is dropped.
+func photoURLs(v any) []string {
+	items, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	type photo struct {
+		url   string
+		order float64
+	}
+	var photos []photo
+	for i, it := range items {
+		switch p := it.(type) {
+		case string:
+			if p != "" {
+				photos = append(photos, photo{p, float64(i)})
+			}
+		case map[string]any:
+			if c := firstString(p, "MediaCategory", "mediaCategory", "type"); c != "" && !isPhotoCategory(c) {
+				continue
+			}
+			u := firstString(p, "MediaURL", "mediaUrl", "url")
+			if u == "" {
+				continue
+			}
+			order, ok := firstNumber(p, "Order", "order")
+			if !ok {
+				order = float64(i)
+			}
+			photos = append(photos, photo{u, order})
+		}
+	}
+	sort.SliceStable(photos, func(i, j int) bool { return photos[i].order < photos[j].order })
+	out := make([]string, 0, len(photos))
+	for _, p := range photos {
+		out = append(out, p.url)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// isPhotoCategory reports whether a RESO MediaCategory names a still image.
+func isPhotoCategory(c string) bool {
+	switch strings.ToLower(strings.ReplaceAll(c, " ", "")) {
+	case "photo", "photos", "image", "floorplan":
+		return true
+	}
+	return false
+}
+
+func firstString(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// firstNumber returns the first key holding a number or numeric string.
+func firstNumber(m map[string]any, keys ...string) (float64, bool) {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case float64:
+			return v, true
+		case string:
+			if f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(v), ",", ""), 64); err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,58 @@
+package listings
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestMapRawListing verifies extended fields are mapped and unmapped keys are
+// preserved on ProviderMeta.
+func TestMapRawListing(t *testing.T) {
+	raw := json.RawMessage(`{
+		"externalId": "A1",
+		"address": {"street1": "12 Main St", "city": "Austin", "state": "TX", "postal": "78701"},
+		"listPrice": 450000,
+		"beds": 3,
+		"status": "Active",
+		"listedAt": "2025-03-01T00:00:00Z",
+		"media": [{"MediaURL": "https://img/1.jpg"}, {"url": "https://img/2.jpg"}],
+		"publicRemarks": "  Charming bungalow ",
+		"yearBuilt": "1948",
+		"lotSize": 6500,
+		"associationFee": "1,200",
+		"garageSpaces": 2,
+		"stories": "2",
+		"listAgentId": "AG-7",
+		"hasPool": true
+	}`)
+
+	l, err := MapRawListing(ProviderMLS, raw)
+	if err != nil {
+		t.Fatalf("MapRawListing returned error: %v", err)
+	}
+	if l.ExternalID != "A1" || l.Source != ProviderMLS || l.ListPrice != 450000 || l.Address.City != "Austin" {
+		t.Errorf("core fields not mapped: %+v", l)
+	}
+	if l.ListedAt.IsZero() {
+		t.Error("listedAt not parsed")
+	}
+	if len(l.Photos) != 2 || l.Photos[0] != "https://img/1.jpg" {
+		t.Errorf("Photos = %v", l.Photos)
+	}
+	if l.Remarks != "Charming bungalow" {
+		t.Errorf("Remarks = %q", l.Remarks)
+	}
+	if l.YearBuilt != 1948 || l.LotSizeSqft != 6500 || l.HOAFee != 1200 {
+		t.Errorf("YearBuilt=%d LotSizeSqft=%v HOAFee=%v", l.YearBuilt, l.LotSizeSqft, l.HOAFee)
+	}
+	if l.GarageSpaces != 2 || l.Stories != 2 || l.ListAgentKey != "AG-7" {
+		t.Errorf("GarageSpaces=%d Stories=%d ListAgentKey=%q", l.GarageSpaces, l.Stories, l.ListAgentKey)
+	}
+	meta, ok := l.ProviderMeta.(map[string]any)
+	if !ok || meta["hasPool"] != true || len(meta) != 1 {
+		t.Errorf("ProviderMeta = %#v", l.ProviderMeta)
+	}
+	if string(l.RawJSON) != string(raw) {
+		t.Error("RawJSON not preserved")
+	}
+}
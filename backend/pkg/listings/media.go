@@ -0,0 +1,574 @@
+package listings
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register GIF decoding
+	"image/jpeg"
+	_ "image/png" // register PNG decoding
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/objectstore"
+)
+
+// mediaAssetsCollection indexes stored media by content hash so the same
+// photo syndicated by several providers is stored once.
+const mediaAssetsCollection = "media_assets"
+
+// Media processing states stored in a property's mediaStatus.
+const (
+	MediaPending = "pending"
+	MediaReady   = "ready"
+	// MediaPartial means some photos could not be fetched or decoded; they
+	// are retried when the listing's photos next change.
+	MediaPartial = "partial"
+)
+
+const (
+	defaultMediaMaxBytes  = 15 << 20
+	defaultThumbnailWidth = 480
+	// maxMediaPixels rejects images whose decoded size would exhaust memory.
+	maxMediaPixels = 40_000_000
+	// maxMediaPerListing bounds the photos stored for one listing.
+	maxMediaPerListing = 60
+	mediaBatchSize     = 20
+	thumbnailQuality   = 80
+	// maxMediaRedirects caps the redirects followed for one photo.
+	maxMediaRedirects = 3
+)
+
+// nonPublicPrefixes are special-purpose ranges netip does not already treat
+// as private or non-global: shared address space, benchmarking, IETF
+// protocol assignments, reserved, and NAT64.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// mediaTypes are the accepted image content types and their file extensions.
+var mediaTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+var (
+	// ErrMediaTooLarge is returned for media over the worker's MaxBytes.
+	ErrMediaTooLarge = errors.New("media exceeds size limit")
+	// ErrUnsupportedMedia is returned for media that is not a supported image.
+	ErrUnsupportedMedia = errors.New("unsupported media type")
+	// ErrPrivateMediaHost is returned when a media URL resolves to a
+	// loopback, link-local, private or otherwise non-public address.
+	ErrPrivateMediaHost = errors.New("media host is not a public address")
+)
+
+// publicAddr reports whether a is routable on the public internet.
+func publicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	if !a.IsGlobalUnicast() || a.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// newMediaClient returns the HTTP client photos are downloaded with. Photo
+// URLs come from feeds, pushes and agent imports, so the dialer refuses
+// non-public addresses after DNS resolution (which also covers redirects and
+// DNS rebinding), no proxy is used, and redirects are capped.
+func newMediaClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(ap.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateMediaHost, ap.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxMediaRedirects {
+				return fmt.Errorf("media fetch stopped after %d redirects", maxMediaRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("invalid media redirect to %q", req.URL)
+			}
+			return nil
+		},
+	}
+}
+
+// MediaRef is one photo attached to a property document under "media", in
+// the order the provider listed them.
+type MediaRef struct {
+	Order        int    `firestore:"order" json:"order"`
+	SourceURL    string `firestore:"sourceUrl" json:"sourceUrl"`
+	Hash         string `firestore:"hash" json:"hash"`
+	ContentType  string `firestore:"contentType" json:"contentType"`
+	Width        int    `firestore:"width" json:"width"`
+	Height       int    `firestore:"height" json:"height"`
+	Bytes        int    `firestore:"bytes" json:"bytes"`
+	URL          string `firestore:"url" json:"url"`
+	ThumbnailURL string `firestore:"thumbnailUrl" json:"thumbnailUrl"`
+}
+
+// MediaAsset is a stored original and its thumbnail, keyed by the SHA-256 of
+// the original bytes.
+type MediaAsset struct {
+	Hash         string    `firestore:"hash" json:"hash"`
+	ContentType  string    `firestore:"contentType" json:"contentType"`
+	Width        int       `firestore:"width" json:"width"`
+	Height       int       `firestore:"height" json:"height"`
+	Bytes        int       `firestore:"bytes" json:"bytes"`
+	OriginalKey  string    `firestore:"originalKey" json:"originalKey"`
+	ThumbnailKey string    `firestore:"thumbnailKey" json:"thumbnailKey"`
+	OriginalURI  string    `firestore:"originalUri" json:"originalUri"`
+	CreatedAt    time.Time `firestore:"createdAt" json:"createdAt"`
+}
+
+// ref returns the property-facing reference for the asset.
+func (a *MediaAsset) ref(sourceURL string, order int) MediaRef {
+	return MediaRef{
+		Order:        order,
+		SourceURL:    sourceURL,
+		Hash:         a.Hash,
+		ContentType:  a.ContentType,
+		Width:        a.Width,
+		Height:       a.Height,
+		Bytes:        a.Bytes,
+		URL:          MediaURL(a.OriginalKey),
+		ThumbnailURL: MediaURL(a.ThumbnailKey),
+	}
+}
+
+// MediaURL returns the URL clients load a stored media object from:
+// MEDIA_PUBLIC_BASE_URL (e.g. a CDN in front of the bucket) when set,
+// otherwise the API's own /api/media route.
+func MediaURL(key string) string {
+	if base := strings.TrimRight(os.Getenv("MEDIA_PUBLIC_BASE_URL"), "/"); base != "" {
+		return base + "/" + key
+	}
+	return "/api/media/" + strings.TrimPrefix(key, "media/")
+}
+
+// MediaSummary reports a media worker pass.
+type MediaSummary struct {
+	Properties int `json:"properties"`
+	Stored     int `json:"stored"`
+	Reused     int `json:"reused"`
+	Failed     int `json:"failed"`
+}
+
+// MediaWorker downloads listing photos queued by ingest (mediaStatus
+// pending), stores originals and thumbnails in object storage and attaches
+// ordered MediaRefs to the property documents.
+type MediaWorker struct {
+	ProjectID string
+	Store     objectstore.Store
+	Client    *http.Client
+
+	// MaxBytes rejects larger downloads.
+	MaxBytes int64
+	// ThumbnailWidth is the width thumbnails are scaled down to.
+	ThumbnailWidth int
+	// Interval is how often pending properties are checked.
+	Interval time.Duration
+}
+
+// NewMediaWorker constructs a worker with limits from MEDIA_MAX_BYTES and
+// MEDIA_THUMBNAIL_WIDTH.
+func NewMediaWorker(projectID string, store objectstore.Store) *MediaWorker {
+	w := &MediaWorker{
+		ProjectID:      projectID,
+		Store:          store,
+		Client:         newMediaClient(),
+		MaxBytes:       defaultMediaMaxBytes,
+		ThumbnailWidth: defaultThumbnailWidth,
+		Interval:       30 * time.Second,
+	}
+	if n, err := strconv.ParseInt(os.Getenv("MEDIA_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		w.MaxBytes = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("MEDIA_THUMBNAIL_WIDTH")); err == nil && n > 0 {
+		w.ThumbnailWidth = n
+	}
+	return w
+}
+
+// Run processes pending media every Interval until ctx is cancelled.
+func (w *MediaWorker) Run(ctx context.Context) {
+	log.Printf("[media] starting media worker (interval=%s)", w.Interval)
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.ProcessPending(ctx); err != nil {
+			log.Printf("[media] pass failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessPending processes one batch of properties whose media is pending.
+func (w *MediaWorker) ProcessPending(ctx context.Context) (*MediaSummary, error) {
+	client, err := fs.Client(ctx, w.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := client.Collection("properties").
+		Where("mediaStatus", "==", MediaPending).
+		Select().
+		Limit(mediaBatchSize).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	summary := &MediaSummary{}
+	for _, doc := range snap {
+		if err := w.processProperty(ctx, client, doc.Ref, summary); err != nil {
+			log.Printf("[media] failed to process media for property %s: %v", doc.Ref.ID, err)
+		}
+	}
+	return summary, nil
+}
+
+// ProcessProperty stores the media of a single property regardless of its
+// mediaStatus.
+func (w *MediaWorker) ProcessProperty(ctx context.Context, propertyID string) (*MediaSummary, error) {
+	if w.ProjectID == "" || propertyID == "" {
+		return nil, fmt.Errorf("projectID and propertyID are required")
+	}
+	client, err := fs.Client(ctx, w.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &MediaSummary{}
+	if err := w.processProperty(ctx, client, client.Collection("properties").Doc(propertyID), summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func (w *MediaWorker) processProperty(ctx context.Context, client *gfs.Client, ref *gfs.DocumentRef, summary *MediaSummary) error {
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return err
+	}
+	data := doc.Data()
+	images := stringList(data["images"])
+	if len(images) > maxMediaPerListing {
+		images = images[:maxMediaPerListing]
+	}
+	// Photos already stored for this listing are not downloaded again.
+	var stored struct {
+		Media []MediaRef `firestore:"media"`
+	}
+	if err := doc.DataTo(&stored); err != nil {
+		log.Printf("[media] ignoring malformed media on property %s: %v", ref.ID, err)
+	}
+	known := map[string]MediaRef{}
+	for _, r := range stored.Media {
+		if r.SourceURL != "" {
+			known[r.SourceURL] = r
+		}
+	}
+
+	summary.Properties++
+	media := make([]MediaRef, 0, len(images))
+	failed := 0
+	for _, src := range images {
+		if r, ok := known[src]; ok {
+			r.Order = len(media)
+			media = append(media, r)
+			summary.Reused++
+			continue
+		}
+		asset, reused, err := w.ingestURL(ctx, client, src)
+		if err != nil {
+			log.Printf("[media] skipping %s for property %s: %v", src, ref.ID, err)
+			failed++
+			continue
+		}
+		if reused {
+			summary.Reused++
+		} else {
+			summary.Stored++
+		}
+		media = append(media, asset.ref(src, len(media)))
+	}
+	summary.Failed += failed
+
+	state := MediaReady
+	if failed > 0 {
+		state = MediaPartial
+	}
+	// Only write if the listing was not re-ingested meanwhile; a newer photo
+	// list leaves the document pending for the next pass.
+	_, err = ref.Update(ctx, []gfs.Update{
+		{Path: "media", Value: media},
+		{Path: "mediaStatus", Value: state},
+		{Path: "mediaFailed", Value: failed},
+		{Path: "mediaUpdatedAt", Value: time.Now()},
+	}, gfs.LastUpdateTime(doc.UpdateTime))
+	return err
+}
+
+// ingestURL downloads src and stores it unless an asset with the same content
+// hash already exists. reused reports a hash hit.
+func (w *MediaWorker) ingestURL(ctx context.Context, client *gfs.Client, src string) (asset *MediaAsset, reused bool, err error) {
+	data, err := w.fetch(ctx, src)
+	if err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	assetRef := client.Collection(mediaAssetsCollection).Doc(hash)
+	if doc, err := assetRef.Get(ctx); err == nil {
+		var existing MediaAsset
+		if err := doc.DataTo(&existing); err == nil {
+			return &existing, true, nil
+		}
+	} else if !fs.IsNotFound(err) {
+		return nil, false, err
+	}
+
+	asset, err = storeMedia(ctx, w.Store, hash, data, w.ThumbnailWidth)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := assetRef.Set(ctx, asset); err != nil {
+		return nil, false, err
+	}
+	return asset, false, nil
+}
+
+// fetch downloads an http(s) URL, enforcing MaxBytes.
+func (w *MediaWorker) fetch(ctx context.Context, src string) ([]byte, error) {
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid media URL %q", src)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/jpeg, image/png, image/gif")
+	client := w.Client
+	if client == nil {
+		client = newMediaClient()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("media fetch returned status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "image/") {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMedia, ct)
+	}
+	maxBytes := w.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMediaMaxBytes
+	}
+	if resp.ContentLength > maxBytes {
+		return nil, ErrMediaTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrMediaTooLarge
+	}
+	return data, nil
+}
+
+// storeMedia validates an image by its content, then stores the original and
+// a JPEG thumbnail under content-addressed keys.
+func storeMedia(ctx context.Context, store objectstore.Store, hash string, data []byte, thumbWidth int) (*MediaAsset, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := mediaTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMedia, contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedMedia, err)
+	}
+	if cfg.Width*cfg.Height > maxMediaPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrMediaTooLarge, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedMedia, err)
+	}
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, resizeToWidth(img, thumbWidth), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+
+	prefix := "media/" + hash[:2] + "/" + hash
+	asset := &MediaAsset{
+		Hash:         hash,
+		ContentType:  contentType,
+		Width:        cfg.Width,
+		Height:       cfg.Height,
+		Bytes:        len(data),
+		OriginalKey:  prefix + "." + ext,
+		ThumbnailKey: prefix + "_thumb.jpg",
+		CreatedAt:    time.Now(),
+	}
+	if err := store.Put(ctx, asset.OriginalKey, data, contentType); err != nil {
+		return nil, err
+	}
+	if err := store.Put(ctx, asset.ThumbnailKey, thumb.Bytes(), "image/jpeg"); err != nil {
+		return nil, err
+	}
+	asset.OriginalURI = store.URI(asset.OriginalKey)
+	return asset, nil
+}
+
+// resizeToWidth scales img down to width using a box filter, flattening
+// transparency onto white for JPEG output. Narrower images are only
+// flattened.
+func resizeToWidth(img image.Image, width int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Over)
+	if width <= 0 || b.Dx() <= width {
+		return src
+	}
+
+	height := int(math.Max(1, math.Round(float64(b.Dy())*float64(width)/float64(b.Dx()))))
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sx := float64(b.Dx()) / float64(width)
+	sy := float64(b.Dy()) / float64(height)
+	for y := 0; y < height; y++ {
+		y0, y1 := span(y, sy, b.Dy())
+		for x := 0; x < width; x++ {
+			x0, x1 := span(x, sx, b.Dx())
+			var r, g, bl, a, n uint32
+			for yy := y0; yy < y1; yy++ {
+				off := src.PixOffset(x0, yy)
+				for xx := x0; xx < x1; xx++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					bl += uint32(src.Pix[off+2])
+					a += uint32(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(bl / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span returns the source pixel range [lo, hi) covered by destination pixel i
+// at the given scale, never empty and never past limit.
+func span(i int, scale float64, limit int) (int, int) {
+	lo := int(float64(i) * scale)
+	hi := int(float64(i+1) * scale)
+	if hi > limit {
+		hi = limit
+	}
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// stringList converts a Firestore array of strings, or a []string from an
+// unwritten update.
+func stringList(v any) []string {
+	var items []any
+	switch vv := v.(type) {
+	case []any:
+		items = vv
+	case []string:
+		items = make([]any, len(vv))
+		for i, s := range vv {
+			items[i] = s
+		}
+	}
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		if s, ok := it.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// mediaChanged reports whether an ingested photo list differs from the
+// previous document's images, so the media worker should pick it up.
+func mediaChanged(prev map[string]any, photos []string) bool {
+	if len(photos) == 0 {
+		return false
+	}
+	old := stringList(prev["images"])
+	if len(old) != len(photos) {
+		return true
+	}
+	for i := range old {
+		if old[i] != photos[i] {
+			return true
+		}
+	}
+	// Listings ingested before the media worker existed have no media yet.
+	_, hasStatus := prev["mediaStatus"]
+	return !hasStatus
+}
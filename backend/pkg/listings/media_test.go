@@ -0,0 +1,197 @@
+package listings
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SirsiMaster/assiduous/backend/pkg/objectstore"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestStoreMedia verifies originals and JPEG thumbnails are stored under
+// content-addressed keys and non-images are rejected.
+func TestStoreMedia(t *testing.T) {
+	ctx := context.Background()
+	store := objectstore.NewLocal(t.TempDir())
+	data := testPNG(t, 1200, 800)
+	hash := strings.Repeat("ab", 32)
+
+	asset, err := storeMedia(ctx, store, hash, data, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.ContentType != "image/png" || asset.Width != 1200 || asset.Height != 800 || asset.Bytes != len(data) {
+		t.Errorf("asset = %+v", asset)
+	}
+	if asset.OriginalKey != "media/ab/"+hash+".png" || asset.ThumbnailKey != "media/ab/"+hash+"_thumb.jpg" {
+		t.Errorf("keys = %s, %s", asset.OriginalKey, asset.ThumbnailKey)
+	}
+	if got, err := store.Get(ctx, asset.OriginalKey); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("original not stored: %v", err)
+	}
+	thumb, err := store.Get(ctx, asset.ThumbnailKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 300 || cfg.Height != 200 {
+		t.Errorf("thumbnail = %dx%d, want 300x200", cfg.Width, cfg.Height)
+	}
+
+	if _, err := storeMedia(ctx, store, hash, []byte("<html>not a photo</html>"), 300); !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("html err = %v", err)
+	}
+}
+
+// TestResizeToWidth verifies only wider images are scaled down.
+func TestResizeToWidth(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 101, 33))
+	if b := resizeToWidth(img, 50).Bounds(); b.Dx() != 50 || b.Dy() != 16 {
+		t.Errorf("scaled = %v", b)
+	}
+	if b := resizeToWidth(img, 200).Bounds(); b.Dx() != 101 || b.Dy() != 33 {
+		t.Errorf("narrow image resized to %v", b)
+	}
+}
+
+// TestMediaWorkerFetch verifies the size and content type limits.
+func TestMediaWorkerFetch(t *testing.T) {
+	photo := testPNG(t, 20, 20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(photo)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	w := &MediaWorker{Client: srv.Client(), MaxBytes: int64(len(photo))}
+	ctx := context.Background()
+	if got, err := w.fetch(ctx, srv.URL+"/photo.png"); err != nil || !bytes.Equal(got, photo) {
+		t.Errorf("fetch = %d bytes, %v", len(got), err)
+	}
+	if _, err := w.fetch(ctx, srv.URL+"/page"); !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("html err = %v", err)
+	}
+	if _, err := w.fetch(ctx, srv.URL+"/missing"); err == nil {
+		t.Error("expected error for 404")
+	}
+	if _, err := w.fetch(ctx, "file:///etc/passwd"); err == nil {
+		t.Error("expected error for non-http URL")
+	}
+	w.MaxBytes = int64(len(photo)) - 1
+	if _, err := w.fetch(ctx, srv.URL+"/photo.png"); !errors.Is(err, ErrMediaTooLarge) {
+		t.Errorf("oversize err = %v", err)
+	}
+}
+
+// TestMediaClientBlocksPrivateHosts verifies the worker's client refuses
+// non-public addresses and caps redirects.
+func TestMediaClientBlocksPrivateHosts(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	}))
+	defer srv.Close()
+	w := NewMediaWorker("p", nil)
+	if _, err := w.fetch(context.Background(), srv.URL+"/photo.png"); !errors.Is(err, ErrPrivateMediaHost) {
+		t.Errorf("loopback fetch err = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://cdn.example/a.jpg", nil)
+	if err := w.Client.CheckRedirect(req, make([]*http.Request, maxMediaRedirects)); err == nil {
+		t.Error("redirect cap not enforced")
+	}
+	req = httptest.NewRequest(http.MethodGet, "https://cdn.example/a.jpg", nil)
+	req.URL.Scheme = "file"
+	if err := w.Client.CheckRedirect(req, nil); err == nil {
+		t.Error("redirect to a non-http scheme allowed")
+	}
+}
+
+// TestMediaChanged verifies which ingests queue the media worker.
+func TestMediaChanged(t *testing.T) {
+	prev := map[string]any{"images": []any{"a", "b"}, "mediaStatus": MediaReady}
+	if mediaChanged(prev, []string{"a", "b"}) {
+		t.Error("unchanged photos queued")
+	}
+	if !mediaChanged(prev, []string{"b", "a"}) {
+		t.Error("reordered photos not queued")
+	}
+	if mediaChanged(prev, nil) {
+		t.Error("listing without photos queued")
+	}
+	if !mediaChanged(nil, []string{"a"}) {
+		t.Error("new listing not queued")
+	}
+	if !mediaChanged(map[string]any{"images": []any{"a"}}, []string{"a"}) {
+		t.Error("listing never processed not queued")
+	}
+}
+
+// TestPhotoURLsOrder verifies RESO media is ordered and filtered to photos.
+func TestPhotoURLsOrder(t *testing.T) {
+	got := photoURLs([]any{
+		map[string]any{"MediaURL": "https://x/3.jpg", "Order": float64(3)},
+		map[string]any{"MediaURL": "https://x/tour", "MediaCategory": "Virtual Tour", "Order": float64(0)},
+		map[string]any{"MediaURL": "https://x/1.jpg", "Order": float64(1), "MediaCategory": "Photo"},
+		map[string]any{"MediaURL": "https://x/2.jpg", "Order": "2"},
+	})
+	want := []string{"https://x/1.jpg", "https://x/2.jpg", "https://x/3.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("photoURLs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,416 @@
+package listings
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/kms"
+)
+
+// mlsCredentialsCollection holds envelope-encrypted agent MLS secrets, one
+// document per board connection (see credentialKey). It is kept apart from
+// mls_connections so connection metadata can be read by clients while
+// secrets stay server-only.
+const mlsCredentialsCollection = "mls_credentials"
+
+// Credential states for AgentMLSCredentialStatus.Status.
+const (
+	CredentialActive  = "active"
+	CredentialRevoked = "revoked"
+)
+
+// ErrCredentialsRevoked is returned when an agent's credentials were revoked.
+var ErrCredentialsRevoked = errors.New("agent MLS credentials revoked")
+
+// wrapDEK, unwrapDEK and loadMLSCredentials are swapped out in tests.
+var (
+	wrapDEK            = kms.WrapDEK
+	unwrapDEK          = kms.UnwrapDEK
+	loadMLSCredentials = LoadAgentMLSCredentials
+)
+
+// credentialKey is the mls_credentials document id for one of an agent's
+// board connections. Each board has its own credentials. Without a
+// connection it is the agent uid, which is also the id of the agent's
+// original single-board connection (see MLSConnectionID).
+func credentialKey(agentUID, connectionID string) string {
+	if connectionID == "" {
+		return agentUID
+	}
+	return connectionID
+}
+
+// ownsConnectionID reports whether id is one of agentUID's connection ids.
+func ownsConnectionID(agentUID, id string) bool {
+	return id == agentUID || strings.HasPrefix(id, agentUID+"_")
+}
+
+// MLSCredentials are an agent's own MLS / RESO Web API secrets. They are only
+// ever accepted by the API, never returned.
+type MLSCredentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// BaseURL optionally points at the agent's own board endpoint instead of
+	// MLS_API_BASE_URL. Its host must be allowed by allowedMLSHost.
+	BaseURL string `json:"baseUrl,omitempty"`
+}
+
+// knownRESOHosts are RESO Web API vendors agents' boards are served from. A
+// host matches an entry or any subdomain of it.
+var knownRESOHosts = []string{
+	"api.bridgedataoutput.com",
+	"api.mlsgrid.com",
+	"sparkapi.com",
+	"api-trestle.corelogic.com",
+	"query.ampre.ca",
+}
+
+// allowedMLSHost reports whether the server may fetch listings from host: a
+// known RESO vendor, the host of MLS_API_BASE_URL, or one listed in
+// MLS_ALLOWED_HOSTS (comma-separated). The base URL is agent-supplied, so
+// anything else is refused rather than fetched.
+func allowedMLSHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+	allowed := append([]string{}, knownRESOHosts...)
+	if u, err := url.Parse(os.Getenv("MLS_API_BASE_URL")); err == nil && u.Hostname() != "" {
+		allowed = append(allowed, u.Hostname())
+	}
+	allowed = append(allowed, strings.Split(os.Getenv("MLS_ALLOWED_HOSTS"), ",")...)
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a != "" && (host == a || strings.HasSuffix(host, "."+a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the credentials are usable.
+func (c MLSCredentials) Validate() error {
+	if strings.TrimSpace(c.ClientID) == "" {
+		return fmt.Errorf("clientId is required")
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || u.Scheme != "https" || u.User != nil {
+			return fmt.Errorf("baseUrl must be an https URL")
+		}
+		if !allowedMLSHost(u.Hostname()) {
+			return fmt.Errorf("baseUrl host %q is not an allowed MLS endpoint", u.Hostname())
+		}
+	}
+	return nil
+}
+
+// AgentMLSCredentialStatus is the non-secret view of stored credentials.
+type AgentMLSCredentialStatus struct {
+	AgentUID     string    `firestore:"agentUid" json:"agentUid"`
+	ConnectionID string    `firestore:"connectionId,omitempty" json:"connectionId,omitempty"`
+	Status       string    `firestore:"status" json:"status"`
+	ClientIDHint string    `firestore:"clientIdHint,omitempty" json:"clientIdHint,omitempty"`
+	BaseURL      string    `firestore:"baseUrl,omitempty" json:"baseUrl,omitempty"`
+	Version      int       `firestore:"version" json:"version"`
+	KeyName      string    `firestore:"keyName,omitempty" json:"keyName,omitempty"`
+	CreatedAt    time.Time `firestore:"createdAt" json:"createdAt"`
+	RotatedAt    time.Time `firestore:"rotatedAt,omitempty" json:"rotatedAt,omitempty"`
+	RevokedAt    time.Time `firestore:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	LastUsedAt   time.Time `firestore:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+}
+
+// sealedCredentials is the encrypted envelope stored alongside the status.
+type sealedCredentials struct {
+	Ciphertext []byte `firestore:"ciphertext"`
+	Nonce      []byte `firestore:"nonce"`
+	WrappedDEK []byte `firestore:"wrappedDek"`
+}
+
+// sealCredentials encrypts creds with a fresh AES-256-GCM data key and wraps
+// the key with Cloud KMS. The credential key is bound as associated data so
+// an envelope cannot be replayed onto another agent's or board's document.
+func sealCredentials(ctx context.Context, keyName, key string, creds MLSCredentials) (*sealedCredentials, error) {
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return nil, err
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapDEK(ctx, keyName, dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	return &sealedCredentials{
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, []byte(key)),
+		Nonce:      nonce,
+		WrappedDEK: wrapped,
+	}, nil
+}
+
+// open reverses sealCredentials.
+func (s *sealedCredentials) open(ctx context.Context, keyName, key string) (*MLSCredentials, error) {
+	dek, err := unwrapDEK(ctx, keyName, s.WrappedDEK)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, s.Nonce, s.Ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credentials: %w", err)
+	}
+	var creds MLSCredentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// clientIDHint keeps the last four characters of a client id for display.
+func clientIDHint(id string) string {
+	id = strings.TrimSpace(id)
+	if len(id) <= 4 {
+		return strings.Repeat("*", len(id))
+	}
+	return "****" + id[len(id)-4:]
+}
+
+// StoreAgentMLSCredentials encrypts and saves an agent's credentials for one
+// board connection (the agent's default ones when connectionID is empty),
+// replacing (rotating) any existing ones. The version increments on every
+// store so rotations are visible in the status.
+func StoreAgentMLSCredentials(ctx context.Context, projectID, agentUID, connectionID string, creds MLSCredentials) (*AgentMLSCredentialStatus, error) {
+	if projectID == "" || agentUID == "" {
+		return nil, fmt.Errorf("projectID and agentUID are required")
+	}
+	key := credentialKey(agentUID, connectionID)
+	if !ownsConnectionID(agentUID, key) {
+		return nil, ErrConnectionNotFound
+	}
+	if err := creds.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	keyName := os.Getenv("KMS_KEY_NAME")
+	sealed, err := sealCredentials(ctx, keyName, key, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := client.Collection(mlsCredentialsCollection).Doc(key)
+	var status AgentMLSCredentialStatus
+	err = client.RunTransaction(ctx, func(ctx context.Context, tx *gfs.Transaction) error {
+		now := time.Now()
+		status = AgentMLSCredentialStatus{AgentUID: agentUID, ConnectionID: connectionID, CreatedAt: now}
+		doc, err := tx.Get(ref)
+		if err != nil && !fs.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			var prev AgentMLSCredentialStatus
+			if err := doc.DataTo(&prev); err != nil {
+				return err
+			}
+			if prev.AgentUID != agentUID {
+				return ErrConnectionNotFound
+			}
+			status = prev
+			status.RotatedAt = now
+			status.RevokedAt = time.Time{}
+		}
+		status.Status = CredentialActive
+		status.ClientIDHint = clientIDHint(creds.ClientID)
+		status.BaseURL = creds.BaseURL
+		status.KeyName = keyName
+		status.Version++
+		data := map[string]any{
+			"agentUid":     status.AgentUID,
+			"connectionId": status.ConnectionID,
+			"status":       status.Status,
+			"clientIdHint": status.ClientIDHint,
+			"baseUrl":      status.BaseURL,
+			"version":      status.Version,
+			"keyName":      status.KeyName,
+			"createdAt":    status.CreatedAt,
+			"revokedAt":    gfs.Delete,
+			"ciphertext":   sealed.Ciphertext,
+			"nonce":        sealed.Nonce,
+			"wrappedDek":   sealed.WrappedDEK,
+		}
+		if !status.RotatedAt.IsZero() {
+			data["rotatedAt"] = status.RotatedAt
+		}
+		return tx.Set(ref, data, gfs.MergeAll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetAgentMLSCredentialStatus returns the non-secret status of the
+// credentials stored for an agent's board connection, or (nil, nil) when
+// none were ever stored.
+func GetAgentMLSCredentialStatus(ctx context.Context, projectID, agentUID, connectionID string) (*AgentMLSCredentialStatus, error) {
+	doc, err := getAgentCredentialDoc(ctx, projectID, agentUID, connectionID)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	var status AgentMLSCredentialStatus
+	if err := doc.DataTo(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// RevokeAgentMLSCredentials destroys the encrypted secrets and marks the
+// record revoked. Ingest for the board falls back to the global MLS
+// credentials afterwards. It returns false when nothing was stored.
+func RevokeAgentMLSCredentials(ctx context.Context, projectID, agentUID, connectionID string) (bool, error) {
+	doc, err := getAgentCredentialDoc(ctx, projectID, agentUID, connectionID)
+	if err != nil || doc == nil {
+		return false, err
+	}
+	_, err = doc.Ref.Set(ctx, map[string]any{
+		"status":     CredentialRevoked,
+		"revokedAt":  time.Now(),
+		"ciphertext": gfs.Delete,
+		"nonce":      gfs.Delete,
+		"wrappedDek": gfs.Delete,
+	}, gfs.MergeAll)
+	return err == nil, err
+}
+
+// RotateAgentMLSCredentialKey re-encrypts the stored secrets under a fresh
+// data key and the current KMS key, without the agent re-entering them.
+func RotateAgentMLSCredentialKey(ctx context.Context, projectID, agentUID, connectionID string) (*AgentMLSCredentialStatus, error) {
+	creds, err := LoadAgentMLSCredentials(ctx, projectID, agentUID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return nil, nil
+	}
+	return StoreAgentMLSCredentials(ctx, projectID, agentUID, connectionID, *creds)
+}
+
+// LoadAgentMLSCredentials decrypts the credentials of an agent's board
+// connection for ingest. It returns (nil, nil) when none are stored and
+// ErrCredentialsRevoked when they were revoked.
+func LoadAgentMLSCredentials(ctx context.Context, projectID, agentUID, connectionID string) (*MLSCredentials, error) {
+	doc, err := getAgentCredentialDoc(ctx, projectID, agentUID, connectionID)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	var status AgentMLSCredentialStatus
+	if err := doc.DataTo(&status); err != nil {
+		return nil, err
+	}
+	if status.Status == CredentialRevoked {
+		return nil, ErrCredentialsRevoked
+	}
+	var sealed sealedCredentials
+	if err := doc.DataTo(&sealed); err != nil {
+		return nil, err
+	}
+	creds, err := sealed.open(ctx, status.KeyName, doc.Ref.ID)
+	if err != nil {
+		return nil, err
+	}
+	// lastUsedAt is informational; a failed write must not block ingest.
+	_, _ = doc.Ref.Set(ctx, map[string]any{"lastUsedAt": time.Now()}, gfs.MergeAll)
+	return creds, nil
+}
+
+// getAgentCredentialDoc reads the credentials document of an agent's board
+// connection, or nil when there is none or it belongs to another agent.
+func getAgentCredentialDoc(ctx context.Context, projectID, agentUID, connectionID string) (*gfs.DocumentSnapshot, error) {
+	if projectID == "" || agentUID == "" {
+		return nil, fmt.Errorf("projectID and agentUID are required")
+	}
+	key := credentialKey(agentUID, connectionID)
+	if !ownsConnectionID(agentUID, key) {
+		return nil, nil
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := client.Collection(mlsCredentialsCollection).Doc(key).Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if owner, _ := doc.Data()["agentUid"].(string); owner != agentUID {
+		return nil, nil
+	}
+	return doc, nil
+}
+
+// CredentialedProvider is implemented by providers that can authenticate as
+// a specific agent.
+type CredentialedProvider interface {
+	WithCredentials(creds MLSCredentials) Provider
+}
+
+// agentProvider returns prov authenticated as agentUID on one of their board
+// connections when credentials are stored for it and prov supports them;
+// otherwise prov itself. Revoked credentials fall back to prov; decryption
+// failures are returned so a run never silently switches identity on a
+// broken key.
+func agentProvider(ctx context.Context, projectID string, prov Provider, agentUID, connectionID string) (Provider, bool, error) {
+	cp, ok := prov.(CredentialedProvider)
+	if !ok || agentUID == "" {
+		return prov, false, nil
+	}
+	creds, err := loadMLSCredentials(ctx, projectID, agentUID, connectionID)
+	if errors.Is(err, ErrCredentialsRevoked) || (err == nil && creds == nil) {
+		return prov, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("load MLS credentials for agent %s: %w", agentUID, err)
+	}
+	// Credentials stored before the host allowlist are checked again, so
+	// an old base URL is never fetched.
+	if err := creds.Validate(); err != nil {
+		return nil, false, fmt.Errorf("MLS credentials for agent %s: %w", agentUID, err)
+	}
+	return cp.WithCredentials(*creds), true, nil
+}
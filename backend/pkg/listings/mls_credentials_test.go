@@ -0,0 +1,96 @@
+package listings
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// fakeKMS replaces Cloud KMS with a reversible XOR so envelopes can be
+// exercised offline.
+func fakeKMS(t *testing.T) {
+	t.Helper()
+	xor := func(_ context.Context, _ string, b []byte) ([]byte, error) {
+		out := make([]byte, len(b))
+		for i := range b {
+			out[i] = b[i] ^ 0x5a
+		}
+		return out, nil
+	}
+	prevWrap, prevUnwrap := wrapDEK, unwrapDEK
+	wrapDEK, unwrapDEK = xor, xor
+	t.Cleanup(func() { wrapDEK, unwrapDEK = prevWrap, prevUnwrap })
+}
+
+// TestSealCredentialsRoundTrip verifies credentials decrypt only for the
+// agent they were sealed for.
+func TestSealCredentialsRoundTrip(t *testing.T) {
+	fakeKMS(t)
+	ctx := context.Background()
+	creds := MLSCredentials{ClientID: "agent-client-1234", ClientSecret: "s3cret", BaseURL: "https://board.example"}
+
+	sealed, err := sealCredentials(ctx, "key", "agent-1", creds)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("s3cret")) {
+		t.Fatal("ciphertext contains the plaintext secret")
+	}
+	got, err := sealed.open(ctx, "key", "agent-1")
+	if err != nil || *got != creds {
+		t.Fatalf("open = %+v, %v", got, err)
+	}
+	if _, err := sealed.open(ctx, "key", "agent-2"); err == nil {
+		t.Error("envelope opened for a different agent")
+	}
+
+	again, _ := sealCredentials(ctx, "key", "agent-1", creds)
+	if bytes.Equal(again.WrappedDEK, sealed.WrappedDEK) || bytes.Equal(again.Ciphertext, sealed.Ciphertext) {
+		t.Error("each seal should use a fresh data key and nonce")
+	}
+}
+
+// TestMLSProviderWithCredentials verifies the agent copy leaves the global
+// provider untouched.
+func TestMLSProviderWithCredentials(t *testing.T) {
+	global := &MLSProvider{key: ProviderMLS, BaseURL: "https://global.example", ClientID: "global", client: NewProviderClient(ClientConfig{})}
+	agent := global.WithCredentials(MLSCredentials{ClientID: "agent", ClientSecret: "x"}).(*MLSProvider)
+	if agent.ClientID != "agent" || agent.ClientSecret != "x" || agent.BaseURL != "https://global.example" {
+		t.Errorf("agent provider = %+v", agent)
+	}
+	if agent.client == global.client || agent.client.breaker == global.client.breaker {
+		t.Error("agent provider shares the global circuit breaker")
+	}
+	if global.ClientID != "global" || global.ClientSecret != "" {
+		t.Errorf("global provider mutated: %+v", global)
+	}
+	if h := clientIDHint("agent-client-1234"); h != "****1234" {
+		t.Errorf("hint = %q", h)
+	}
+}
+
+// TestMLSCredentialsBaseURL verifies agent base URLs are limited to known
+// RESO hosts, the global MLS host and MLS_ALLOWED_HOSTS.
+func TestMLSCredentialsBaseURL(t *testing.T) {
+	t.Setenv("MLS_API_BASE_URL", "https://mls.internal.example/v1")
+	t.Setenv("MLS_ALLOWED_HOSTS", "board.example, other.example")
+	cases := map[string]bool{
+		"":                                   true,
+		"https://api.mlsgrid.com/v2":         true,
+		"https://replication.sparkapi.com":   true,
+		"https://mls.internal.example":       true,
+		"https://board.example/reso":         true,
+		"https://evilsparkapi.com":           false,
+		"http://api.mlsgrid.com":             false,
+		"https://169.254.169.254/latest":     false,
+		"https://localhost:8080":             false,
+		"https://user:pw@api.mlsgrid.com":    false,
+		"https://api.mlsgrid.com.evil.test/": false,
+	}
+	for baseURL, ok := range cases {
+		err := MLSCredentials{ClientID: "c", BaseURL: baseURL}.Validate()
+		if (err == nil) != ok {
+			t.Errorf("%q: err = %v, want ok = %v", baseURL, err, ok)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package listings
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+)
+
+// Dry-run preview actions, mirroring the counters of UpsertSummary.
+const (
+	PreviewCreate     = "create"
+	PreviewUpdate     = "update"
+	PreviewSkip       = "skip"
+	PreviewQuarantine = "quarantine"
+)
+
+// maxPreviews caps the listings described in one dry run's summary.
+const maxPreviews = 1000
+
+// bookkeepingFields change on every write and are left out of previews.
+var bookkeepingFields = map[string]bool{
+	"updatedAt":  true,
+	"lastSeenAt": true,
+	"createdAt":  true,
+	"sources":    true,
+	"provenance": true,
+	"rawPayload": true,
+}
+
+// ListingPreview describes what an upsert would do with one listing.
+type ListingPreview struct {
+	ExternalID string `json:"externalId,omitempty"`
+	PropertyID string `json:"propertyId,omitempty"`
+	Action     string `json:"action"`
+	// MergedInto is set when the listing would merge into another
+	// provider's property at the same address.
+	MergedInto string        `json:"mergedInto,omitempty"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+	// Rejected lists fields that would keep another source's value under
+	// the precedence policy.
+	Rejected []string `json:"rejected,omitempty"`
+	// Events lists the history event types the write would record.
+	Events []string `json:"events,omitempty"`
+	// Issues lists the failed validation rules of a quarantined listing.
+	Issues []string `json:"issues,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+// FieldChange is one document field a write would change. Nested fields use
+// dotted paths, e.g. "address.city"; Old is nil for fields being added and
+// New is nil for fields being removed.
+type FieldChange struct {
+	Field string `firestore:"field" json:"field"`
+	Old   any    `firestore:"old" json:"old"`
+	New   any    `firestore:"new" json:"new"`
+}
+
+// PreviewExternalListings reports what UpsertExternalListingsToFirestore
+// would create, update, skip or quarantine for res, with per-field diffs
+// against the current documents. It reads Firestore but writes nothing.
+func PreviewExternalListings(ctx context.Context, projectID string, res *IngestResult) (*UpsertSummary, error) {
+	return upsertListings(ctx, projectID, res, upsertOptions{dryRun: true})
+}
+
+// preview records p on a dry run's summary.
+func (s *UpsertSummary) preview(opts upsertOptions, p ListingPreview) {
+	if !opts.dryRun {
+		return
+	}
+	if len(s.Previews) >= maxPreviews {
+		s.PreviewsTruncated = true
+		return
+	}
+	s.Previews = append(s.Previews, p)
+}
+
+// diffFields compares a merge update with the current document. Nested maps
+// are compared leaf by leaf, as MergeAll writes them.
+func diffFields(prev, data map[string]any) []FieldChange {
+	var out []FieldChange
+	var walk func(prefix string, cur, update map[string]any)
+	walk = func(prefix string, cur, update map[string]any) {
+		for k, v := range update {
+			if prefix == "" && bookkeepingFields[k] {
+				continue
+			}
+			path := prefix + k
+			old, exists := cur[k]
+			if m, ok := v.(map[string]any); ok {
+				sub, _ := old.(map[string]any)
+				walk(path+".", sub, m)
+				continue
+			}
+			if v == gfs.Delete {
+				if exists {
+					out = append(out, FieldChange{Field: path, Old: old})
+				}
+				continue
+			}
+			if !exists || !sameValue(old, v) {
+				out = append(out, FieldChange{Field: path, Old: old, New: v})
+			}
+		}
+	}
+	walk("", prev, data)
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
+
+// sameValue compares a stored Firestore value with an update value, ignoring
+// the numeric and slice type differences Firestore introduces on read.
+func sameValue(a, b any) bool {
+	return reflect.DeepEqual(normalizeValue(a), normalizeValue(b))
+}
+
+func normalizeValue(v any) any {
+	if f, ok := asFloat(v); ok {
+		return f
+	}
+	switch x := v.(type) {
+	case time.Time:
+		return x.UTC()
+	case []string:
+		out := make([]any, len(x))
+		for i, s := range x {
+			out[i] = s
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = normalizeValue(e)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,86 @@
+package listings
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+)
+
+// TestDiffFields verifies leaf-level diffs against a stored document,
+// ignoring Firestore's type changes and bookkeeping fields.
+func TestDiffFields(t *testing.T) {
+	listed := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	prev := map[string]any{
+		"price":        350000.0,
+		"bedrooms":     int64(3),
+		"images":       []any{"a.jpg", "b.jpg"},
+		"listedAt":     listed,
+		"status":       "off_market",
+		"statusReason": statusReasonNotSeen,
+		"address":      map[string]any{"city": "Austin", "state": "TX"},
+		"updatedAt":    listed,
+	}
+	data := propertyFields(ExternalListing{
+		Source:    ProviderMLS,
+		ListPrice: 340000,
+		Beds:      3,
+		Photos:    []string{"a.jpg", "b.jpg"},
+		ListedAt:  listed.In(time.FixedZone("CST", -6*3600)),
+		Status:    "active",
+		Address:   Address{City: "Austin", State: "TX", Postal: "78701"},
+	})
+	data["statusReason"] = gfs.Delete
+	data["updatedAt"] = time.Now()
+
+	got := map[string]FieldChange{}
+	for _, c := range diffFields(prev, data) {
+		got[c.Field] = c
+	}
+	want := map[string]FieldChange{
+		"price":              {Field: "price", Old: 350000.0, New: 340000.0},
+		"status":             {Field: "status", Old: "off_market", New: "active"},
+		"statusReason":       {Field: "statusReason", Old: statusReasonNotSeen},
+		"address.postalCode": {Field: "address.postalCode", New: "78701"},
+	}
+	for field, w := range want {
+		if !reflect.DeepEqual(got[field], w) {
+			t.Errorf("%s = %+v, want %+v", field, got[field], w)
+		}
+		delete(got, field)
+	}
+	// Everything else is either unchanged or new identity fields.
+	for field := range got {
+		switch field {
+		case "source", "externalId", "mlsId", "mappingVersion", "addressKey":
+		default:
+			t.Errorf("unexpected change %s: %+v", field, got[field])
+		}
+	}
+
+	// A create diffs every field against nothing.
+	if changes := diffFields(nil, map[string]any{"price": 1.0, "updatedAt": time.Now()}); len(changes) != 1 || changes[0].Old != nil {
+		t.Errorf("create changes = %+v", changes)
+	}
+}
+
+// TestSummaryPreviewCap verifies previews are only kept for dry runs and are
+// capped when summaries are combined.
+func TestSummaryPreviewCap(t *testing.T) {
+	s := &UpsertSummary{}
+	s.preview(upsertOptions{}, ListingPreview{Action: PreviewCreate})
+	if len(s.Previews) != 0 {
+		t.Error("preview recorded outside a dry run")
+	}
+
+	page := &UpsertSummary{}
+	for i := 0; i < maxPreviews; i++ {
+		page.preview(upsertOptions{dryRun: true}, ListingPreview{Action: PreviewUpdate})
+	}
+	s.add(page)
+	s.add(&UpsertSummary{Previews: []ListingPreview{{Action: PreviewSkip}}})
+	if len(s.Previews) != maxPreviews || !s.PreviewsTruncated {
+		t.Errorf("previews = %d, truncated = %v", len(s.Previews), s.PreviewsTruncated)
+	}
+}
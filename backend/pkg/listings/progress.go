@@ -0,0 +1,235 @@
+package listings
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Run event types streamed for asynchronous ingest runs.
+const (
+	// RunEventStarted is sent when a ledger run begins; fanned-out agent runs
+	// send one per board.
+	RunEventStarted = "started"
+	// RunEventPage reports a page fetched from the provider.
+	RunEventPage = "page"
+	// RunEventUpserted reports a page written to Firestore.
+	RunEventUpserted = "upserted"
+	// RunEventError reports a page that failed without ending the run.
+	RunEventError = "error"
+	// RunEventDone, RunEventFailed and RunEventCancelled end the stream.
+	RunEventDone      = "done"
+	RunEventFailed    = "failed"
+	RunEventCancelled = "cancelled"
+)
+
+// trackedRunRetention is how long finished runs stay available for streaming.
+const trackedRunRetention = 15 * time.Minute
+
+var (
+	// ErrRunCancelled is returned when an ingest run's context is cancelled.
+	// Pages persisted before the cancel are kept.
+	ErrRunCancelled = errors.New("ingest run cancelled")
+	// ErrRunNotActive is returned when cancelling a run that has finished.
+	ErrRunNotActive = errors.New("ingest run is not active")
+)
+
+// RunEvent is one progress update of an ingest run.
+type RunEvent struct {
+	Seq          int         `json:"seq"`
+	Type         string      `json:"type"`
+	Provider     ProviderKey `json:"provider,omitempty"`
+	RunID        string      `json:"runId,omitempty"`
+	ConnectionID string      `json:"connectionId,omitempty"`
+	Page         int         `json:"page,omitempty"`
+	Fetched      int         `json:"fetched,omitempty"`
+	// Summary is the page's upsert summary on RunEventUpserted.
+	Summary *UpsertSummary `json:"summary,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	// Outcome is the run's final result on RunEventDone and
+	// RunEventCancelled.
+	Outcome *IngestOutcome `json:"outcome,omitempty"`
+	At      time.Time      `json:"at"`
+}
+
+// IsFinal reports whether e ends its run's stream.
+func (e RunEvent) IsFinal() bool {
+	switch e.Type {
+	case RunEventDone, RunEventFailed, RunEventCancelled:
+		return true
+	}
+	return false
+}
+
+type progressKey struct{}
+
+// withProgress returns a context whose ingest progress is sent to emit.
+func withProgress(ctx context.Context, emit func(RunEvent)) context.Context {
+	return context.WithValue(ctx, progressKey{}, emit)
+}
+
+// emitProgress sends ev to the context's progress listener, if any.
+func emitProgress(ctx context.Context, ev RunEvent) {
+	if emit, ok := ctx.Value(progressKey{}).(func(RunEvent)); ok {
+		emit(ev)
+	}
+}
+
+// RunTracker runs ingests in the background and keeps their progress events
+// so clients can stream them and cancel runs. It is in-process: a run is
+// only tracked by the instance that started it.
+type RunTracker struct {
+	ProjectID string
+	Registry  *Registry
+
+	mu   sync.Mutex
+	runs map[string]*trackedRun
+}
+
+// NewRunTracker constructs a RunTracker for the given project and registry.
+func NewRunTracker(projectID string, reg *Registry) *RunTracker {
+	return &RunTracker{ProjectID: projectID, Registry: reg, runs: make(map[string]*trackedRun)}
+}
+
+type trackedRun struct {
+	id          string
+	triggeredBy string
+	cancel      context.CancelFunc
+
+	mu         sync.Mutex
+	events     []RunEvent
+	finished   bool
+	finishedAt time.Time
+	// changed is closed and replaced whenever an event is added.
+	changed chan struct{}
+}
+
+// Start begins req in the background and returns its run id, which is also
+// the id of its ingest_runs ledger entry. Runs fanned out across an agent's
+// MLS boards report each board's ledger id in their started events.
+func (t *RunTracker) Start(req IngestRequest) (string, error) {
+	if _, ok := t.Registry.Get(req.Provider); !ok {
+		return "", ErrUnknownProvider
+	}
+	id, err := newRunID()
+	if err != nil {
+		return "", err
+	}
+	req.RunID = id
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := &trackedRun{id: id, triggeredBy: req.TriggeredBy, cancel: cancel, changed: make(chan struct{})}
+	t.mu.Lock()
+	t.pruneLocked(time.Now())
+	t.runs[id] = tr
+	t.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		out, err := RunIngest(withProgress(ctx, tr.emit), t.ProjectID, t.Registry, req)
+		switch {
+		case errors.Is(err, ErrRunCancelled), err != nil && ctx.Err() != nil:
+			tr.emit(RunEvent{Type: RunEventCancelled, Provider: req.Provider, Outcome: out, Error: err.Error()})
+		case err != nil:
+			tr.emit(RunEvent{Type: RunEventFailed, Provider: req.Provider, Error: err.Error()})
+		default:
+			tr.emit(RunEvent{Type: RunEventDone, Provider: req.Provider, Outcome: out})
+		}
+	}()
+	return id, nil
+}
+
+// Cancel stops a running ingest. It returns false when the run is not
+// tracked here, and ErrRunNotActive when it has already finished.
+func (t *RunTracker) Cancel(id string) (bool, error) {
+	tr := t.get(id)
+	if tr == nil {
+		return false, nil
+	}
+	tr.mu.Lock()
+	finished := tr.finished
+	tr.mu.Unlock()
+	if finished {
+		return true, ErrRunNotActive
+	}
+	tr.cancel()
+	return true, nil
+}
+
+// TriggeredBy returns the uid that started a tracked run.
+func (t *RunTracker) TriggeredBy(id string) (string, bool) {
+	tr := t.get(id)
+	if tr == nil {
+		return "", false
+	}
+	return tr.triggeredBy, true
+}
+
+// Events returns a tracked run's events with Seq greater than after, whether
+// the run has finished, and a channel closed when another event arrives.
+// ok is false when the run is not tracked here.
+func (t *RunTracker) Events(id string, after int) (events []RunEvent, finished bool, changed <-chan struct{}, ok bool) {
+	tr := t.get(id)
+	if tr == nil {
+		return nil, false, nil, false
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if after < 0 {
+		after = 0
+	}
+	if after < len(tr.events) {
+		events = append(events, tr.events[after:]...)
+	}
+	return events, tr.finished, tr.changed, true
+}
+
+func (t *RunTracker) get(id string) *trackedRun {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.runs[id]
+}
+
+// pruneLocked drops runs that finished more than trackedRunRetention ago.
+func (t *RunTracker) pruneLocked(now time.Time) {
+	for id, tr := range t.runs {
+		tr.mu.Lock()
+		expired := tr.finished && now.Sub(tr.finishedAt) > trackedRunRetention
+		tr.mu.Unlock()
+		if expired {
+			delete(t.runs, id)
+		}
+	}
+}
+
+// emit appends ev, numbering it from 1, and wakes streaming clients.
+func (r *trackedRun) emit(ev RunEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+	ev.Seq = len(r.events) + 1
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	r.events = append(r.events, ev)
+	if ev.IsFinal() {
+		r.finished = true
+		r.finishedAt = ev.At
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// newRunID returns a random id for a tracked run and its ledger entry.
+func newRunID() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
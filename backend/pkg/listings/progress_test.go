@@ -0,0 +1,75 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestTrackedRunEvents verifies events are numbered, replayed after a
+// sequence number and closed off by a final event.
+func TestTrackedRunEvents(t *testing.T) {
+	tracker := NewRunTracker("p", NewRegistry())
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := &trackedRun{id: "r1", triggeredBy: "u1", cancel: cancel, changed: make(chan struct{})}
+	tracker.runs["r1"] = tr
+
+	_, _, changed, ok := tracker.Events("r1", 0)
+	if !ok {
+		t.Fatal("run not tracked")
+	}
+	tr.emit(RunEvent{Type: RunEventStarted, RunID: "r1"})
+	select {
+	case <-changed:
+	default:
+		t.Error("subscribers not woken by an event")
+	}
+	tr.emit(RunEvent{Type: RunEventPage, Page: 1, Fetched: 20})
+	tr.emit(RunEvent{Type: RunEventDone})
+	tr.emit(RunEvent{Type: RunEventPage, Page: 2})
+
+	events, finished, _, _ := tracker.Events("r1", 1)
+	if !finished || len(events) != 2 || events[0].Seq != 2 || events[1].Type != RunEventDone {
+		t.Errorf("events = %+v, finished = %v", events, finished)
+	}
+	if by, ok := tracker.TriggeredBy("r1"); !ok || by != "u1" {
+		t.Errorf("triggeredBy = %q", by)
+	}
+	if found, err := tracker.Cancel("r1"); !found || !errors.Is(err, ErrRunNotActive) {
+		t.Errorf("cancel finished run = %v, %v", found, err)
+	}
+	if found, _ := tracker.Cancel("missing"); found {
+		t.Error("cancel of an unknown run reported found")
+	}
+}
+
+type cancellingProvider struct {
+	stubProvider
+	cancel context.CancelFunc
+}
+
+func (p cancellingProvider) FetchListings(ctx context.Context, _ FetchParams) (*IngestResult, error) {
+	p.cancel()
+	return nil, ctx.Err()
+}
+
+// TestIngestPagesCancelled verifies a cancelled context ends a run with
+// ErrRunCancelled rather than a provider error.
+func TestIngestPagesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	prov := cancellingProvider{stubProvider{key: "stub"}, cancel}
+	out, err := ingestPages(ctx, "p", prov, FetchParams{}, 3, false)
+	if !errors.Is(err, ErrRunCancelled) || out == nil || out.Pages != 0 {
+		t.Errorf("out = %+v, err = %v", out, err)
+	}
+
+	var events []RunEvent
+	ctx = withProgress(ctx, func(ev RunEvent) { events = append(events, ev) })
+	if _, err := ingestPages(ctx, "p", stubProvider{key: "stub"}, FetchParams{}, 3, false); !errors.Is(err, ErrRunCancelled) {
+		t.Errorf("pre-cancelled err = %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("events = %+v", events)
+	}
+}
@@ -0,0 +1,379 @@
+package listings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+)
+
+// Precedence strategies for resolving a field written by several sources.
+const (
+	// PrecedencePriority prefers sources earlier in FieldRule.Sources;
+	// sources of equal rank fall back to the newest value.
+	PrecedencePriority = "priority"
+	// PrecedenceNewest keeps the value with the latest timestamp.
+	PrecedenceNewest = "newest"
+)
+
+// maxMergeCandidates bounds the properties read when looking for an existing
+// document at a listing's address.
+const maxMergeCandidates = 5
+
+// provenanceFields maps each resolved field to the document keys it covers.
+// Keys that move together (the address and the values derived from it) are
+// resolved as one field.
+var provenanceFields = map[string][]string{
+	"address":         {"address", "addressKey", "geohash"},
+	"price":           {"price"},
+	"status":          {"status"},
+	"bedrooms":        {"bedrooms"},
+	"bathrooms":       {"bathrooms"},
+	"squareFeet":      {"squareFeet"},
+	"listedAt":        {"listedAt"},
+	"images":          {"images"},
+	"description":     {"description"},
+	"yearBuilt":       {"yearBuilt"},
+	"lotSize":         {"lotSize"},
+	"hoaFees":         {"hoaFees"},
+	"stories":         {"stories"},
+	"parkingTotal":    {"parkingTotal"},
+	"garageSpaces":    {"garageSpaces"},
+	"propertySubType": {"propertySubType"},
+}
+
+// canonicalFields belong to the provider that created a property document.
+// Other providers merging into it do not overwrite them.
+var canonicalFields = []string{"source", "externalId", "mappingVersion", "rawPayload", "qualityScore", "qualityIssues", "listAgentKey", "listOfficeKey"}
+
+// FieldRule decides which source's value a field keeps.
+type FieldRule struct {
+	Strategy string `json:"strategy"`
+	// Sources ranks providers for PrecedencePriority, highest first. An
+	// entry also matches instances of it, so "mls" covers "mls-ca".
+	// Unlisted sources rank below every listed one.
+	Sources []ProviderKey `json:"sources,omitempty"`
+}
+
+// PrecedencePolicy holds the field rules applied when providers write the
+// same property.
+type PrecedencePolicy struct {
+	Default FieldRule            `json:"default"`
+	Fields  map[string]FieldRule `json:"fields,omitempty"`
+	// MergeByAddress routes a provider's new listing into an existing
+	// property from another provider with the same standardized address.
+	MergeByAddress bool `json:"mergeByAddress"`
+}
+
+// DefaultPrecedencePolicy trusts the MLS for status and listing date and
+// otherwise keeps the newest value.
+func DefaultPrecedencePolicy() PrecedencePolicy {
+	mlsFirst := FieldRule{Strategy: PrecedencePriority, Sources: []ProviderKey{ProviderMLS}}
+	return PrecedencePolicy{
+		Default: FieldRule{Strategy: PrecedenceNewest},
+		Fields: map[string]FieldRule{
+			"status":   mlsFirst,
+			"listedAt": mlsFirst,
+			"price":    {Strategy: PrecedenceNewest},
+		},
+		MergeByAddress: true,
+	}
+}
+
+// PrecedencePolicyFromEnv overlays LISTING_FIELD_PRECEDENCE, a JSON
+// PrecedencePolicy, onto the default. Field rules are merged by name;
+// malformed values are logged and ignored.
+func PrecedencePolicyFromEnv() PrecedencePolicy {
+	p := DefaultPrecedencePolicy()
+	raw := strings.TrimSpace(os.Getenv("LISTING_FIELD_PRECEDENCE"))
+	if raw == "" {
+		return p
+	}
+	var overlay struct {
+		Default        *FieldRule           `json:"default"`
+		Fields         map[string]FieldRule `json:"fields"`
+		MergeByAddress *bool                `json:"mergeByAddress"`
+	}
+	if err := json.Unmarshal([]byte(raw), &overlay); err != nil {
+		log.Printf("[listings] ignoring malformed LISTING_FIELD_PRECEDENCE: %v", err)
+		return p
+	}
+	if overlay.Default != nil {
+		p.Default = *overlay.Default
+	}
+	for field, rule := range overlay.Fields {
+		p.Fields[field] = rule
+	}
+	if overlay.MergeByAddress != nil {
+		p.MergeByAddress = *overlay.MergeByAddress
+	}
+	return p
+}
+
+// Rule returns the rule for field.
+func (p PrecedencePolicy) Rule(field string) FieldRule {
+	if r, ok := p.Fields[field]; ok {
+		return r
+	}
+	return p.Default
+}
+
+// rank returns the position of source in r.Sources, or len(r.Sources) when
+// it is not listed.
+func (r FieldRule) rank(source ProviderKey) int {
+	for i, s := range r.Sources {
+		if source == s || strings.HasPrefix(string(source), string(s)+"-") {
+			return i
+		}
+	}
+	return len(r.Sources)
+}
+
+// FieldProvenance records which source last set a field and as of when.
+type FieldProvenance struct {
+	Source ProviderKey `firestore:"source" json:"source"`
+	At     time.Time   `firestore:"at" json:"at"`
+}
+
+// takes reports whether incoming replaces the current value under rule. A
+// field without provenance, or last set by the same source, always takes the
+// new value; one set by an agent is never replaced by a provider.
+func (r FieldRule) takes(current *FieldProvenance, incoming FieldProvenance) bool {
+	if current == nil || current.Source == "" || current.Source == incoming.Source {
+		return true
+	}
+	if current.Source == SourceAgent {
+		return false
+	}
+	if r.Strategy == PrecedencePriority {
+		if in, cur := r.rank(incoming.Source), r.rank(current.Source); in != cur {
+			return in < cur
+		}
+	}
+	return !incoming.At.Before(current.At)
+}
+
+// observedAt is when a listing's values were true: the provider's update time
+// when it has one, otherwise now.
+func observedAt(l ExternalListing, now time.Time) time.Time {
+	if !l.UpdatedAt.IsZero() && l.UpdatedAt.Before(now) {
+		return l.UpdatedAt
+	}
+	return now
+}
+
+// decodeProvenance reads the provenance map from a properties document.
+func decodeProvenance(doc map[string]any) map[string]*FieldProvenance {
+	raw, _ := doc["provenance"].(map[string]any)
+	out := make(map[string]*FieldProvenance, len(raw))
+	for field, v := range raw {
+		m, _ := v.(map[string]any)
+		source, _ := m["source"].(string)
+		if source == "" {
+			continue
+		}
+		at, _ := m["at"].(time.Time)
+		out[field] = &FieldProvenance{Source: ProviderKey(source), At: at}
+	}
+	return out
+}
+
+// resolveFields applies policy to the fields in data, a propertyFields
+// update from source observed at. Fields that lose to the values already in
+// prev are removed from data; the rest gain provenance entries. It returns
+// the resolved fields that were rejected.
+func resolveFields(policy PrecedencePolicy, prev, data map[string]any, source ProviderKey, at time.Time) []string {
+	current := decodeProvenance(prev)
+	incoming := FieldProvenance{Source: source, At: at}
+	prov := map[string]any{}
+	var rejected []string
+	for field, keys := range provenanceFields {
+		if _, ok := data[keys[0]]; !ok {
+			continue
+		}
+		if policy.Rule(field).takes(current[field], incoming) {
+			prov[field] = map[string]any{"source": string(source), "at": at}
+			continue
+		}
+		for _, k := range keys {
+			delete(data, k)
+		}
+		rejected = append(rejected, field)
+	}
+	if len(prov) > 0 {
+		data["provenance"] = prov
+	}
+	sort.Strings(rejected)
+	derivePricePerSqft(prev, data)
+	return rejected
+}
+
+// derivePricePerSqft recomputes pricePerSqft from whichever price and size
+// the document ends up with after resolution.
+func derivePricePerSqft(prev, data map[string]any) {
+	delete(data, "pricePerSqft")
+	_, price := data["price"]
+	_, sqft := data["squareFeet"]
+	if !price && !sqft {
+		return
+	}
+	p, _ := asFloat(effectiveValue(prev, data, "price"))
+	s, _ := asFloat(effectiveValue(prev, data, "squareFeet"))
+	if p > 0 && s > 0 {
+		data["pricePerSqft"] = math.Round(p/s*100) / 100
+	}
+}
+
+func effectiveValue(prev, data map[string]any, key string) any {
+	if v, ok := data[key]; ok {
+		return v
+	}
+	return prev[key]
+}
+
+// applyRejected zeroes the listing values whose fields were rejected, so
+// history only records changes the document actually took.
+func applyRejected(l ExternalListing, rejected []string) ExternalListing {
+	for _, field := range rejected {
+		switch field {
+		case "price":
+			l.ListPrice = 0
+		case "status":
+			l.Status = ""
+		case "listedAt":
+			l.ListedAt = time.Time{}
+		case "images":
+			l.Photos = nil
+		}
+	}
+	return l
+}
+
+// dropForeignFields removes fields from a remap update that another source
+// now owns, so re-deriving a document from its canonical raw payload does not
+// undo precedence.
+func dropForeignFields(prev, update map[string]any, source ProviderKey) {
+	for field, p := range decodeProvenance(prev) {
+		if p.Source == source {
+			continue
+		}
+		for _, k := range provenanceFields[field] {
+			delete(update, k)
+		}
+	}
+	derivePricePerSqft(prev, update)
+}
+
+// findMergeTarget returns another provider's property at the listing's
+// standardized address, or nil when there is none. See mergeCandidate for
+// which properties match.
+func findMergeTarget(ctx context.Context, client *gfs.Client, provider ProviderKey, l ExternalListing) (*gfs.DocumentSnapshot, error) {
+	key := StandardAddress(l.Address).Key()
+	if key == "" {
+		return nil, nil
+	}
+	snap, err := client.Collection("properties").
+		Where("addressKey", "==", key).
+		Limit(maxMergeCandidates).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range snap {
+		if mergeCandidate(doc.Data(), provider, l.ExternalID) {
+			return doc, nil
+		}
+	}
+	return nil, nil
+}
+
+// mergeCandidate reports whether a listing from provider may merge into the
+// property doc. Agent-entered properties are never merge targets: the agent
+// owns them, so a feed or file listing at the same address stays separate.
+// Nor is a property the provider already contributes a different listing
+// to.
+func mergeCandidate(doc map[string]any, provider ProviderKey, externalID string) bool {
+	source, _ := doc["source"].(string)
+	if source == "" || ProviderKey(source) == provider || ProviderKey(source) == SourceAgent {
+		return false
+	}
+	if ref, ok := propertySources(doc)[provider]; ok && ref.ExternalID != externalID {
+		return false
+	}
+	return true
+}
+
+// SourceRef is one provider's contribution to a property.
+type SourceRef struct {
+	ExternalID string    `firestore:"externalId" json:"externalId"`
+	LastSeenAt time.Time `firestore:"lastSeenAt" json:"lastSeenAt"`
+}
+
+// propertySources reads the sources map from a properties document.
+func propertySources(doc map[string]any) map[ProviderKey]SourceRef {
+	raw, _ := doc["sources"].(map[string]any)
+	out := make(map[ProviderKey]SourceRef, len(raw))
+	for provider, v := range raw {
+		m, _ := v.(map[string]any)
+		id, _ := m["externalId"].(string)
+		seen, _ := m["lastSeenAt"].(time.Time)
+		out[ProviderKey(provider)] = SourceRef{ExternalID: id, LastSeenAt: seen}
+	}
+	return out
+}
+
+// sourceLastSeen is when provider last returned the property: its sources
+// entry, or the document's lastSeenAt for properties written before sources
+// were tracked.
+func sourceLastSeen(doc map[string]any, provider ProviderKey) (time.Time, bool) {
+	if ref, ok := propertySources(doc)[provider]; ok && !ref.LastSeenAt.IsZero() {
+		return ref.LastSeenAt, true
+	}
+	seen, ok := doc["lastSeenAt"].(time.Time)
+	return seen, ok
+}
+
+// PropertyProvenance describes where a property's values came from.
+type PropertyProvenance struct {
+	PropertyID string                      `json:"propertyId"`
+	Source     ProviderKey                 `json:"source"`
+	Sources    map[ProviderKey]SourceRef   `json:"sources"`
+	Fields     map[string]*FieldProvenance `json:"fields"`
+	Policy     PrecedencePolicy            `json:"policy"`
+}
+
+// GetPropertyProvenance loads a property's field provenance, or nil when it
+// does not exist.
+func GetPropertyProvenance(ctx context.Context, projectID, propertyID string) (*PropertyProvenance, error) {
+	if projectID == "" || propertyID == "" {
+		return nil, fmt.Errorf("projectID and propertyID are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := client.Collection("properties").Doc(propertyID).Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data := snap.Data()
+	source, _ := data["source"].(string)
+	return &PropertyProvenance{
+		PropertyID: propertyID,
+		Source:     ProviderKey(source),
+		Sources:    propertySources(data),
+		Fields:     decodeProvenance(data),
+		Policy:     PrecedencePolicyFromEnv(),
+	}, nil
+}
@@ -0,0 +1,172 @@
+package listings
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func provenanceDoc(fields map[string]FieldProvenance, values map[string]any) map[string]any {
+	prov := map[string]any{}
+	for f, p := range fields {
+		prov[f] = map[string]any{"source": string(p.Source), "at": p.At}
+	}
+	values["provenance"] = prov
+	return values
+}
+
+// TestFieldRuleTakes verifies the priority and newest strategies.
+func TestFieldRuleTakes(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	older, newer := t0.Add(-time.Hour), t0.Add(time.Hour)
+	mlsFirst := FieldRule{Strategy: PrecedencePriority, Sources: []ProviderKey{ProviderMLS, ProviderRealtor}}
+	newest := FieldRule{Strategy: PrecedenceNewest}
+
+	cases := []struct {
+		name     string
+		rule     FieldRule
+		current  *FieldProvenance
+		incoming FieldProvenance
+		want     bool
+	}{
+		{"no provenance", mlsFirst, nil, FieldProvenance{ProviderZillow, older}, true},
+		{"same source older", newest, &FieldProvenance{ProviderZillow, t0}, FieldProvenance{ProviderZillow, older}, true},
+		{"portal vs mls", mlsFirst, &FieldProvenance{ProviderMLS, older}, FieldProvenance{ProviderZillow, newer}, false},
+		{"mls vs portal", mlsFirst, &FieldProvenance{ProviderZillow, newer}, FieldProvenance{ProviderMLS, older}, true},
+		{"mls instance", mlsFirst, &FieldProvenance{ProviderRealtor, t0}, FieldProvenance{"mls-ca", older}, true},
+		{"unranked tie newer", mlsFirst, &FieldProvenance{ProviderZillow, t0}, FieldProvenance{ProviderRedfin, newer}, true},
+		{"unranked tie older", mlsFirst, &FieldProvenance{ProviderZillow, t0}, FieldProvenance{ProviderRedfin, older}, false},
+		{"newest wins", newest, &FieldProvenance{ProviderMLS, t0}, FieldProvenance{ProviderZillow, newer}, true},
+		{"older loses", newest, &FieldProvenance{ProviderMLS, t0}, FieldProvenance{ProviderZillow, older}, false},
+	}
+	for _, c := range cases {
+		if got := c.rule.takes(c.current, c.incoming); got != c.want {
+			t.Errorf("%s: takes = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// TestResolveFields verifies losing fields are dropped, winners get
+// provenance and pricePerSqft follows the resolved values.
+func TestResolveFields(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	prev := provenanceDoc(map[string]FieldProvenance{
+		"status":     {ProviderMLS, t0},
+		"price":      {ProviderMLS, t0},
+		"squareFeet": {ProviderMLS, t0},
+	}, map[string]any{"status": "active", "price": 400000.0, "squareFeet": int64(2000)})
+
+	l := ExternalListing{ExternalID: "z1", Source: ProviderZillow, ListPrice: 390000, Sqft: 2100, Status: "Pending", Beds: 3}
+	data := propertyFields(l)
+	rejected := resolveFields(DefaultPrecedencePolicy(), prev, data, ProviderZillow, t0.Add(-time.Hour))
+
+	if !reflect.DeepEqual(rejected, []string{"price", "squareFeet", "status"}) {
+		t.Errorf("rejected = %v", rejected)
+	}
+	for _, k := range []string{"status", "price", "squareFeet", "pricePerSqft"} {
+		if _, ok := data[k]; ok {
+			t.Errorf("%s should have been dropped", k)
+		}
+	}
+	prov, _ := data["provenance"].(map[string]any)
+	if _, ok := prov["bedrooms"]; !ok || len(prov) != 1 {
+		t.Errorf("provenance = %v", prov)
+	}
+
+	// Newer portal values win where the newest rule applies, and
+	// pricePerSqft follows them.
+	data = propertyFields(l)
+	resolveFields(DefaultPrecedencePolicy(), prev, data, ProviderZillow, t0.Add(time.Hour))
+	if data["price"] != 390000.0 || data["pricePerSqft"] != 185.71 {
+		t.Errorf("price = %v, pricePerSqft = %v", data["price"], data["pricePerSqft"])
+	}
+	if _, ok := data["status"]; ok {
+		t.Error("portal status should lose to MLS regardless of age")
+	}
+
+	got := applyRejected(l, []string{"price", "status"})
+	if got.ListPrice != 0 || got.Status != "" || got.Beds != 3 {
+		t.Errorf("applyRejected = %+v", got)
+	}
+}
+
+// TestDropForeignFields verifies a remap leaves fields other sources own.
+func TestDropForeignFields(t *testing.T) {
+	t0 := time.Now()
+	prev := provenanceDoc(map[string]FieldProvenance{
+		"status": {ProviderMLS, t0},
+		"price":  {ProviderZillow, t0},
+	}, map[string]any{"price": 300000.0, "squareFeet": int64(1500)})
+	update := propertyFields(ExternalListing{Source: ProviderMLS, Status: "Active", ListPrice: 310000, Sqft: 1500})
+	dropForeignFields(prev, update, ProviderMLS)
+	if _, ok := update["price"]; ok {
+		t.Error("remap overwrote zillow-owned price")
+	}
+	if update["status"] != "Active" || update["pricePerSqft"] != 200.0 {
+		t.Errorf("update = %v", update)
+	}
+}
+
+// TestPrecedencePolicyFromEnv verifies the JSON overlay.
+func TestPrecedencePolicyFromEnv(t *testing.T) {
+	t.Setenv("LISTING_FIELD_PRECEDENCE", `{"fields":{"price":{"strategy":"priority","sources":["mls"]}},"mergeByAddress":false}`)
+	p := PrecedencePolicyFromEnv()
+	if p.MergeByAddress || p.Rule("price").Strategy != PrecedencePriority || p.Rule("status").Strategy != PrecedencePriority {
+		t.Errorf("policy = %+v", p)
+	}
+	if p.Rule("bedrooms").Strategy != PrecedenceNewest {
+		t.Errorf("default rule = %+v", p.Rule("bedrooms"))
+	}
+
+	t.Setenv("LISTING_FIELD_PRECEDENCE", `{not json`)
+	if p := PrecedencePolicyFromEnv(); !reflect.DeepEqual(p, DefaultPrecedencePolicy()) {
+		t.Errorf("malformed policy = %+v", p)
+	}
+}
+
+// TestSourceLastSeen verifies per-source sightings take priority over the
+// document-wide lastSeenAt.
+func TestSourceLastSeen(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	doc := map[string]any{
+		"lastSeenAt": t0,
+		"sources": map[string]any{
+			"mls": map[string]any{"externalId": "m1", "lastSeenAt": t0.Add(-48 * time.Hour)},
+		},
+	}
+	if seen, ok := sourceLastSeen(doc, ProviderMLS); !ok || !seen.Equal(t0.Add(-48*time.Hour)) {
+		t.Errorf("mls seen = %v", seen)
+	}
+	if seen, ok := sourceLastSeen(doc, ProviderZillow); !ok || !seen.Equal(t0) {
+		t.Errorf("fallback seen = %v", seen)
+	}
+}
+
+// TestMergeCandidate verifies listings merge into other providers' properties
+// but never into agent-entered ones or their own other listings.
+func TestMergeCandidate(t *testing.T) {
+	mls := map[string]any{
+		"source":  string(ProviderMLS),
+		"sources": map[string]any{string(ProviderMLS): map[string]any{"externalId": "m1"}},
+	}
+	if !mergeCandidate(mls, ProviderZillow, "z1") {
+		t.Error("zillow listing did not merge into the MLS property")
+	}
+	if mergeCandidate(mls, ProviderMLS, "m2") {
+		t.Error("provider merged into its own property")
+	}
+	mls["sources"].(map[string]any)[string(ProviderZillow)] = map[string]any{"externalId": "z1"}
+	if mergeCandidate(mls, ProviderZillow, "z2") {
+		t.Error("second zillow listing merged into a property zillow already lists")
+	}
+
+	agent := map[string]any{"source": string(SourceAgent), "agentId": "a1"}
+	for _, provider := range []ProviderKey{ProviderMLS, ProviderZillow, ProviderFile} {
+		if mergeCandidate(agent, provider, "x1") {
+			t.Errorf("%s listing merged into an agent-entered property", provider)
+		}
+	}
+	if mergeCandidate(map[string]any{}, ProviderMLS, "m1") {
+		t.Error("merged into a property with no source")
+	}
+}
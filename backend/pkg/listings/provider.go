@@ -3,6 +3,7 @@ package listings
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -19,19 +20,19 @@ const (
 
 // RegionFilter describes a geographic region to search.
 type RegionFilter struct {
-	City       string `json:"city,omitempty"`
-	State      string `json:"state,omitempty"`
-	PostalCode string `json:"postalCode,omitempty"`
+	City       string `firestore:"city,omitempty" json:"city,omitempty"`
+	State      string `firestore:"state,omitempty" json:"state,omitempty"`
+	PostalCode string `firestore:"postalCode,omitempty" json:"postalCode,omitempty"`
 	// Optional bounding box in lat/lng for map-style searches.
-	BBox *BBox `json:"bbox,omitempty"`
+	BBox *BBox `firestore:"bbox,omitempty" json:"bbox,omitempty"`
 }
 
 // BBox represents a simple lat/lng bounding box.
 type BBox struct {
-	North float64 `json:"north"`
-	South float64 `json:"south"`
-	East  float64 `json:"east"`
-	West  float64 `json:"west"`
+	North float64 `firestore:"north" json:"north"`
+	South float64 `firestore:"south" json:"south"`
+	East  float64 `firestore:"east" json:"east"`
+	West  float64 `firestore:"west" json:"west"`
 }
 
 // FetchParams controls how many listings to fetch and from when.
@@ -59,11 +60,32 @@ type ExternalListing struct {
 	Lat       float64 `json:"lat,omitempty"`
 	Lng       float64 `json:"lng,omitempty"`
 
-	Status       string    `json:"status,omitempty"`
-	ListedAt     time.Time `json:"listedAt,omitempty"`
-	UpdatedAt    time.Time `json:"updatedAt,omitempty"`
-	RawJSON      []byte    `json:"rawJson,omitempty"`
-	ProviderMeta any       `json:"providerMeta,omitempty"`
+	Photos      []string `json:"photos,omitempty"`
+	Remarks     string   `json:"remarks,omitempty"`
+	YearBuilt   int      `json:"yearBuilt,omitempty"`
+	LotSizeSqft float64  `json:"lotSizeSqft,omitempty"`
+	HOAFee      float64  `json:"hoaFee,omitempty"`
+
+	Stories         int    `json:"stories,omitempty"`
+	ParkingTotal    int    `json:"parkingTotal,omitempty"`
+	GarageSpaces    int    `json:"garageSpaces,omitempty"`
+	PropertySubType string `json:"propertySubType,omitempty"`
+	// ListAgentKey and ListOfficeKey are the provider's ids for the listing
+	// agent and office.
+	ListAgentKey  string `json:"listAgentKey,omitempty"`
+	ListOfficeKey string `json:"listOfficeKey,omitempty"`
+
+	Status    string    `json:"status,omitempty"`
+	ListedAt  time.Time `json:"listedAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+	RawJSON   []byte    `json:"rawJson,omitempty"`
+	// RawFormat names the schema of RawJSON (see RawFormatRESO).
+	RawFormat    string `json:"rawFormat,omitempty"`
+	ProviderMeta any    `json:"providerMeta,omitempty"`
+
+	// OwnerUID is the agent who imported the listing from a file. It is
+	// never read from provider JSON.
+	OwnerUID string `json:"-"`
 }
 
 // Address is a simple mailing address plus coordinates.
@@ -102,3 +124,14 @@ type Provider interface {
 // not currently configured (e.g. missing API keys or base URL). API handlers
 // can translate this into a 503-style response.
 var ErrNotConfigured = errors.New("provider not configured")
+
+// StatusError is returned by HTTP providers when the upstream API responds
+// with a non-200 status, so callers can tell auth failures from outages.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s provider returned status %d", e.Provider, e.StatusCode)
+}
@@ -0,0 +1,275 @@
+package listings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProvidersConfigEnv names the environment variable holding the path of a
+// provider registry config file. When unset the built-in providers are
+// configured from the environment.
+const ProvidersConfigEnv = "LISTINGS_PROVIDERS_CONFIG"
+
+// Settings understood by the built-in provider factories.
+const (
+	SettingBaseURL      = "baseUrl"
+	SettingAPIKey       = "apiKey"
+	SettingClientID     = "clientId"
+	SettingClientSecret = "clientSecret"
+	SettingRegionID     = "regionId"
+)
+
+var (
+	// ErrUnknownProviderType is returned when no factory is registered for a
+	// provider config's type.
+	ErrUnknownProviderType = errors.New("unknown provider type")
+	// ErrDuplicateProvider is returned when a provider key is registered twice.
+	ErrDuplicateProvider = errors.New("provider already registered")
+)
+
+// ProviderConfig describes one provider instance.
+type ProviderConfig struct {
+	// Key identifies the instance in URLs, schedules and property ids, e.g.
+	// "zillow-tx". It must be lowercase letters, digits, '-' or '_'.
+	Key ProviderKey `json:"key" yaml:"key"`
+	// Type selects the factory, e.g. "zillow".
+	Type string `json:"type" yaml:"type"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// EnvPrefix is where transport (ClientConfigFromEnv), reconcile and push
+	// settings are read from. It defaults to ProviderEnvPrefix(Key).
+	EnvPrefix string `json:"envPrefix,omitempty" yaml:"envPrefix,omitempty"`
+	// Settings are passed to the factory. Values loaded from a config file
+	// have ${VAR} references expanded so secrets can stay in the environment.
+	Settings map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
+}
+
+// RegistryConfig is the shape of a provider registry config file.
+type RegistryConfig struct {
+	Providers []ProviderConfig `json:"providers" yaml:"providers"`
+}
+
+var providerKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func validateProviderKey(key ProviderKey) error {
+	if !providerKeyPattern.MatchString(string(key)) {
+		return fmt.Errorf("invalid provider key %q", key)
+	}
+	if key == ProviderFile {
+		// File imports are attributed to ProviderFile in the ledger.
+		return fmt.Errorf("provider key %q is reserved", key)
+	}
+	return nil
+}
+
+func (c ProviderConfig) validate() error {
+	if c.Key == "" || c.Type == "" {
+		return fmt.Errorf("provider key and type are required")
+	}
+	return validateProviderKey(c.Key)
+}
+
+func (c ProviderConfig) envPrefix() string {
+	if c.EnvPrefix != "" {
+		return c.EnvPrefix
+	}
+	return ProviderEnvPrefix(c.Key)
+}
+
+func (c ProviderConfig) name(fallback string) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return fallback
+}
+
+// ProviderEnvPrefix returns the default environment prefix for a provider
+// key: upper-cased, '-' replaced by '_', with an "_API" suffix, so "zillow"
+// reads ZILLOW_API_* and "zillow-tx" reads ZILLOW_TX_API_*.
+func ProviderEnvPrefix(key ProviderKey) string {
+	return strings.ToUpper(strings.ReplaceAll(string(key), "-", "_")) + "_API"
+}
+
+// ProviderFactory builds a provider instance from its config.
+type ProviderFactory func(cfg ProviderConfig) (Provider, error)
+
+var (
+	factoriesMu       sync.RWMutex
+	providerFactories = map[string]ProviderFactory{
+		string(ProviderMLS):     newMLSProvider,
+		string(ProviderZillow):  newZillowProvider,
+		string(ProviderRedfin):  newRedfinProvider,
+		string(ProviderRealtor): newRealtorProvider,
+		string(ProviderFSBO):    newFSBOProvider,
+	}
+)
+
+// RegisterProviderFactory makes a provider type available to RegisterConfig
+// and config files. It panics if typ is empty or already registered.
+func RegisterProviderFactory(typ string, f ProviderFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if typ == "" || f == nil {
+		panic("listings: provider type and factory are required")
+	}
+	if _, ok := providerFactories[typ]; ok {
+		panic("listings: provider factory registered twice for type " + typ)
+	}
+	providerFactories[typ] = f
+}
+
+func lookupProviderFactory(typ string) (ProviderFactory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	f, ok := providerFactories[typ]
+	return f, ok
+}
+
+// builtinProviderConfigs returns one instance of each built-in type, keyed by
+// the type and configured from the environment variables NewRegistry has
+// always read.
+func builtinProviderConfigs() []ProviderConfig {
+	return []ProviderConfig{
+		{Key: ProviderMLS, Type: string(ProviderMLS), Settings: map[string]string{
+			SettingBaseURL:      os.Getenv("MLS_API_BASE_URL"),
+			SettingClientID:     os.Getenv("MLS_API_CLIENT_ID"),
+			SettingClientSecret: os.Getenv("MLS_API_CLIENT_SECRET"),
+		}},
+		{Key: ProviderZillow, Type: string(ProviderZillow), Settings: map[string]string{
+			SettingBaseURL:  os.Getenv("ZILLOW_API_BASE_URL"),
+			SettingAPIKey:   os.Getenv("ZILLOW_API_KEY"),
+			SettingRegionID: os.Getenv("ZILLOW_REGION_ID"),
+		}},
+		{Key: ProviderRedfin, Type: string(ProviderRedfin), Settings: map[string]string{
+			SettingBaseURL: os.Getenv("REDFIN_API_BASE_URL"),
+			SettingAPIKey:  os.Getenv("REDFIN_API_KEY"),
+		}},
+		{Key: ProviderRealtor, Type: string(ProviderRealtor), Settings: map[string]string{
+			SettingBaseURL: os.Getenv("REALTOR_API_BASE_URL"),
+			SettingAPIKey:  os.Getenv("REALTOR_API_KEY"),
+		}},
+		{Key: ProviderFSBO, Type: string(ProviderFSBO), Settings: map[string]string{
+			SettingBaseURL: os.Getenv("FSBO_API_BASE_URL"),
+			SettingAPIKey:  os.Getenv("FSBO_API_KEY"),
+		}},
+	}
+}
+
+func newMLSProvider(cfg ProviderConfig) (Provider, error) {
+	return &MLSProvider{
+		client:       NewProviderClient(ClientConfigFromEnv(cfg.envPrefix())),
+		key:          cfg.Key,
+		name:         cfg.name("MLS / RESO"),
+		BaseURL:      cfg.Settings[SettingBaseURL],
+		ClientID:     cfg.Settings[SettingClientID],
+		ClientSecret: cfg.Settings[SettingClientSecret],
+	}, nil
+}
+
+func newZillowProvider(cfg ProviderConfig) (Provider, error) {
+	return &ZillowProvider{
+		client:   NewProviderClient(ClientConfigFromEnv(cfg.envPrefix())),
+		key:      cfg.Key,
+		name:     cfg.name("Zillow"),
+		BaseURL:  cfg.Settings[SettingBaseURL],
+		APIKey:   cfg.Settings[SettingAPIKey],
+		RegionID: cfg.Settings[SettingRegionID],
+	}, nil
+}
+
+func newRedfinProvider(cfg ProviderConfig) (Provider, error) {
+	return &RedfinProvider{
+		client:  NewProviderClient(ClientConfigFromEnv(cfg.envPrefix())),
+		key:     cfg.Key,
+		name:    cfg.name("Redfin"),
+		BaseURL: cfg.Settings[SettingBaseURL],
+		APIKey:  cfg.Settings[SettingAPIKey],
+	}, nil
+}
+
+func newRealtorProvider(cfg ProviderConfig) (Provider, error) {
+	return &RealtorProvider{
+		client:  NewProviderClient(ClientConfigFromEnv(cfg.envPrefix())),
+		key:     cfg.Key,
+		name:    cfg.name("Realtor.com"),
+		BaseURL: cfg.Settings[SettingBaseURL],
+		APIKey:  cfg.Settings[SettingAPIKey],
+	}, nil
+}
+
+func newFSBOProvider(cfg ProviderConfig) (Provider, error) {
+	return &FSBOProvider{
+		client:  NewProviderClient(ClientConfigFromEnv(cfg.envPrefix())),
+		key:     cfg.Key,
+		name:    cfg.name("For Sale By Owner"),
+		BaseURL: cfg.Settings[SettingBaseURL],
+		APIKey:  cfg.Settings[SettingAPIKey],
+	}, nil
+}
+
+// LoadRegistryConfig reads a provider registry config file. Files ending in
+// .yaml or .yml are parsed as YAML and anything else as JSON. Unknown fields
+// are rejected so typos do not silently drop settings.
+func LoadRegistryConfig(path string) (RegistryConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RegistryConfig{}, fmt.Errorf("failed to read provider config: %w", err)
+	}
+	cfg, err := ParseRegistryConfig(data, filepath.Ext(path))
+	if err != nil {
+		return RegistryConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseRegistryConfig decodes a provider registry config in the format named
+// by ext (".json", ".yaml" or ".yml") and expands ${VAR} references in
+// settings.
+func ParseRegistryConfig(data []byte, ext string) (RegistryConfig, error) {
+	var cfg RegistryConfig
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil {
+			return RegistryConfig{}, fmt.Errorf("invalid provider config: %w", err)
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return RegistryConfig{}, fmt.Errorf("invalid provider config: %w", err)
+		}
+	}
+	if len(cfg.Providers) == 0 {
+		return RegistryConfig{}, fmt.Errorf("provider config lists no providers")
+	}
+	for i := range cfg.Providers {
+		for k, v := range cfg.Providers[i].Settings {
+			cfg.Providers[i].Settings[k] = os.ExpandEnv(v)
+		}
+	}
+	return cfg, nil
+}
+
+// RegistryFromEnv returns the registry described by the file named in
+// ProvidersConfigEnv, or NewRegistry when it is unset.
+func RegistryFromEnv() (*Registry, error) {
+	path := os.Getenv(ProvidersConfigEnv)
+	if path == "" {
+		return NewRegistry(), nil
+	}
+	cfg, err := LoadRegistryConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistryFromConfig(cfg)
+}
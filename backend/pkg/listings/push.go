@@ -0,0 +1,240 @@
+package listings
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TriggerPush marks ledger entries created by provider push batches.
+const TriggerPush = "push"
+
+// Push request headers. The signature is "sha256=" followed by the hex
+// HMAC-SHA256 of "{timestamp}.{body}" keyed with the provider's push secret;
+// the timestamp is Unix seconds.
+const (
+	PushSignatureHeader = "X-Assiduous-Signature"
+	PushTimestampHeader = "X-Assiduous-Timestamp"
+)
+
+// Formats accepted in PushBatch.Format.
+const (
+	// PushFormatNormalized records are ExternalListing JSON.
+	PushFormatNormalized = "normalized"
+	// PushFormatNative records are the provider's own listing records, mapped
+	// like a polled page.
+	PushFormatNative = "native"
+	// PushFormatRESO records are RESO Data Dictionary Property records.
+	PushFormatRESO = "reso"
+)
+
+// Per-record acknowledgement statuses.
+const (
+	PushCreated  = "created"
+	PushUpdated  = "updated"
+	PushRejected = "rejected"
+	// PushQuarantined records failed validation and are held for review.
+	PushQuarantined = "quarantined"
+)
+
+const (
+	// pushTolerance bounds clock skew and how long a captured request can be
+	// replayed.
+	pushTolerance = 5 * time.Minute
+	// MaxPushBatch is the most records accepted in one push.
+	MaxPushBatch = 500
+)
+
+var (
+	// ErrPushNotConfigured is returned when a provider has no push secret.
+	ErrPushNotConfigured = errors.New("push is not configured for provider")
+	// ErrPushSignature is returned when a push fails signature or timestamp
+	// verification.
+	ErrPushSignature = errors.New("invalid push signature")
+)
+
+// PushSecretsFromEnv reads {prefix}_PUSH_SECRET. Several comma-separated
+// secrets may be set while a provider rotates.
+func PushSecretsFromEnv(prefix string) []string {
+	var out []string
+	for _, s := range strings.Split(os.Getenv(prefix+"_PUSH_SECRET"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// SignPush returns the signature header value for body sent at timestamp.
+func SignPush(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPushSignature checks signature against any of secrets and that
+// timestamp is within pushTolerance of now.
+func VerifyPushSignature(secrets []string, timestamp, signature string, body []byte, now time.Time) error {
+	if len(secrets) == 0 {
+		return ErrPushNotConfigured
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or malformed timestamp", ErrPushSignature)
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > pushTolerance || d < -pushTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrPushSignature)
+	}
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(SignPush(secret, timestamp, body)), []byte(strings.TrimSpace(signature))) {
+			return nil
+		}
+	}
+	return ErrPushSignature
+}
+
+// PushBatch is the body of a push request.
+type PushBatch struct {
+	// Format defaults to PushFormatNormalized.
+	Format   string            `json:"format,omitempty"`
+	Listings []json.RawMessage `json:"listings"`
+}
+
+// PushAck acknowledges one record of a batch by its index.
+type PushAck struct {
+	Index      int    `json:"index"`
+	ExternalID string `json:"externalId,omitempty"`
+	PropertyID string `json:"propertyId,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PushResult reports a processed push batch.
+type PushResult struct {
+	Provider    ProviderKey    `json:"provider"`
+	RunID       string         `json:"runId,omitempty"`
+	Accepted    int            `json:"accepted"`
+	Quarantined int            `json:"quarantined"`
+	Rejected    int            `json:"rejected"`
+	Acks        []PushAck      `json:"acks"`
+	Summary     *UpsertSummary `json:"summary"`
+	Alerts      int            `json:"alerts,omitempty"`
+}
+
+// mapPushRecord maps one pushed record in the batch's format.
+func mapPushRecord(provider ProviderKey, format string, raw json.RawMessage) (ExternalListing, error) {
+	switch format {
+	case "", PushFormatNormalized:
+		var l ExternalListing
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return ExternalListing{}, fmt.Errorf("failed to decode listing: %w", err)
+		}
+		l.Source = provider
+		l.RawJSON = append([]byte(nil), raw...)
+		l.RawFormat = RawFormatProvider
+		return l, nil
+	case PushFormatNative:
+		return MapRawListing(provider, raw)
+	case PushFormatRESO:
+		return MapRESORecord(provider, raw)
+	default:
+		return ExternalListing{}, fmt.Errorf("unknown push format %q", format)
+	}
+}
+
+// IngestPush maps a verified push batch and writes each record through the
+// same upsert pipeline as polled ingest, recording the batch in the ingest
+// ledger. Records that cannot be mapped or written are rejected individually;
+// the rest are still applied.
+func IngestPush(ctx context.Context, projectID string, provider ProviderKey, batch PushBatch) (*PushResult, error) {
+	if projectID == "" || provider == "" {
+		return nil, fmt.Errorf("projectID and provider are required")
+	}
+	switch batch.Format {
+	case "", PushFormatNormalized, PushFormatNative, PushFormatRESO:
+	default:
+		return nil, fmt.Errorf("unknown push format %q", batch.Format)
+	}
+	if len(batch.Listings) == 0 {
+		return nil, fmt.Errorf("listings are required")
+	}
+	if len(batch.Listings) > MaxPushBatch {
+		return nil, fmt.Errorf("at most %d listings may be pushed at once", MaxPushBatch)
+	}
+
+	req := IngestRequest{Provider: provider, Trigger: TriggerPush}
+	run := startIngestRun(ctx, projectID, req, FetchParams{})
+	out := &IngestOutcome{Provider: provider, Pages: 1, Fetched: len(batch.Listings), Summary: &UpsertSummary{Provider: provider}}
+	res := &PushResult{Provider: provider, Acks: make([]PushAck, 0, len(batch.Listings))}
+
+	reject := func(ack PushAck, err error) {
+		ack.Status = PushRejected
+		ack.Error = err.Error()
+		res.Acks = append(res.Acks, ack)
+		res.Rejected++
+		out.Errors = append(out.Errors, fmt.Sprintf("record %d: %v", ack.Index, err))
+	}
+	for i, raw := range batch.Listings {
+		ack := PushAck{Index: i}
+		l, err := mapPushRecord(provider, batch.Format, raw)
+		if err != nil {
+			reject(ack, err)
+			continue
+		}
+		ack.ExternalID = l.ExternalID
+		if strings.TrimSpace(l.ExternalID) == "" {
+			reject(ack, fmt.Errorf("externalId is required"))
+			continue
+		}
+		ack.PropertyID = buildPropertyID(provider, l.ExternalID)
+
+		// Records are written one at a time so each gets its own outcome.
+		summary, err := UpsertExternalListingsToFirestore(ctx, projectID, &IngestResult{Provider: provider, Listings: []ExternalListing{l}})
+		if err != nil {
+			reject(ack, fmt.Errorf("%w: %v", ErrIngestPersist, err))
+			continue
+		}
+		out.Summary.add(summary)
+		if len(summary.propertyIDs) == 1 {
+			// The record may have merged into another provider's property.
+			ack.PropertyID = summary.propertyIDs[0]
+		}
+		switch {
+		case summary.Created > 0:
+			ack.Status = PushCreated
+		case summary.Updated > 0:
+			ack.Status = PushUpdated
+		case summary.Quarantined > 0:
+			ack.Status = PushQuarantined
+			res.Acks = append(res.Acks, ack)
+			res.Quarantined++
+			continue
+		default:
+			reject(ack, fmt.Errorf("listing could not be written"))
+			continue
+		}
+		res.Acks = append(res.Acks, ack)
+		res.Accepted++
+	}
+
+	finishIngestRun(ctx, projectID, run, out, nil)
+	underwriteForRun(ctx, projectID, out)
+	notifyForRun(ctx, projectID, out)
+
+	res.Summary = out.Summary
+	res.Alerts = out.Alerts
+	if run != nil {
+		res.RunID = run.ID
+	}
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package listings
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestVerifyPushSignature verifies the HMAC, rotation and timestamp checks.
+func TestVerifyPushSignature(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"listings":[]}`)
+	sig := SignPush("s3cret", ts, body)
+
+	if err := VerifyPushSignature([]string{"s3cret"}, ts, sig, body, now); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := VerifyPushSignature([]string{"new", "s3cret"}, ts, sig, body, now.Add(4*time.Minute)); err != nil {
+		t.Errorf("rotated secret rejected: %v", err)
+	}
+
+	cases := map[string]struct {
+		secrets []string
+		ts, sig string
+		body    []byte
+		now     time.Time
+		want    error
+	}{
+		"no secrets":   {nil, ts, sig, body, now, ErrPushNotConfigured},
+		"wrong secret": {[]string{"other"}, ts, sig, body, now, ErrPushSignature},
+		"tampered":     {[]string{"s3cret"}, ts, sig, []byte(`{"listings":[{}]}`), now, ErrPushSignature},
+		"stale":        {[]string{"s3cret"}, ts, sig, body, now.Add(6 * time.Minute), ErrPushSignature},
+		"future":       {[]string{"s3cret"}, ts, sig, body, now.Add(-6 * time.Minute), ErrPushSignature},
+		"no timestamp": {[]string{"s3cret"}, "", sig, body, now, ErrPushSignature},
+	}
+	for name, c := range cases {
+		if err := VerifyPushSignature(c.secrets, c.ts, c.sig, c.body, c.now); !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, c.want)
+		}
+	}
+}
+
+// TestPushSecretsFromEnv verifies comma-separated secrets are split.
+func TestPushSecretsFromEnv(t *testing.T) {
+	t.Setenv("ZILLOW_API_PUSH_SECRET", " old , new ,")
+	if got := PushSecretsFromEnv("ZILLOW_API"); !reflect.DeepEqual(got, []string{"old", "new"}) {
+		t.Errorf("secrets = %v", got)
+	}
+	if got := PushSecretsFromEnv("REDFIN_API"); got != nil {
+		t.Errorf("unset secrets = %v", got)
+	}
+}
+
+// TestMapPushRecord verifies each push format maps to an ExternalListing
+// attributed to the pushing provider.
+func TestMapPushRecord(t *testing.T) {
+	normalized := json.RawMessage(`{"externalId":"z1","source":"mls","listPrice":250000,"address":{"city":"Austin"}}`)
+	l, err := mapPushRecord(ProviderZillow, "", normalized)
+	if err != nil || l.ExternalID != "z1" || l.Source != ProviderZillow || l.ListPrice != 250000 || l.Address.City != "Austin" {
+		t.Errorf("normalized = %+v, %v", l, err)
+	}
+
+	native := json.RawMessage(`{"externalId":"z2","listPrice":300000,"beds":3}`)
+	if l, err := mapPushRecord(ProviderZillow, PushFormatNative, native); err != nil || l.ExternalID != "z2" || l.Beds != 3 {
+		t.Errorf("native = %+v, %v", l, err)
+	}
+
+	reso := json.RawMessage(`{"ListingKey":"r1","ListPrice":410000,"City":"Dallas","StandardStatus":"Active"}`)
+	if l, err := mapPushRecord(ProviderMLS, PushFormatRESO, reso); err != nil || l.ExternalID != "r1" || l.Address.City != "Dallas" || l.RawFormat != RawFormatRESO {
+		t.Errorf("reso = %+v, %v", l, err)
+	}
+
+	if _, err := mapPushRecord(ProviderMLS, "xml", reso); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := mapPushRecord(ProviderMLS, "", json.RawMessage(`[1,2]`)); err == nil {
+		t.Error("expected error for malformed record")
+	}
+}
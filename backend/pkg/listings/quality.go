@@ -0,0 +1,418 @@
+package listings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+)
+
+// quarantineCollection holds listings that failed validation, keyed by the
+// property id they would have been written to.
+const quarantineCollection = "listing_quarantine"
+
+// Quarantine review states.
+const (
+	QuarantinePending   = "pending"
+	QuarantineReleased  = "released"
+	QuarantineDiscarded = "discarded"
+)
+
+// Issue severities. Any error quarantines a listing; warnings only lower its
+// score.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// defaultMinQualityScore quarantines listings whose warnings add up, even
+// without an outright error.
+const defaultMinQualityScore = 50
+
+// Plausibility bounds for listing fields.
+const (
+	minPlausiblePrice = 1000
+	maxPlausiblePrice = 250_000_000
+	minPlausibleSqft  = 100
+	maxPlausibleSqft  = 200_000
+	maxPlausibleRooms = 50
+	minPlausibleYear  = 1600
+)
+
+// ErrQuarantineResolved is returned when a released or discarded listing is
+// changed.
+var ErrQuarantineResolved = errors.New("quarantined listing already resolved")
+
+// QualityIssue is one failed validation rule.
+type QualityIssue struct {
+	Rule     string `firestore:"rule" json:"rule"`
+	Field    string `firestore:"field" json:"field"`
+	Severity string `firestore:"severity" json:"severity"`
+	Message  string `firestore:"message" json:"message"`
+	// Penalty is subtracted from the listing's score of 100.
+	Penalty int `firestore:"penalty" json:"penalty"`
+}
+
+// QualityReport is the outcome of ValidateListing.
+type QualityReport struct {
+	Score      int            `json:"score"`
+	Issues     []QualityIssue `json:"issues,omitempty"`
+	Quarantine bool           `json:"quarantine"`
+}
+
+// qualityRule inspects one aspect of a listing, returning nil when it passes.
+type qualityRule func(l ExternalListing, now time.Time) *QualityIssue
+
+// qualityRules are run in order by ValidateListing.
+var qualityRules = []qualityRule{
+	func(l ExternalListing, _ time.Time) *QualityIssue {
+		switch {
+		case l.ListPrice <= 0:
+			return &QualityIssue{"price_missing", "listPrice", SeverityWarning, "list price is missing", 15}
+		case l.ListPrice < minPlausiblePrice:
+			return &QualityIssue{"price_implausible", "listPrice", SeverityError, fmt.Sprintf("list price $%.0f is implausibly low", l.ListPrice), 60}
+		case l.ListPrice > maxPlausiblePrice:
+			return &QualityIssue{"price_implausible", "listPrice", SeverityError, fmt.Sprintf("list price $%.0f is implausibly high", l.ListPrice), 60}
+		}
+		return nil
+	},
+	func(l ExternalListing, _ time.Time) *QualityIssue {
+		switch {
+		case l.Sqft <= 0:
+			return &QualityIssue{"sqft_missing", "sqft", SeverityWarning, "living area is missing or zero", 15}
+		case l.Sqft < minPlausibleSqft || l.Sqft > maxPlausibleSqft:
+			return &QualityIssue{"sqft_implausible", "sqft", SeverityError, fmt.Sprintf("living area of %.0f sqft is implausible", l.Sqft), 40}
+		}
+		return nil
+	},
+	func(l ExternalListing, _ time.Time) *QualityIssue {
+		switch {
+		case l.Lat == 0 && l.Lng == 0:
+			return &QualityIssue{"coordinates_missing", "lat", SeverityWarning, "coordinates are missing or 0,0", 15}
+		case l.Lat < -90 || l.Lat > 90 || l.Lng < -180 || l.Lng > 180 || l.Lat == 0 || l.Lng == 0:
+			return &QualityIssue{"coordinates_invalid", "lat", SeverityError, fmt.Sprintf("coordinates %.5f,%.5f are invalid", l.Lat, l.Lng), 40}
+		}
+		return nil
+	},
+	func(l ExternalListing, _ time.Time) *QualityIssue {
+		if strings.TrimSpace(l.Address.City) == "" {
+			return &QualityIssue{"city_missing", "address.city", SeverityError, "city is missing", 40}
+		}
+		return nil
+	},
+	func(l ExternalListing, _ time.Time) *QualityIssue {
+		if strings.TrimSpace(l.Address.State) == "" {
+			return &QualityIssue{"state_missing", "address.state", SeverityWarning, "state is missing", 10}
+		}
+		return nil
+	},
+	func(l ExternalListing, _ time.Time) *QualityIssue {
+		if strings.TrimSpace(l.Address.Street1) == "" {
+			return &QualityIssue{"street_missing", "address.street1", SeverityWarning, "street address is missing", 15}
+		}
+		return nil
+	},
+	func(l ExternalListing, _ time.Time) *QualityIssue {
+		if l.Beds > maxPlausibleRooms || l.Baths > maxPlausibleRooms || l.Beds < 0 || l.Baths < 0 {
+			return &QualityIssue{"rooms_implausible", "beds", SeverityError, fmt.Sprintf("%.0f beds / %.1f baths is implausible", l.Beds, l.Baths), 40}
+		}
+		return nil
+	},
+	func(l ExternalListing, now time.Time) *QualityIssue {
+		if l.YearBuilt != 0 && (l.YearBuilt < minPlausibleYear || l.YearBuilt > now.Year()+2) {
+			return &QualityIssue{"year_built_implausible", "yearBuilt", SeverityError, fmt.Sprintf("year built %d is implausible", l.YearBuilt), 20}
+		}
+		return nil
+	},
+}
+
+// MinQualityScore returns the score below which listings are quarantined
+// (LISTING_MIN_QUALITY_SCORE, default 50).
+func MinQualityScore() int {
+	if n, err := strconv.Atoi(os.Getenv("LISTING_MIN_QUALITY_SCORE")); err == nil && n >= 0 && n <= 100 {
+		return n
+	}
+	return defaultMinQualityScore
+}
+
+// ValidateListing runs the quality rules against l and scores it from 0 to
+// 100. A listing is quarantined when any rule fails with an error or the
+// score falls below minScore.
+func ValidateListing(l ExternalListing, minScore int, now time.Time) QualityReport {
+	rep := QualityReport{Score: 100}
+	for _, rule := range qualityRules {
+		issue := rule(l, now)
+		if issue == nil {
+			continue
+		}
+		rep.Issues = append(rep.Issues, *issue)
+		rep.Score -= issue.Penalty
+		if issue.Severity == SeverityError {
+			rep.Quarantine = true
+		}
+	}
+	if rep.Score < 0 {
+		rep.Score = 0
+	}
+	if rep.Score < minScore {
+		rep.Quarantine = true
+	}
+	return rep
+}
+
+// issueRules returns the rule names of issues, for storing on properties.
+func issueRules(issues []QualityIssue) []string {
+	out := make([]string, len(issues))
+	for i, is := range issues {
+		out[i] = is.Rule
+	}
+	return out
+}
+
+// QuarantinedListing is a listing held back from properties for review.
+type QuarantinedListing struct {
+	ID         string         `firestore:"-" json:"id"`
+	Provider   ProviderKey    `firestore:"provider" json:"provider"`
+	ExternalID string         `firestore:"externalId" json:"externalId"`
+	Status     string         `firestore:"status" json:"status"`
+	Score      int            `firestore:"score" json:"score"`
+	Issues     []QualityIssue `firestore:"issues" json:"issues"`
+	// Listing is the ExternalListing JSON as received (or as fixed by an
+	// admin).
+	Listing string `firestore:"listing" json:"-"`
+	// OwnerUID is the listing's OwnerUID, which the listing JSON omits.
+	OwnerUID    string `firestore:"ownerUid,omitempty" json:"ownerUid,omitempty"`
+	Occurrences int    `firestore:"occurrences" json:"occurrences"`
+	// Existing is true when the listing is already in properties and only
+	// this update was held back.
+	Existing      bool      `firestore:"existing" json:"existing"`
+	QuarantinedAt time.Time `firestore:"quarantinedAt" json:"quarantinedAt"`
+	LastSeenAt    time.Time `firestore:"lastSeenAt" json:"lastSeenAt"`
+	ResolvedAt    time.Time `firestore:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+	ResolvedBy    string    `firestore:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+
+	// ListingData is Listing decoded for API responses.
+	ListingData *ExternalListing `firestore:"-" json:"listing,omitempty"`
+}
+
+// decodeListing parses the stored listing JSON.
+func (q *QuarantinedListing) decodeListing() (ExternalListing, error) {
+	var l ExternalListing
+	err := json.Unmarshal([]byte(q.Listing), &l)
+	l.OwnerUID = q.OwnerUID
+	return l, err
+}
+
+// quarantineListing records a failed listing under its property id. A
+// listing that keeps failing is updated in place; one an admin discarded
+// stays discarded.
+func quarantineListing(ctx context.Context, client *gfs.Client, id string, l ExternalListing, rep QualityReport, existing bool, now time.Time) error {
+	encoded, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	ref := client.Collection(quarantineCollection).Doc(id)
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *gfs.Transaction) error {
+		data := map[string]any{
+			"provider":    string(l.Source),
+			"externalId":  l.ExternalID,
+			"score":       rep.Score,
+			"issues":      rep.Issues,
+			"listing":     string(encoded),
+			"ownerUid":    l.OwnerUID,
+			"existing":    existing,
+			"lastSeenAt":  now,
+			"occurrences": gfs.Increment(1),
+		}
+		doc, err := tx.Get(ref)
+		switch {
+		case err != nil && !fs.IsNotFound(err):
+			return err
+		case err != nil, doc.Data()["status"] == QuarantineReleased:
+			// New, or released earlier and failing again: back to review.
+			data["status"] = QuarantinePending
+			data["quarantinedAt"] = now
+			data["resolvedAt"] = gfs.Delete
+			data["resolvedBy"] = gfs.Delete
+		}
+		return tx.Set(ref, data, gfs.MergeAll)
+	})
+}
+
+// QuarantineFilter selects quarantined listings for review.
+type QuarantineFilter struct {
+	Status   string
+	Provider ProviderKey
+	Limit    int
+}
+
+// ListQuarantinedListings returns quarantined listings, most recently seen
+// first. Status defaults to pending.
+func ListQuarantinedListings(ctx context.Context, projectID string, f QuarantineFilter) ([]*QuarantinedListing, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("projectID is required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	status := f.Status
+	if status == "" {
+		status = QuarantinePending
+	}
+	q := client.Collection(quarantineCollection).Where("status", "==", status)
+	if f.Provider != "" {
+		q = q.Where("provider", "==", string(f.Provider))
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	snap, err := q.OrderBy("lastSeenAt", gfs.Desc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*QuarantinedListing, 0, len(snap))
+	for _, doc := range snap {
+		var ql QuarantinedListing
+		if err := doc.DataTo(&ql); err != nil {
+			log.Printf("[listings] skipping malformed quarantined listing %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		ql.ID = doc.Ref.ID
+		out = append(out, &ql)
+	}
+	return out, nil
+}
+
+// GetQuarantinedListing loads a quarantined listing with its decoded
+// listing. It returns (nil, nil) when it does not exist.
+func GetQuarantinedListing(ctx context.Context, projectID, id string) (*QuarantinedListing, error) {
+	if projectID == "" || id == "" {
+		return nil, fmt.Errorf("projectID and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := client.Collection(quarantineCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ql QuarantinedListing
+	if err := doc.DataTo(&ql); err != nil {
+		return nil, err
+	}
+	ql.ID = doc.Ref.ID
+	if l, err := ql.decodeListing(); err == nil {
+		ql.ListingData = &l
+	}
+	return &ql, nil
+}
+
+// FixQuarantinedListing applies a partial ExternalListing JSON patch to a
+// pending quarantined listing and re-validates it. The fixed listing stays in
+// quarantine until released.
+func FixQuarantinedListing(ctx context.Context, projectID, id string, patch json.RawMessage) (*QuarantinedListing, error) {
+	ql, err := GetQuarantinedListing(ctx, projectID, id)
+	if err != nil || ql == nil {
+		return nil, err
+	}
+	if ql.Status != QuarantinePending {
+		return nil, ErrQuarantineResolved
+	}
+	l, err := ql.decodeListing()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &l); err != nil {
+		return nil, fmt.Errorf("invalid listing patch: %w", err)
+	}
+	// Identity is fixed by the document id.
+	l.Source, l.ExternalID = ql.Provider, ql.ExternalID
+
+	encoded, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	rep := ValidateListing(l, MinQualityScore(), time.Now())
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.Collection(quarantineCollection).Doc(id).Set(ctx, map[string]any{
+		"listing": string(encoded),
+		"score":   rep.Score,
+		"issues":  rep.Issues,
+	}, gfs.MergeAll); err != nil {
+		return nil, err
+	}
+	ql.Listing, ql.ListingData, ql.Score, ql.Issues = string(encoded), &l, rep.Score, rep.Issues
+	return ql, nil
+}
+
+// ReleaseQuarantinedListing writes a pending quarantined listing into
+// properties through the normal upsert pipeline, bypassing validation on the
+// admin's say-so, and marks it released.
+func ReleaseQuarantinedListing(ctx context.Context, projectID, id, reviewer string) (*UpsertSummary, error) {
+	ql, err := GetQuarantinedListing(ctx, projectID, id)
+	if err != nil || ql == nil {
+		return nil, err
+	}
+	if ql.Status != QuarantinePending {
+		return nil, ErrQuarantineResolved
+	}
+	l, err := ql.decodeListing()
+	if err != nil {
+		return nil, err
+	}
+	summary, err := upsertListings(ctx, projectID, &IngestResult{Provider: ql.Provider, Listings: []ExternalListing{l}}, upsertOptions{skipValidation: true})
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveQuarantine(ctx, projectID, id, QuarantineReleased, reviewer); err != nil {
+		return nil, err
+	}
+	out := &IngestOutcome{Provider: ql.Provider, Summary: summary}
+	underwriteForRun(ctx, projectID, out)
+	notifyForRun(ctx, projectID, out)
+	return summary, nil
+}
+
+// DiscardQuarantinedListing marks a pending quarantined listing discarded.
+// Later failing copies of it stay discarded. It reports false when the
+// listing does not exist.
+func DiscardQuarantinedListing(ctx context.Context, projectID, id, reviewer string) (bool, error) {
+	ql, err := GetQuarantinedListing(ctx, projectID, id)
+	if err != nil || ql == nil {
+		return false, err
+	}
+	if ql.Status != QuarantinePending {
+		return false, ErrQuarantineResolved
+	}
+	return true, resolveQuarantine(ctx, projectID, id, QuarantineDiscarded, reviewer)
+}
+
+func resolveQuarantine(ctx context.Context, projectID, id, status, reviewer string) error {
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	_, err = client.Collection(quarantineCollection).Doc(id).Set(ctx, map[string]any{
+		"status":     status,
+		"resolvedAt": time.Now(),
+		"resolvedBy": reviewer,
+	}, gfs.MergeAll)
+	return err
+}
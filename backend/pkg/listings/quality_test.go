@@ -0,0 +1,98 @@
+package listings
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func goodListing() ExternalListing {
+	return ExternalListing{
+		ExternalID: "x1",
+		Source:     ProviderMLS,
+		Address:    Address{Street1: "1 Main St", City: "Austin", State: "TX", Postal: "78701"},
+		ListPrice:  350000,
+		Beds:       3,
+		Baths:      2,
+		Sqft:       1800,
+		Lat:        30.27,
+		Lng:        -97.74,
+		YearBuilt:  1995,
+	}
+}
+
+// TestValidateListing verifies the rules, scoring and quarantine decision.
+func TestValidateListing(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	if rep := ValidateListing(goodListing(), defaultMinQualityScore, now); rep.Score != 100 || rep.Quarantine || len(rep.Issues) != 0 {
+		t.Errorf("good listing = %+v", rep)
+	}
+
+	cases := []struct {
+		name       string
+		mutate     func(*ExternalListing)
+		rules      []string
+		quarantine bool
+	}{
+		{"one dollar", func(l *ExternalListing) { l.ListPrice = 1 }, []string{"price_implausible"}, true},
+		{"zero sqft", func(l *ExternalListing) { l.Sqft = 0 }, []string{"sqft_missing"}, false},
+		{"null island", func(l *ExternalListing) { l.Lat, l.Lng = 0, 0 }, []string{"coordinates_missing"}, false},
+		{"missing city", func(l *ExternalListing) { l.Address.City = " " }, []string{"city_missing"}, true},
+		{"bad latitude", func(l *ExternalListing) { l.Lat = 97 }, []string{"coordinates_invalid"}, true},
+		{"future build", func(l *ExternalListing) { l.YearBuilt = 2031 }, []string{"year_built_implausible"}, true},
+		{"too many rooms", func(l *ExternalListing) { l.Beds = 120 }, []string{"rooms_implausible"}, true},
+		{
+			"warnings add up",
+			func(l *ExternalListing) { l.Sqft, l.Lat, l.Lng, l.Address.Street1, l.ListPrice = 0, 0, 0, "", 0 },
+			[]string{"price_missing", "sqft_missing", "coordinates_missing", "street_missing"},
+			true,
+		},
+	}
+	for _, c := range cases {
+		l := goodListing()
+		c.mutate(&l)
+		rep := ValidateListing(l, defaultMinQualityScore, now)
+		if got := issueRules(rep.Issues); !reflect.DeepEqual(got, c.rules) {
+			t.Errorf("%s: rules = %v, want %v", c.name, got, c.rules)
+		}
+		if rep.Quarantine != c.quarantine {
+			t.Errorf("%s: quarantine = %v (score %d)", c.name, rep.Quarantine, rep.Score)
+		}
+	}
+}
+
+// TestMinQualityScore verifies the environment override and its bounds.
+func TestMinQualityScore(t *testing.T) {
+	if got := MinQualityScore(); got != defaultMinQualityScore {
+		t.Errorf("default = %d", got)
+	}
+	t.Setenv("LISTING_MIN_QUALITY_SCORE", "70")
+	if got := MinQualityScore(); got != 70 {
+		t.Errorf("override = %d", got)
+	}
+	t.Setenv("LISTING_MIN_QUALITY_SCORE", "170")
+	if got := MinQualityScore(); got != defaultMinQualityScore {
+		t.Errorf("out of range = %d", got)
+	}
+}
+
+// TestQuarantinedListingPatch verifies a partial fix merges onto the stored
+// listing without clearing other fields.
+func TestQuarantinedListingPatch(t *testing.T) {
+	l := goodListing()
+	l.Address.City = ""
+	encoded, _ := json.Marshal(l)
+	ql := &QuarantinedListing{Listing: string(encoded)}
+
+	got, err := ql.decodeListing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"address":{"city":"Austin"}}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, goodListing()) {
+		t.Errorf("patched = %+v", got)
+	}
+}
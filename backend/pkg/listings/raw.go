@@ -0,0 +1,153 @@
+package listings
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/objectstore"
+)
+
+// rawPayloadKey is the object key for an archived listing record. Keys are
+// content-addressed so unchanged payloads are never rewritten.
+func rawPayloadKey(provider ProviderKey, externalID, hash string) string {
+	return fmt.Sprintf("raw/listings/%s/%s/%s.json", provider, url.PathEscape(externalID), hash)
+}
+
+// rawPayloadHash returns the content hash recorded on a property document, or
+// "" when none is present.
+func rawPayloadHash(doc map[string]any) string {
+	raw, _ := doc["rawPayload"].(map[string]any)
+	h, _ := raw["sha256"].(string)
+	return h
+}
+
+// archiveRawPayload stores l.RawJSON and returns the pointer to merge onto the
+// property document under rawPayload. It returns nil when the payload hash
+// matches prevHash, since the existing pointer is still current.
+func archiveRawPayload(ctx context.Context, store objectstore.Store, l ExternalListing, prevHash string) (map[string]any, error) {
+	sum := sha256.Sum256(l.RawJSON)
+	hash := hex.EncodeToString(sum[:])
+	if hash == prevHash {
+		return nil, nil
+	}
+	key := rawPayloadKey(l.Source, l.ExternalID, hash)
+	if err := store.Put(ctx, key, l.RawJSON, "application/json"); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"key":      key,
+		"uri":      store.URI(key),
+		"sha256":   hash,
+		"format":   l.RawFormat,
+		"size":     len(l.RawJSON),
+		"storedAt": time.Now(),
+	}, nil
+}
+
+// RemapRequest selects which properties RemapProperties re-derives.
+type RemapRequest struct {
+	// Provider restricts the job to a single source. Optional.
+	Provider ProviderKey `json:"provider,omitempty"`
+	// Force re-maps documents already at the current MappingVersion.
+	Force bool `json:"force,omitempty"`
+	Limit int  `json:"limit,omitempty"`
+}
+
+// RemapSummary reports what a remap job did.
+type RemapSummary struct {
+	MappingVersion int `json:"mappingVersion"`
+	Scanned        int `json:"scanned"`
+	Remapped       int `json:"remapped"`
+	Skipped        int `json:"skipped"`
+	Failed         int `json:"failed"`
+}
+
+// RemapProperties re-derives property fields from archived raw payloads so
+// improvements to MapRawListing can be applied without re-fetching from
+// providers. Only documents with an archived payload are touched, and unless
+// Force is set only those mapped by an older MappingVersion.
+func RemapProperties(ctx context.Context, projectID string, req RemapRequest) (*RemapSummary, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("projectID is required")
+	}
+	store, err := objectstore.Default(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, fmt.Errorf("object storage is not configured; set OBJECT_STORE_BUCKET or OBJECT_STORE_DIR")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 200
+	}
+	q := client.Collection("properties").Query
+	if req.Provider != "" {
+		q = q.Where("source", "==", string(req.Provider))
+	}
+	if !req.Force {
+		q = q.Where("mappingVersion", "<", MappingVersion)
+	}
+	snap, err := q.Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RemapSummary{MappingVersion: MappingVersion}
+	for _, doc := range snap {
+		summary.Scanned++
+		data := doc.Data()
+		raw, _ := data["rawPayload"].(map[string]any)
+		key, _ := raw["key"].(string)
+		source, _ := data["source"].(string)
+		if key == "" || source == "" {
+			summary.Skipped++
+			continue
+		}
+
+		payload, err := store.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, objectstore.ErrNotFound) {
+				summary.Skipped++
+				continue
+			}
+			log.Printf("[listings] remap: failed to read raw payload %s: %v", key, err)
+			summary.Failed++
+			continue
+		}
+		format, _ := raw["format"].(string)
+		l, err := mapArchivedPayload(ProviderKey(source), format, payload)
+		if err != nil {
+			log.Printf("[listings] remap: property doc id=%s: %v", doc.Ref.ID, err)
+			summary.Failed++
+			continue
+		}
+
+		update := propertyFields(l)
+		dropForeignFields(data, update, ProviderKey(source))
+		if _, ok := update["images"]; ok && mediaChanged(data, l.Photos) {
+			update["mediaStatus"] = MediaPending
+		}
+		update["updatedAt"] = time.Now()
+		update["remappedAt"] = time.Now()
+		if _, err := doc.Ref.Set(ctx, update, fs.MergeAll()); err != nil {
+			log.Printf("[listings] remap: failed to write property doc id=%s: %v", doc.Ref.ID, err)
+			summary.Failed++
+			continue
+		}
+		summary.Remapped++
+	}
+	return summary, nil
+}
@@ -0,0 +1,264 @@
+package listings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// statusReasonNotSeen is stored in statusReason on listings reconciled away
+// because their provider stopped returning them.
+const statusReasonNotSeen = "not_seen_in_feed"
+
+// maxReconcileScan bounds the listings marked per sweep.
+const maxReconcileScan = 2000
+
+// ReconcilePolicy controls what happens to a provider's listings that a full
+// sweep no longer returns. Documents are never deleted; they are only marked.
+type ReconcilePolicy struct {
+	Enabled bool `json:"enabled"`
+	// Action is the status given to missing listings: StatusOffMarket for
+	// authoritative feeds, StatusUnknown where absence is weaker evidence.
+	Action string `json:"action"`
+	// GracePeriod is how long a listing may go unseen before it is marked.
+	GracePeriod time.Duration `json:"-"`
+	// MinSweepListings skips reconciliation when a sweep returned fewer
+	// listings, which usually means a broken feed rather than a dead market.
+	MinSweepListings int `json:"minSweepListings"`
+	// MaxMarkFraction aborts when more than this share of the region's
+	// open listings would be marked in one sweep.
+	MaxMarkFraction float64 `json:"maxMarkFraction"`
+}
+
+// MarshalJSON renders GracePeriod as a duration string such as "72h0m0s".
+func (p ReconcilePolicy) MarshalJSON() ([]byte, error) {
+	type policy ReconcilePolicy
+	return json.Marshal(struct {
+		policy
+		GracePeriod string `json:"gracePeriod"`
+	}{policy(p), p.GracePeriod.String()})
+}
+
+// defaultReconcilePolicy returns the built-in policy for a provider. The MLS
+// is the system of record, so a missing listing really is off market;
+// aggregators drop listings for many reasons, so theirs become unknown.
+// Reconciliation rewrites statuses, so it is off until enabled per provider.
+func defaultReconcilePolicy(key ProviderKey) ReconcilePolicy {
+	p := ReconcilePolicy{
+		Enabled:          false,
+		Action:           StatusUnknown,
+		GracePeriod:      72 * time.Hour,
+		MinSweepListings: 1,
+		MaxMarkFraction:  0.5,
+	}
+	if key == ProviderMLS {
+		p.Action = StatusOffMarket
+		p.GracePeriod = 48 * time.Hour
+	}
+	return p
+}
+
+// ReconcilePolicyFromEnv overlays {prefix}_RECONCILE_ENABLED, _ACTION,
+// _GRACE, _MIN_LISTINGS and _MAX_FRACTION onto the provider's default policy.
+func ReconcilePolicyFromEnv(key ProviderKey, prefix string) ReconcilePolicy {
+	p := defaultReconcilePolicy(key)
+	if b, err := strconv.ParseBool(os.Getenv(prefix + "_RECONCILE_ENABLED")); err == nil {
+		p.Enabled = b
+	}
+	switch a := os.Getenv(prefix + "_RECONCILE_ACTION"); a {
+	case StatusOffMarket, StatusUnknown:
+		p.Action = a
+	}
+	if d, err := time.ParseDuration(os.Getenv(prefix + "_RECONCILE_GRACE")); err == nil && d >= 0 {
+		p.GracePeriod = d
+	}
+	if n, err := strconv.Atoi(os.Getenv(prefix + "_RECONCILE_MIN_LISTINGS")); err == nil && n >= 0 {
+		p.MinSweepListings = n
+	}
+	if f, err := strconv.ParseFloat(os.Getenv(prefix+"_RECONCILE_MAX_FRACTION"), 64); err == nil && f > 0 && f <= 1 {
+		p.MaxMarkFraction = f
+	}
+	return p
+}
+
+// ReconcileSummary reports one reconciliation pass.
+type ReconcileSummary struct {
+	Provider ProviderKey `json:"provider"`
+	Action   string      `json:"action"`
+	Cutoff   time.Time   `json:"cutoff"`
+	// Open counts the region's active and pending listings; Missing those
+	// unseen since Cutoff.
+	Open    int `json:"open"`
+	Missing int `json:"missing"`
+	Marked  int `json:"marked"`
+	Failed  int `json:"failed"`
+	// Aborted explains why nothing was marked, e.g. the safety fraction.
+	Aborted string `json:"aborted,omitempty"`
+}
+
+// isFullSweep reports whether a run walked the provider's whole result set
+// for its region, which is the only time absence means anything.
+func isFullSweep(params FetchParams, out *IngestOutcome) bool {
+	return params.Since.IsZero() && params.PageToken == "" &&
+		out != nil && out.NextPage == "" && len(out.Errors) == 0 && out.Pages > 0
+}
+
+// ReconcileMissingListings marks the provider's open listings in region that
+// have not been seen since sweepStart minus the policy grace period. It is run
+// after a full sweep; seen listings have lastSeenAt >= sweepStart.
+func ReconcileMissingListings(ctx context.Context, projectID string, provider ProviderKey, region RegionFilter, policy ReconcilePolicy, sweepStart time.Time) (*ReconcileSummary, error) {
+	if projectID == "" || provider == "" {
+		return nil, fmt.Errorf("projectID and provider are required")
+	}
+	cutoff := sweepStart.Add(-policy.GracePeriod)
+	summary := &ReconcileSummary{Provider: provider, Action: policy.Action, Cutoff: cutoff}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the region's open listings so the safety fraction has a
+	// denominator.
+	var stale []*gfs.DocumentSnapshot
+	iter := client.Collection("properties").
+		Where("source", "==", string(provider)).
+		Select("status", "address", "lastSeenAt", "sources", "provenance").
+		Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+		data := doc.Data()
+		if !isOpenStatus(data["status"]) || !regionContains(region, data) {
+			continue
+		}
+		// A status held by another source under the precedence policy is
+		// that source's to change.
+		if p := decodeProvenance(data)["status"]; p != nil && p.Source != provider {
+			continue
+		}
+		summary.Open++
+		// Documents written before lastSeenAt existed are left alone until a
+		// sweep stamps them; we cannot tell how long they have been missing.
+		seen, ok := sourceLastSeen(data, provider)
+		if !ok || !seen.Before(cutoff) {
+			continue
+		}
+		summary.Missing++
+		if len(stale) < maxReconcileScan {
+			stale = append(stale, doc)
+		}
+	}
+
+	if len(stale) == 0 {
+		return summary, nil
+	}
+	if policy.MaxMarkFraction > 0 && float64(summary.Missing) > policy.MaxMarkFraction*float64(summary.Open) {
+		summary.Aborted = fmt.Sprintf("%d of %d open listings missing exceeds max fraction %.2f", summary.Missing, summary.Open, policy.MaxMarkFraction)
+		log.Printf("[listings] reconcile for provider %s aborted: %s", provider, summary.Aborted)
+		return summary, nil
+	}
+
+	now := time.Now()
+	for _, doc := range stale {
+		oldStatus, _ := doc.Data()["status"].(string)
+		if _, err := doc.Ref.Set(ctx, map[string]any{
+			"status":             policy.Action,
+			"statusReason":       statusReasonNotSeen,
+			"statusReconciledAt": now,
+			"updatedAt":          now,
+			"provenance": map[string]any{
+				"status": map[string]any{"source": string(provider), "at": now},
+			},
+		}, fs.MergeAll()); err != nil {
+			log.Printf("[listings] failed to reconcile property %s: %v", doc.Ref.ID, err)
+			summary.Failed++
+			continue
+		}
+		summary.Marked++
+		ev := HistoryEvent{
+			Type:     eventForStatus(policy.Action),
+			Field:    "status",
+			OldValue: oldStatus,
+			NewValue: policy.Action,
+			Source:   provider,
+			At:       now,
+		}
+		if err := recordHistoryEvents(ctx, client, doc.Ref.ID, []HistoryEvent{ev}); err != nil {
+			log.Printf("[listings] failed to record reconcile history for property %s: %v", doc.Ref.ID, err)
+		}
+	}
+	return summary, nil
+}
+
+// isOpenStatus reports whether a stored status is one reconciliation should
+// consider (active or pending).
+func isOpenStatus(v any) bool {
+	s, _ := v.(string)
+	switch NormalizeStatus(s) {
+	case StatusActive, StatusPending:
+		return true
+	}
+	return false
+}
+
+// regionContains reports whether a properties document lies inside the
+// sweep's region. An empty region covers the whole provider.
+func regionContains(r RegionFilter, data map[string]any) bool {
+	addr, _ := data["address"].(map[string]any)
+	field := func(k string) string { s, _ := addr[k].(string); return strings.TrimSpace(s) }
+	if r.City != "" && !strings.EqualFold(r.City, field("city")) {
+		return false
+	}
+	if r.State != "" && !strings.EqualFold(r.State, field("state")) {
+		return false
+	}
+	if r.PostalCode != "" && !strings.EqualFold(r.PostalCode, field("postalCode")) {
+		return false
+	}
+	if r.BBox != nil {
+		coords, _ := addr["coordinates"].(map[string]any)
+		lat, ok1 := coords["latitude"].(float64)
+		lng, ok2 := coords["longitude"].(float64)
+		if !ok1 || !ok2 || lat < r.BBox.South || lat > r.BBox.North || lng < r.BBox.West || lng > r.BBox.East {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileForRun reconciles after a full sweep when the provider's policy
+// allows it. Failures are logged; they never fail the run.
+func reconcileForRun(ctx context.Context, projectID string, reg *Registry, params FetchParams, out *IngestOutcome, sweepStart time.Time) {
+	if !isFullSweep(params, out) {
+		return
+	}
+	policy := reg.ReconcilePolicy(out.Provider)
+	if !policy.Enabled {
+		return
+	}
+	if out.Fetched < policy.MinSweepListings {
+		log.Printf("[listings] skipping reconcile for provider %s: sweep returned %d listings", out.Provider, out.Fetched)
+		return
+	}
+	summary, err := ReconcileMissingListings(ctx, projectID, out.Provider, params.Region, policy, sweepStart)
+	if err != nil {
+		log.Printf("[listings] reconcile failed for provider %s: %v", out.Provider, err)
+		return
+	}
+	out.Reconciled = summary
+}
@@ -0,0 +1,77 @@
+package listings
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReconcilePolicyFromEnv verifies per-provider defaults and env overrides.
+func TestReconcilePolicyFromEnv(t *testing.T) {
+	if p := ReconcilePolicyFromEnv(ProviderMLS, "MLS_API"); p.Action != StatusOffMarket || p.Enabled {
+		t.Errorf("MLS default = %+v", p)
+	}
+	if p := ReconcilePolicyFromEnv(ProviderZillow, "ZILLOW_API"); p.Action != StatusUnknown || p.GracePeriod != 72*time.Hour {
+		t.Errorf("Zillow default = %+v", p)
+	}
+
+	t.Setenv("ZILLOW_API_RECONCILE_ENABLED", "true")
+	t.Setenv("ZILLOW_API_RECONCILE_ACTION", StatusOffMarket)
+	t.Setenv("ZILLOW_API_RECONCILE_GRACE", "24h")
+	t.Setenv("ZILLOW_API_RECONCILE_MAX_FRACTION", "0.2")
+	t.Setenv("REDFIN_API_RECONCILE_ENABLED", "false")
+	t.Setenv("REDFIN_API_RECONCILE_ACTION", "deleted")
+	p := ReconcilePolicyFromEnv(ProviderZillow, "ZILLOW_API")
+	if !p.Enabled || p.Action != StatusOffMarket || p.GracePeriod != 24*time.Hour || p.MaxMarkFraction != 0.2 {
+		t.Errorf("Zillow override = %+v", p)
+	}
+	if p := ReconcilePolicyFromEnv(ProviderRedfin, "REDFIN_API"); p.Enabled || p.Action != StatusUnknown {
+		t.Errorf("Redfin override = %+v", p)
+	}
+
+	b, _ := json.Marshal(p)
+	if !strings.Contains(string(b), `"gracePeriod":"24h0m0s"`) {
+		t.Errorf("json = %s", b)
+	}
+}
+
+// TestIsFullSweep verifies only complete, non-incremental runs reconcile.
+func TestIsFullSweep(t *testing.T) {
+	done := &IngestOutcome{Pages: 3}
+	if !isFullSweep(FetchParams{}, done) {
+		t.Error("complete run should be a full sweep")
+	}
+	if isFullSweep(FetchParams{Since: time.Now()}, done) {
+		t.Error("incremental run is not a full sweep")
+	}
+	if isFullSweep(FetchParams{PageToken: "p2"}, done) {
+		t.Error("resumed run is not a full sweep")
+	}
+	if isFullSweep(FetchParams{}, &IngestOutcome{Pages: 3, NextPage: "p4"}) {
+		t.Error("truncated run is not a full sweep")
+	}
+	if isFullSweep(FetchParams{}, &IngestOutcome{Pages: 2, Errors: []string{"page 3: boom"}}) {
+		t.Error("run with page errors is not a full sweep")
+	}
+}
+
+// TestRegionContains checks locality and bounding box matching.
+func TestRegionContains(t *testing.T) {
+	doc := map[string]any{"address": map[string]any{
+		"city": "Austin", "state": "TX", "postalCode": "78701",
+		"coordinates": map[string]any{"latitude": 30.27, "longitude": -97.74},
+	}}
+	if !regionContains(RegionFilter{}, doc) || !regionContains(RegionFilter{City: "austin", State: "tx"}, doc) {
+		t.Error("expected match")
+	}
+	if regionContains(RegionFilter{PostalCode: "78702"}, doc) {
+		t.Error("zip should not match")
+	}
+	if !regionContains(RegionFilter{BBox: &BBox{North: 31, South: 30, East: -97, West: -98}}, doc) {
+		t.Error("bbox should contain point")
+	}
+	if regionContains(RegionFilter{BBox: &BBox{North: 33, South: 32, East: -96, West: -97}}, doc) {
+		t.Error("bbox should not contain point")
+	}
+}
@@ -6,78 +6,160 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
+	"sync"
 	"time"
 )
 
 // Registry holds the configured provider instances for use by API handlers or
-// background jobs.
+// background jobs. Providers may be added with Register or RegisterConfig
+// after construction, so one provider type can run as several instances
+// under different keys (e.g. "zillow-tx" and "zillow-fl").
 type Registry struct {
+	mu        sync.RWMutex
 	providers map[ProviderKey]Provider
+	// types records the factory type each provider was built from; providers
+	// registered directly have none.
+	types     map[ProviderKey]string
+	reconcile map[ProviderKey]ReconcilePolicy
+	// push holds each provider's push signing secrets; providers without any
+	// cannot push.
+	push map[ProviderKey][]string
 }
 
-// NewRegistry constructs a Registry with stub provider implementations wired to
+func newRegistry() *Registry {
+	return &Registry{
+		providers: make(map[ProviderKey]Provider),
+		types:     make(map[ProviderKey]string),
+		reconcile: make(map[ProviderKey]ReconcilePolicy),
+		push:      make(map[ProviderKey][]string),
+	}
+}
+
+// NewRegistry constructs a Registry with the built-in providers wired to
 // environment-driven configuration. Each provider's Enabled method reflects
-// whether the minimum config is present; FetchListings will currently return
-// ErrNotConfigured until concrete HTTP integrations are added.
+// whether the minimum config is present; FetchListings returns
+// ErrNotConfigured until it is.
 func NewRegistry() *Registry {
-	r := &Registry{providers: make(map[ProviderKey]Provider)}
+	r := newRegistry()
+	for _, cfg := range builtinProviderConfigs() {
+		if err := r.RegisterConfig(cfg); err != nil {
+			// Built-in configs are static, so this is a programming error.
+			panic(err)
+		}
+	}
+	return r
+}
+
+// NewRegistryFromConfig constructs a Registry holding exactly the provider
+// instances listed in cfg.
+func NewRegistryFromConfig(cfg RegistryConfig) (*Registry, error) {
+	r := newRegistry()
+	for _, pc := range cfg.Providers {
+		if err := r.RegisterConfig(pc); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
 
-	// Generic MLS / RESO Web API provider
-	r.providers[ProviderMLS] = &MLSProvider{
-		key:          ProviderMLS,
-		name:         "MLS / RESO",
-		BaseURL:      os.Getenv("MLS_API_BASE_URL"),
-		ClientID:     os.Getenv("MLS_API_CLIENT_ID"),
-		ClientSecret: os.Getenv("MLS_API_CLIENT_SECRET"),
+// Register adds a provider built outside the factory set. Its transport,
+// reconcile and push settings are read from the environment under
+// ProviderEnvPrefix(p.Key()).
+func (r *Registry) Register(p Provider) error {
+	if p == nil {
+		return fmt.Errorf("provider is required")
 	}
+	return r.register(p, "", ProviderEnvPrefix(p.Key()))
+}
 
-	// Zillow provider (typically backed by a partner or scraper API)
-	r.providers[ProviderZillow] = &ZillowProvider{
-		key:      ProviderZillow,
-		name:     "Zillow",
-		BaseURL:  os.Getenv("ZILLOW_API_BASE_URL"),
-		APIKey:   os.Getenv("ZILLOW_API_KEY"),
-		RegionID: os.Getenv("ZILLOW_REGION_ID"),
+// RegisterConfig builds a provider instance with the factory for cfg.Type and
+// adds it under cfg.Key.
+func (r *Registry) RegisterConfig(cfg ProviderConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	factory, ok := lookupProviderFactory(cfg.Type)
+	if !ok {
+		return fmt.Errorf("provider %s: %w %q", cfg.Key, ErrUnknownProviderType, cfg.Type)
+	}
+	p, err := factory(cfg)
+	if err != nil {
+		return fmt.Errorf("provider %s: %w", cfg.Key, err)
+	}
+	if p.Key() != cfg.Key {
+		return fmt.Errorf("provider %s: factory %q returned key %q", cfg.Key, cfg.Type, p.Key())
 	}
+	return r.register(p, cfg.Type, cfg.envPrefix())
+}
 
-	// Redfin provider
-	r.providers[ProviderRedfin] = &RedfinProvider{
-		key:     ProviderRedfin,
-		name:    "Redfin",
-		BaseURL: os.Getenv("REDFIN_API_BASE_URL"),
-		APIKey:  os.Getenv("REDFIN_API_KEY"),
+func (r *Registry) register(p Provider, typ, prefix string) error {
+	key := p.Key()
+	if err := validateProviderKey(key); err != nil {
+		return err
 	}
 
-	// Realtor.com provider
-	r.providers[ProviderRealtor] = &RealtorProvider{
-		key:     ProviderRealtor,
-		name:    "Realtor.com",
-		BaseURL: os.Getenv("REALTOR_API_BASE_URL"),
-		APIKey:  os.Getenv("REALTOR_API_KEY"),
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.providers[key]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateProvider, key)
+	}
+	r.providers[key] = p
+	r.types[key] = typ
+	// Reconcile defaults follow the provider type, so every MLS instance is
+	// treated as an authoritative feed.
+	defaults := key
+	if typ != "" {
+		defaults = ProviderKey(typ)
 	}
+	r.reconcile[key] = ReconcilePolicyFromEnv(defaults, prefix)
+	if secrets := PushSecretsFromEnv(prefix); len(secrets) > 0 {
+		r.push[key] = secrets
+	}
+	return nil
+}
 
-	// Generic FSBO provider (e.g. FSBO portals, classifieds, etc.)
-	r.providers[ProviderFSBO] = &FSBOProvider{
-		key:     ProviderFSBO,
-		name:    "For Sale By Owner",
-		BaseURL: os.Getenv("FSBO_API_BASE_URL"),
-		APIKey:  os.Getenv("FSBO_API_KEY"),
+// ReconcilePolicy returns the off-market reconciliation policy for a
+// provider, falling back to the built-in default.
+func (r *Registry) ReconcilePolicy(key ProviderKey) ReconcilePolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.reconcile[key]; ok {
+		return p
 	}
+	return defaultReconcilePolicy(key)
+}
 
-	return r
+// PushSecrets returns the secrets a provider signs pushes with, or nil when
+// it is not allowed to push.
+func (r *Registry) PushSecrets(key ProviderKey) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.push[key]
+}
+
+// Type returns the factory type a provider was built from, or "" when it was
+// registered directly.
+func (r *Registry) Type(key ProviderKey) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.types[key]
 }
 
 // Get returns a provider by key if configured in the registry.
 func (r *Registry) Get(key ProviderKey) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	p, ok := r.providers[key]
 	return p, ok
 }
 
-// EnabledProviders returns all providers that report Enabled()==true.
+// EnabledProviders returns all providers that report Enabled()==true, sorted
+// by key.
 func (r *Registry) EnabledProviders() []Provider {
-	out := make([]Provider, 0, len(r.providers))
-	for _, p := range r.providers {
+	all := r.Providers()
+	out := all[:0]
+	for _, p := range all {
 		if p.Enabled() {
 			out = append(out, p)
 		}
@@ -85,13 +167,15 @@ func (r *Registry) EnabledProviders() []Provider {
 	return out
 }
 
-// Providers returns all providers currently registered. The slice is not
-// sorted and should be treated as read-only by callers.
+// Providers returns all providers currently registered, sorted by key.
 func (r *Registry) Providers() []Provider {
+	r.mu.RLock()
 	out := make([]Provider, 0, len(r.providers))
 	for _, p := range r.providers {
 		out = append(out, p)
 	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Key() < out[j].Key() })
 	return out
 }
 
@@ -102,6 +186,7 @@ func (r *Registry) Providers() []Provider {
 // upstream service is responsible for authenticating to the real MLS and
 // mapping its payloads into our normalized ExternalListing shape.
 type MLSProvider struct {
+	client       *ProviderClient
 	key          ProviderKey
 	name         string
 	BaseURL      string
@@ -109,9 +194,25 @@ type MLSProvider struct {
 	ClientSecret string
 }
 
-func (p *MLSProvider) Key() ProviderKey    { return p.key }
-func (p *MLSProvider) DisplayName() string { return p.name }
-func (p *MLSProvider) Enabled() bool       { return p.BaseURL != "" && p.ClientID != "" }
+func (p *MLSProvider) Key() ProviderKey       { return p.key }
+func (p *MLSProvider) DisplayName() string    { return p.name }
+func (p *MLSProvider) Health() ProviderHealth { return p.client.Health() }
+func (p *MLSProvider) Enabled() bool          { return p.BaseURL != "" && p.ClientID != "" }
+
+// WithCredentials returns a copy of p that authenticates with an agent's own
+// credentials. The copy gets its own rate limiter and circuit breaker, so an
+// agent's bad endpoint or credentials cannot trip the breaker for everyone.
+func (p *MLSProvider) WithCredentials(creds MLSCredentials) Provider {
+	cp := *p
+	cp.client = p.client.fork()
+	cp.ClientID = creds.ClientID
+	cp.ClientSecret = creds.ClientSecret
+	if creds.BaseURL != "" {
+		cp.BaseURL = creds.BaseURL
+	}
+	return &cp
+}
+
 func (p *MLSProvider) FetchListings(ctx context.Context, fp FetchParams) (*IngestResult, error) {
 	if p.BaseURL == "" || p.ClientID == "" {
 		return nil, ErrNotConfigured
@@ -133,6 +234,9 @@ func (p *MLSProvider) FetchListings(ctx context.Context, fp FetchParams) (*Inges
 	if fp.Region.PostalCode != "" {
 		q.Set("postalCode", fp.Region.PostalCode)
 	}
+	if b := fp.Region.BBox; b != nil {
+		q.Set("bbox", fmt.Sprintf("%g,%g,%g,%g", b.South, b.West, b.North, b.East))
+	}
 	if !fp.Since.IsZero() {
 		q.Set("since", fp.Since.Format(time.RFC3339))
 	}
@@ -156,63 +260,20 @@ func (p *MLSProvider) FetchListings(ctx context.Context, fp FetchParams) (*Inges
 		req.Header.Set("X-MLS-Client-Secret", p.ClientSecret)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("MLS HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("MLS provider returned status %d", resp.StatusCode)
-	}
-
-	var payload struct {
-		Listings      []struct {
-			ExternalID string  `json:"externalId"`
-			Address    Address `json:"address"`
-			ListPrice  float64 `json:"listPrice"`
-			Beds       float64 `json:"beds"`
-			Baths      float64 `json:"baths"`
-			Sqft       float64 `json:"sqft"`
-			Lat        float64 `json:"lat"`
-			Lng        float64 `json:"lng"`
-			Status     string  `json:"status"`
-			ListedAt   string  `json:"listedAt"`
-			UpdatedAt  string  `json:"updatedAt"`
-		} `json:"listings"`
-		NextPageToken string `json:"nextPageToken"`
+		return nil, &StatusError{Provider: "MLS", StatusCode: resp.StatusCode}
 	}
+
+	var payload listingsPage
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		return nil, fmt.Errorf("failed to decode MLS payload: %w", err)
 	}
-
-	out := &IngestResult{Provider: ProviderMLS}
-	for _, l := range payload.Listings {
-		el := ExternalListing{
-			ExternalID: l.ExternalID,
-			Source:     ProviderMLS,
-			Address:    l.Address,
-			ListPrice:  l.ListPrice,
-			Beds:       l.Beds,
-			Baths:      l.Baths,
-			Sqft:       l.Sqft,
-			Lat:        l.Lat,
-			Lng:        l.Lng,
-			Status:     l.Status,
-		}
-		if l.ListedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.ListedAt); err == nil {
-				el.ListedAt = t
-			}
-		}
-		if l.UpdatedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.UpdatedAt); err == nil {
-				el.UpdatedAt = t
-			}
-		}
-		out.Listings = append(out.Listings, el)
-	}
-	out.NextPage = payload.NextPageToken
-	return out, nil
+	return mapListingsPage(p.key, payload), nil
 }
 
 // ZillowProvider talks to a Zillow-style or portal aggregator API. The upstream
@@ -220,6 +281,7 @@ func (p *MLSProvider) FetchListings(ctx context.Context, fp FetchParams) (*Inges
 // normalized JSON payload; this connector focuses on mapping that payload into
 // ExternalListing.
 type ZillowProvider struct {
+	client   *ProviderClient
 	key      ProviderKey
 	name     string
 	BaseURL  string
@@ -227,9 +289,10 @@ type ZillowProvider struct {
 	RegionID string
 }
 
-func (p *ZillowProvider) Key() ProviderKey    { return p.key }
-func (p *ZillowProvider) DisplayName() string { return p.name }
-func (p *ZillowProvider) Enabled() bool       { return p.BaseURL != "" && p.APIKey != "" }
+func (p *ZillowProvider) Key() ProviderKey       { return p.key }
+func (p *ZillowProvider) DisplayName() string    { return p.name }
+func (p *ZillowProvider) Health() ProviderHealth { return p.client.Health() }
+func (p *ZillowProvider) Enabled() bool          { return p.BaseURL != "" && p.APIKey != "" }
 func (p *ZillowProvider) FetchListings(ctx context.Context, fp FetchParams) (*IngestResult, error) {
 	if !p.Enabled() {
 		return nil, ErrNotConfigured
@@ -251,6 +314,9 @@ func (p *ZillowProvider) FetchListings(ctx context.Context, fp FetchParams) (*In
 	if fp.Region.PostalCode != "" {
 		q.Set("postalCode", fp.Region.PostalCode)
 	}
+	if b := fp.Region.BBox; b != nil {
+		q.Set("bbox", fmt.Sprintf("%g,%g,%g,%g", b.South, b.West, b.North, b.East))
+	}
 	if !fp.Since.IsZero() {
 		q.Set("since", fp.Since.Format(time.RFC3339))
 	}
@@ -274,76 +340,35 @@ func (p *ZillowProvider) FetchListings(ctx context.Context, fp FetchParams) (*In
 	}
 	req.Header.Set("X-API-Key", p.APIKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Zillow HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Zillow provider returned status %d", resp.StatusCode)
-	}
-
-	var payload struct {
-		Listings      []struct {
-			ExternalID string  `json:"externalId"`
-			Address    Address `json:"address"`
-			ListPrice  float64 `json:"listPrice"`
-			Beds       float64 `json:"beds"`
-			Baths      float64 `json:"baths"`
-			Sqft       float64 `json:"sqft"`
-			Lat        float64 `json:"lat"`
-			Lng        float64 `json:"lng"`
-			Status     string  `json:"status"`
-			ListedAt   string  `json:"listedAt"`
-			UpdatedAt  string  `json:"updatedAt"`
-		} `json:"listings"`
-		NextPageToken string `json:"nextPageToken"`
+		return nil, &StatusError{Provider: "Zillow", StatusCode: resp.StatusCode}
 	}
+
+	var payload listingsPage
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		return nil, fmt.Errorf("failed to decode Zillow payload: %w", err)
 	}
-
-	out := &IngestResult{Provider: ProviderZillow}
-	for _, l := range payload.Listings {
-		el := ExternalListing{
-			ExternalID: l.ExternalID,
-			Source:     ProviderZillow,
-			Address:    l.Address,
-			ListPrice:  l.ListPrice,
-			Beds:       l.Beds,
-			Baths:      l.Baths,
-			Sqft:       l.Sqft,
-			Lat:        l.Lat,
-			Lng:        l.Lng,
-			Status:     l.Status,
-		}
-		if l.ListedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.ListedAt); err == nil {
-				el.ListedAt = t
-			}
-		}
-		if l.UpdatedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.UpdatedAt); err == nil {
-				el.UpdatedAt = t
-			}
-		}
-		out.Listings = append(out.Listings, el)
-	}
-	out.NextPage = payload.NextPageToken
-	return out, nil
+	return mapListingsPage(p.key, payload), nil
 }
 
 // RedfinProvider talks to a Redfin-style or portal aggregator.
 type RedfinProvider struct {
+	client  *ProviderClient
 	key     ProviderKey
 	name    string
 	BaseURL string
 	APIKey  string
 }
 
-func (p *RedfinProvider) Key() ProviderKey    { return p.key }
-func (p *RedfinProvider) DisplayName() string { return p.name }
-func (p *RedfinProvider) Enabled() bool       { return p.BaseURL != "" && p.APIKey != "" }
+func (p *RedfinProvider) Key() ProviderKey       { return p.key }
+func (p *RedfinProvider) DisplayName() string    { return p.name }
+func (p *RedfinProvider) Health() ProviderHealth { return p.client.Health() }
+func (p *RedfinProvider) Enabled() bool          { return p.BaseURL != "" && p.APIKey != "" }
 func (p *RedfinProvider) FetchListings(ctx context.Context, fp FetchParams) (*IngestResult, error) {
 	if !p.Enabled() {
 		return nil, ErrNotConfigured
@@ -365,6 +390,9 @@ func (p *RedfinProvider) FetchListings(ctx context.Context, fp FetchParams) (*In
 	if fp.Region.PostalCode != "" {
 		q.Set("postalCode", fp.Region.PostalCode)
 	}
+	if b := fp.Region.BBox; b != nil {
+		q.Set("bbox", fmt.Sprintf("%g,%g,%g,%g", b.South, b.West, b.North, b.East))
+	}
 	if !fp.Since.IsZero() {
 		q.Set("since", fp.Since.Format(time.RFC3339))
 	}
@@ -385,76 +413,35 @@ func (p *RedfinProvider) FetchListings(ctx context.Context, fp FetchParams) (*In
 	}
 	req.Header.Set("X-API-Key", p.APIKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Redfin HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Redfin provider returned status %d", resp.StatusCode)
-	}
-
-	var payload struct {
-		Listings      []struct {
-			ExternalID string  `json:"externalId"`
-			Address    Address `json:"address"`
-			ListPrice  float64 `json:"listPrice"`
-			Beds       float64 `json:"beds"`
-			Baths      float64 `json:"baths"`
-			Sqft       float64 `json:"sqft"`
-			Lat        float64 `json:"lat"`
-			Lng        float64 `json:"lng"`
-			Status     string  `json:"status"`
-			ListedAt   string  `json:"listedAt"`
-			UpdatedAt  string  `json:"updatedAt"`
-		} `json:"listings"`
-		NextPageToken string `json:"nextPageToken"`
+		return nil, &StatusError{Provider: "Redfin", StatusCode: resp.StatusCode}
 	}
+
+	var payload listingsPage
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		return nil, fmt.Errorf("failed to decode Redfin payload: %w", err)
 	}
-
-	out := &IngestResult{Provider: ProviderRedfin}
-	for _, l := range payload.Listings {
-		el := ExternalListing{
-			ExternalID: l.ExternalID,
-			Source:     ProviderRedfin,
-			Address:    l.Address,
-			ListPrice:  l.ListPrice,
-			Beds:       l.Beds,
-			Baths:      l.Baths,
-			Sqft:       l.Sqft,
-			Lat:        l.Lat,
-			Lng:        l.Lng,
-			Status:     l.Status,
-		}
-		if l.ListedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.ListedAt); err == nil {
-				el.ListedAt = t
-			}
-		}
-		if l.UpdatedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.UpdatedAt); err == nil {
-				el.UpdatedAt = t
-			}
-		}
-		out.Listings = append(out.Listings, el)
-	}
-	out.NextPage = payload.NextPageToken
-	return out, nil
+	return mapListingsPage(p.key, payload), nil
 }
 
 // RealtorProvider talks to a Realtor.com-style portal aggregator.
 type RealtorProvider struct {
+	client  *ProviderClient
 	key     ProviderKey
 	name    string
 	BaseURL string
 	APIKey  string
 }
 
-func (p *RealtorProvider) Key() ProviderKey    { return p.key }
-func (p *RealtorProvider) DisplayName() string { return p.name }
-func (p *RealtorProvider) Enabled() bool       { return p.BaseURL != "" && p.APIKey != "" }
+func (p *RealtorProvider) Key() ProviderKey       { return p.key }
+func (p *RealtorProvider) DisplayName() string    { return p.name }
+func (p *RealtorProvider) Health() ProviderHealth { return p.client.Health() }
+func (p *RealtorProvider) Enabled() bool          { return p.BaseURL != "" && p.APIKey != "" }
 func (p *RealtorProvider) FetchListings(ctx context.Context, fp FetchParams) (*IngestResult, error) {
 	if !p.Enabled() {
 		return nil, ErrNotConfigured
@@ -476,6 +463,9 @@ func (p *RealtorProvider) FetchListings(ctx context.Context, fp FetchParams) (*I
 	if fp.Region.PostalCode != "" {
 		q.Set("postalCode", fp.Region.PostalCode)
 	}
+	if b := fp.Region.BBox; b != nil {
+		q.Set("bbox", fmt.Sprintf("%g,%g,%g,%g", b.South, b.West, b.North, b.East))
+	}
 	if !fp.Since.IsZero() {
 		q.Set("since", fp.Since.Format(time.RFC3339))
 	}
@@ -496,77 +486,36 @@ func (p *RealtorProvider) FetchListings(ctx context.Context, fp FetchParams) (*I
 	}
 	req.Header.Set("X-API-Key", p.APIKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Realtor HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Realtor provider returned status %d", resp.StatusCode)
-	}
-
-	var payload struct {
-		Listings      []struct {
-			ExternalID string  `json:"externalId"`
-			Address    Address `json:"address"`
-			ListPrice  float64 `json:"listPrice"`
-			Beds       float64 `json:"beds"`
-			Baths      float64 `json:"baths"`
-			Sqft       float64 `json:"sqft"`
-			Lat        float64 `json:"lat"`
-			Lng        float64 `json:"lng"`
-			Status     string  `json:"status"`
-			ListedAt   string  `json:"listedAt"`
-			UpdatedAt  string  `json:"updatedAt"`
-		} `json:"listings"`
-		NextPageToken string `json:"nextPageToken"`
+		return nil, &StatusError{Provider: "Realtor", StatusCode: resp.StatusCode}
 	}
+
+	var payload listingsPage
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		return nil, fmt.Errorf("failed to decode Realtor payload: %w", err)
 	}
-
-	out := &IngestResult{Provider: ProviderRealtor}
-	for _, l := range payload.Listings {
-		el := ExternalListing{
-			ExternalID: l.ExternalID,
-			Source:     ProviderRealtor,
-			Address:    l.Address,
-			ListPrice:  l.ListPrice,
-			Beds:       l.Beds,
-			Baths:      l.Baths,
-			Sqft:       l.Sqft,
-			Lat:        l.Lat,
-			Lng:        l.Lng,
-			Status:     l.Status,
-		}
-		if l.ListedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.ListedAt); err == nil {
-				el.ListedAt = t
-			}
-		}
-		if l.UpdatedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.UpdatedAt); err == nil {
-				el.UpdatedAt = t
-			}
-		}
-		out.Listings = append(out.Listings, el)
-	}
-	out.NextPage = payload.NextPageToken
-	return out, nil
+	return mapListingsPage(p.key, payload), nil
 }
 
 // FSBOProvider talks to an FSBO/owner-direct aggregator (classifieds, FSBO
 // portals, etc.).
 type FSBOProvider struct {
+	client  *ProviderClient
 	key     ProviderKey
 	name    string
 	BaseURL string
 	APIKey  string
 }
 
-func (p *FSBOProvider) Key() ProviderKey    { return p.key }
-func (p *FSBOProvider) DisplayName() string { return p.name }
-func (p *FSBOProvider) Enabled() bool       { return p.BaseURL != "" && p.APIKey != "" }
+func (p *FSBOProvider) Key() ProviderKey       { return p.key }
+func (p *FSBOProvider) DisplayName() string    { return p.name }
+func (p *FSBOProvider) Health() ProviderHealth { return p.client.Health() }
+func (p *FSBOProvider) Enabled() bool          { return p.BaseURL != "" && p.APIKey != "" }
 func (p *FSBOProvider) FetchListings(ctx context.Context, fp FetchParams) (*IngestResult, error) {
 	if !p.Enabled() {
 		return nil, ErrNotConfigured
@@ -588,6 +537,9 @@ func (p *FSBOProvider) FetchListings(ctx context.Context, fp FetchParams) (*Inge
 	if fp.Region.PostalCode != "" {
 		q.Set("postalCode", fp.Region.PostalCode)
 	}
+	if b := fp.Region.BBox; b != nil {
+		q.Set("bbox", fmt.Sprintf("%g,%g,%g,%g", b.South, b.West, b.North, b.East))
+	}
 	if !fp.Since.IsZero() {
 		q.Set("since", fp.Since.Format(time.RFC3339))
 	}
@@ -608,61 +560,18 @@ func (p *FSBOProvider) FetchListings(ctx context.Context, fp FetchParams) (*Inge
 	}
 	req.Header.Set("X-API-Key", p.APIKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("FSBO HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("FSBO provider returned status %d", resp.StatusCode)
-	}
-
-	var payload struct {
-		Listings      []struct {
-			ExternalID string  `json:"externalId"`
-			Address    Address `json:"address"`
-			ListPrice  float64 `json:"listPrice"`
-			Beds       float64 `json:"beds"`
-			Baths      float64 `json:"baths"`
-			Sqft       float64 `json:"sqft"`
-			Lat        float64 `json:"lat"`
-			Lng        float64 `json:"lng"`
-			Status     string  `json:"status"`
-			ListedAt   string  `json:"listedAt"`
-			UpdatedAt  string  `json:"updatedAt"`
-		} `json:"listings"`
-		NextPageToken string `json:"nextPageToken"`
+		return nil, &StatusError{Provider: "FSBO", StatusCode: resp.StatusCode}
 	}
+
+	var payload listingsPage
 	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 		return nil, fmt.Errorf("failed to decode FSBO payload: %w", err)
 	}
-
-	out := &IngestResult{Provider: ProviderFSBO}
-	for _, l := range payload.Listings {
-		el := ExternalListing{
-			ExternalID: l.ExternalID,
-			Source:     ProviderFSBO,
-			Address:    l.Address,
-			ListPrice:  l.ListPrice,
-			Beds:       l.Beds,
-			Baths:      l.Baths,
-			Sqft:       l.Sqft,
-			Lat:        l.Lat,
-			Lng:        l.Lng,
-			Status:     l.Status,
-		}
-		if l.ListedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.ListedAt); err == nil {
-				el.ListedAt = t
-			}
-		}
-		if l.UpdatedAt != "" {
-			if t, err := time.Parse(time.RFC3339, l.UpdatedAt); err == nil {
-				el.UpdatedAt = t
-			}
-		}
-		out.Listings = append(out.Listings, el)
-	}
-	out.NextPage = payload.NextPageToken
-	return out, nil
+	return mapListingsPage(p.key, payload), nil
 }
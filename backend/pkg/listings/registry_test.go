@@ -0,0 +1,186 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type stubProvider struct{ key ProviderKey }
+
+func (p stubProvider) Key() ProviderKey    { return p.key }
+func (p stubProvider) DisplayName() string { return "Stub" }
+func (p stubProvider) Enabled() bool       { return true }
+func (p stubProvider) FetchListings(context.Context, FetchParams) (*IngestResult, error) {
+	return &IngestResult{Provider: p.key}, nil
+}
+
+func providerKeys(ps []Provider) []ProviderKey {
+	out := make([]ProviderKey, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, p.Key())
+	}
+	return out
+}
+
+// TestNewRegistryBuiltins verifies the env-driven registry still exposes the
+// five built-in providers under their historical keys and env vars.
+func TestNewRegistryBuiltins(t *testing.T) {
+	t.Setenv("ZILLOW_API_BASE_URL", "https://zillow.example")
+	t.Setenv("ZILLOW_API_KEY", "k")
+	t.Setenv("ZILLOW_API_PUSH_SECRET", "s")
+
+	r := NewRegistry()
+	want := []ProviderKey{ProviderFSBO, ProviderMLS, ProviderRealtor, ProviderRedfin, ProviderZillow}
+	if got := providerKeys(r.Providers()); !reflect.DeepEqual(got, want) {
+		t.Errorf("providers = %v, want %v", got, want)
+	}
+	if got := providerKeys(r.EnabledProviders()); !reflect.DeepEqual(got, []ProviderKey{ProviderZillow}) {
+		t.Errorf("enabled = %v", got)
+	}
+	if r.Type(ProviderZillow) != "zillow" || len(r.PushSecrets(ProviderZillow)) != 1 {
+		t.Errorf("zillow type %q, push %v", r.Type(ProviderZillow), r.PushSecrets(ProviderZillow))
+	}
+	if r.ReconcilePolicy(ProviderMLS).Action != StatusOffMarket {
+		t.Error("MLS reconcile default lost")
+	}
+}
+
+// TestRegistryFromConfig verifies two instances of one type get their own
+// keys, settings, env prefixes and type-based defaults.
+func TestRegistryFromConfig(t *testing.T) {
+	t.Setenv("ZILLOW_TX_KEY", "tx-secret")
+	t.Setenv("ZILLOW_FL_API_PUSH_SECRET", "fl-push")
+	t.Setenv("BOARD_CA_RECONCILE_ENABLED", "false")
+
+	yamlConfig := `
+providers:
+  - key: zillow-tx
+    type: zillow
+    name: Zillow (Texas)
+    settings:
+      baseUrl: https://zillow.example
+      apiKey: ${ZILLOW_TX_KEY}
+      regionId: TX
+  - key: zillow-fl
+    type: zillow
+    settings:
+      baseUrl: https://zillow.example
+  - key: mls-ca
+    type: mls
+    envPrefix: BOARD_CA
+`
+	cfg, err := ParseRegistryConfig([]byte(yamlConfig), ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRegistryFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := providerKeys(r.Providers()); !reflect.DeepEqual(got, []ProviderKey{"mls-ca", "zillow-fl", "zillow-tx"}) {
+		t.Errorf("providers = %v", got)
+	}
+	p, _ := r.Get("zillow-tx")
+	tx := p.(*ZillowProvider)
+	if tx.APIKey != "tx-secret" || tx.RegionID != "TX" || tx.DisplayName() != "Zillow (Texas)" || !tx.Enabled() {
+		t.Errorf("zillow-tx = %+v", tx)
+	}
+	if p, _ := r.Get("zillow-fl"); p.Enabled() || p.DisplayName() != "Zillow" {
+		t.Errorf("zillow-fl enabled without key or wrong name %q", p.DisplayName())
+	}
+	if r.PushSecrets("zillow-fl") == nil || r.PushSecrets("zillow-tx") != nil {
+		t.Error("push secrets not read per instance")
+	}
+	if pol := r.ReconcilePolicy("mls-ca"); pol.Enabled || pol.Action != StatusOffMarket {
+		t.Errorf("mls-ca reconcile = %+v", pol)
+	}
+	if _, ok := r.Get(ProviderZillow); ok {
+		t.Error("config registry should not include unlisted built-ins")
+	}
+}
+
+// TestRegistryRegisterErrors verifies keys must be valid and unique and types
+// must have a factory.
+func TestRegistryRegisterErrors(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(stubProvider{key: "custom"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := r.Get("custom"); !ok || r.Type("custom") != "" || !p.Enabled() {
+		t.Error("custom provider not registered")
+	}
+	if err := r.Register(stubProvider{key: ProviderZillow}); !errors.Is(err, ErrDuplicateProvider) {
+		t.Errorf("duplicate err = %v", err)
+	}
+	if err := r.RegisterConfig(ProviderConfig{Key: "x", Type: "craigslist"}); !errors.Is(err, ErrUnknownProviderType) {
+		t.Errorf("unknown type err = %v", err)
+	}
+	for _, key := range []ProviderKey{"Zillow", "a/b", "-x", ProviderFile} {
+		if err := r.Register(stubProvider{key: key}); err == nil {
+			t.Errorf("key %q accepted", key)
+		}
+	}
+
+	RegisterProviderFactory("stub-test", func(cfg ProviderConfig) (Provider, error) {
+		return stubProvider{key: cfg.Key}, nil
+	})
+	if err := r.RegisterConfig(ProviderConfig{Key: "stub-1", Type: "stub-test"}); err != nil {
+		t.Errorf("custom factory: %v", err)
+	}
+	if r.Type("stub-1") != "stub-test" {
+		t.Errorf("type = %q", r.Type("stub-1"))
+	}
+}
+
+// TestLoadRegistryConfig verifies JSON files load and malformed files fail.
+func TestLoadRegistryConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := LoadRegistryConfig(write("providers.json", `{"providers":[{"key":"redfin-west","type":"redfin","settings":{"baseUrl":"https://r.example"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Providers) != 1 || cfg.Providers[0].Key != "redfin-west" || cfg.Providers[0].Settings[SettingBaseURL] != "https://r.example" {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	bad := map[string]string{
+		"typo.json":  `{"providers":[{"key":"a","type":"mls","setings":{}}]}`,
+		"typo.yml":   "providers:\n  - key: a\n    kind: mls\n",
+		"empty.json": `{"providers":[]}`,
+	}
+	for name, body := range bad {
+		if _, err := LoadRegistryConfig(write(name, body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := LoadRegistryConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+// TestProviderEnvPrefix verifies built-in keys keep their historical prefix.
+func TestProviderEnvPrefix(t *testing.T) {
+	cases := map[ProviderKey]string{
+		ProviderMLS:    "MLS_API",
+		ProviderZillow: "ZILLOW_API",
+		"zillow-tx":    "ZILLOW_TX_API",
+	}
+	for key, want := range cases {
+		if got := ProviderEnvPrefix(key); got != want {
+			t.Errorf("%s: prefix = %q, want %q", key, got, want)
+		}
+	}
+}
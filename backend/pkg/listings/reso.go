@@ -0,0 +1,197 @@
+package listings
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Raw payload formats recorded on ExternalListing.RawFormat so archived
+// records can be re-mapped with the mapper that produced them.
+const (
+	// RawFormatProvider is the normalized envelope returned by our HTTP
+	// providers and handled by MapRawListing.
+	RawFormatProvider = ""
+	// RawFormatRESO is a RESO Data Dictionary record handled by MapRESORecord.
+	RawFormatRESO = "reso"
+)
+
+// resoMappedKeys are the RESO Data Dictionary fields consumed by
+// MapRESORecord. Everything else is surfaced on ProviderMeta.
+var resoMappedKeys = map[string]bool{
+	"ListingKey": true, "ListingId": true, "UnparsedAddress": true,
+	"StreetNumber": true, "StreetDirPrefix": true, "StreetName": true,
+	"StreetSuffix": true, "StreetDirSuffix": true, "UnitNumber": true,
+	"City": true, "StateOrProvince": true, "PostalCode": true, "Country": true,
+	"ListPrice": true, "BedroomsTotal": true, "BathroomsTotalInteger": true,
+	"BathroomsTotalDecimal": true, "LivingArea": true, "Latitude": true,
+	"Longitude": true, "StandardStatus": true, "MlsStatus": true,
+	"OnMarketDate": true, "ListingContractDate": true,
+	"ModificationTimestamp": true, "PublicRemarks": true, "YearBuilt": true,
+	"LotSizeSquareFeet": true, "LotSizeAcres": true, "AssociationFee": true,
+	"Media": true, "PhotoURLs": true, "Stories": true, "StoriesTotal": true,
+	"ParkingTotal": true, "GarageSpaces": true, "PropertySubType": true,
+	"ListAgentKey": true, "ListAgentMlsId": true, "ListOfficeKey": true,
+	"ListOfficeMlsId": true,
+}
+
+// MapRESORecord maps a RESO Data Dictionary record (as found in RESO Web API
+// and file exports) into ExternalListing. Numeric and date fields may be JSON
+// numbers or strings, since file imports carry everything as text. Values that
+// are present but malformed are reported as errors so imports can surface
+// them per row.
+func MapRESORecord(provider ProviderKey, raw json.RawMessage) (ExternalListing, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return ExternalListing{}, fmt.Errorf("failed to decode RESO record: %w", err)
+	}
+
+	var problems []string
+	num := func(keys ...string) float64 {
+		for _, k := range keys {
+			switch v := fields[k].(type) {
+			case float64:
+				return v
+			case string:
+				s := strings.TrimSpace(strings.NewReplacer(",", "", "$", "").Replace(v))
+				if s == "" {
+					continue
+				}
+				f, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: invalid number %q", k, v))
+					continue
+				}
+				return f
+			}
+		}
+		return 0
+	}
+	date := func(keys ...string) time.Time {
+		for _, k := range keys {
+			s := firstString(fields, k)
+			if s == "" {
+				continue
+			}
+			t, err := parseFlexibleTime(s)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid date %q", k, s))
+				continue
+			}
+			return t
+		}
+		return time.Time{}
+	}
+
+	el := ExternalListing{
+		ExternalID: firstString(fields, "ListingId", "ListingKey"),
+		Source:     provider,
+		Address: Address{
+			Street1: firstString(fields, "UnparsedAddress"),
+			City:    firstString(fields, "City"),
+			State:   firstString(fields, "StateOrProvince"),
+			Postal:  firstString(fields, "PostalCode"),
+			Country: firstString(fields, "Country"),
+		},
+		ListPrice:   num("ListPrice"),
+		Beds:        num("BedroomsTotal"),
+		Baths:       num("BathroomsTotalDecimal", "BathroomsTotalInteger"),
+		Sqft:        num("LivingArea"),
+		Lat:         num("Latitude"),
+		Lng:         num("Longitude"),
+		Status:      firstString(fields, "StandardStatus", "MlsStatus"),
+		ListedAt:    date("OnMarketDate", "ListingContractDate"),
+		UpdatedAt:   date("ModificationTimestamp"),
+		Remarks:     firstString(fields, "PublicRemarks"),
+		YearBuilt:   int(num("YearBuilt")),
+		LotSizeSqft: num("LotSizeSquareFeet"),
+		HOAFee:      num("AssociationFee"),
+		RawJSON:     append([]byte(nil), raw...),
+		RawFormat:   RawFormatRESO,
+
+		Stories:         int(num("Stories", "StoriesTotal")),
+		ParkingTotal:    int(num("ParkingTotal")),
+		GarageSpaces:    int(num("GarageSpaces")),
+		PropertySubType: firstString(fields, "PropertySubType"),
+		ListAgentKey:    firstString(fields, "ListAgentKey", "ListAgentMlsId"),
+		ListOfficeKey:   firstString(fields, "ListOfficeKey", "ListOfficeMlsId"),
+	}
+	if el.ExternalID == "" {
+		// JSON exports sometimes carry numeric listing ids.
+		if id, ok := fields["ListingId"].(float64); ok {
+			el.ExternalID = strconv.FormatFloat(id, 'f', -1, 64)
+		}
+	}
+	if el.Address.Street1 == "" {
+		var parts []string
+		for _, k := range []string{"StreetNumber", "StreetDirPrefix", "StreetName", "StreetSuffix", "StreetDirSuffix"} {
+			if s := firstString(fields, k); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		el.Address.Street1 = strings.Join(parts, " ")
+	}
+	if unit := firstString(fields, "UnitNumber"); unit != "" {
+		el.Address.Street2 = "Unit " + unit
+	}
+	el.Address.Lat, el.Address.Lng = el.Lat, el.Lng
+	if el.LotSizeSqft == 0 {
+		if acres := num("LotSizeAcres"); acres > 0 {
+			el.LotSizeSqft = acres * 43560
+		}
+	}
+
+	el.Photos = photoURLs(fields["Media"])
+	if len(el.Photos) == 0 {
+		// Flat files carry photos as a single delimited column.
+		if s := firstString(fields, "PhotoURLs"); s != "" {
+			for _, u := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+				el.Photos = append(el.Photos, u)
+			}
+		}
+	}
+
+	meta := map[string]any{}
+	for k, v := range fields {
+		if !resoMappedKeys[k] {
+			meta[k] = v
+		}
+	}
+	if len(meta) > 0 {
+		el.ProviderMeta = meta
+	}
+
+	if el.ExternalID == "" {
+		problems = append([]string{"ListingId: required"}, problems...)
+	}
+	if len(problems) > 0 {
+		return el, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return el, nil
+}
+
+// parseFlexibleTime accepts the timestamp layouts seen in RESO exports and
+// hand-built CSV files.
+func parseFlexibleTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "01/02/2006", "1/2/2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+// mapArchivedPayload maps an archived raw payload with the mapper matching
+// the format it was captured in.
+func mapArchivedPayload(provider ProviderKey, format string, raw []byte) (ExternalListing, error) {
+	switch format {
+	case RawFormatRESO:
+		return MapRESORecord(provider, raw)
+	case RawFormatProvider:
+		return MapRawListing(provider, raw)
+	default:
+		return ExternalListing{}, fmt.Errorf("unknown raw payload format %q", format)
+	}
+}
@@ -0,0 +1,352 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrUnknownProvider is returned by RunIngest when the requested provider key
+// is not present in the registry.
+var ErrUnknownProvider = errors.New("unknown listings provider")
+
+// ErrIngestPersist wraps failures writing fetched listings into Firestore so
+// callers can tell them apart from upstream provider errors.
+var ErrIngestPersist = errors.New("failed to persist listings")
+
+// ErrConnectionNotFound is returned when an agent-scoped run names an MLS
+// connection the agent does not have.
+var ErrConnectionNotFound = errors.New("MLS connection not found")
+
+// IngestRequest describes a single ingest run, whether it was triggered over
+// HTTP or by the built-in scheduler.
+type IngestRequest struct {
+	Provider    ProviderKey  `json:"provider"`
+	Since       time.Time    `json:"since,omitempty"`
+	Region      RegionFilter `json:"region"`
+	Limit       int          `json:"limit,omitempty"`
+	MaxPages    int          `json:"maxPages,omitempty"`
+	IncludeSold bool         `json:"includeSold,omitempty"`
+	AgentUID    string       `json:"agentUid,omitempty"`
+	PageToken   string       `json:"pageToken,omitempty"`
+	// ConnectionID limits an agent-scoped MLS run to one of the agent's
+	// boards. Without it the run fans out across every enabled board.
+	ConnectionID string `json:"connectionId,omitempty"`
+	// DryRun fetches and maps listings and previews the upsert without
+	// writing properties, ledger entries or sync heartbeats.
+	DryRun bool `json:"dryRun,omitempty"`
+	// RunID presets the ledger entry id of a run started by RunTracker.
+	// Runs fanned out across several boards get their own ids.
+	RunID string `json:"-"`
+
+	// Trigger, TriggeredBy and ScheduleID are recorded on the run ledger.
+	Trigger     string `json:"trigger,omitempty"`
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+	ScheduleID  string `json:"scheduleId,omitempty"`
+}
+
+// IngestOutcome is the aggregate result of an ingest run across all pages.
+type IngestOutcome struct {
+	Provider ProviderKey    `json:"provider"`
+	Fetched  int            `json:"fetched"`
+	Pages    int            `json:"pages"`
+	NextPage string         `json:"nextPage,omitempty"`
+	Summary  *UpsertSummary `json:"summary,omitempty"`
+	RunID    string         `json:"runId,omitempty"`
+	Errors   []string       `json:"errors,omitempty"`
+	// Alerts counts saved search matches raised by the run.
+	Alerts int `json:"alerts,omitempty"`
+	// Underwritten counts listings automatically underwritten after the run.
+	Underwritten int `json:"underwritten,omitempty"`
+	// Reconciled is set when a full sweep marked missing listings.
+	Reconciled *ReconcileSummary `json:"reconciled,omitempty"`
+	// AgentCredentials is true when the run authenticated with the agent's
+	// own MLS credentials.
+	AgentCredentials bool `json:"agentCredentials,omitempty"`
+	// ConnectionID is the agent MLS board the run pulled from. Runs fanned
+	// out across several boards report each board's outcome in Connections
+	// and their totals at the top level.
+	ConnectionID string           `json:"connectionId,omitempty"`
+	Connections  []*IngestOutcome `json:"connections,omitempty"`
+	// DryRun is set when nothing was written; Summary.Previews describes
+	// what would have been.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RunIngest fetches listings from a provider and upserts them into the
+// properties collection. Up to req.MaxPages pages are fetched (one when
+// unset). Agent-scoped MLS runs pull from each of the agent's enabled boards
+// in turn, using each board's default region when none was given and bumping
+// its lastSyncedAt heartbeat. Every run is recorded in the ingest_runs
+// ledger, one entry per board. A run that sweeps its whole region also
+// reconciles listings the provider no longer returns.
+//
+// Progress is reported to the listener RunTracker puts on ctx. When ctx is
+// cancelled the run stops between pages or listings and returns its partial
+// outcome along with ErrRunCancelled.
+func RunIngest(ctx context.Context, projectID string, reg *Registry, req IngestRequest) (*IngestOutcome, error) {
+	if _, ok := reg.Get(req.Provider); !ok {
+		return nil, ErrUnknownProvider
+	}
+	if req.AgentUID == "" || req.Provider != ProviderMLS {
+		return runIngest(ctx, projectID, reg, req, nil)
+	}
+
+	if req.ConnectionID != "" {
+		conn, err := GetAgentMLSConnectionByID(ctx, projectID, req.AgentUID, req.ConnectionID)
+		if err != nil {
+			return nil, err
+		}
+		if conn == nil {
+			return nil, ErrConnectionNotFound
+		}
+		return runIngest(ctx, projectID, reg, req, conn)
+	}
+
+	conns, err := enabledAgentMLSConnections(ctx, projectID, req.AgentUID)
+	if err != nil {
+		// Connections only supply defaults; the agent can still ingest
+		// with an explicit region.
+		log.Printf("[listings] failed to load MLS connections for agent %s: %v", req.AgentUID, err)
+		conns = nil
+	}
+	switch len(conns) {
+	case 0:
+		return runIngest(ctx, projectID, reg, req, nil)
+	case 1:
+		req.ConnectionID = conns[0].ID
+		return runIngest(ctx, projectID, reg, req, conns[0])
+	}
+	if req.PageToken != "" {
+		return nil, ErrConnectionRequired
+	}
+	return ingestBoards(req, conns, func(boardReq IngestRequest, conn *AgentMLSConnection) (*IngestOutcome, error) {
+		return runIngest(ctx, projectID, reg, boardReq, conn)
+	})
+}
+
+// ingestBoards runs req once per board connection through run and folds the
+// outcomes together. Each board's request names its connection, so it is
+// authenticated with that board's credentials.
+func ingestBoards(req IngestRequest, conns []*AgentMLSConnection, run func(IngestRequest, *AgentMLSConnection) (*IngestOutcome, error)) (*IngestOutcome, error) {
+	out := &IngestOutcome{Provider: req.Provider, Summary: &UpsertSummary{Provider: req.Provider}}
+	var firstErr error
+	for _, conn := range conns {
+		boardReq := req
+		boardReq.ConnectionID = conn.ID
+		boardReq.RunID = ""
+		res, err := run(boardReq, conn)
+		if errors.Is(err, ErrRunCancelled) {
+			out.merge(res, conn.Board)
+			return out, err
+		}
+		if err != nil {
+			// One board being down should not starve the agent's others.
+			log.Printf("[listings] ingest for agent %s board %s failed: %v", req.AgentUID, conn.ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			out.Errors = append(out.Errors, fmt.Sprintf("%s: %v", conn.Board, err))
+			continue
+		}
+		out.merge(res, conn.Board)
+	}
+	if len(out.Connections) == 0 {
+		return nil, firstErr
+	}
+	return out, nil
+}
+
+// runIngest performs a single ingest run, against conn's board when set.
+func runIngest(ctx context.Context, projectID string, reg *Registry, req IngestRequest, conn *AgentMLSConnection) (*IngestOutcome, error) {
+	prov, ok := reg.Get(req.Provider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	// Agent-scoped runs authenticate as the agent when they stored their own
+	// MLS credentials for the board.
+	prov, asAgent, err := agentProvider(ctx, projectID, prov, req.AgentUID, req.ConnectionID)
+	if err != nil {
+		return nil, err
+	}
+	if !prov.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	params := FetchParams{
+		Since:       req.Since,
+		Region:      req.Region,
+		Limit:       req.Limit,
+		MaxPages:    req.MaxPages,
+		IncludeSold: req.IncludeSold,
+		PageToken:   req.PageToken,
+	}
+	// If no explicit region was provided, fall back to the board's defaults.
+	if params.Region == (RegionFilter{}) && conn != nil {
+		params.Region = conn.Region()
+	}
+
+	maxPages := req.MaxPages
+	if maxPages <= 0 {
+		maxPages = 1
+	}
+
+	if req.DryRun {
+		emitProgress(ctx, RunEvent{Type: RunEventStarted, Provider: req.Provider, ConnectionID: req.ConnectionID})
+		out, err := ingestPages(ctx, projectID, prov, params, maxPages, true)
+		if errors.Is(err, ErrRunCancelled) {
+			out.ConnectionID = req.ConnectionID
+			out.DryRun = true
+			return out, err
+		}
+		if err != nil {
+			return nil, err
+		}
+		out.AgentCredentials = asAgent
+		out.ConnectionID = req.ConnectionID
+		out.DryRun = true
+		return out, nil
+	}
+
+	run := startIngestRun(ctx, projectID, req, params)
+	started := RunEvent{Type: RunEventStarted, Provider: req.Provider, ConnectionID: req.ConnectionID}
+	if run != nil {
+		started.RunID = run.ID
+	}
+	emitProgress(ctx, started)
+	sweepStart := time.Now()
+	out, err := ingestPages(ctx, projectID, prov, params, maxPages, false)
+	if run != nil {
+		out.RunID = run.ID
+	}
+	out.AgentCredentials = asAgent
+	out.ConnectionID = req.ConnectionID
+	// The ledger and hooks must still be written after a cancel.
+	finishIngestRun(context.WithoutCancel(ctx), projectID, run, out, err)
+	if errors.Is(err, ErrRunCancelled) {
+		// A cancelled run is not a full sweep, but the listings it wrote
+		// are still underwritten and matched against saved searches.
+		bg := context.WithoutCancel(ctx)
+		underwriteForRun(bg, projectID, out)
+		notifyForRun(bg, projectID, out)
+		return out, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A board run only sees that board's listings, and agent credentials
+	// only the agent's view of it, so neither can tell what left the market.
+	if conn == nil && !asAgent {
+		reconcileForRun(ctx, projectID, reg, params, out, sweepStart)
+	}
+	underwriteForRun(ctx, projectID, out)
+	notifyForRun(ctx, projectID, out)
+
+	// Record a lightweight sync heartbeat on the board so admin tooling can
+	// display "last MLS sync" per agent and board.
+	if conn != nil {
+		if err := TouchAgentMLSLastSynced(ctx, projectID, conn.ID, time.Now()); err != nil {
+			log.Printf("[listings] failed to touch MLS lastSyncedAt for connection %s: %v", conn.ID, err)
+		}
+	}
+
+	return out, nil
+}
+
+// merge folds one board's outcome into a fanned-out outcome.
+func (o *IngestOutcome) merge(res *IngestOutcome, board string) {
+	o.Fetched += res.Fetched
+	o.Pages += res.Pages
+	o.Summary.add(res.Summary)
+	for _, e := range res.Errors {
+		o.Errors = append(o.Errors, board+": "+e)
+	}
+	o.Alerts += res.Alerts
+	o.Underwritten += res.Underwritten
+	o.AgentCredentials = o.AgentCredentials || res.AgentCredentials
+	o.DryRun = o.DryRun || res.DryRun
+	o.Connections = append(o.Connections, res)
+}
+
+// ingestPages fetches and persists up to maxPages pages, or only previews the
+// upsert when dryRun is set. The returned outcome is never nil so partial
+// progress can be recorded even when err is set.
+func ingestPages(ctx context.Context, projectID string, prov Provider, params FetchParams, maxPages int, dryRun bool) (*IngestOutcome, error) {
+	out := &IngestOutcome{Provider: prov.Key(), Summary: &UpsertSummary{Provider: prov.Key()}}
+	for out.Pages < maxPages {
+		if ctx.Err() != nil {
+			return out, cancelled(ctx, out)
+		}
+		res, err := prov.FetchListings(ctx, params)
+		if err != nil {
+			if ctx.Err() != nil {
+				return out, cancelled(ctx, out)
+			}
+			if out.Pages == 0 {
+				return out, err
+			}
+			// Keep what earlier pages already persisted; the caller can resume
+			// from NextPage.
+			log.Printf("[listings] ingest for provider %s stopped after %d pages: %v", prov.Key(), out.Pages, err)
+			out.Errors = append(out.Errors, fmt.Sprintf("page %d: %v", out.Pages+1, err))
+			emitProgress(ctx, RunEvent{Type: RunEventError, Provider: prov.Key(), Page: out.Pages + 1, Error: err.Error()})
+			break
+		}
+		out.Pages++
+		out.Fetched += len(res.Listings)
+		out.NextPage = res.NextPage
+		emitProgress(ctx, RunEvent{Type: RunEventPage, Provider: prov.Key(), Page: out.Pages, Fetched: len(res.Listings)})
+
+		// Normalize into Firestore properties collection. This helper is
+		// intentionally conservative and uses Merge semantics so existing
+		// documents created by legacy flows are not clobbered.
+		upsert := UpsertExternalListingsToFirestore
+		if dryRun {
+			upsert = PreviewExternalListings
+		}
+		summary, err := upsert(ctx, projectID, res)
+		if err != nil {
+			if ctx.Err() != nil {
+				out.Summary.add(summary)
+				return out, cancelled(ctx, out)
+			}
+			emitProgress(ctx, RunEvent{Type: RunEventError, Provider: prov.Key(), Page: out.Pages, Error: err.Error()})
+			return out, fmt.Errorf("%w: %v", ErrIngestPersist, err)
+		}
+		out.Summary.add(summary)
+		emitProgress(ctx, RunEvent{Type: RunEventUpserted, Provider: prov.Key(), Page: out.Pages, Summary: summary})
+
+		if res.NextPage == "" {
+			break
+		}
+		params.PageToken = res.NextPage
+	}
+	return out, nil
+}
+
+// cancelled logs a run stopped by its context and returns ErrRunCancelled.
+func cancelled(ctx context.Context, out *IngestOutcome) error {
+	log.Printf("[listings] ingest for provider %s cancelled after %d pages", out.Provider, out.Pages)
+	return fmt.Errorf("%w: %v", ErrRunCancelled, ctx.Err())
+}
+
+// add folds another page's summary into s.
+func (s *UpsertSummary) add(o *UpsertSummary) {
+	if o == nil {
+		return
+	}
+	s.Attempted += o.Attempted
+	s.Created += o.Created
+	s.Updated += o.Updated
+	s.Skipped += o.Skipped
+	s.HistoryEvents += o.HistoryEvents
+	s.Quarantined += o.Quarantined
+	s.changes = append(s.changes, o.changes...)
+	s.propertyIDs = append(s.propertyIDs, o.propertyIDs...)
+	for _, p := range o.Previews {
+		s.preview(upsertOptions{dryRun: true}, p)
+	}
+	s.PreviewsTruncated = s.PreviewsTruncated || o.PreviewsTruncated
+}
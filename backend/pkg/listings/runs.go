@@ -0,0 +1,239 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+)
+
+// Ingest trigger sources recorded on IngestRun.Trigger.
+const (
+	TriggerAPI       = "api"
+	TriggerScheduler = "scheduler"
+)
+
+// Ingest run statuses.
+const (
+	RunStatusRunning   = "running"
+	RunStatusSucceeded = "succeeded"
+	// RunStatusPartial means at least one page was persisted before a later
+	// page failed; NextPageToken can be used to resume.
+	RunStatusPartial = "partial"
+	RunStatusFailed  = "failed"
+	// RunStatusCancelled means the run was stopped on request; pages
+	// persisted before the cancel are kept.
+	RunStatusCancelled = "cancelled"
+)
+
+const ingestRunsCollection = "ingest_runs"
+
+// IngestRun is the ledger entry written to ingest_runs for every ingest run so
+// admins can audit feed health over time.
+type IngestRun struct {
+	ID           string      `firestore:"-" json:"id"`
+	Provider     ProviderKey `firestore:"provider" json:"provider"`
+	Trigger      string      `firestore:"trigger" json:"trigger"`
+	TriggeredBy  string      `firestore:"triggeredBy,omitempty" json:"triggeredBy,omitempty"`
+	ScheduleID   string      `firestore:"scheduleId,omitempty" json:"scheduleId,omitempty"`
+	AgentUID     string      `firestore:"agentUid,omitempty" json:"agentUid,omitempty"`
+	ConnectionID string      `firestore:"connectionId,omitempty" json:"connectionId,omitempty"`
+	Params       RunParams   `firestore:"params" json:"params"`
+
+	Status        string        `firestore:"status" json:"status"`
+	PagesFetched  int           `firestore:"pagesFetched" json:"pagesFetched"`
+	Fetched       int           `firestore:"fetched" json:"fetched"`
+	Summary       UpsertSummary `firestore:"summary" json:"summary"`
+	Errors        []string      `firestore:"errors,omitempty" json:"errors,omitempty"`
+	NextPageToken string        `firestore:"nextPageToken,omitempty" json:"nextPageToken,omitempty"`
+
+	StartedAt  time.Time `firestore:"startedAt" json:"startedAt"`
+	FinishedAt time.Time `firestore:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	DurationMs int64     `firestore:"durationMs" json:"durationMs"`
+}
+
+// RunParams is the subset of IngestRequest persisted with a run.
+type RunParams struct {
+	Since       time.Time    `firestore:"since,omitempty" json:"since,omitempty"`
+	Region      RegionFilter `firestore:"region" json:"region"`
+	Limit       int          `firestore:"limit,omitempty" json:"limit,omitempty"`
+	MaxPages    int          `firestore:"maxPages,omitempty" json:"maxPages,omitempty"`
+	IncludeSold bool         `firestore:"includeSold" json:"includeSold"`
+	PageToken   string       `firestore:"pageToken,omitempty" json:"pageToken,omitempty"`
+}
+
+// RunFilter narrows ListIngestRuns. Empty fields are ignored. Each equality
+// filter combined with the startedAt ordering needs a composite index; see
+// firestore.indexes.json.
+type RunFilter struct {
+	Provider ProviderKey
+	Trigger  string
+	Status   string
+	AgentUID string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+// startIngestRun writes the initial ledger entry for a run. Ledger failures
+// never block ingest, so errors are logged and a nil run is returned.
+func startIngestRun(ctx context.Context, projectID string, req IngestRequest, params FetchParams) *IngestRun {
+	run := &IngestRun{
+		Provider:     req.Provider,
+		Trigger:      req.Trigger,
+		TriggeredBy:  req.TriggeredBy,
+		ScheduleID:   req.ScheduleID,
+		AgentUID:     req.AgentUID,
+		ConnectionID: req.ConnectionID,
+		Params: RunParams{
+			Since:       params.Since,
+			Region:      params.Region,
+			Limit:       params.Limit,
+			MaxPages:    params.MaxPages,
+			IncludeSold: params.IncludeSold,
+			PageToken:   params.PageToken,
+		},
+		Status:    RunStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if run.Trigger == "" {
+		run.Trigger = TriggerAPI
+	}
+	run.Summary.Provider = req.Provider
+
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		log.Printf("[listings] failed to start ingest run ledger entry: %v", err)
+		return nil
+	}
+	ref := client.Collection(ingestRunsCollection).NewDoc()
+	if req.RunID != "" {
+		ref = client.Collection(ingestRunsCollection).Doc(req.RunID)
+	}
+	run.ID = ref.ID
+	if _, err := ref.Set(ctx, run); err != nil {
+		log.Printf("[listings] failed to write ingest run %s: %v", run.ID, err)
+		return nil
+	}
+	return run
+}
+
+// finishIngestRun records the final state of a run. It is a no-op for a nil
+// run (ledger unavailable).
+func finishIngestRun(ctx context.Context, projectID string, run *IngestRun, out *IngestOutcome, runErr error) {
+	if run == nil {
+		return
+	}
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	if out != nil {
+		run.PagesFetched = out.Pages
+		run.Fetched = out.Fetched
+		run.NextPageToken = out.NextPage
+		if out.Summary != nil {
+			run.Summary = *out.Summary
+		}
+		run.Errors = append(run.Errors, out.Errors...)
+	}
+	switch {
+	case errors.Is(runErr, ErrRunCancelled):
+		run.Status = RunStatusCancelled
+		run.Errors = append(run.Errors, runErr.Error())
+	case runErr != nil:
+		run.Status = RunStatusFailed
+		run.Errors = append(run.Errors, runErr.Error())
+	case len(run.Errors) > 0:
+		run.Status = RunStatusPartial
+	default:
+		run.Status = RunStatusSucceeded
+	}
+
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		log.Printf("[listings] failed to finish ingest run %s: %v", run.ID, err)
+		return
+	}
+	if _, err := client.Collection(ingestRunsCollection).Doc(run.ID).Set(ctx, run); err != nil {
+		log.Printf("[listings] failed to finish ingest run %s: %v", run.ID, err)
+	}
+}
+
+// GetIngestRun loads a single run. It returns (nil, nil) when the run does not
+// exist.
+func GetIngestRun(ctx context.Context, projectID, id string) (*IngestRun, error) {
+	if projectID == "" || id == "" {
+		return nil, fmt.Errorf("projectID and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := client.Collection(ingestRunsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var run IngestRun
+	if err := doc.DataTo(&run); err != nil {
+		return nil, err
+	}
+	run.ID = doc.Ref.ID
+	return &run, nil
+}
+
+// ListIngestRuns returns runs matching f, most recent first.
+func ListIngestRuns(ctx context.Context, projectID string, f RunFilter) ([]*IngestRun, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("projectID is required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := client.Collection(ingestRunsCollection).Query
+	if f.Provider != "" {
+		q = q.Where("provider", "==", string(f.Provider))
+	}
+	if f.Trigger != "" {
+		q = q.Where("trigger", "==", f.Trigger)
+	}
+	if f.Status != "" {
+		q = q.Where("status", "==", f.Status)
+	}
+	if f.AgentUID != "" {
+		q = q.Where("agentUid", "==", f.AgentUID)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("startedAt", ">=", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("startedAt", "<", f.Until)
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	q = q.OrderBy("startedAt", gfs.Desc).Limit(limit)
+
+	snap, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*IngestRun, 0, len(snap))
+	for _, doc := range snap {
+		var run IngestRun
+		if err := doc.DataTo(&run); err != nil {
+			continue
+		}
+		run.ID = doc.Ref.ID
+		out = append(out, &run)
+	}
+	return out, nil
+}
@@ -0,0 +1,578 @@
+package listings
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Alert frequencies for SavedSearch.Frequency.
+const (
+	AlertInstant = "instant"
+	AlertDaily   = "daily"
+)
+
+// Alert reasons recorded on PropertyChange and saved search matches.
+const (
+	AlertReasonNew          = "new_listing"
+	AlertReasonPriceDrop    = "price_drop"
+	AlertReasonBackOnMarket = "back_on_market"
+	AlertReasonChanged      = "changed"
+)
+
+// Notification types written to the notifications collection.
+const (
+	NotificationSavedSearchMatch  = "saved_search_match"
+	NotificationSavedSearchDigest = "saved_search_digest"
+)
+
+const (
+	savedSearchesCollection      = "saved_searches"
+	savedSearchMatchesCollection = "saved_search_matches"
+	notificationsCollection      = "notifications"
+
+	// maxBatchWrites is Firestore's limit on writes per batch.
+	maxBatchWrites = 500
+)
+
+// SearchCriteria is what a client is hunting for. Zero values and empty lists
+// do not constrain the match. Strings compare case-insensitively.
+type SearchCriteria struct {
+	MinPrice float64  `firestore:"minPrice,omitempty" json:"minPrice,omitempty"`
+	MaxPrice float64  `firestore:"maxPrice,omitempty" json:"maxPrice,omitempty"`
+	MinBeds  int      `firestore:"minBeds,omitempty" json:"minBeds,omitempty"`
+	MinBaths float64  `firestore:"minBaths,omitempty" json:"minBaths,omitempty"`
+	MinSqft  int      `firestore:"minSqft,omitempty" json:"minSqft,omitempty"`
+	MaxSqft  int      `firestore:"maxSqft,omitempty" json:"maxSqft,omitempty"`
+	Types    []string `firestore:"types,omitempty" json:"types,omitempty"`
+	Cities   []string `firestore:"cities,omitempty" json:"cities,omitempty"`
+	States   []string `firestore:"states,omitempty" json:"states,omitempty"`
+	Zips     []string `firestore:"zips,omitempty" json:"zips,omitempty"`
+	// Statuses are normalized Status* values; empty means active only.
+	Statuses []string `firestore:"statuses,omitempty" json:"statuses,omitempty"`
+}
+
+// SavedSearch is a user's standing search stored in saved_searches. Deals
+// with a searchProfile are treated as saved searches for their client too,
+// with ids from DealSavedSearchID.
+type SavedSearch struct {
+	ID        string         `firestore:"-" json:"id"`
+	UserID    string         `firestore:"userId" json:"userId"`
+	DealID    string         `firestore:"dealId,omitempty" json:"dealId,omitempty"`
+	Name      string         `firestore:"name" json:"name"`
+	Criteria  SearchCriteria `firestore:"criteria" json:"criteria"`
+	Frequency string         `firestore:"frequency" json:"frequency"`
+	Active    bool           `firestore:"active" json:"active"`
+	CreatedAt time.Time      `firestore:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time      `firestore:"updatedAt" json:"updatedAt"`
+}
+
+// PropertyChange identifies a property created or changed by ingest.
+type PropertyChange struct {
+	PropertyID string
+	Reason     string
+}
+
+// changeReason picks the alert reason for an update from its history
+// events, or "" when nothing alert-worthy changed.
+func changeReason(events []HistoryEvent) string {
+	reason := ""
+	for _, ev := range events {
+		switch ev.Type {
+		case EventPriceDrop:
+			return AlertReasonPriceDrop
+		case EventBackOnMarket, EventListed:
+			reason = AlertReasonBackOnMarket
+		default:
+			if reason == "" {
+				reason = AlertReasonChanged
+			}
+		}
+	}
+	return reason
+}
+
+// Validate normalizes the frequency and checks required fields.
+func (s *SavedSearch) Validate() error {
+	if strings.TrimSpace(s.UserID) == "" {
+		return fmt.Errorf("userId is required")
+	}
+	switch s.Frequency {
+	case "":
+		s.Frequency = AlertInstant
+	case AlertInstant, AlertDaily:
+	default:
+		return fmt.Errorf("frequency must be %q or %q", AlertInstant, AlertDaily)
+	}
+	c := s.Criteria
+	if c.MinPrice < 0 || c.MaxPrice < 0 || c.MinBeds < 0 || c.MinBaths < 0 || c.MinSqft < 0 || c.MaxSqft < 0 {
+		return fmt.Errorf("criteria values must be non-negative")
+	}
+	if c.MaxPrice > 0 && c.MinPrice > c.MaxPrice {
+		return fmt.Errorf("minPrice must not exceed maxPrice")
+	}
+	return nil
+}
+
+// Matches reports whether a properties document satisfies the criteria.
+func (c SearchCriteria) Matches(data map[string]any) bool {
+	num := func(field string) (float64, bool) { return asFloat(data[field]) }
+	inRange := func(field string, lo, hi float64) bool {
+		if lo <= 0 && hi <= 0 {
+			return true
+		}
+		v, ok := num(field)
+		return ok && (lo <= 0 || v >= lo) && (hi <= 0 || v <= hi)
+	}
+	if !inRange("price", c.MinPrice, c.MaxPrice) ||
+		!inRange("bedrooms", float64(c.MinBeds), 0) ||
+		!inRange("bathrooms", c.MinBaths, 0) ||
+		!inRange("squareFeet", float64(c.MinSqft), float64(c.MaxSqft)) {
+		return false
+	}
+
+	str := func(v any) string { s, _ := v.(string); return s }
+	addr, _ := data["address"].(map[string]any)
+	statuses := c.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{StatusActive}
+	}
+	return oneOf(c.Types, str(data["type"])) &&
+		oneOf(c.Cities, str(addr["city"])) &&
+		oneOf(c.States, str(addr["state"])) &&
+		oneOf(c.Zips, str(addr["postalCode"])) &&
+		oneOf(statuses, NormalizeStatus(str(data["status"])))
+}
+
+// oneOf reports whether v case-insensitively equals an entry of allowed, or
+// allowed is empty.
+func oneOf(allowed []string, v string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CriteriaFromSearchProfile reads the free-form deals.Deal.SearchProfile map.
+// Both scalar and list forms are accepted ("city" or "cities"), as are
+// budgetMin/budgetMax for the price range. The frequency is taken from
+// "alertFrequency" and defaults to instant.
+func CriteriaFromSearchProfile(profile map[string]any) (SearchCriteria, string) {
+	var c SearchCriteria
+	num := func(keys ...string) float64 {
+		for _, k := range keys {
+			if v, ok := asFloat(profile[k]); ok {
+				return v
+			}
+		}
+		return 0
+	}
+	list := func(keys ...string) []string {
+		var out []string
+		for _, k := range keys {
+			switch v := profile[k].(type) {
+			case string:
+				if strings.TrimSpace(v) != "" {
+					out = append(out, v)
+				}
+			case []any:
+				for _, item := range v {
+					if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+						out = append(out, s)
+					}
+				}
+			case []string:
+				out = append(out, v...)
+			}
+		}
+		return out
+	}
+	c.MinPrice = num("minPrice", "budgetMin")
+	c.MaxPrice = num("maxPrice", "budgetMax", "budget")
+	c.MinBeds = int(num("minBeds", "bedrooms", "beds"))
+	c.MinBaths = num("minBaths", "bathrooms", "baths")
+	c.MinSqft = int(num("minSqft"))
+	c.MaxSqft = int(num("maxSqft"))
+	c.Types = list("types", "propertyTypes", "propertyType", "type")
+	c.Cities = list("cities", "city")
+	c.States = list("states", "state")
+	c.Zips = list("zips", "zip", "postalCodes", "postalCode")
+	for _, s := range list("statuses", "status") {
+		c.Statuses = append(c.Statuses, NormalizeStatus(s))
+	}
+
+	freq, _ := profile["alertFrequency"].(string)
+	if freq != AlertDaily {
+		freq = AlertInstant
+	}
+	return c, freq
+}
+
+// DealSavedSearchID is the synthetic saved search id for a deal's profile.
+func DealSavedSearchID(dealID string) string {
+	return "deal_" + dealID
+}
+
+// CreateSavedSearch stores a new saved search and fills in its id.
+func CreateSavedSearch(ctx context.Context, projectID string, s *SavedSearch) error {
+	if projectID == "" || s == nil {
+		return fmt.Errorf("projectID and saved search are required")
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	s.Active = true
+	s.CreatedAt, s.UpdatedAt = now, now
+	ref := client.Collection(savedSearchesCollection).NewDoc()
+	if _, err := ref.Set(ctx, s); err != nil {
+		return err
+	}
+	s.ID = ref.ID
+	return nil
+}
+
+// ListSavedSearches returns userID's saved searches.
+func ListSavedSearches(ctx context.Context, projectID, userID string) ([]*SavedSearch, error) {
+	if projectID == "" || userID == "" {
+		return nil, fmt.Errorf("projectID and userID are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := client.Collection(savedSearchesCollection).Where("userId", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return decodeSavedSearches(snap), nil
+}
+
+// DeleteSavedSearch removes a saved search owned by userID. It returns false
+// when the search does not exist or belongs to someone else.
+func DeleteSavedSearch(ctx context.Context, projectID, userID, id string) (bool, error) {
+	if projectID == "" || userID == "" || id == "" {
+		return false, fmt.Errorf("projectID, userID and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return false, err
+	}
+	ref := client.Collection(savedSearchesCollection).Doc(id)
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if owner, _ := doc.Data()["userId"].(string); owner != userID {
+		return false, nil
+	}
+	if _, err := ref.Delete(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func decodeSavedSearches(snap []*gfs.DocumentSnapshot) []*SavedSearch {
+	out := make([]*SavedSearch, 0, len(snap))
+	for _, doc := range snap {
+		var s SavedSearch
+		if err := doc.DataTo(&s); err != nil {
+			log.Printf("[listings] skipping malformed saved search %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		s.ID = doc.Ref.ID
+		out = append(out, &s)
+	}
+	return out
+}
+
+// activeSavedSearches loads enabled saved searches plus one synthetic search
+// per deal that has a client and a searchProfile.
+func activeSavedSearches(ctx context.Context, client *gfs.Client) ([]*SavedSearch, error) {
+	snap, err := client.Collection(savedSearchesCollection).Where("active", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	searches := decodeSavedSearches(snap)
+
+	deals, err := client.Collection("deals").Where("searchProfile", "!=", nil).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range deals {
+		data := doc.Data()
+		clientUID, _ := data["clientUid"].(string)
+		profile, _ := data["searchProfile"].(map[string]any)
+		if clientUID == "" || len(profile) == 0 {
+			continue
+		}
+		criteria, freq := CriteriaFromSearchProfile(profile)
+		searches = append(searches, &SavedSearch{
+			ID:        DealSavedSearchID(doc.Ref.ID),
+			UserID:    clientUID,
+			DealID:    doc.Ref.ID,
+			Name:      "Deal search",
+			Criteria:  criteria,
+			Frequency: freq,
+			Active:    true,
+		})
+	}
+	return searches, nil
+}
+
+// savedSearchMatch is recorded once per user and property in
+// saved_search_matches; its deterministic id is what de-duplicates alerts.
+type savedSearchMatch struct {
+	UserID        string    `firestore:"userId"`
+	PropertyID    string    `firestore:"propertyId"`
+	SavedSearchID string    `firestore:"savedSearchId"`
+	Reason        string    `firestore:"reason"`
+	Frequency     string    `firestore:"frequency"`
+	Pending       bool      `firestore:"pending"`
+	MatchedAt     time.Time `firestore:"matchedAt"`
+}
+
+func savedSearchMatchID(userID, propertyID string) string {
+	return userID + "_" + propertyID
+}
+
+// NotifySavedSearches evaluates changed properties against every active saved
+// search. Each user is alerted at most once per property: instant searches
+// write a notification straight away, daily ones queue the match for
+// SendSavedSearchDigests. It returns the number of new matches.
+func NotifySavedSearches(ctx context.Context, projectID string, changes []PropertyChange) (int, error) {
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	if projectID == "" {
+		return 0, fmt.Errorf("projectID is required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	searches, err := activeSavedSearches(ctx, client)
+	if err != nil || len(searches) == 0 {
+		return 0, err
+	}
+
+	refs := make([]*gfs.DocumentRef, len(changes))
+	for i, ch := range changes {
+		refs[i] = client.Collection("properties").Doc(ch.PropertyID)
+	}
+	docs, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	matched := 0
+	for i, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		data := doc.Data()
+		notified := map[string]bool{}
+		for _, s := range searches {
+			if notified[s.UserID] || !s.Criteria.Matches(data) {
+				continue
+			}
+			notified[s.UserID] = true
+			m := savedSearchMatch{
+				UserID:        s.UserID,
+				PropertyID:    doc.Ref.ID,
+				SavedSearchID: s.ID,
+				Reason:        changes[i].Reason,
+				Frequency:     s.Frequency,
+				Pending:       s.Frequency == AlertDaily,
+				MatchedAt:     now,
+			}
+			_, err := client.Collection(savedSearchMatchesCollection).Doc(savedSearchMatchID(s.UserID, doc.Ref.ID)).Create(ctx, m)
+			if status.Code(err) == codes.AlreadyExists {
+				continue
+			}
+			if err != nil {
+				log.Printf("[listings] failed to record saved search match user=%s property=%s: %v", s.UserID, doc.Ref.ID, err)
+				continue
+			}
+			matched++
+			if m.Pending {
+				continue
+			}
+			if _, _, err := client.Collection(notificationsCollection).Add(ctx, matchNotification(s, doc.Ref.ID, data, m.Reason, now)); err != nil {
+				log.Printf("[listings] failed to write notification user=%s property=%s: %v", s.UserID, doc.Ref.ID, err)
+			}
+		}
+	}
+	return matched, nil
+}
+
+// notifyForRun alerts saved searches about the properties an ingest run
+// created or changed. Failures are logged; they never fail the run.
+func notifyForRun(ctx context.Context, projectID string, out *IngestOutcome) {
+	if out == nil || out.Summary == nil {
+		return
+	}
+	n, err := NotifySavedSearches(ctx, projectID, out.Summary.changes)
+	if err != nil {
+		log.Printf("[listings] saved search alerts failed for provider %s: %v", out.Provider, err)
+		return
+	}
+	out.Alerts = n
+}
+
+// matchNotification builds the instant alert for a single property.
+func matchNotification(s *SavedSearch, propertyID string, data map[string]any, reason string, now time.Time) map[string]any {
+	title := "New listing matches your search"
+	switch reason {
+	case AlertReasonPriceDrop:
+		title = "Price drop on a listing matching your search"
+	case AlertReasonBackOnMarket:
+		title = "A listing matching your search is back on the market"
+	case AlertReasonChanged:
+		title = "A listing matching your search was updated"
+	}
+	return map[string]any{
+		"userId":        s.UserID,
+		"type":          NotificationSavedSearchMatch,
+		"title":         title,
+		"message":       propertySummary(data),
+		"propertyId":    propertyID,
+		"savedSearchId": s.ID,
+		"reason":        reason,
+		"read":          false,
+		"createdAt":     now,
+	}
+}
+
+// propertySummary renders a one-line description like
+// "123 Main St, Austin TX - $450,000 - 3 bd / 2 ba".
+func propertySummary(data map[string]any) string {
+	var parts []string
+	if addr, ok := data["address"].(map[string]any); ok {
+		street, _ := addr["street"].(string)
+		city, _ := addr["city"].(string)
+		state, _ := addr["state"].(string)
+		loc := strings.TrimSpace(strings.TrimSpace(city + " " + state))
+		if street != "" && loc != "" {
+			loc = street + ", " + loc
+		} else if street != "" {
+			loc = street
+		}
+		if loc != "" {
+			parts = append(parts, loc)
+		}
+	}
+	if price, ok := asFloat(data["price"]); ok && price > 0 {
+		parts = append(parts, "$"+formatThousands(int64(price)))
+	}
+	beds, _ := asFloat(data["bedrooms"])
+	baths, _ := asFloat(data["bathrooms"])
+	if beds > 0 || baths > 0 {
+		parts = append(parts, fmt.Sprintf("%g bd / %g ba", beds, baths))
+	}
+	return strings.Join(parts, " - ")
+}
+
+func formatThousands(n int64) string {
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// SendSavedSearchDigests sends one notification per user summarizing their
+// pending daily matches, then clears them. The digest notification id is
+// keyed by user and day so concurrent schedulers send it only once. It
+// returns the number of digests sent.
+func SendSavedSearchDigests(ctx context.Context, projectID string, now time.Time) (int, error) {
+	if projectID == "" {
+		return 0, fmt.Errorf("projectID is required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	snap, err := client.Collection(savedSearchMatchesCollection).Where("pending", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+	byUser := map[string][]*gfs.DocumentSnapshot{}
+	var users []string
+	for _, doc := range snap {
+		uid, _ := doc.Data()["userId"].(string)
+		if uid == "" {
+			continue
+		}
+		if _, ok := byUser[uid]; !ok {
+			users = append(users, uid)
+		}
+		byUser[uid] = append(byUser[uid], doc)
+	}
+
+	day := now.UTC().Format("20060102")
+	sent := 0
+	for _, uid := range users {
+		matches := byUser[uid]
+		ids := make([]string, 0, len(matches))
+		for _, m := range matches {
+			pid, _ := m.Data()["propertyId"].(string)
+			ids = append(ids, pid)
+		}
+		title := fmt.Sprintf("%d new listings match your saved searches", len(ids))
+		if len(ids) == 1 {
+			title = "1 new listing matches your saved searches"
+		}
+		_, err := client.Collection(notificationsCollection).Doc("digest_"+uid+"_"+day).Create(ctx, map[string]any{
+			"userId":      uid,
+			"type":        NotificationSavedSearchDigest,
+			"title":       title,
+			"message":     "Your daily saved search digest is ready.",
+			"propertyIds": ids,
+			"read":        false,
+			"createdAt":   now,
+		})
+		if status.Code(err) == codes.AlreadyExists {
+			// Today's digest went out already; these matches wait for the
+			// next one.
+			continue
+		}
+		if err != nil {
+			log.Printf("[listings] failed to send saved search digest to %s: %v", uid, err)
+			continue
+		}
+		sent++
+
+		for start := 0; start < len(matches); start += maxBatchWrites {
+			end := min(start+maxBatchWrites, len(matches))
+			batch := client.Batch()
+			for _, m := range matches[start:end] {
+				batch.Set(m.Ref, map[string]any{"pending": false, "digestedAt": now}, gfs.MergeAll)
+			}
+			if _, err := batch.Commit(ctx); err != nil {
+				log.Printf("[listings] failed to clear pending matches for %s: %v", uid, err)
+			}
+		}
+	}
+	return sent, nil
+}
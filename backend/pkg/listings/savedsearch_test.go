@@ -0,0 +1,75 @@
+package listings
+
+import "testing"
+
+// TestSearchCriteriaMatches covers numeric ranges, case-insensitive location
+// filters and the active-only status default.
+func TestSearchCriteriaMatches(t *testing.T) {
+	doc := map[string]any{
+		"price":      450000.0,
+		"bedrooms":   int64(3),
+		"bathrooms":  2.0,
+		"squareFeet": int64(1800),
+		"type":       "single_family",
+		"status":     "Active",
+		"address":    map[string]any{"city": "Austin", "state": "TX", "postalCode": "78701"},
+	}
+	tests := []struct {
+		name string
+		c    SearchCriteria
+		want bool
+	}{
+		{"empty", SearchCriteria{}, true},
+		{"budget", SearchCriteria{MinPrice: 400000, MaxPrice: 500000, MinBeds: 3}, true},
+		{"over budget", SearchCriteria{MaxPrice: 400000}, false},
+		{"city case", SearchCriteria{Cities: []string{"austin", "Dallas"}, States: []string{"tx"}}, true},
+		{"wrong zip", SearchCriteria{Zips: []string{"78702"}}, false},
+		{"type", SearchCriteria{Types: []string{"condo"}}, false},
+		{"pending only", SearchCriteria{Statuses: []string{StatusPending}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.c.Matches(doc); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	doc["status"] = "Closed"
+	if (SearchCriteria{}).Matches(doc) {
+		t.Error("sold listings should not match the default status filter")
+	}
+}
+
+// TestCriteriaFromSearchProfile verifies the free-form deal profile is read
+// with its aliases and scalar/list forms.
+func TestCriteriaFromSearchProfile(t *testing.T) {
+	c, freq := CriteriaFromSearchProfile(map[string]any{
+		"budgetMin":      int64(300000),
+		"budgetMax":      500000.0,
+		"beds":           int64(2),
+		"city":           "Austin",
+		"propertyTypes":  []any{"condo", "townhouse"},
+		"alertFrequency": "daily",
+	})
+	if c.MinPrice != 300000 || c.MaxPrice != 500000 || c.MinBeds != 2 {
+		t.Errorf("numeric criteria = %+v", c)
+	}
+	if len(c.Cities) != 1 || len(c.Types) != 2 || freq != AlertDaily {
+		t.Errorf("criteria = %+v, frequency = %s", c, freq)
+	}
+	if _, freq := CriteriaFromSearchProfile(map[string]any{"alertFrequency": "hourly"}); freq != AlertInstant {
+		t.Errorf("unknown frequency should default to instant, got %s", freq)
+	}
+}
+
+// TestChangeReason verifies price drops take precedence over other events.
+func TestChangeReason(t *testing.T) {
+	if r := changeReason(nil); r != "" {
+		t.Errorf("no events: %q", r)
+	}
+	if r := changeReason([]HistoryEvent{{Type: EventStatusChange}, {Type: EventPriceDrop}}); r != AlertReasonPriceDrop {
+		t.Errorf("got %q, want price drop", r)
+	}
+	if r := changeReason([]HistoryEvent{{Type: EventBackOnMarket}}); r != AlertReasonBackOnMarket {
+		t.Errorf("got %q, want back on market", r)
+	}
+}
@@ -0,0 +1,525 @@
+package listings
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+)
+
+// IngestSchedule is a recurring ingest job stored in the ingest_schedules
+// collection. Schedules are either created by admins or generated
+// automatically (Auto) from enabled AgentMLSConnection records.
+type IngestSchedule struct {
+	ID           string       `firestore:"-" json:"id"`
+	Cron         string       `firestore:"cron" json:"cron"`
+	TimeZone     string       `firestore:"timeZone,omitempty" json:"timeZone,omitempty"`
+	Provider     ProviderKey  `firestore:"provider" json:"provider"`
+	Region       RegionFilter `firestore:"region" json:"region"`
+	AgentUID     string       `firestore:"agentUid,omitempty" json:"agentUid,omitempty"`
+	ConnectionID string       `firestore:"connectionId,omitempty" json:"connectionId,omitempty"`
+	Limit        int          `firestore:"limit,omitempty" json:"limit,omitempty"`
+	MaxPages     int          `firestore:"maxPages,omitempty" json:"maxPages,omitempty"`
+	IncludeSold  bool         `firestore:"includeSold" json:"includeSold"`
+	Enabled      bool         `firestore:"enabled" json:"enabled"`
+	Auto         bool         `firestore:"auto" json:"auto"`
+	// DisabledBy is "sync" when SyncAgentSchedules disabled an automatic
+	// schedule, so it only re-enables schedules it turned off itself.
+	DisabledBy string `firestore:"disabledBy,omitempty" json:"disabledBy,omitempty"`
+
+	NextRunAt  time.Time `firestore:"nextRunAt" json:"nextRunAt"`
+	LastRunAt  time.Time `firestore:"lastRunAt,omitempty" json:"lastRunAt,omitempty"`
+	LastStatus string    `firestore:"lastStatus,omitempty" json:"lastStatus,omitempty"`
+	LastError  string    `firestore:"lastError,omitempty" json:"lastError,omitempty"`
+	LastRunID  string    `firestore:"lastRunId,omitempty" json:"lastRunId,omitempty"`
+
+	// Lease fields implement a simple lock so only one API instance runs a
+	// given schedule at a time.
+	LeaseOwner     string    `firestore:"leaseOwner,omitempty" json:"leaseOwner,omitempty"`
+	LeaseExpiresAt time.Time `firestore:"leaseExpiresAt,omitempty" json:"leaseExpiresAt,omitempty"`
+
+	CreatedAt time.Time `firestore:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `firestore:"updatedAt" json:"updatedAt"`
+}
+
+const ingestSchedulesCollection = "ingest_schedules"
+
+// disabledBySync marks automatic schedules disabled by SyncAgentSchedules.
+const disabledBySync = "sync"
+
+// defaultAgentScheduleCron is used for automatic agent MLS schedules unless
+// INGEST_AGENT_SCHEDULE_CRON overrides it.
+const defaultAgentScheduleCron = "0 */6 * * *"
+
+// defaultDigestHour is the UTC hour daily saved search digests go out unless
+// SAVED_SEARCH_DIGEST_HOUR overrides it.
+const defaultDigestHour = 13
+
+// NextRun computes the next activation after t in the schedule's time zone.
+func (s *IngestSchedule) NextRun(t time.Time) (time.Time, error) {
+	cs, err := ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.UTC
+	if s.TimeZone != "" {
+		if loc, err = time.LoadLocation(s.TimeZone); err != nil {
+			return time.Time{}, fmt.Errorf("invalid timeZone %q: %w", s.TimeZone, err)
+		}
+	}
+	next := cs.Next(t.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never fires", s.Cron)
+	}
+	return next, nil
+}
+
+func (s *IngestSchedule) ingestRequest() IngestRequest {
+	return IngestRequest{
+		Provider:     s.Provider,
+		Region:       s.Region,
+		Limit:        s.Limit,
+		MaxPages:     s.MaxPages,
+		IncludeSold:  s.IncludeSold,
+		AgentUID:     s.AgentUID,
+		ConnectionID: s.ConnectionID,
+		Trigger:      TriggerScheduler,
+		ScheduleID:   s.ID,
+	}
+}
+
+// ListIngestSchedules returns every schedule, enabled or not.
+func ListIngestSchedules(ctx context.Context, projectID string) ([]*IngestSchedule, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("projectID is required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := client.Collection(ingestSchedulesCollection).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return decodeSchedules(snap), nil
+}
+
+// GetIngestSchedule loads a single schedule. It returns (nil, nil) when the
+// schedule does not exist.
+func GetIngestSchedule(ctx context.Context, projectID, id string) (*IngestSchedule, error) {
+	if projectID == "" || id == "" {
+		return nil, fmt.Errorf("projectID and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := client.Collection(ingestSchedulesCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var s IngestSchedule
+	if err := doc.DataTo(&s); err != nil {
+		return nil, err
+	}
+	s.ID = doc.Ref.ID
+	return &s, nil
+}
+
+// SaveIngestSchedule validates and writes a schedule, computing its next run
+// time. A new document id is allocated when s.ID is empty. Run bookkeeping
+// and lease fields are left untouched on existing documents.
+func SaveIngestSchedule(ctx context.Context, projectID string, s *IngestSchedule) error {
+	if projectID == "" {
+		return fmt.Errorf("projectID is required")
+	}
+	if s == nil {
+		return fmt.Errorf("schedule is required")
+	}
+	if s.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if strings.TrimSpace(s.Cron) == "" {
+		return fmt.Errorf("cron is required")
+	}
+	now := time.Now()
+	next, err := s.NextRun(now)
+	if err != nil {
+		return err
+	}
+
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	col := client.Collection(ingestSchedulesCollection)
+	var ref *gfs.DocumentRef
+	if s.ID == "" {
+		ref = col.NewDoc()
+		s.ID = ref.ID
+		s.CreatedAt = now
+	} else {
+		ref = col.Doc(s.ID)
+	}
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = now
+	}
+	s.UpdatedAt = now
+	s.NextRunAt = next
+
+	data := map[string]any{
+		"cron":         s.Cron,
+		"timeZone":     s.TimeZone,
+		"provider":     string(s.Provider),
+		"region":       s.Region,
+		"agentUid":     s.AgentUID,
+		"connectionId": s.ConnectionID,
+		"limit":        s.Limit,
+		"maxPages":     s.MaxPages,
+		"includeSold":  s.IncludeSold,
+		"enabled":      s.Enabled,
+		"auto":         s.Auto,
+		"disabledBy":   s.DisabledBy,
+		"nextRunAt":    s.NextRunAt,
+		"createdAt":    s.CreatedAt,
+		"updatedAt":    s.UpdatedAt,
+	}
+	_, err = ref.Set(ctx, data, fs.MergeAll())
+	return err
+}
+
+// DeleteIngestSchedule removes a schedule.
+func DeleteIngestSchedule(ctx context.Context, projectID, id string) error {
+	if projectID == "" || id == "" {
+		return fmt.Errorf("projectID and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	_, err = client.Collection(ingestSchedulesCollection).Doc(id).Delete(ctx)
+	return err
+}
+
+// Scheduler runs due IngestSchedules in-process. Several API instances can
+// run a Scheduler concurrently: each due schedule is claimed with a
+// transactional lease on its document so only one instance executes it.
+type Scheduler struct {
+	ProjectID string
+	Registry  *Registry
+
+	// Interval is how often due schedules are checked.
+	Interval time.Duration
+	// LeaseTTL bounds how long a claimed run may take before another
+	// instance is allowed to pick the schedule up again.
+	LeaseTTL time.Duration
+	// AgentSyncInterval is how often automatic agent MLS schedules are
+	// reconciled against mls_connections.
+	AgentSyncInterval time.Duration
+	// AgentCron is the cron expression given to new automatic schedules.
+	AgentCron string
+	// DigestHour is the UTC hour after which daily saved search digests are
+	// sent.
+	DigestHour int
+
+	instanceID string
+	lastSync   time.Time
+	lastDigest string
+}
+
+// NewScheduler constructs a Scheduler with defaults suitable for Cloud Run.
+func NewScheduler(projectID string, reg *Registry) *Scheduler {
+	agentCron := os.Getenv("INGEST_AGENT_SCHEDULE_CRON")
+	if agentCron == "" {
+		agentCron = defaultAgentScheduleCron
+	}
+	digestHour := defaultDigestHour
+	if h, err := strconv.Atoi(os.Getenv("SAVED_SEARCH_DIGEST_HOUR")); err == nil && h >= 0 && h < 24 {
+		digestHour = h
+	}
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return &Scheduler{
+		ProjectID:         projectID,
+		Registry:          reg,
+		Interval:          time.Minute,
+		LeaseTTL:          30 * time.Minute,
+		AgentSyncInterval: 15 * time.Minute,
+		AgentCron:         agentCron,
+		DigestHour:        digestHour,
+		instanceID:        host + "-" + hex.EncodeToString(suffix),
+	}
+}
+
+// Run checks for due schedules every Interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("[scheduler] starting ingest scheduler (instance=%s, interval=%s)", s.instanceID, s.Interval)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Tick(ctx, time.Now()); err != nil {
+			log.Printf("[scheduler] tick error: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick reconciles automatic agent schedules (at most every
+// AgentSyncInterval), sends the daily saved search digests once DigestHour
+// has passed, and then runs every schedule that is due at now.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) error {
+	if now.Sub(s.lastSync) >= s.AgentSyncInterval {
+		if err := s.SyncAgentSchedules(ctx); err != nil {
+			log.Printf("[scheduler] agent schedule sync failed: %v", err)
+		} else {
+			s.lastSync = now
+		}
+	}
+	if day := now.UTC().Format("2006-01-02"); day != s.lastDigest && now.UTC().Hour() >= s.DigestHour {
+		if n, err := SendSavedSearchDigests(ctx, s.ProjectID, now); err != nil {
+			log.Printf("[scheduler] saved search digests failed: %v", err)
+		} else {
+			s.lastDigest = day
+			if n > 0 {
+				log.Printf("[scheduler] sent %d saved search digests", n)
+			}
+		}
+	}
+
+	client, err := fs.Client(ctx, s.ProjectID)
+	if err != nil {
+		return err
+	}
+	snap, err := client.Collection(ingestSchedulesCollection).Where("enabled", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, sched := range decodeSchedules(snap) {
+		if sched.NextRunAt.After(now) {
+			continue
+		}
+		claimed, err := s.claim(ctx, client, sched.ID, now)
+		if err != nil {
+			log.Printf("[scheduler] failed to claim schedule %s: %v", sched.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		s.runSchedule(ctx, client, sched, now)
+	}
+	return nil
+}
+
+// errNotClaimable aborts the lease transaction without treating it as an
+// error.
+var errNotClaimable = errors.New("schedule not claimable")
+
+// claim takes the lease on a due schedule. It returns false when the schedule
+// is no longer due or another instance holds an unexpired lease.
+func (s *Scheduler) claim(ctx context.Context, client *gfs.Client, id string, now time.Time) (bool, error) {
+	ref := client.Collection(ingestSchedulesCollection).Doc(id)
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *gfs.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var cur IngestSchedule
+		if err := doc.DataTo(&cur); err != nil {
+			return err
+		}
+		if !cur.Enabled || cur.NextRunAt.After(now) {
+			return errNotClaimable
+		}
+		if cur.LeaseOwner != "" && cur.LeaseOwner != s.instanceID && cur.LeaseExpiresAt.After(now) {
+			return errNotClaimable
+		}
+		return tx.Set(ref, map[string]any{
+			"leaseOwner":     s.instanceID,
+			"leaseExpiresAt": now.Add(s.LeaseTTL),
+		}, gfs.MergeAll)
+	})
+	if errors.Is(err, errNotClaimable) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// runSchedule executes a claimed schedule and records the outcome, releasing
+// the lease and advancing nextRunAt.
+func (s *Scheduler) runSchedule(ctx context.Context, client *gfs.Client, sched *IngestSchedule, now time.Time) {
+	runCtx, cancel := context.WithTimeout(ctx, s.LeaseTTL)
+	defer cancel()
+
+	update := map[string]any{
+		"lastRunAt":      now,
+		"leaseOwner":     gfs.Delete,
+		"leaseExpiresAt": gfs.Delete,
+		"updatedAt":      time.Now(),
+	}
+
+	out, err := RunIngest(runCtx, s.ProjectID, s.Registry, sched.ingestRequest())
+	if err != nil {
+		log.Printf("[scheduler] schedule %s (provider=%s) failed: %v", sched.ID, sched.Provider, err)
+		update["lastStatus"] = "error"
+		update["lastError"] = err.Error()
+	} else {
+		log.Printf("[scheduler] schedule %s (provider=%s) fetched=%d pages=%d", sched.ID, sched.Provider, out.Fetched, out.Pages)
+		update["lastStatus"] = "ok"
+		update["lastError"] = gfs.Delete
+		if out.RunID != "" {
+			update["lastRunId"] = out.RunID
+		}
+	}
+
+	if next, err := sched.NextRun(now); err == nil {
+		update["nextRunAt"] = next
+	} else {
+		// A schedule whose cron can no longer be evaluated would otherwise
+		// fire on every tick.
+		log.Printf("[scheduler] disabling schedule %s: %v", sched.ID, err)
+		update["enabled"] = false
+	}
+
+	if _, err := client.Collection(ingestSchedulesCollection).Doc(sched.ID).Set(ctx, update, gfs.MergeAll); err != nil {
+		log.Printf("[scheduler] failed to record run for schedule %s: %v", sched.ID, err)
+	}
+}
+
+// AgentScheduleID returns the document id of the automatic schedule for an
+// agent's MLS connection. Original single-board connections are keyed by the
+// agent uid, so their schedules keep the ids they always had.
+func AgentScheduleID(connectionID string) string {
+	return "agent_mls_" + connectionID
+}
+
+// SyncAgentSchedules makes sure every enabled AgentMLSConnection with a
+// default city or state has an automatic MLS schedule pinned to its board,
+// and disables automatic schedules whose connection has been disabled or
+// removed. The cron and enabled flag of an existing automatic schedule are
+// never overwritten so admins can tune or pause it; only schedules sync
+// disabled itself are enabled again.
+func (s *Scheduler) SyncAgentSchedules(ctx context.Context) error {
+	client, err := fs.Client(ctx, s.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	connSnap, err := client.Collection(mlsConnectionsCollection).Where("enabled", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	schedSnap, err := client.Collection(ingestSchedulesCollection).Where("auto", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*IngestSchedule)
+	for _, sched := range decodeSchedules(schedSnap) {
+		existing[sched.ID] = sched
+	}
+
+	wanted := make(map[string]bool)
+	for _, doc := range connSnap {
+		var conn AgentMLSConnection
+		if err := doc.DataTo(&conn); err != nil {
+			continue
+		}
+		if conn.AgentUID == "" {
+			conn.AgentUID = doc.Ref.ID
+		}
+		if conn.DefaultCity == "" && conn.DefaultState == "" {
+			continue
+		}
+		conn.ID = doc.Ref.ID
+		id := AgentScheduleID(conn.ID)
+		wanted[id] = true
+
+		sched := syncAgentSchedule(existing[id], &conn, s.AgentCron)
+		if sched == nil {
+			continue
+		}
+		if err := SaveIngestSchedule(ctx, s.ProjectID, sched); err != nil {
+			log.Printf("[scheduler] failed to save automatic schedule for agent %s board %s: %v", conn.AgentUID, conn.ID, err)
+		}
+	}
+
+	for id, sched := range existing {
+		if wanted[id] || !sched.Enabled {
+			continue
+		}
+		if _, err := client.Collection(ingestSchedulesCollection).Doc(id).Set(ctx, map[string]any{
+			"enabled":    false,
+			"disabledBy": disabledBySync,
+			"updatedAt":  time.Now(),
+		}, gfs.MergeAll); err != nil {
+			log.Printf("[scheduler] failed to disable automatic schedule %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// syncAgentSchedule returns the automatic schedule to save for conn, or nil
+// when sched is already up to date. A new schedule is created with cron;
+// an existing one only has its board and region refreshed, and is enabled
+// again only if sync disabled it.
+func syncAgentSchedule(sched *IngestSchedule, conn *AgentMLSConnection, cron string) *IngestSchedule {
+	region := conn.Region()
+	if sched == nil {
+		return &IngestSchedule{
+			ID:           AgentScheduleID(conn.ID),
+			Cron:         cron,
+			Provider:     ProviderMLS,
+			Region:       region,
+			AgentUID:     conn.AgentUID,
+			ConnectionID: conn.ID,
+			Enabled:      true,
+			Auto:         true,
+		}
+	}
+	changed := false
+	if sched.Region != region || sched.ConnectionID != conn.ID {
+		sched.Region = region
+		sched.ConnectionID = conn.ID
+		changed = true
+	}
+	if !sched.Enabled && sched.DisabledBy == disabledBySync {
+		sched.Enabled = true
+		sched.DisabledBy = ""
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return sched
+}
+
+func decodeSchedules(snap []*gfs.DocumentSnapshot) []*IngestSchedule {
+	out := make([]*IngestSchedule, 0, len(snap))
+	for _, doc := range snap {
+		var s IngestSchedule
+		if err := doc.DataTo(&s); err != nil {
+			log.Printf("[scheduler] skipping malformed schedule %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		s.ID = doc.Ref.ID
+		out = append(out, &s)
+	}
+	return out
+}
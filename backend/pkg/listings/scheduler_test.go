@@ -0,0 +1,34 @@
+package listings
+
+import "testing"
+
+// TestSyncAgentSchedule verifies sync creates missing automatic schedules,
+// refreshes their board and region, and never re-enables a schedule an admin
+// disabled.
+func TestSyncAgentSchedule(t *testing.T) {
+	conn := &AgentMLSConnection{ID: "a1_austin", AgentUID: "a1", DefaultCity: "Austin", DefaultState: "TX"}
+
+	sched := syncAgentSchedule(nil, conn, "0 */6 * * *")
+	if sched == nil || !sched.Enabled || !sched.Auto || sched.ID != AgentScheduleID("a1_austin") ||
+		sched.ConnectionID != "a1_austin" || sched.Region != conn.Region() {
+		t.Fatalf("new schedule = %+v", sched)
+	}
+	if got := syncAgentSchedule(sched, conn, "0 */6 * * *"); got != nil {
+		t.Errorf("up-to-date schedule saved again: %+v", got)
+	}
+
+	adminOff := &IngestSchedule{ID: sched.ID, Cron: "0 3 * * *", ConnectionID: conn.ID, Region: conn.Region(), Auto: true}
+	if got := syncAgentSchedule(adminOff, conn, "0 */6 * * *"); got != nil {
+		t.Errorf("admin-disabled schedule re-enabled: %+v", got)
+	}
+	conn.DefaultCity = "Round Rock"
+	got := syncAgentSchedule(adminOff, conn, "0 */6 * * *")
+	if got == nil || got.Enabled || got.Region.City != "Round Rock" || got.Cron != "0 3 * * *" {
+		t.Errorf("admin-disabled region refresh = %+v", got)
+	}
+
+	syncOff := &IngestSchedule{ID: sched.ID, ConnectionID: conn.ID, Region: conn.Region(), Auto: true, DisabledBy: disabledBySync}
+	if got := syncAgentSchedule(syncOff, conn, "0 */6 * * *"); got == nil || !got.Enabled || got.DisabledBy != "" {
+		t.Errorf("sync-disabled schedule = %+v", got)
+	}
+}
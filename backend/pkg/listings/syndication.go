@@ -0,0 +1,746 @@
+package listings
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// Syndication feed formats.
+const (
+	// FeedFormatRESO is a RESO Web API style JSON document of Property
+	// records using Data Dictionary field names.
+	FeedFormatRESO = "reso"
+	// FeedFormatZillow is a Zillow-style XML listing feed.
+	FeedFormatZillow = "zillow"
+	// FeedFormatCSV is a flat CSV export with one listing per row.
+	FeedFormatCSV = "csv"
+)
+
+// FeedFormats lists the supported syndication formats.
+var FeedFormats = []string{FeedFormatRESO, FeedFormatZillow, FeedFormatCSV}
+
+const (
+	syndicationFeedsCollection = "syndication_feeds"
+	feedListingsCollection     = "listings"
+
+	// feedScanPage is how many updated properties a generation reads per
+	// query, and maxFeedScan caps one generation; the rest is picked up by
+	// the next one.
+	feedScanPage = 500
+	maxFeedScan  = 5000
+)
+
+// FeedRefreshInterval is how stale a feed may get before a fetch regenerates
+// it.
+const FeedRefreshInterval = 5 * time.Minute
+
+// ErrUnknownFeedFormat is returned for a format outside FeedFormats.
+var ErrUnknownFeedFormat = errors.New("unknown feed format")
+
+// SyndicationFeed is an outbound feed of agent-owned properties stored in
+// syndication_feeds. Its listings are kept in a subcollection that each
+// generation updates from properties changed since GeneratedThrough.
+type SyndicationFeed struct {
+	ID   string `firestore:"-" json:"id"`
+	Name string `firestore:"name" json:"name"`
+	// AgentID limits the feed to one agent's properties; empty includes
+	// every agent-owned property.
+	AgentID string `firestore:"agentId,omitempty" json:"agentId,omitempty"`
+	// Statuses are normalized Status* values; empty means active only.
+	Statuses []string `firestore:"statuses,omitempty" json:"statuses,omitempty"`
+	OwnerUID string   `firestore:"ownerUid" json:"ownerUid"`
+	// TokenHash is the SHA-256 of the feed URL token; the token itself is
+	// only returned when the feed is created.
+	TokenHash string `firestore:"tokenHash" json:"-"`
+
+	// Version increases whenever a generation changes the feed's listings
+	// and keys its ETags.
+	Version          int64     `firestore:"version" json:"version"`
+	Listings         int       `firestore:"listings" json:"listings"`
+	GeneratedThrough time.Time `firestore:"generatedThrough,omitempty" json:"generatedThrough,omitempty"`
+	GeneratedAt      time.Time `firestore:"generatedAt,omitempty" json:"generatedAt,omitempty"`
+	CreatedAt        time.Time `firestore:"createdAt" json:"createdAt"`
+	UpdatedAt        time.Time `firestore:"updatedAt" json:"updatedAt"`
+}
+
+// FeedListing is one property as published in a syndication feed.
+type FeedListing struct {
+	PropertyID   string    `firestore:"propertyId" json:"propertyId"`
+	AgentID      string    `firestore:"agentId" json:"agentId"`
+	Status       string    `firestore:"status" json:"status"`
+	Price        float64   `firestore:"price,omitempty" json:"price,omitempty"`
+	Street       string    `firestore:"street,omitempty" json:"street,omitempty"`
+	City         string    `firestore:"city,omitempty" json:"city,omitempty"`
+	State        string    `firestore:"state,omitempty" json:"state,omitempty"`
+	PostalCode   string    `firestore:"postalCode,omitempty" json:"postalCode,omitempty"`
+	Lat          float64   `firestore:"lat,omitempty" json:"lat,omitempty"`
+	Lng          float64   `firestore:"lng,omitempty" json:"lng,omitempty"`
+	Bedrooms     int       `firestore:"bedrooms,omitempty" json:"bedrooms,omitempty"`
+	Bathrooms    float64   `firestore:"bathrooms,omitempty" json:"bathrooms,omitempty"`
+	SquareFeet   int       `firestore:"squareFeet,omitempty" json:"squareFeet,omitempty"`
+	LotSize      float64   `firestore:"lotSize,omitempty" json:"lotSize,omitempty"`
+	YearBuilt    int       `firestore:"yearBuilt,omitempty" json:"yearBuilt,omitempty"`
+	HOAFee       float64   `firestore:"hoaFees,omitempty" json:"hoaFees,omitempty"`
+	PropertyType string    `firestore:"type,omitempty" json:"type,omitempty"`
+	Description  string    `firestore:"description,omitempty" json:"description,omitempty"`
+	Images       []string  `firestore:"images,omitempty" json:"images,omitempty"`
+	ListedAt     time.Time `firestore:"listedAt,omitempty" json:"listedAt,omitempty"`
+	UpdatedAt    time.Time `firestore:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+// FeedGeneration summarizes one GenerateFeed call.
+type FeedGeneration struct {
+	Feed     *SyndicationFeed `json:"feed"`
+	Scanned  int              `json:"scanned"`
+	Upserted int              `json:"upserted"`
+	Removed  int              `json:"removed"`
+	// Truncated is set when more changed properties remain than one
+	// generation reads.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Validate normalizes the statuses and checks required fields.
+func (f *SyndicationFeed) Validate() error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(f.OwnerUID) == "" {
+		return fmt.Errorf("ownerUid is required")
+	}
+	for i, s := range f.Statuses {
+		n := NormalizeStatus(s)
+		if n == "" || n == StatusUnknown {
+			return fmt.Errorf("unknown status %q", s)
+		}
+		f.Statuses[i] = n
+	}
+	return nil
+}
+
+// CheckToken reports whether token is the feed's URL token.
+func (f *SyndicationFeed) CheckToken(token string) bool {
+	if token == "" || f.TokenHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashFeedToken(token)), []byte(f.TokenHash)) == 1
+}
+
+// ETag identifies the content of the feed rendered as format.
+func (f *SyndicationFeed) ETag(format string) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%s-%d-%s", f.ID, f.Version, format))
+}
+
+// Stale reports whether the feed is due for regeneration.
+func (f *SyndicationFeed) Stale(now time.Time) bool {
+	return f.GeneratedAt.IsZero() || now.Sub(f.GeneratedAt) > FeedRefreshInterval
+}
+
+// includes reports whether a listing belongs in the feed.
+func (f *SyndicationFeed) includes(l FeedListing) bool {
+	if l.AgentID == "" || (f.AgentID != "" && l.AgentID != f.AgentID) {
+		return false
+	}
+	statuses := f.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{StatusActive}
+	}
+	return oneOf(statuses, l.Status)
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSyndicationFeed stores a new feed, fills in its id and returns the
+// token that authenticates its feed URLs.
+func CreateSyndicationFeed(ctx context.Context, projectID string, f *SyndicationFeed) (string, error) {
+	if projectID == "" || f == nil {
+		return "", fmt.Errorf("projectID and feed are required")
+	}
+	if err := f.Validate(); err != nil {
+		return "", err
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	f.TokenHash = hashFeedToken(token)
+	f.Version, f.Listings = 0, 0
+	f.GeneratedThrough, f.GeneratedAt = time.Time{}, time.Time{}
+	f.CreatedAt, f.UpdatedAt = now, now
+	ref := client.Collection(syndicationFeedsCollection).NewDoc()
+	if _, err := ref.Set(ctx, f); err != nil {
+		return "", err
+	}
+	f.ID = ref.ID
+	return token, nil
+}
+
+// GetSyndicationFeed loads a feed, or nil when it does not exist.
+func GetSyndicationFeed(ctx context.Context, projectID, id string) (*SyndicationFeed, error) {
+	if projectID == "" || id == "" {
+		return nil, fmt.Errorf("projectID and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return getSyndicationFeed(ctx, client, id)
+}
+
+func getSyndicationFeed(ctx context.Context, client *gfs.Client, id string) (*SyndicationFeed, error) {
+	doc, err := client.Collection(syndicationFeedsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		if fs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var f SyndicationFeed
+	if err := doc.DataTo(&f); err != nil {
+		return nil, err
+	}
+	f.ID = doc.Ref.ID
+	return &f, nil
+}
+
+// ListSyndicationFeeds returns the feeds created by ownerUID, or every feed
+// when ownerUID is empty.
+func ListSyndicationFeeds(ctx context.Context, projectID, ownerUID string) ([]*SyndicationFeed, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("projectID is required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	q := client.Collection(syndicationFeedsCollection).Query
+	if ownerUID != "" {
+		q = q.Where("ownerUid", "==", ownerUID)
+	}
+	snap, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*SyndicationFeed, 0, len(snap))
+	for _, doc := range snap {
+		var f SyndicationFeed
+		if err := doc.DataTo(&f); err != nil {
+			log.Printf("[listings] skipping malformed syndication feed %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		f.ID = doc.Ref.ID
+		out = append(out, &f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// DeleteSyndicationFeed removes a feed and its generated listings.
+func DeleteSyndicationFeed(ctx context.Context, projectID, id string) error {
+	if projectID == "" || id == "" {
+		return fmt.Errorf("projectID and id are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	ref := client.Collection(syndicationFeedsCollection).Doc(id)
+	if err := clearFeedListings(ctx, client, ref); err != nil {
+		return err
+	}
+	_, err = ref.Delete(ctx)
+	return err
+}
+
+// clearFeedListings deletes every generated listing of a feed.
+func clearFeedListings(ctx context.Context, client *gfs.Client, feed *gfs.DocumentRef) error {
+	refs, err := feed.Collection(feedListingsCollection).DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(refs); start += maxBatchWrites {
+		end := min(start+maxBatchWrites, len(refs))
+		batch := client.Batch()
+		for _, ref := range refs[start:end] {
+			batch.Delete(ref)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateFeed brings a feed's listings up to date with the properties
+// updated since its last generation: matching properties are written and
+// ones that no longer match are removed. With full set the feed is rebuilt
+// from scratch, which also drops properties deleted outright. It returns nil
+// when the feed does not exist.
+func GenerateFeed(ctx context.Context, projectID, feedID string, full bool) (*FeedGeneration, error) {
+	if projectID == "" || feedID == "" {
+		return nil, fmt.Errorf("projectID and feedID are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := getSyndicationFeed(ctx, client, feedID)
+	if err != nil || feed == nil {
+		return nil, err
+	}
+	feedRef := client.Collection(syndicationFeedsCollection).Doc(feedID)
+	items := feedRef.Collection(feedListingsCollection)
+
+	existing := map[string]bool{}
+	if full {
+		if err := clearFeedListings(ctx, client, feedRef); err != nil {
+			return nil, err
+		}
+		feed.GeneratedThrough = time.Time{}
+	} else {
+		refs, err := items.DocumentRefs(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			existing[ref.ID] = true
+		}
+	}
+
+	q := client.Collection("properties").Query
+	if feed.AgentID != "" {
+		q = q.Where("agentId", "==", feed.AgentID)
+	}
+	if !feed.GeneratedThrough.IsZero() {
+		q = q.Where("updatedAt", ">", feed.GeneratedThrough)
+	}
+	q = q.OrderBy("updatedAt", gfs.Asc).Limit(feedScanPage)
+
+	gen := &FeedGeneration{Feed: feed}
+	through := feed.GeneratedThrough
+	var last *gfs.DocumentSnapshot
+	for gen.Scanned < maxFeedScan {
+		page := q
+		if last != nil {
+			page = q.StartAfter(last)
+		}
+		snap, err := page.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		batch := client.Batch()
+		writes := 0
+		for _, doc := range snap {
+			gen.Scanned++
+			last = doc
+			l := feedListingFromDoc(doc.Ref.ID, doc.Data())
+			if l.UpdatedAt.After(through) {
+				through = l.UpdatedAt
+			}
+			switch {
+			case feed.includes(l):
+				batch.Set(items.Doc(l.PropertyID), l)
+				existing[l.PropertyID] = true
+				gen.Upserted++
+				writes++
+			case existing[l.PropertyID]:
+				batch.Delete(items.Doc(l.PropertyID))
+				delete(existing, l.PropertyID)
+				gen.Removed++
+				writes++
+			}
+		}
+		if writes > 0 {
+			if _, err := batch.Commit(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if len(snap) < feedScanPage {
+			break
+		}
+	}
+	gen.Truncated = gen.Scanned >= maxFeedScan
+
+	now := time.Now()
+	update := map[string]any{
+		"listings":         len(existing),
+		"generatedThrough": through,
+		"generatedAt":      now,
+		"updatedAt":        now,
+	}
+	if gen.Upserted > 0 || gen.Removed > 0 || full {
+		feed.Version++
+		update["version"] = feed.Version
+	}
+	if _, err := feedRef.Set(ctx, update, gfs.MergeAll); err != nil {
+		return nil, err
+	}
+	feed.Listings = len(existing)
+	feed.GeneratedThrough, feed.GeneratedAt, feed.UpdatedAt = through, now, now
+	return gen, nil
+}
+
+// ListFeedListings returns a feed's generated listings ordered by property
+// id.
+func ListFeedListings(ctx context.Context, projectID, feedID string) ([]FeedListing, error) {
+	if projectID == "" || feedID == "" {
+		return nil, fmt.Errorf("projectID and feedID are required")
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	iter := client.Collection(syndicationFeedsCollection).Doc(feedID).
+		Collection(feedListingsCollection).
+		OrderBy(gfs.DocumentID, gfs.Asc).
+		Documents(ctx)
+	defer iter.Stop()
+	var out []FeedListing
+	for {
+		doc, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var l FeedListing
+		if err := doc.DataTo(&l); err != nil {
+			log.Printf("[listings] skipping malformed feed listing %s/%s: %v", feedID, doc.Ref.ID, err)
+			continue
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// feedListingFromDoc reads the published fields of a properties document.
+func feedListingFromDoc(id string, data map[string]any) FeedListing {
+	str := func(m map[string]any, k string) string { s, _ := m[k].(string); return strings.TrimSpace(s) }
+	num := func(k string) float64 { f, _ := asFloat(data[k]); return f }
+	addr, _ := data["address"].(map[string]any)
+	l := FeedListing{
+		PropertyID:   id,
+		AgentID:      str(data, "agentId"),
+		Status:       NormalizeStatus(str(data, "status")),
+		Price:        num("price"),
+		Street:       str(addr, "street"),
+		City:         str(addr, "city"),
+		State:        str(addr, "state"),
+		PostalCode:   str(addr, "postalCode"),
+		Bedrooms:     int(num("bedrooms")),
+		Bathrooms:    num("bathrooms"),
+		SquareFeet:   int(num("squareFeet")),
+		LotSize:      num("lotSize"),
+		YearBuilt:    int(num("yearBuilt")),
+		HOAFee:       num("hoaFees"),
+		PropertyType: str(data, "type"),
+		Description:  str(data, "description"),
+	}
+	if coords, ok := addr["coordinates"].(map[string]any); ok {
+		l.Lat, _ = asFloat(coords["latitude"])
+		l.Lng, _ = asFloat(coords["longitude"])
+	}
+	switch imgs := data["images"].(type) {
+	case []any:
+		for _, v := range imgs {
+			if s, ok := v.(string); ok && s != "" {
+				l.Images = append(l.Images, s)
+			}
+		}
+	case []string:
+		l.Images = append(l.Images, imgs...)
+	}
+	l.ListedAt, _ = data["listedAt"].(time.Time)
+	l.UpdatedAt, _ = data["updatedAt"].(time.Time)
+	return l
+}
+
+// FeedContentType returns the Content-Type of a feed format.
+func FeedContentType(format string) string {
+	switch format {
+	case FeedFormatRESO:
+		return "application/json; charset=utf-8"
+	case FeedFormatZillow:
+		return "application/xml; charset=utf-8"
+	case FeedFormatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return ""
+}
+
+// WriteFeed renders a feed's listings in format.
+func WriteFeed(w io.Writer, format string, feed *SyndicationFeed, items []FeedListing) error {
+	switch format {
+	case FeedFormatRESO:
+		return writeRESOFeed(w, feed, items)
+	case FeedFormatZillow:
+		return writeZillowFeed(w, feed, items)
+	case FeedFormatCSV:
+		return writeCSVFeed(w, items)
+	}
+	return ErrUnknownFeedFormat
+}
+
+// resoStandardStatus maps a normalized status to RESO StandardStatus.
+func resoStandardStatus(status string) string {
+	switch status {
+	case StatusActive:
+		return "Active"
+	case StatusPending:
+		return "Pending"
+	case StatusSold:
+		return "Closed"
+	case StatusOffMarket:
+		return "Withdrawn"
+	}
+	return ""
+}
+
+type resoMedia struct {
+	MediaURL string `json:"MediaURL"`
+	Order    int    `json:"Order"`
+}
+
+// resoProperty uses the RESO Data Dictionary names read by MapRESORecord.
+type resoProperty struct {
+	ListingKey            string      `json:"ListingKey"`
+	ListingID             string      `json:"ListingId"`
+	StandardStatus        string      `json:"StandardStatus,omitempty"`
+	ListPrice             float64     `json:"ListPrice,omitempty"`
+	UnparsedAddress       string      `json:"UnparsedAddress,omitempty"`
+	City                  string      `json:"City,omitempty"`
+	StateOrProvince       string      `json:"StateOrProvince,omitempty"`
+	PostalCode            string      `json:"PostalCode,omitempty"`
+	Latitude              float64     `json:"Latitude,omitempty"`
+	Longitude             float64     `json:"Longitude,omitempty"`
+	BedroomsTotal         int         `json:"BedroomsTotal,omitempty"`
+	BathroomsTotalDecimal float64     `json:"BathroomsTotalDecimal,omitempty"`
+	LivingArea            int         `json:"LivingArea,omitempty"`
+	LotSizeSquareFeet     float64     `json:"LotSizeSquareFeet,omitempty"`
+	YearBuilt             int         `json:"YearBuilt,omitempty"`
+	AssociationFee        float64     `json:"AssociationFee,omitempty"`
+	PropertyType          string      `json:"PropertyType,omitempty"`
+	PublicRemarks         string      `json:"PublicRemarks,omitempty"`
+	ListAgentKey          string      `json:"ListAgentKey,omitempty"`
+	OnMarketDate          string      `json:"OnMarketDate,omitempty"`
+	ModificationTimestamp string      `json:"ModificationTimestamp,omitempty"`
+	Media                 []resoMedia `json:"Media,omitempty"`
+}
+
+func writeRESOFeed(w io.Writer, feed *SyndicationFeed, items []FeedListing) error {
+	value := make([]resoProperty, 0, len(items))
+	for _, l := range items {
+		p := resoProperty{
+			ListingKey:            l.PropertyID,
+			ListingID:             l.PropertyID,
+			StandardStatus:        resoStandardStatus(l.Status),
+			ListPrice:             l.Price,
+			UnparsedAddress:       l.Street,
+			City:                  l.City,
+			StateOrProvince:       l.State,
+			PostalCode:            l.PostalCode,
+			Latitude:              l.Lat,
+			Longitude:             l.Lng,
+			BedroomsTotal:         l.Bedrooms,
+			BathroomsTotalDecimal: l.Bathrooms,
+			LivingArea:            l.SquareFeet,
+			LotSizeSquareFeet:     l.LotSize,
+			YearBuilt:             l.YearBuilt,
+			AssociationFee:        l.HOAFee,
+			PropertyType:          l.PropertyType,
+			PublicRemarks:         l.Description,
+			ListAgentKey:          l.AgentID,
+		}
+		if !l.ListedAt.IsZero() {
+			p.OnMarketDate = l.ListedAt.UTC().Format("2006-01-02")
+		}
+		if !l.UpdatedAt.IsZero() {
+			p.ModificationTimestamp = l.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		for i, url := range l.Images {
+			p.Media = append(p.Media, resoMedia{MediaURL: url, Order: i + 1})
+		}
+		value = append(value, p)
+	}
+	doc := map[string]any{
+		"@odata.context": "$metadata#Property",
+		"@odata.count":   len(value),
+		"value":          value,
+	}
+	if !feed.GeneratedAt.IsZero() {
+		doc["generatedAt"] = feed.GeneratedAt.UTC().Format(time.RFC3339)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// zillowStatus maps a normalized status to the Zillow feed's status values.
+func zillowStatus(status string) string {
+	switch status {
+	case StatusActive:
+		return "Active"
+	case StatusPending:
+		return "Pending"
+	case StatusSold:
+		return "Sold"
+	case StatusOffMarket:
+		return "Withdrawn"
+	}
+	return ""
+}
+
+type zillowListings struct {
+	XMLName     xml.Name        `xml:"Listings"`
+	GeneratedAt string          `xml:"generatedAt,attr,omitempty"`
+	Listings    []zillowListing `xml:"Listing"`
+}
+
+type zillowPicture struct {
+	PictureURL string `xml:"PictureUrl"`
+}
+
+type zillowListing struct {
+	Location struct {
+		StreetAddress string  `xml:"StreetAddress,omitempty"`
+		City          string  `xml:"City,omitempty"`
+		State         string  `xml:"State,omitempty"`
+		Zip           string  `xml:"Zip,omitempty"`
+		Lat           float64 `xml:"Lat,omitempty"`
+		Long          float64 `xml:"Long,omitempty"`
+	} `xml:"Location"`
+	ListingDetails struct {
+		Status            string  `xml:"Status"`
+		Price             float64 `xml:"Price,omitempty"`
+		ProviderListingID string  `xml:"ProviderListingId"`
+		DateListed        string  `xml:"DateListed,omitempty"`
+	} `xml:"ListingDetails"`
+	BasicDetails struct {
+		PropertyType string  `xml:"PropertyType,omitempty"`
+		Description  string  `xml:"Description,omitempty"`
+		Bedrooms     int     `xml:"Bedrooms,omitempty"`
+		Bathrooms    float64 `xml:"Bathrooms,omitempty"`
+		LivingArea   int     `xml:"LivingArea,omitempty"`
+		LotSize      float64 `xml:"LotSize,omitempty"`
+		YearBuilt    int     `xml:"YearBuilt,omitempty"`
+	} `xml:"BasicDetails"`
+	Pictures []zillowPicture `xml:"Pictures>Picture,omitempty"`
+	Agent    struct {
+		AgentID string `xml:"AgentId"`
+	} `xml:"Agent"`
+	HOAFee float64 `xml:"Fees>HOAFee,omitempty"`
+}
+
+func writeZillowFeed(w io.Writer, feed *SyndicationFeed, items []FeedListing) error {
+	doc := zillowListings{Listings: make([]zillowListing, 0, len(items))}
+	if !feed.GeneratedAt.IsZero() {
+		doc.GeneratedAt = feed.GeneratedAt.UTC().Format(time.RFC3339)
+	}
+	for _, l := range items {
+		var z zillowListing
+		z.Location.StreetAddress = l.Street
+		z.Location.City = l.City
+		z.Location.State = l.State
+		z.Location.Zip = l.PostalCode
+		z.Location.Lat = l.Lat
+		z.Location.Long = l.Lng
+		z.ListingDetails.Status = zillowStatus(l.Status)
+		z.ListingDetails.Price = l.Price
+		z.ListingDetails.ProviderListingID = l.PropertyID
+		if !l.ListedAt.IsZero() {
+			z.ListingDetails.DateListed = l.ListedAt.UTC().Format("2006-01-02")
+		}
+		z.BasicDetails.PropertyType = l.PropertyType
+		z.BasicDetails.Description = l.Description
+		z.BasicDetails.Bedrooms = l.Bedrooms
+		z.BasicDetails.Bathrooms = l.Bathrooms
+		z.BasicDetails.LivingArea = l.SquareFeet
+		z.BasicDetails.LotSize = l.LotSize
+		z.BasicDetails.YearBuilt = l.YearBuilt
+		for _, url := range l.Images {
+			z.Pictures = append(z.Pictures, zillowPicture{PictureURL: url})
+		}
+		z.Agent.AgentID = l.AgentID
+		z.HOAFee = l.HOAFee
+		doc.Listings = append(doc.Listings, z)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// csvFeedHeader is the column order of CSV feeds.
+var csvFeedHeader = []string{
+	"listing_key", "status", "price", "street", "city", "state", "postal_code",
+	"latitude", "longitude", "bedrooms", "bathrooms", "square_feet", "lot_size",
+	"year_built", "hoa_fee", "property_type", "description", "photos",
+	"agent_id", "listed_at", "updated_at",
+}
+
+func writeCSVFeed(w io.Writer, items []FeedListing) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvFeedHeader); err != nil {
+		return err
+	}
+	num := func(f float64) string {
+		if f == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	for _, l := range items {
+		row := []string{
+			l.PropertyID, l.Status, num(l.Price), l.Street, l.City, l.State, l.PostalCode,
+			num(l.Lat), num(l.Lng), num(float64(l.Bedrooms)), num(l.Bathrooms), num(float64(l.SquareFeet)), num(l.LotSize),
+			num(float64(l.YearBuilt)), num(l.HOAFee), l.PropertyType, l.Description, strings.Join(l.Images, "|"),
+			l.AgentID, date(l.ListedAt), date(l.UpdatedAt),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
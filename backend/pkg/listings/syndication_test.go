@@ -0,0 +1,144 @@
+package listings
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleFeedListing() FeedListing {
+	listed := time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC)
+	return feedListingFromDoc("prop-1", map[string]any{
+		"agentId":     "agent-1",
+		"status":      "Active",
+		"price":       425000.0,
+		"bedrooms":    int64(3),
+		"bathrooms":   2.5,
+		"squareFeet":  int64(1850),
+		"lotSize":     6000.0,
+		"yearBuilt":   int64(1998),
+		"hoaFees":     120.0,
+		"type":        "single_family",
+		"description": "Corner lot",
+		"images":      []any{"https://cdn/a.jpg", "https://cdn/b.jpg"},
+		"address": map[string]any{
+			"street": "12 Oak St", "city": "Austin", "state": "TX", "postalCode": "78701",
+			"coordinates": map[string]any{"latitude": 30.27, "longitude": -97.74},
+		},
+		"listedAt":  listed,
+		"updatedAt": listed.Add(48 * time.Hour),
+	})
+}
+
+// TestFeedIncludes verifies agent and status filtering.
+func TestFeedIncludes(t *testing.T) {
+	l := sampleFeedListing()
+	if l.Status != StatusActive || l.Bedrooms != 3 || l.Lat != 30.27 || len(l.Images) != 2 {
+		t.Fatalf("listing = %+v", l)
+	}
+	cases := []struct {
+		feed SyndicationFeed
+		l    FeedListing
+		want bool
+	}{
+		{SyndicationFeed{}, l, true},
+		{SyndicationFeed{AgentID: "agent-1"}, l, true},
+		{SyndicationFeed{AgentID: "agent-2"}, l, false},
+		{SyndicationFeed{}, FeedListing{Status: StatusActive}, false},
+		{SyndicationFeed{}, FeedListing{AgentID: "a", Status: StatusPending}, false},
+		{SyndicationFeed{Statuses: []string{StatusActive, StatusPending}}, FeedListing{AgentID: "a", Status: StatusPending}, true},
+	}
+	for i, c := range cases {
+		if got := c.feed.includes(c.l); got != c.want {
+			t.Errorf("case %d: includes = %v, want %v", i, got, c.want)
+		}
+	}
+
+	f := SyndicationFeed{Name: "Portal", OwnerUID: "u", Statuses: []string{"Under Contract"}}
+	if err := f.Validate(); err != nil || f.Statuses[0] != StatusPending {
+		t.Errorf("validate = %v, statuses = %v", err, f.Statuses)
+	}
+	if err := (&SyndicationFeed{Name: "x", OwnerUID: "u", Statuses: []string{"bogus"}}).Validate(); err == nil {
+		t.Error("unknown status accepted")
+	}
+}
+
+// TestFeedToken verifies tokens are checked against their hash and ETags
+// change with the feed version and format.
+func TestFeedToken(t *testing.T) {
+	f := SyndicationFeed{ID: "f1", TokenHash: hashFeedToken("secret"), Version: 3}
+	if !f.CheckToken("secret") || f.CheckToken("Secret") || f.CheckToken("") {
+		t.Error("token check mismatch")
+	}
+	if f.ETag(FeedFormatCSV) == f.ETag(FeedFormatRESO) {
+		t.Error("formats share an ETag")
+	}
+	before := f.ETag(FeedFormatCSV)
+	f.Version++
+	if f.ETag(FeedFormatCSV) == before {
+		t.Error("ETag did not change with the version")
+	}
+}
+
+// TestWriteRESOFeed verifies the RESO export maps back through
+// MapRESORecord.
+func TestWriteRESOFeed(t *testing.T) {
+	l := sampleFeedListing()
+	var buf bytes.Buffer
+	if err := WriteFeed(&buf, FeedFormatRESO, &SyndicationFeed{}, []FeedListing{l}); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Count int               `json:"@odata.count"`
+		Value []json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil || doc.Count != 1 {
+		t.Fatalf("doc = %s, err = %v", buf.String(), err)
+	}
+	got, err := MapRESORecord("partner", doc.Value[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ExternalID != "prop-1" || got.ListPrice != 425000 || got.Baths != 2.5 || got.Sqft != 1850 ||
+		got.Address.City != "Austin" || got.YearBuilt != 1998 || got.HOAFee != 120 || got.Remarks != "Corner lot" ||
+		!got.ListedAt.Equal(l.ListedAt) || NormalizeStatus(got.Status) != StatusActive {
+		t.Errorf("round trip = %+v", got)
+	}
+}
+
+// TestWriteZillowAndCSVFeeds verifies the XML and CSV exports.
+func TestWriteZillowAndCSVFeeds(t *testing.T) {
+	l := sampleFeedListing()
+	var buf bytes.Buffer
+	if err := WriteFeed(&buf, FeedFormatZillow, &SyndicationFeed{}, []FeedListing{l}); err != nil {
+		t.Fatal(err)
+	}
+	var doc zillowListings
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil || len(doc.Listings) != 1 {
+		t.Fatalf("xml = %s, err = %v", buf.String(), err)
+	}
+	z := doc.Listings[0]
+	if z.ListingDetails.Status != "Active" || z.Location.Zip != "78701" || len(z.Pictures) != 2 || z.HOAFee != 120 {
+		t.Errorf("listing = %+v", z)
+	}
+
+	buf.Reset()
+	if err := WriteFeed(&buf, FeedFormatCSV, &SyndicationFeed{}, []FeedListing{l}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil || len(rows) != 2 || len(rows[1]) != len(csvFeedHeader) {
+		t.Fatalf("rows = %v, err = %v", rows, err)
+	}
+	if rows[1][0] != "prop-1" || rows[1][2] != "425000" || rows[1][17] != "https://cdn/a.jpg|https://cdn/b.jpg" {
+		t.Errorf("row = %v", rows[1])
+	}
+
+	if err := WriteFeed(&buf, "rss", &SyndicationFeed{}, nil); err != ErrUnknownFeedFormat {
+		t.Errorf("unknown format err = %v", err)
+	}
+}
@@ -0,0 +1,454 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by provider requests while the provider's circuit
+// breaker is open after repeated upstream failures.
+var ErrCircuitOpen = errors.New("provider circuit breaker is open")
+
+// Circuit breaker states reported by ProviderHealth.State.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// ClientConfig tunes the shared provider HTTP client. Zero values fall back to
+// the defaults in DefaultClientConfig.
+type ClientConfig struct {
+	// Timeout bounds a single HTTP attempt, including reading the body.
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt for 429,
+	// 5xx and transport errors.
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// RatePerSecond and Burst configure a token bucket shared by every request
+	// to the provider. A zero rate disables throttling.
+	RatePerSecond float64
+	Burst         int
+	// BreakerThreshold consecutive failed calls open the breaker for
+	// BreakerCooldown, after which a single trial call is let through.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultClientConfig is used for any field left unset.
+var DefaultClientConfig = ClientConfig{
+	Timeout:          30 * time.Second,
+	MaxRetries:       3,
+	BaseBackoff:      500 * time.Millisecond,
+	MaxBackoff:       30 * time.Second,
+	Burst:            1,
+	BreakerThreshold: 5,
+	BreakerCooldown:  time.Minute,
+}
+
+// ClientConfigFromEnv reads per-provider overrides using the provider's env
+// prefix, e.g. ZILLOW_API_TIMEOUT=15s, ZILLOW_API_MAX_RETRIES=5,
+// ZILLOW_API_RATE_LIMIT=2 (requests/second), ZILLOW_API_BURST=4,
+// ZILLOW_API_BREAKER_THRESHOLD=10 and ZILLOW_API_BREAKER_COOLDOWN=2m.
+// Malformed values are ignored.
+func ClientConfigFromEnv(prefix string) ClientConfig {
+	var c ClientConfig
+	if d, err := time.ParseDuration(os.Getenv(prefix + "_TIMEOUT")); err == nil {
+		c.Timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv(prefix + "_MAX_RETRIES")); err == nil && n >= 0 {
+		// Distinguish an explicit 0 from unset.
+		c.MaxRetries = n
+		if n == 0 {
+			c.MaxRetries = -1
+		}
+	}
+	if f, err := strconv.ParseFloat(os.Getenv(prefix+"_RATE_LIMIT"), 64); err == nil && f > 0 {
+		c.RatePerSecond = f
+	}
+	if n, err := strconv.Atoi(os.Getenv(prefix + "_BURST")); err == nil && n > 0 {
+		c.Burst = n
+	}
+	if n, err := strconv.Atoi(os.Getenv(prefix + "_BREAKER_THRESHOLD")); err == nil && n > 0 {
+		c.BreakerThreshold = n
+	}
+	if d, err := time.ParseDuration(os.Getenv(prefix + "_BREAKER_COOLDOWN")); err == nil {
+		c.BreakerCooldown = d
+	}
+	return c
+}
+
+func (c ClientConfig) withDefaults() ClientConfig {
+	d := DefaultClientConfig
+	if c.Timeout > 0 {
+		d.Timeout = c.Timeout
+	}
+	switch {
+	case c.MaxRetries < 0:
+		d.MaxRetries = 0
+	case c.MaxRetries > 0:
+		d.MaxRetries = c.MaxRetries
+	}
+	if c.BaseBackoff > 0 {
+		d.BaseBackoff = c.BaseBackoff
+	}
+	if c.MaxBackoff > 0 {
+		d.MaxBackoff = c.MaxBackoff
+	}
+	if c.RatePerSecond > 0 {
+		d.RatePerSecond = c.RatePerSecond
+	}
+	if c.Burst > 0 {
+		d.Burst = c.Burst
+	}
+	if c.BreakerThreshold > 0 {
+		d.BreakerThreshold = c.BreakerThreshold
+	}
+	if c.BreakerCooldown > 0 {
+		d.BreakerCooldown = c.BreakerCooldown
+	}
+	return d
+}
+
+// ProviderHealth is a point-in-time view of a provider's client.
+type ProviderHealth struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	OpenedAt            time.Time `json:"openedAt,omitempty"`
+	RetryAt             time.Time `json:"retryAt,omitempty"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastSuccessAt       time.Time `json:"lastSuccessAt,omitempty"`
+}
+
+// HealthReporter is implemented by providers that track upstream health.
+type HealthReporter interface {
+	Health() ProviderHealth
+}
+
+// ProviderClient is the shared HTTP client used by provider connectors. It
+// applies a per-attempt timeout, retries 429/5xx and transport errors with
+// exponential backoff (honoring Retry-After), throttles with a token bucket
+// and trips a circuit breaker after repeated failures.
+type ProviderClient struct {
+	cfg     ClientConfig
+	http    *http.Client
+	limiter *tokenBucket
+	breaker *circuitBreaker
+
+	// sleep is swapped out in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewProviderClient constructs a client; zero config fields use defaults.
+func NewProviderClient(cfg ClientConfig) *ProviderClient {
+	cfg = cfg.withDefaults()
+	c := &ProviderClient{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: cfg.Timeout},
+		breaker: &circuitBreaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown, now: time.Now},
+		sleep:   sleepCtx,
+	}
+	if cfg.RatePerSecond > 0 {
+		c.limiter = newTokenBucket(cfg.RatePerSecond, cfg.Burst)
+	}
+	return c
+}
+
+// fork returns a client with c's configuration but its own rate limiter and
+// circuit breaker, so failures against one endpoint or set of credentials do
+// not stall other callers. A nil client forks to nil.
+func (c *ProviderClient) fork() *ProviderClient {
+	if c == nil {
+		return nil
+	}
+	f := NewProviderClient(c.cfg)
+	f.sleep = c.sleep
+	return f
+}
+
+// Health reports the circuit breaker state. A nil client is always healthy.
+func (c *ProviderClient) Health() ProviderHealth {
+	if c == nil {
+		return ProviderHealth{State: CircuitClosed}
+	}
+	return c.breaker.health()
+}
+
+// Do sends req, retrying as configured. On success the caller owns the
+// response body. Responses with retryable statuses are returned as-is once
+// retries are exhausted so callers keep their existing status handling.
+func (c *ProviderClient) Do(req *http.Request) (*http.Response, error) {
+	if c == nil {
+		return http.DefaultClient.Do(req)
+	}
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx, c.sleep); err != nil {
+				c.breaker.release()
+				return nil, err
+			}
+		}
+
+		r := req
+		if attempt > 0 {
+			if r, err = cloneRequest(req); err != nil {
+				c.breaker.release()
+				return nil, err
+			}
+		}
+		resp, err = c.http.Do(r)
+
+		if ctx.Err() != nil {
+			// Caller cancelled; not the provider's fault.
+			c.breaker.release()
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+		if !retryable(resp, err) {
+			break
+		}
+		if attempt >= c.cfg.MaxRetries {
+			break
+		}
+
+		delay := c.backoff(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if err := c.sleep(ctx, delay); err != nil {
+			c.breaker.release()
+			return nil, err
+		}
+	}
+
+	switch {
+	case err != nil:
+		c.breaker.failure(err.Error())
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		c.breaker.failure(fmt.Sprintf("status %d", resp.StatusCode))
+	default:
+		c.breaker.success()
+	}
+	return resp, err
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the next attempt: Retry-After when the
+// server sent one, otherwise exponential backoff with full jitter. Both are
+// capped at MaxBackoff.
+func (c *ProviderClient) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if d > c.cfg.MaxBackoff {
+				d = c.cfg.MaxBackoff
+			}
+			return d
+		}
+	}
+	exp := float64(c.cfg.BaseBackoff) * math.Pow(2, float64(attempt))
+	if exp > float64(c.cfg.MaxBackoff) {
+		exp = float64(c.cfg.MaxBackoff)
+	}
+	return time.Duration(rand.Float64() * exp)
+}
+
+// parseRetryAfter accepts both delta-seconds and HTTP-date forms.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("cannot retry request with non-rewindable body")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// tokenBucket is a minimal token-bucket rate limiter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) wait(ctx context.Context, sleep func(context.Context, time.Duration) error) error {
+	return sleep(ctx, b.reserve(time.Now()))
+}
+
+// circuitBreaker opens after threshold consecutive failures and lets a single
+// trial call through once cooldown has elapsed.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	failures    int
+	openedAt    time.Time
+	trial       bool
+	lastError   string
+	lastSuccess time.Time
+}
+
+func (b *circuitBreaker) state() string {
+	if b.failures < b.threshold {
+		return CircuitClosed
+	}
+	if b.now().Sub(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state() {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// release ends a call without judging the provider, e.g. on cancellation.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+	b.lastSuccess = b.now()
+}
+
+func (b *circuitBreaker) failure(msg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	b.lastError = msg
+	if b.failures >= b.threshold {
+		// Re-arm the cooldown on every failure while open, including a failed
+		// half-open trial.
+		b.openedAt = b.now()
+	}
+}
+
+func (b *circuitBreaker) health() ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := ProviderHealth{
+		State:               b.state(),
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+		LastSuccessAt:       b.lastSuccess,
+	}
+	if h.State != CircuitClosed {
+		h.OpenedAt = b.openedAt
+		h.RetryAt = b.openedAt.Add(b.cooldown)
+	}
+	return h
+}
@@ -0,0 +1,119 @@
+package listings
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestProviderClientRetriesHonorRetryAfter verifies 429/5xx responses are
+// retried and Retry-After is used as the delay.
+func TestProviderClientRetriesHonorRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewProviderClient(ClientConfig{MaxRetries: 3, MaxBackoff: time.Minute})
+	var delays []time.Duration
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("status=%d calls=%d", resp.StatusCode, calls)
+	}
+	if len(delays) != 2 || delays[0] != 7*time.Second {
+		t.Errorf("delays = %v, want Retry-After of 7s first", delays)
+	}
+	if h := c.Health(); h.State != CircuitClosed || h.ConsecutiveFailures != 0 {
+		t.Errorf("health = %+v", h)
+	}
+}
+
+// TestProviderClientCircuitBreaker verifies the breaker opens after repeated
+// failures and lets one trial call through after the cooldown.
+func TestProviderClientCircuitBreaker(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewProviderClient(ClientConfig{MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+
+	get := func() (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := c.Do(req)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := get(); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if _, err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if h := c.Health(); h.State != CircuitOpen || h.LastError != "status 503" {
+		t.Errorf("health = %+v", h)
+	}
+
+	now = now.Add(2 * time.Minute)
+	fail.Store(false)
+	if c.Health().State != CircuitHalfOpen {
+		t.Errorf("expected half-open after cooldown")
+	}
+	if resp, err := get(); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("trial call failed: %v", err)
+	}
+	if c.Health().State != CircuitClosed {
+		t.Errorf("expected closed after successful trial")
+	}
+}
+
+// TestTokenBucket verifies waits accrue once the burst is spent.
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2, 2)
+	now := b.last
+	if d := b.reserve(now); d != 0 {
+		t.Errorf("first reserve waited %v", d)
+	}
+	if d := b.reserve(now); d != 0 {
+		t.Errorf("second reserve waited %v", d)
+	}
+	if d := b.reserve(now); d != 500*time.Millisecond {
+		t.Errorf("third reserve waited %v, want 500ms", d)
+	}
+}
@@ -0,0 +1,322 @@
+package listings
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	gfs "cloud.google.com/go/firestore"
+	fs "github.com/SirsiMaster/assiduous/backend/pkg/firestore"
+	"github.com/SirsiMaster/assiduous/backend/pkg/microflip"
+)
+
+// Automatic underwriting assumptions. Rehab is estimated per square foot by
+// building age; ARV from the upper quartile of local price per square foot,
+// standing in for what renovated homes nearby list at.
+const (
+	underwriteHoldingDays = 90
+	// minUnderwriteComps is how many priced neighbours a zip needs before we
+	// trust its price per square foot.
+	minUnderwriteComps = 3
+	// maxUnderwriteComps bounds the comparables read per zip.
+	maxUnderwriteComps = 200
+	// arvPercentile picks the comparable price per square foot used for ARV.
+	arvPercentile = 0.75
+	// unknownAgeRehabPerSqft applies when yearBuilt is missing.
+	unknownAgeRehabPerSqft = 25.0
+)
+
+// rehabTiers are per-square-foot rehab estimates by building age in years,
+// checked in order.
+var rehabTiers = []struct {
+	maxAge  int
+	perSqft float64
+}{
+	{10, 8},
+	{25, 15},
+	{50, 25},
+	{75, 35},
+	{math.MaxInt, 45},
+}
+
+// dealQualityRanks orders microflip deal quality ratings best first so
+// inventory can be sorted on underwriting.qualityRank.
+var dealQualityRanks = map[string]int{
+	"A+ (Exceptional)": 1,
+	"A (Excellent)":    2,
+	"B (Good)":         3,
+	"C (Fair)":         4,
+	"D (Poor)":         5,
+}
+
+// AutoUnderwriteEnabled reports whether ingest underwrites new and changed
+// listings (AUTO_UNDERWRITE_ENABLED=true).
+func AutoUnderwriteEnabled() bool {
+	return os.Getenv("AUTO_UNDERWRITE_ENABLED") == "true"
+}
+
+// EstimateRehab returns a rough rehab budget for a home of sqft square feet
+// built in yearBuilt (0 when unknown).
+func EstimateRehab(sqft float64, yearBuilt int, now time.Time) float64 {
+	if sqft <= 0 {
+		return 0
+	}
+	perSqft := unknownAgeRehabPerSqft
+	if yearBuilt > 0 {
+		age := now.Year() - yearBuilt
+		for _, tier := range rehabTiers {
+			if age <= tier.maxAge {
+				perSqft = tier.perSqft
+				break
+			}
+		}
+	}
+	return math.Round(sqft * perSqft)
+}
+
+// percentile returns the p-th percentile (0..1) of sorted values using
+// linear interpolation.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := p * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// Underwriting is the automatic analysis stored on a property under
+// "underwriting".
+type Underwriting struct {
+	Viability         string    `firestore:"viability" json:"viability"`
+	DealQuality       string    `firestore:"dealQuality" json:"dealQuality"`
+	QualityRank       int       `firestore:"qualityRank" json:"qualityRank"`
+	MaxAllowableOffer float64   `firestore:"maxAllowableOffer" json:"maxAllowableOffer"`
+	RiskLevel         string    `firestore:"riskLevel" json:"riskLevel"`
+	ARV               float64   `firestore:"arv" json:"arv"`
+	ARVPricePerSqft   float64   `firestore:"arvPricePerSqft" json:"arvPricePerSqft"`
+	Comps             int       `firestore:"comps" json:"comps"`
+	RehabEstimate     float64   `firestore:"rehabEstimate" json:"rehabEstimate"`
+	NetProfit         float64   `firestore:"netProfit" json:"netProfit"`
+	ROI               float64   `firestore:"roi" json:"roi"`
+	AnalyzedAt        time.Time `firestore:"analyzedAt" json:"analyzedAt"`
+}
+
+// underwriteProperty analyzes a properties document given the sorted
+// comparable prices per square foot for its zip. It returns nil with a
+// reason when the listing cannot be underwritten.
+func underwriteProperty(engine *microflip.Engine, data map[string]any, comps []float64, now time.Time) (*Underwriting, string) {
+	price, _ := asFloat(data["price"])
+	sqft, _ := asFloat(data["squareFeet"])
+	if price <= 0 || sqft <= 0 {
+		return nil, "missing price or square footage"
+	}
+	if len(comps) < minUnderwriteComps {
+		return nil, "not enough comparables"
+	}
+	yearBuilt, _ := asFloat(data["yearBuilt"])
+	hoa, _ := asFloat(data["hoaFees"])
+
+	ppsf := percentile(comps, arvPercentile)
+	arv := math.Round(ppsf * sqft)
+	rehab := EstimateRehab(sqft, int(yearBuilt), now)
+	analysis := engine.AnalyzeDeal(microflip.DealInput{
+		PurchasePrice:    price,
+		AfterRepairValue: arv,
+		RehabCosts:       rehab,
+		HoldingPeriod:    underwriteHoldingDays,
+		FinancingType:    "cash",
+		HOAMonthly:       hoa,
+	})
+	return &Underwriting{
+		Viability:         analysis.Assessment.Viability,
+		DealQuality:       analysis.Assessment.DealQuality,
+		QualityRank:       dealQualityRanks[analysis.Assessment.DealQuality],
+		MaxAllowableOffer: math.Round(analysis.Assessment.MaxAllowableOffer),
+		RiskLevel:         analysis.Assessment.RiskLevel,
+		ARV:               arv,
+		ARVPricePerSqft:   math.Round(ppsf*100) / 100,
+		Comps:             len(comps),
+		RehabEstimate:     rehab,
+		NetProfit:         math.Round(analysis.Metrics.NetProfit),
+		ROI:               math.Round(analysis.Metrics.ROI*100) / 100,
+		AnalyzedAt:        now,
+	}, ""
+}
+
+// UnderwriteSummary reports an UnderwriteProperties pass.
+type UnderwriteSummary struct {
+	Attempted    int `json:"attempted"`
+	Underwritten int `json:"underwritten"`
+	Skipped      int `json:"skipped"`
+	Failed       int `json:"failed"`
+}
+
+// UnderwriteProperties runs the automatic analysis for the given property ids
+// and stores the result on each document. Comparables are the other priced
+// listings in the same zip, read once per zip.
+func UnderwriteProperties(ctx context.Context, projectID string, engine *microflip.Engine, ids []string) (*UnderwriteSummary, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("projectID is required")
+	}
+	if engine == nil {
+		engine = microflip.NewEngine()
+	}
+	summary := &UnderwriteSummary{}
+	if len(ids) == 0 {
+		return summary, nil
+	}
+	client, err := fs.Client(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]*gfs.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = client.Collection("properties").Doc(id)
+	}
+	docs, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	compsByZip := map[string][]float64{}
+	for _, doc := range docs {
+		summary.Attempted++
+		if !doc.Exists() {
+			summary.Skipped++
+			continue
+		}
+		data := doc.Data()
+		addr, _ := data["address"].(map[string]any)
+		zip, _ := addr["postalCode"].(string)
+		zip = strings.TrimSpace(zip)
+		if zip == "" {
+			summary.Skipped++
+			continue
+		}
+		comps, ok := compsByZip[zip]
+		if !ok {
+			if comps, err = zipPricePerSqft(ctx, client, zip); err != nil {
+				log.Printf("[listings] failed to load comparables for zip %s: %v", zip, err)
+				summary.Failed++
+				continue
+			}
+			compsByZip[zip] = comps
+		}
+
+		uw, reason := underwriteProperty(engine, data, withoutValue(comps, data["pricePerSqft"]), now)
+		if uw == nil {
+			log.Printf("[listings] not underwriting property %s: %s", doc.Ref.ID, reason)
+			summary.Skipped++
+			continue
+		}
+		if _, err := doc.Ref.Set(ctx, map[string]any{"underwriting": uw}, fs.MergeAll()); err != nil {
+			log.Printf("[listings] failed to store underwriting for property %s: %v", doc.Ref.ID, err)
+			summary.Failed++
+			continue
+		}
+		summary.Underwritten++
+	}
+	return summary, nil
+}
+
+// UnderwriteRequest selects properties for an on-demand underwriting pass:
+// explicit ids, or up to Limit listings from Provider.
+type UnderwriteRequest struct {
+	PropertyIDs []string    `json:"propertyIds,omitempty"`
+	Provider    ProviderKey `json:"provider,omitempty"`
+	Limit       int         `json:"limit,omitempty"`
+}
+
+// RunUnderwriting resolves req to property ids and underwrites them. It is
+// used to backfill listings ingested before AUTO_UNDERWRITE_ENABLED was set.
+func RunUnderwriting(ctx context.Context, projectID string, engine *microflip.Engine, req UnderwriteRequest) (*UnderwriteSummary, error) {
+	ids := req.PropertyIDs
+	if len(ids) == 0 {
+		if req.Provider == "" {
+			return nil, fmt.Errorf("propertyIds or provider is required")
+		}
+		limit := req.Limit
+		if limit <= 0 || limit > 500 {
+			limit = 100
+		}
+		client, err := fs.Client(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := client.Collection("properties").
+			Where("source", "==", string(req.Provider)).
+			Select().
+			Limit(limit).
+			Documents(ctx).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range refs {
+			ids = append(ids, doc.Ref.ID)
+		}
+	}
+	return UnderwriteProperties(ctx, projectID, engine, ids)
+}
+
+// zipPricePerSqft returns the sorted prices per square foot of listings in zip.
+func zipPricePerSqft(ctx context.Context, client *gfs.Client, zip string) ([]float64, error) {
+	snap, err := client.Collection("properties").
+		Where("address.postalCode", "==", zip).
+		Select("pricePerSqft").
+		Limit(maxUnderwriteComps).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var out []float64
+	for _, doc := range snap {
+		if v, ok := asFloat(doc.Data()["pricePerSqft"]); ok && v > 0 {
+			out = append(out, v)
+		}
+	}
+	sort.Float64s(out)
+	return out, nil
+}
+
+// withoutValue returns sorted without one occurrence of the subject's own
+// price per square foot so a listing is not its own comparable.
+func withoutValue(sorted []float64, subject any) []float64 {
+	v, ok := asFloat(subject)
+	if !ok {
+		return sorted
+	}
+	i := sort.SearchFloat64s(sorted, v)
+	if i >= len(sorted) || sorted[i] != v {
+		return sorted
+	}
+	out := make([]float64, 0, len(sorted)-1)
+	out = append(out, sorted[:i]...)
+	return append(out, sorted[i+1:]...)
+}
+
+// underwriteForRun underwrites the properties an ingest run created or
+// changed when AUTO_UNDERWRITE_ENABLED is set. Failures are logged; they never
+// fail the run.
+func underwriteForRun(ctx context.Context, projectID string, out *IngestOutcome) {
+	if !AutoUnderwriteEnabled() || out == nil || out.Summary == nil || len(out.Summary.changes) == 0 {
+		return
+	}
+	ids := make([]string, len(out.Summary.changes))
+	for i, ch := range out.Summary.changes {
+		ids[i] = ch.PropertyID
+	}
+	summary, err := UnderwriteProperties(ctx, projectID, nil, ids)
+	if err != nil {
+		log.Printf("[listings] automatic underwriting failed for provider %s: %v", out.Provider, err)
+		return
+	}
+	out.Underwritten = summary.Underwritten
+}
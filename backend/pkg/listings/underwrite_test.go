@@ -0,0 +1,60 @@
+package listings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SirsiMaster/assiduous/backend/pkg/microflip"
+)
+
+// TestEstimateRehab verifies the age tiers and the unknown-age fallback.
+func TestEstimateRehab(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		yearBuilt int
+		want      float64
+	}{
+		{2020, 8000},
+		{2005, 15000},
+		{1980, 25000},
+		{1960, 35000},
+		{1920, 45000},
+		{0, 25000},
+	}
+	for _, tt := range tests {
+		if got := EstimateRehab(1000, tt.yearBuilt, now); got != tt.want {
+			t.Errorf("EstimateRehab(1000, %d) = %v, want %v", tt.yearBuilt, got, tt.want)
+		}
+	}
+}
+
+// TestUnderwriteProperty runs a discounted listing through the engine and
+// checks the stored grade, rank and offer.
+func TestUnderwriteProperty(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	data := map[string]any{
+		"price":        100000.0,
+		"squareFeet":   int64(1000),
+		"yearBuilt":    int64(2010),
+		"pricePerSqft": 100.0,
+	}
+	comps := withoutValue([]float64{100, 180, 190, 200, 210}, data["pricePerSqft"])
+	if len(comps) != 4 {
+		t.Fatalf("subject not removed from comps: %v", comps)
+	}
+
+	uw, reason := underwriteProperty(microflip.NewEngine(), data, comps, now)
+	if uw == nil {
+		t.Fatalf("not underwritten: %s", reason)
+	}
+	if uw.ARVPricePerSqft != 202.5 || uw.ARV != 202500 || uw.RehabEstimate != 15000 {
+		t.Errorf("ARV/rehab = %+v", uw)
+	}
+	if uw.DealQuality != "A+ (Exceptional)" || uw.QualityRank != 1 || uw.MaxAllowableOffer != 126750 {
+		t.Errorf("assessment = %+v", uw)
+	}
+
+	if uw, _ := underwriteProperty(microflip.NewEngine(), data, comps[:2], now); uw != nil {
+		t.Error("expected no underwriting with too few comparables")
+	}
+}
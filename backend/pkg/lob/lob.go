@@ -7,6 +7,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/SirsiMaster/assiduous/backend/pkg/address"
 	lob "github.com/lob/lob-go"
 )
 
@@ -63,6 +64,15 @@ func (s *Service) CreateLetter(ctx context.Context, req CreateLetterRequest) (st
 	city, _ := req.ToAddress["address_city"].(string)
 	state, _ := req.ToAddress["address_state"].(string)
 	zip, _ := req.ToAddress["address_zip"].(string)
+	line2, _ := req.ToAddress["address_line2"].(string)
+
+	// Standardize to USPS conventions before sending so certified mail goes out
+	// with the same address form we use for dedup and search.
+	std := address.Standardize(line1, line2, city, state, zip)
+	if std.StreetLine() != "" {
+		line1, line2 = std.StreetLine(), std.UnitLine()
+		city, state, zip = std.City, std.State, std.ZIP()
+	}
 
 	lobTo := map[string]interface{}{
 		"name":            name,
@@ -72,6 +82,9 @@ func (s *Service) CreateLetter(ctx context.Context, req CreateLetterRequest) (st
 		"address_zip":     zip,
 		"address_country": "US",
 	}
+	if line2 != "" {
+		lobTo["address_line2"] = line2
+	}
 
 	// Inject BasicAuth into the context as recommended by the lob-go SDK.
 	authCtx := context.WithValue(ctx, lob.ContextBasicAuth, lob.BasicAuth{
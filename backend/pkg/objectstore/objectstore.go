@@ -0,0 +1,161 @@
+// Package objectstore provides a minimal blob store abstraction backed by
+// Google Cloud Storage in production and the local filesystem in development.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+// ErrNotFound is returned by Get when no object exists under the key.
+var ErrNotFound = errors.New("object not found")
+
+// Store reads and writes opaque objects addressed by slash-separated keys.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// URI returns a stable pointer for key suitable for storing on documents,
+	// e.g. gs://bucket/key or file:///path/key.
+	URI(key string) string
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultStore Store
+	defaultErr   error
+)
+
+// Default returns a process-wide Store configured from the environment:
+// OBJECT_STORE_BUCKET selects a GCS bucket, otherwise OBJECT_STORE_DIR selects
+// a local directory. When neither is set Default returns (nil, nil) and callers
+// should treat object storage as disabled.
+func Default(ctx context.Context) (Store, error) {
+	defaultOnce.Do(func() {
+		if bucket := os.Getenv("OBJECT_STORE_BUCKET"); bucket != "" {
+			gcs, err := NewGCS(ctx, bucket)
+			if err != nil {
+				defaultErr = err
+				return
+			}
+			defaultStore = gcs
+			return
+		}
+		if dir := os.Getenv("OBJECT_STORE_DIR"); dir != "" {
+			defaultStore = NewLocal(dir)
+		}
+	})
+	return defaultStore, defaultErr
+}
+
+// GCS stores objects in a Cloud Storage bucket.
+type GCS struct {
+	Bucket string
+	client *storage.Client
+}
+
+// NewGCS constructs a GCS store using Application Default Credentials.
+func NewGCS(ctx context.Context, bucket string) (*GCS, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GCS{Bucket: bucket, client: client}, nil
+}
+
+func (s *GCS) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	w := s.client.Bucket(s.Bucket).Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *GCS) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.client.Bucket(s.Bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (s *GCS) URI(key string) string { return "gs://" + s.Bucket + "/" + key }
+
+// Local stores objects as files under Root. It is intended for development
+// and tests.
+type Local struct {
+	Root string
+}
+
+// NewLocal constructs a filesystem-backed store rooted at dir.
+func NewLocal(dir string) *Local { return &Local{Root: dir} }
+
+func (s *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(clean)), nil
+}
+
+func (s *Local) Put(_ context.Context, key string, data []byte, _ string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	// Write to a temp file and rename so readers never observe a partial
+	// object.
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *Local) Get(_ context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *Local) URI(key string) string {
+	abs, err := filepath.Abs(s.Root)
+	if err != nil {
+		abs = s.Root
+	}
+	return "file://" + filepath.ToSlash(filepath.Join(abs, filepath.FromSlash(key)))
+}